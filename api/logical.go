@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 
 	"github.com/hashicorp/errwrap"
@@ -108,6 +109,29 @@ func (c *Logical) Write(path string, data map[string]interface{}) (*Secret, erro
 		return nil, err
 	}
 
+	return c.write(r)
+}
+
+// JSONMergePatch sends a JSON merge patch (RFC 7386) to the given path. It is
+// applied by the backend to the data stored at that path.
+func (c *Logical) JSONMergePatch(path string, data map[string]interface{}) (*Secret, error) {
+	r := c.c.NewRequest("PATCH", "/v1/"+path)
+	if err := r.SetJSONBody(data); err != nil {
+		return nil, err
+	}
+
+	// Copy the headers so that the client's headers are left untouched
+	headers := make(http.Header, len(r.Headers)+1)
+	for k, v := range r.Headers {
+		headers[k] = v
+	}
+	headers.Set("Content-Type", "application/merge-patch+json")
+	r.Headers = headers
+
+	return c.write(r)
+}
+
+func (c *Logical) write(r *Request) (*Secret, error) {
 	resp, err := c.c.RawRequest(r)
 	if resp != nil {
 		defer resp.Body.Close()
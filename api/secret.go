@@ -241,6 +241,27 @@ type SecretAuth struct {
 
 	LeaseDuration int  `json:"lease_duration"`
 	Renewable     bool `json:"renewable"`
+
+	MFARequirement *MFARequirement `json:"mfa_requirement"`
+}
+
+// MFARequirement is returned instead of a token by logins that must be
+// completed with login MFA, using Sys().MFAValidate.
+type MFARequirement struct {
+	MFARequestID   string                       `json:"mfa_request_id"`
+	MFAConstraints map[string]*MFAConstraintAny `json:"mfa_constraints"`
+}
+
+// MFAConstraintAny is satisfied by validating any of its methods
+type MFAConstraintAny struct {
+	Any []*MFAMethodID `json:"any"`
+}
+
+// MFAMethodID identifies a login MFA method
+type MFAMethodID struct {
+	Type         string `json:"type"`
+	ID           string `json:"id"`
+	UsesPasscode bool   `json:"uses_passcode"`
 }
 
 // ParseSecret is used to parse a secret value from JSON from an io.Reader.
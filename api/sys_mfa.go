@@ -0,0 +1,26 @@
+package api
+
+// MFAValidate completes a login requiring login MFA. The payload maps the IDs
+// of the validated MFA methods to their passcodes; methods that don't use
+// passcodes are given an empty list.
+func (c *Sys) MFAValidate(requestID string, payload map[string][]string) (*Secret, error) {
+	body := map[string]interface{}{
+		"mfa_request_id": requestID,
+		"mfa_payload":    payload,
+	}
+
+	r := c.c.NewRequest("PUT", "/v1/sys/mfa/validate")
+	if err := r.SetJSONBody(body); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSecret(resp.Body)
+}
@@ -83,7 +83,7 @@ func (b *backend) pathLoginUpdate(ctx context.Context, req *logical.Request, dat
 		return logical.ErrorResponse("invalid role ID"), nil
 	}
 
-	var metadata map[string]string
+	var metadata, attestedMetadata map[string]string
 	if role.BindSecretID {
 		secretID := strings.TrimSpace(data.Get("secret_id").(string))
 		if secretID == "" {
@@ -248,6 +248,7 @@ func (b *backend) pathLoginUpdate(ctx context.Context, req *logical.Request, dat
 		}
 
 		metadata = entry.Metadata
+		attestedMetadata = entry.AttestedMetadata
 	}
 
 	if len(role.BoundCIDRList) != 0 {
@@ -265,22 +266,19 @@ func (b *backend) pathLoginUpdate(ctx context.Context, req *logical.Request, dat
 	metadata["role_name"] = role.name
 
 	auth := &logical.Auth{
-		NumUses: role.TokenNumUses,
-		Period:  role.Period,
 		InternalData: map[string]interface{}{
 			"role_name": role.name,
 		},
 		Metadata: metadata,
-		Policies: role.Policies,
 		LeaseOptions: logical.LeaseOptions{
 			Renewable: true,
-			TTL:       role.TokenTTL,
-			MaxTTL:    role.TokenMaxTTL,
 		},
 		Alias: &logical.Alias{
-			Name: role.RoleID,
+			Name:     role.RoleID,
+			Metadata: attestedMetadata,
 		},
 	}
+	role.PopulateTokenAuth(auth)
 
 	return &logical.Response{
 		Auth: auth,
@@ -310,7 +308,8 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, data
 	resp := &logical.Response{Auth: req.Auth}
 	resp.Auth.TTL = role.TokenTTL
 	resp.Auth.MaxTTL = role.TokenMaxTTL
-	resp.Auth.Period = role.Period
+	resp.Auth.Period = role.TokenPeriod
+	resp.Auth.ExplicitMaxTTL = role.TokenExplicitMaxTTL
 	return resp, nil
 }
 
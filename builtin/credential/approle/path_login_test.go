@@ -2,6 +2,7 @@ package approle
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -151,3 +152,101 @@ func generateRenewRequest(s logical.Storage, auth *logical.Auth) *logical.Reques
 
 	return renewReq
 }
+
+func TestAppRole_RoleLogin_AttestedMetadata(t *testing.T) {
+	var resp *logical.Response
+	var err error
+	b, storage := createBackendWithStorage(t)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"policies":                    "a,b",
+			"secret_id_attested_metadata": "pipeline,job",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "role/role1/role-id",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	roleID := resp.Data["role_id"]
+
+	// The token generating the secret ID must carry all the attested keys
+	secretIDReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/role1/secret-id",
+		Storage:   storage,
+		ClientTokenMetadata: map[string]string{
+			"pipeline": "deploy",
+		},
+	}
+	resp, err = b.HandleRequest(context.Background(), secretIDReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error")
+	}
+
+	secretIDReq.ClientTokenMetadata = map[string]string{
+		"pipeline": "deploy",
+		"job":      "42",
+		"other":    "ignored",
+	}
+	resp, err = b.HandleRequest(context.Background(), secretIDReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	secretID := resp.Data["secret_id"]
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/role1/secret-id/lookup",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"secret_id": secretID,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	expected := map[string]string{
+		"pipeline": "deploy",
+		"job":      "42",
+	}
+	if !reflect.DeepEqual(resp.Data["attested_metadata"], expected) {
+		t.Fatalf("bad: attested metadata: %#v", resp.Data["attested_metadata"])
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"role_id":   roleID,
+			"secret_id": secretID,
+		},
+		Connection: &logical.Connection{
+			RemoteAddr: "127.0.0.1",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Auth.Alias.Metadata, expected) {
+		t.Fatalf("bad: alias metadata: %#v", resp.Auth.Alias.Metadata)
+	}
+	if _, ok := resp.Auth.Metadata["pipeline"]; ok {
+		t.Fatalf("bad: attested metadata set on the token: %#v", resp.Auth.Metadata)
+	}
+}
@@ -19,6 +19,8 @@ import (
 
 // roleStorageEntry stores all the options that are set on an role
 type roleStorageEntry struct {
+	framework.TokenParams `mapstructure:",squash"`
+
 	// Name of the role. This field is not persisted on disk. After the role is
 	// read out of disk, the sanitized version of name is set in this field for
 	// subsequent use of role name elsewhere.
@@ -32,8 +34,9 @@ type roleStorageEntry struct {
 	// of the role
 	HMACKey string `json:"hmac_key" mapstructure:"hmac_key"`
 
-	// Policies that are to be required by the token to access this role
-	Policies []string `json:"policies" mapstructure:"policies"`
+	// Policies that are to be required by the token to access this role.
+	// Deprecated in favor of TokenPolicies.
+	Policies []string `json:"policies,omitempty" mapstructure:"policies"`
 
 	// Number of times the SecretID generated against this role can be
 	// used to perform login operation
@@ -43,15 +46,6 @@ type roleStorageEntry struct {
 	// SecretID generated against the role will expire
 	SecretIDTTL time.Duration `json:"secret_id_ttl" mapstructure:"secret_id_ttl"`
 
-	// TokenNumUses defines the number of allowed uses of the token issued
-	TokenNumUses int `json:"token_num_uses" mapstructure:"token_num_uses"`
-
-	// Duration before which an issued token must be renewed
-	TokenTTL time.Duration `json:"token_ttl" mapstructure:"token_ttl"`
-
-	// Duration after which an issued token should not be allowed to be renewed
-	TokenMaxTTL time.Duration `json:"token_max_ttl" mapstructure:"token_max_ttl"`
-
 	// A constraint, if set, requires 'secret_id' credential to be presented during login
 	BindSecretID bool `json:"bind_secret_id" mapstructure:"bind_secret_id"`
 
@@ -66,7 +60,8 @@ type roleStorageEntry struct {
 	// specified by this value. The renewal duration will be fixed if the
 	// value is not modified on the role. If the `Period` in the role is modified,
 	// a token will pick up the new value during its next renewal.
-	Period time.Duration `json:"period" mapstructure:"period"`
+	// Deprecated in favor of TokenPeriod.
+	Period time.Duration `json:"period,omitempty" mapstructure:"period"`
 
 	// LowerCaseRoleName enforces the lower casing of role names for all the
 	// roles that get created since this field was introduced.
@@ -75,6 +70,27 @@ type roleStorageEntry struct {
 	// SecretIDPrefix is the storage prefix for persisting secret IDs. This
 	// differs based on whether the secret IDs are cluster local or not.
 	SecretIDPrefix string `json:"secret_id_prefix" mapstructure:"secret_id_prefix"`
+
+	// SecretIDWrappingRequired enforces the SecretIDs generated against this
+	// role to be response wrapped
+	SecretIDWrappingRequired bool `json:"secret_id_wrapping_required" mapstructure:"secret_id_wrapping_required"`
+
+	// Bounds of the TTL of the wrapping token of the SecretIDs, enforced if
+	// SecretIDWrappingRequired is set
+	SecretIDMinWrapTTL time.Duration `json:"secret_id_min_wrap_ttl" mapstructure:"secret_id_min_wrap_ttl"`
+	SecretIDMaxWrapTTL time.Duration `json:"secret_id_max_wrap_ttl" mapstructure:"secret_id_max_wrap_ttl"`
+
+	// SecretIDAttestedMetadata is the list of keys of the metadata of the
+	// token generating a SecretID that are embedded into the SecretID. The
+	// token must carry all these keys, which attests the context in which the
+	// SecretID was issued.
+	SecretIDAttestedMetadata []string `json:"secret_id_attested_metadata" mapstructure:"secret_id_attested_metadata"`
+}
+
+// roleTokenFieldAliases are the deprecated role fields aliasing token fields
+var roleTokenFieldAliases = map[string]string{
+	"policies": "token_policies",
+	"period":   "token_period",
 }
 
 // roleIDStorageEntry represents the reverse mapping from RoleID to Role
@@ -104,7 +120,7 @@ type roleIDStorageEntry struct {
 // role/<role_name>/secret-id-accessor/lookup - For reading secret_id using accessor
 // role/<role_name>/secret-id-accessor/destroy - For deleting secret_id using accessor
 func rolePaths(b *backend) []*framework.Path {
-	return []*framework.Path{
+	p := []*framework.Path{
 		&framework.Path{
 			Pattern: "role/?",
 			Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -133,7 +149,7 @@ IP addresses which can perform the login operation.`,
 				"policies": &framework.FieldSchema{
 					Type:        framework.TypeCommaStringSlice,
 					Default:     "default",
-					Description: "Comma separated list of policies on the role.",
+					Description: `Deprecated: use "token_policies" instead.`,
 				},
 				"secret_id_num_uses": &framework.FieldSchema{
 					Type: framework.TypeInt,
@@ -144,28 +160,11 @@ will expire. Defaults to 0 meaning that the the secret_id is of unlimited use.`,
 					Type: framework.TypeDurationSecond,
 					Description: `Duration in seconds after which the issued SecretID should expire. Defaults
 to 0, meaning no expiration.`,
-				},
-				"token_num_uses": &framework.FieldSchema{
-					Type:        framework.TypeInt,
-					Description: `Number of times issued tokens can be used`,
-				},
-				"token_ttl": &framework.FieldSchema{
-					Type: framework.TypeDurationSecond,
-					Description: `Duration in seconds after which the issued token should expire. Defaults
-to 0, in which case the value will fall back to the system/mount defaults.`,
-				},
-				"token_max_ttl": &framework.FieldSchema{
-					Type: framework.TypeDurationSecond,
-					Description: `Duration in seconds after which the issued token should not be allowed to
-be renewed. Defaults to 0, in which case the value will fall back to the system/mount defaults.`,
 				},
 				"period": &framework.FieldSchema{
-					Type:    framework.TypeDurationSecond,
-					Default: 0,
-					Description: `If set, indicates that the token generated using this role
-should never expire. The token should be renewed within the
-duration specified by this value. At each renewal, the token's
-TTL will be set to the value of this parameter.`,
+					Type:        framework.TypeDurationSecond,
+					Default:     0,
+					Description: `Deprecated: use "token_period" instead.`,
 				},
 				"role_id": &framework.FieldSchema{
 					Type:        framework.TypeString,
@@ -176,6 +175,27 @@ TTL will be set to the value of this parameter.`,
 					Description: `If set, the secret IDs generated using this role will be cluster local. This
 can only be set during role creation and once set, it can't be reset later.`,
 				},
+				"secret_id_wrapping_required": &framework.FieldSchema{
+					Type: framework.TypeBool,
+					Description: `If set, the SecretIDs generated against this role must be requested
+response wrapped.`,
+				},
+				"secret_id_min_wrap_ttl": &framework.FieldSchema{
+					Type: framework.TypeDurationSecond,
+					Description: `Minimum TTL in seconds of the wrapping token of the SecretIDs, if
+'secret_id_wrapping_required' is set. Defaults to 0, meaning no minimum.`,
+				},
+				"secret_id_max_wrap_ttl": &framework.FieldSchema{
+					Type: framework.TypeDurationSecond,
+					Description: `Maximum TTL in seconds of the wrapping token of the SecretIDs, if
+'secret_id_wrapping_required' is set. Defaults to 0, meaning no maximum.`,
+				},
+				"secret_id_attested_metadata": &framework.FieldSchema{
+					Type: framework.TypeCommaStringSlice,
+					Description: `Comma separated string or list of keys of the metadata of the token
+generating a SecretID. The token must carry all these keys, whose values are
+embedded into the SecretID and set as metadata of the entity alias at login.`,
+				},
 			},
 			ExistenceCheck: b.pathRoleExistenceCheck,
 			Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -542,6 +562,9 @@ the role.`,
 			HelpDescription: strings.TrimSpace(roleHelp["role-custom-secret-id"][1]),
 		},
 	}
+
+	framework.AddTokenFields(p[1].Fields)
+	return p
 }
 
 // pathRoleExistenceCheck returns whether the role with the given name exists or not.
@@ -759,6 +782,17 @@ func (b *backend) roleEntry(ctx context.Context, s logical.Storage, roleName str
 		needsUpgrade = true
 	}
 
+	// Upgrade the deprecated token parameters
+	if len(role.TokenPolicies) == 0 && len(role.Policies) > 0 {
+		role.TokenPolicies = role.Policies
+		needsUpgrade = true
+	}
+	if role.TokenPeriod == 0 && role.Period > 0 {
+		role.TokenPeriod = role.Period
+		needsUpgrade = true
+	}
+	role.Policies, role.Period = nil, 0
+
 	if needsUpgrade && (b.System().LocalMount() || !b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary)) {
 		entry, err := logical.StorageEntryJSON("role/"+strings.ToLower(roleName), &role)
 		if err != nil {
@@ -863,20 +897,22 @@ func (b *backend) pathRoleCreateUpdate(ctx context.Context, req *logical.Request
 		}
 	}
 
-	if policiesRaw, ok := data.GetOk("policies"); ok {
-		role.Policies = policyutil.ParsePolicies(policiesRaw)
-	} else if req.Operation == logical.CreateOperation {
-		role.Policies = policyutil.ParsePolicies(data.Get("policies"))
+	if err := role.ParseTokenFieldAliases(data, roleTokenFieldAliases); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if err := role.ParseTokenFields(req, data); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	periodRaw, ok := data.GetOk("period")
-	if ok {
-		role.Period = time.Second * time.Duration(periodRaw.(int))
-	} else if req.Operation == logical.CreateOperation {
-		role.Period = time.Second * time.Duration(data.Get("period").(int))
+	// Roles are created with the default policy unless policies are given
+	_, policiesOk := data.GetOk("policies")
+	_, tokenPoliciesOk := data.GetOk("token_policies")
+	if !policiesOk && !tokenPoliciesOk && req.Operation == logical.CreateOperation {
+		role.TokenPolicies = policyutil.ParsePolicies(data.Get("policies"))
 	}
-	if role.Period > b.System().MaxLeaseTTL() {
-		return logical.ErrorResponse(fmt.Sprintf("period of %q is greater than the backend's maximum lease TTL of %q", role.Period.String(), b.System().MaxLeaseTTL().String())), nil
+
+	if role.TokenPeriod > b.System().MaxLeaseTTL() {
+		return logical.ErrorResponse(fmt.Sprintf("period of %q is greater than the backend's maximum lease TTL of %q", role.TokenPeriod.String(), b.System().MaxLeaseTTL().String())), nil
 	}
 
 	if secretIDNumUsesRaw, ok := data.GetOk("secret_id_num_uses"); ok {
@@ -894,32 +930,24 @@ func (b *backend) pathRoleCreateUpdate(ctx context.Context, req *logical.Request
 		role.SecretIDTTL = time.Second * time.Duration(data.Get("secret_id_ttl").(int))
 	}
 
-	if tokenNumUsesRaw, ok := data.GetOk("token_num_uses"); ok {
-		role.TokenNumUses = tokenNumUsesRaw.(int)
-	} else if req.Operation == logical.CreateOperation {
-		role.TokenNumUses = data.Get("token_num_uses").(int)
+	if secretIDWrappingRequiredRaw, ok := data.GetOk("secret_id_wrapping_required"); ok {
+		role.SecretIDWrappingRequired = secretIDWrappingRequiredRaw.(bool)
 	}
-	if role.TokenNumUses < 0 {
-		return logical.ErrorResponse("token_num_uses cannot be negative"), nil
+
+	if secretIDMinWrapTTLRaw, ok := data.GetOk("secret_id_min_wrap_ttl"); ok {
+		role.SecretIDMinWrapTTL = time.Second * time.Duration(secretIDMinWrapTTLRaw.(int))
 	}
 
-	if tokenTTLRaw, ok := data.GetOk("token_ttl"); ok {
-		role.TokenTTL = time.Second * time.Duration(tokenTTLRaw.(int))
-	} else if req.Operation == logical.CreateOperation {
-		role.TokenTTL = time.Second * time.Duration(data.Get("token_ttl").(int))
+	if secretIDMaxWrapTTLRaw, ok := data.GetOk("secret_id_max_wrap_ttl"); ok {
+		role.SecretIDMaxWrapTTL = time.Second * time.Duration(secretIDMaxWrapTTLRaw.(int))
 	}
 
-	if tokenMaxTTLRaw, ok := data.GetOk("token_max_ttl"); ok {
-		role.TokenMaxTTL = time.Second * time.Duration(tokenMaxTTLRaw.(int))
-	} else if req.Operation == logical.CreateOperation {
-		role.TokenMaxTTL = time.Second * time.Duration(data.Get("token_max_ttl").(int))
+	if role.SecretIDMaxWrapTTL > time.Duration(0) && role.SecretIDMinWrapTTL > role.SecretIDMaxWrapTTL {
+		return logical.ErrorResponse("secret_id_min_wrap_ttl should not be greater than secret_id_max_wrap_ttl"), nil
 	}
 
-	// Check that the TokenTTL value provided is less than the TokenMaxTTL.
-	// Sanitizing the TTL and MaxTTL is not required now and can be performed
-	// at credential issue time.
-	if role.TokenMaxTTL > time.Duration(0) && role.TokenTTL > role.TokenMaxTTL {
-		return logical.ErrorResponse("token_ttl should not be greater than token_max_ttl"), nil
+	if secretIDAttestedMetadataRaw, ok := data.GetOk("secret_id_attested_metadata"); ok {
+		role.SecretIDAttestedMetadata = secretIDAttestedMetadataRaw.([]string)
 	}
 
 	var resp *logical.Response
@@ -957,16 +985,20 @@ func (b *backend) pathRoleRead(ctx context.Context, req *logical.Request, data *
 	respData := map[string]interface{}{
 		"bind_secret_id":     role.BindSecretID,
 		"bound_cidr_list":    role.BoundCIDRList,
-		"period":             role.Period / time.Second,
-		"policies":           role.Policies,
+		"period":             role.TokenPeriod / time.Second,
+		"policies":           role.TokenPolicies,
 		"secret_id_num_uses": role.SecretIDNumUses,
 		"secret_id_ttl":      role.SecretIDTTL / time.Second,
-		"token_max_ttl":      role.TokenMaxTTL / time.Second,
-		"token_num_uses":     role.TokenNumUses,
-		"token_ttl":          role.TokenTTL / time.Second,
 		"local_secret_ids":   false,
+
+		"secret_id_wrapping_required": role.SecretIDWrappingRequired,
+		"secret_id_min_wrap_ttl":      role.SecretIDMinWrapTTL / time.Second,
+		"secret_id_max_wrap_ttl":      role.SecretIDMaxWrapTTL / time.Second,
+		"secret_id_attested_metadata": role.SecretIDAttestedMetadata,
 	}
 
+	role.PopulateTokenData(respData)
+
 	if role.SecretIDPrefix == secretIDLocalPrefix {
 		respData["local_secret_ids"] = true
 	}
@@ -1135,6 +1167,7 @@ func (entry *secretIDStorageEntry) ToResponseData() map[string]interface{} {
 		"last_updated_time":  entry.LastUpdatedTime,
 		"metadata":           entry.Metadata,
 		"cidr_list":          entry.CIDRList,
+		"attested_metadata":  entry.AttestedMetadata,
 	}
 }
 
@@ -1524,7 +1557,7 @@ func (b *backend) pathRolePoliciesUpdate(ctx context.Context, req *logical.Reque
 		return logical.ErrorResponse("missing policies"), nil
 	}
 
-	role.Policies = policyutil.ParsePolicies(policiesRaw)
+	role.TokenPolicies = policyutil.ParsePolicies(policiesRaw)
 
 	return nil, b.setRoleEntry(ctx, req.Storage, role.name, role, "")
 }
@@ -1549,7 +1582,7 @@ func (b *backend) pathRolePoliciesRead(ctx context.Context, req *logical.Request
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"policies": role.Policies,
+			"policies": role.TokenPolicies,
 		},
 	}, nil
 }
@@ -1572,7 +1605,7 @@ func (b *backend) pathRolePoliciesDelete(ctx context.Context, req *logical.Reque
 		return nil, nil
 	}
 
-	role.Policies = []string{}
+	role.TokenPolicies = []string{}
 
 	return nil, b.setRoleEntry(ctx, req.Storage, role.name, role, "")
 }
@@ -1799,9 +1832,9 @@ func (b *backend) pathRolePeriodUpdate(ctx context.Context, req *logical.Request
 	}
 
 	if periodRaw, ok := data.GetOk("period"); ok {
-		role.Period = time.Second * time.Duration(periodRaw.(int))
-		if role.Period > b.System().MaxLeaseTTL() {
-			return logical.ErrorResponse(fmt.Sprintf("period of %q is greater than the backend's maximum lease TTL of %q", role.Period.String(), b.System().MaxLeaseTTL().String())), nil
+		role.TokenPeriod = time.Second * time.Duration(periodRaw.(int))
+		if role.TokenPeriod > b.System().MaxLeaseTTL() {
+			return logical.ErrorResponse(fmt.Sprintf("period of %q is greater than the backend's maximum lease TTL of %q", role.TokenPeriod.String(), b.System().MaxLeaseTTL().String())), nil
 		}
 		return nil, b.setRoleEntry(ctx, req.Storage, role.name, role, "")
 	} else {
@@ -1829,7 +1862,7 @@ func (b *backend) pathRolePeriodRead(ctx context.Context, req *logical.Request,
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"period": role.Period / time.Second,
+			"period": role.TokenPeriod / time.Second,
 		},
 	}, nil
 }
@@ -1852,7 +1885,7 @@ func (b *backend) pathRolePeriodDelete(ctx context.Context, req *logical.Request
 		return nil, nil
 	}
 
-	role.Period = time.Second * time.Duration(data.GetDefaultOrZero("period").(int))
+	role.TokenPeriod = time.Second * time.Duration(data.GetDefaultOrZero("period").(int))
 
 	return nil, b.setRoleEntry(ctx, req.Storage, role.name, role, "")
 }
@@ -2141,11 +2174,24 @@ func (b *backend) handleRoleSecretIDCommon(ctx context.Context, req *logical.Req
 		return nil, err
 	}
 
+	if role.SecretIDWrappingRequired {
+		if err := verifySecretIDWrapTTL(req.WrapInfo, role.SecretIDMinWrapTTL, role.SecretIDMaxWrapTTL); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	attestedMetadata, err := attestSecretIDMetadata(req.ClientTokenMetadata, role.SecretIDAttestedMetadata)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	secretIDStorage := &secretIDStorageEntry{
 		SecretIDNumUses: role.SecretIDNumUses,
 		SecretIDTTL:     role.SecretIDTTL,
 		Metadata:        make(map[string]string),
 		CIDRList:        secretIDCIDRs,
+
+		AttestedMetadata: attestedMetadata,
 	}
 
 	if err = strutil.ParseArbitraryKeyValues(data.Get("metadata").(string), secretIDStorage.Metadata, ","); err != nil {
@@ -2258,7 +2304,14 @@ to be generated against only this specific role, it can be done via
 'role/<role_name>/secret-id' and 'role/<role_name>/custom-secret-id' endpoints.
 The properties of the SecretID created against the role and the properties
 of the token issued with the SecretID generated against the role, can be
-configured using the parameters of this endpoint.`,
+configured using the parameters of this endpoint.
+
+If 'secret_id_wrapping_required' is set, the SecretIDs of the role must be
+requested response wrapped, with a wrapping TTL within the bounds set by
+'secret_id_min_wrap_ttl' and 'secret_id_max_wrap_ttl'. If
+'secret_id_attested_metadata' is set, the token requesting a SecretID must
+carry the listed metadata keys, which are embedded into the SecretID and set
+as metadata of the entity alias at login.`,
 	},
 	"role-bind-secret-id": {
 		"Impose secret_id to be presented during login using this role.",
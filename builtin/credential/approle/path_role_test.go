@@ -2,6 +2,7 @@ package approle
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -1149,14 +1150,18 @@ func TestAppRole_RoleCRUD(t *testing.T) {
 	}
 
 	expected := map[string]interface{}{
-		"bind_secret_id":     true,
-		"policies":           []string{"p", "q", "r", "s"},
-		"secret_id_num_uses": 10,
-		"secret_id_ttl":      300,
-		"token_ttl":          400,
-		"token_max_ttl":      500,
-		"token_num_uses":     600,
-		"bound_cidr_list":    []string{"127.0.0.1/32", "127.0.0.1/16"},
+		"bind_secret_id":              true,
+		"policies":                    []string{"p", "q", "r", "s"},
+		"secret_id_num_uses":          10,
+		"secret_id_ttl":               300,
+		"token_ttl":                   400,
+		"token_max_ttl":               500,
+		"token_num_uses":              600,
+		"bound_cidr_list":             []string{"127.0.0.1/32", "127.0.0.1/16"},
+		"secret_id_attested_metadata": []string{},
+		"token_policies":              []string{"p", "q", "r", "s"},
+		"token_bound_cidrs":           []string{},
+		"token_type":                  "default",
 	}
 
 	var expectedStruct roleStorageEntry
@@ -1200,6 +1205,7 @@ func TestAppRole_RoleCRUD(t *testing.T) {
 
 	expected = map[string]interface{}{
 		"policies":           []string{"a", "b", "c", "d"},
+		"token_policies":     []string{"a", "b", "c", "d"},
 		"secret_id_num_uses": 100,
 		"secret_id_ttl":      3000,
 		"token_ttl":          4000,
@@ -1591,6 +1597,86 @@ func TestAppRole_RoleCRUD(t *testing.T) {
 	}
 }
 
+func TestAppRole_SecretIDWrappingRequired(t *testing.T) {
+	var resp *logical.Response
+	var err error
+	b, storage := createBackendWithStorage(t)
+
+	roleReq := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"policies":                    "a,b",
+			"secret_id_wrapping_required": true,
+			"secret_id_min_wrap_ttl":      "1m",
+			"secret_id_max_wrap_ttl":      "10m",
+		},
+	}
+	resp, err = b.HandleRequest(context.Background(), roleReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	roleReq.Operation = logical.ReadOperation
+	roleReq.Data = nil
+	resp, err = b.HandleRequest(context.Background(), roleReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["secret_id_wrapping_required"] != true ||
+		resp.Data["secret_id_min_wrap_ttl"] != time.Duration(60) ||
+		resp.Data["secret_id_max_wrap_ttl"] != time.Duration(600) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	cases := []struct {
+		path     string
+		wrapInfo *logical.RequestWrapInfo
+		valid    bool
+	}{
+		{"role/role1/secret-id", nil, false},
+		{"role/role1/secret-id", &logical.RequestWrapInfo{TTL: 30 * time.Second}, false},
+		{"role/role1/secret-id", &logical.RequestWrapInfo{TTL: time.Hour}, false},
+		{"role/role1/secret-id", &logical.RequestWrapInfo{TTL: 5 * time.Minute}, true},
+		{"role/role1/custom-secret-id", nil, false},
+		{"role/role1/custom-secret-id", &logical.RequestWrapInfo{TTL: 5 * time.Minute}, true},
+	}
+	for i, tc := range cases {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      tc.path,
+			Storage:   storage,
+			WrapInfo:  tc.wrapInfo,
+			Data: map[string]interface{}{
+				"secret_id": fmt.Sprintf("custom-%d", i),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.valid == (resp != nil && resp.IsError()) {
+			t.Fatalf("case %d: bad: %#v", i, resp)
+		}
+	}
+
+	// The minimum wrapping TTL cannot exceed the maximum
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"secret_id_min_wrap_ttl": "20m",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error")
+	}
+}
+
 func createRole(t *testing.T, b *backend, s logical.Storage, roleName, policies string) {
 	roleData := map[string]interface{}{
 		"policies":           policies,
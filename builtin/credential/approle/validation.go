@@ -52,6 +52,10 @@ type secretIDStorageEntry struct {
 	// restrictions on the usage of SecretID
 	CIDRList []string `json:"cidr_list" mapstructure:"cidr_list"`
 
+	// AttestedMetadata is the metadata of the token that generated the
+	// SecretID, restricted to the attested keys of the role
+	AttestedMetadata map[string]string `json:"attested_metadata" mapstructure:"attested_metadata"`
+
 	// This is a deprecated field
 	SecretIDNumUsesDeprecated int `json:"SecretIDNumUses" mapstructure:"SecretIDNumUses"`
 }
@@ -83,6 +87,40 @@ func verifyCIDRRoleSecretIDSubset(secretIDCIDRs []string, roleBoundCIDRList []st
 	return nil
 }
 
+// verifySecretIDWrapTTL checks that a SecretID is requested response wrapped
+// with a wrapping TTL within the given bounds. A zero bound is not enforced.
+func verifySecretIDWrapTTL(wrapInfo *logical.RequestWrapInfo, minWrapTTL, maxWrapTTL time.Duration) error {
+	if wrapInfo == nil || wrapInfo.TTL == 0 {
+		return fmt.Errorf("secret_id must be response wrapped")
+	}
+	if minWrapTTL > 0 && wrapInfo.TTL < minWrapTTL {
+		return fmt.Errorf("wrapping TTL %q is less than the minimum wrapping TTL %q of the role", wrapInfo.TTL, minWrapTTL)
+	}
+	if maxWrapTTL > 0 && wrapInfo.TTL > maxWrapTTL {
+		return fmt.Errorf("wrapping TTL %q is greater than the maximum wrapping TTL %q of the role", wrapInfo.TTL, maxWrapTTL)
+	}
+	return nil
+}
+
+// attestSecretIDMetadata returns the values of the attested keys from the
+// metadata of the token generating a SecretID. The token must carry all the
+// attested keys.
+func attestSecretIDMetadata(tokenMetadata map[string]string, attestedKeys []string) (map[string]string, error) {
+	if len(attestedKeys) == 0 {
+		return nil, nil
+	}
+
+	attested := make(map[string]string, len(attestedKeys))
+	for _, key := range attestedKeys {
+		value, ok := tokenMetadata[key]
+		if !ok {
+			return nil, fmt.Errorf("token metadata is missing the attested key %q", key)
+		}
+		attested[key] = value
+	}
+	return attested, nil
+}
+
 // Creates a SHA256 HMAC of the given 'value' using the given 'key' and returns
 // a hex encoded string.
 func createHMAC(key, value string) (string, error) {
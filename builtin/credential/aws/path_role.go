@@ -9,17 +9,24 @@ import (
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/consts"
-	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
 var (
-	currentRoleStorageVersion = 2
+	currentRoleStorageVersion = 3
 )
 
+// roleTokenFieldAliases are the deprecated role fields aliasing token fields
+var roleTokenFieldAliases = map[string]string{
+	"policies": "token_policies",
+	"ttl":      "token_ttl",
+	"max_ttl":  "token_max_ttl",
+	"period":   "token_period",
+}
+
 func pathRole(b *backend) *framework.Path {
-	return &framework.Path{
+	p := &framework.Path{
 		Pattern: "role/" + framework.GenericNameRegex("role"),
 		Fields: map[string]*framework.FieldSchema{
 			"role": {
@@ -131,28 +138,20 @@ Defaults to an empty string, meaning that role tags are disabled. This
 is only allowed if auth_type is ec2.`,
 			},
 			"period": &framework.FieldSchema{
-				Type:    framework.TypeDurationSecond,
-				Default: 0,
-				Description: `
-If set, indicates that the token generated using this role should never expire.
-The token should be renewed within the duration specified by this value. At
-each renewal, the token's TTL will be set to the value of this parameter.`,
+				Type:        framework.TypeDurationSecond,
+				Description: `Deprecated: use "token_period" instead.`,
 			},
 			"ttl": {
-				Type:    framework.TypeDurationSecond,
-				Default: 0,
-				Description: `Duration in seconds after which the issued token should expire. Defaults
-to 0, in which case the value will fallback to the system/mount defaults.`,
+				Type:        framework.TypeDurationSecond,
+				Description: `Deprecated: use "token_ttl" instead.`,
 			},
 			"max_ttl": {
 				Type:        framework.TypeDurationSecond,
-				Default:     0,
-				Description: "The maximum allowed lifetime of tokens issued using this role.",
+				Description: `Deprecated: use "token_max_ttl" instead.`,
 			},
 			"policies": {
 				Type:        framework.TypeCommaStringSlice,
-				Default:     "default",
-				Description: "Policies to be set on tokens issued using this role.",
+				Description: `Deprecated: use "token_policies" instead.`,
 			},
 			"allow_instance_migration": {
 				Type:    framework.TypeBool,
@@ -187,6 +186,9 @@ auth_type is ec2.`,
 		HelpSynopsis:    pathRoleSyn,
 		HelpDescription: pathRoleDesc,
 	}
+
+	framework.AddTokenFields(p.Fields)
+	return p
 }
 
 func pathListRole(b *backend) *framework.Path {
@@ -403,6 +405,15 @@ func (b *backend) upgradeRoleEntry(ctx context.Context, s logical.Storage, roleE
 		}
 		roleEntry.Version = 2
 		fallthrough
+	case 2:
+		// Move the token parameters to the common token fields
+		roleEntry.TokenTTL = roleEntry.TTL
+		roleEntry.TokenMaxTTL = roleEntry.MaxTTL
+		roleEntry.TokenPolicies = roleEntry.Policies
+		roleEntry.TokenPeriod = roleEntry.Period
+		roleEntry.TTL, roleEntry.MaxTTL, roleEntry.Policies, roleEntry.Period = 0, 0, nil, 0
+		roleEntry.Version = 3
+		fallthrough
 	case currentRoleStorageVersion:
 	default:
 		return false, fmt.Errorf("unrecognized role version: %q", roleEntry.Version)
@@ -695,13 +706,6 @@ func (b *backend) pathRoleCreateUpdate(ctx context.Context, req *logical.Request
 		return logical.ErrorResponse("at least be one bound parameter should be specified on the role"), nil
 	}
 
-	policiesRaw, ok := data.GetOk("policies")
-	if ok {
-		roleEntry.Policies = policyutil.ParsePolicies(policiesRaw)
-	} else if req.Operation == logical.CreateOperation {
-		roleEntry.Policies = []string{}
-	}
-
 	disallowReauthenticationBool, ok := data.GetOk("disallow_reauthentication")
 	if ok {
 		if roleEntry.AuthType != ec2AuthType {
@@ -726,50 +730,30 @@ func (b *backend) pathRoleCreateUpdate(ctx context.Context, req *logical.Request
 		return logical.ErrorResponse("cannot specify both disallow_reauthentication=true and allow_instance_migration=true"), nil
 	}
 
-	var resp logical.Response
-
-	ttlRaw, ok := data.GetOk("ttl")
-	if ok {
-		ttl := time.Duration(ttlRaw.(int)) * time.Second
-		defaultLeaseTTL := b.System().DefaultLeaseTTL()
-		if ttl > defaultLeaseTTL {
-			resp.AddWarning(fmt.Sprintf("Given ttl of %d seconds greater than current mount/system default of %d seconds; ttl will be capped at login time", ttl/time.Second, defaultLeaseTTL/time.Second))
-		}
-		roleEntry.TTL = ttl
-	} else if req.Operation == logical.CreateOperation {
-		roleEntry.TTL = time.Duration(data.Get("ttl").(int)) * time.Second
+	if err := roleEntry.ParseTokenFieldAliases(data, roleTokenFieldAliases); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
-
-	maxTTLInt, ok := data.GetOk("max_ttl")
-	if ok {
-		maxTTL := time.Duration(maxTTLInt.(int)) * time.Second
-		systemMaxTTL := b.System().MaxLeaseTTL()
-		if maxTTL > systemMaxTTL {
-			resp.AddWarning(fmt.Sprintf("Given max_ttl of %d seconds greater than current mount/system default of %d seconds; max_ttl will be capped at login time", maxTTL/time.Second, systemMaxTTL/time.Second))
-		}
-
-		if maxTTL < time.Duration(0) {
-			return logical.ErrorResponse("max_ttl cannot be negative"), nil
-		}
-
-		roleEntry.MaxTTL = maxTTL
-	} else if req.Operation == logical.CreateOperation {
-		roleEntry.MaxTTL = time.Duration(data.Get("max_ttl").(int)) * time.Second
+	if err := roleEntry.ParseTokenFields(req, data); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if roleEntry.TokenPolicies == nil {
+		roleEntry.TokenPolicies = []string{}
 	}
 
-	if roleEntry.MaxTTL != 0 && roleEntry.MaxTTL < roleEntry.TTL {
-		return logical.ErrorResponse("ttl should be shorter than max_ttl"), nil
+	var resp logical.Response
+
+	defaultLeaseTTL := b.System().DefaultLeaseTTL()
+	if roleEntry.TokenTTL > defaultLeaseTTL {
+		resp.AddWarning(fmt.Sprintf("Given ttl of %d seconds greater than current mount/system default of %d seconds; ttl will be capped at login time", roleEntry.TokenTTL/time.Second, defaultLeaseTTL/time.Second))
 	}
 
-	periodRaw, ok := data.GetOk("period")
-	if ok {
-		roleEntry.Period = time.Second * time.Duration(periodRaw.(int))
-	} else if req.Operation == logical.CreateOperation {
-		roleEntry.Period = time.Second * time.Duration(data.Get("period").(int))
+	systemMaxTTL := b.System().MaxLeaseTTL()
+	if roleEntry.TokenMaxTTL > systemMaxTTL {
+		resp.AddWarning(fmt.Sprintf("Given max_ttl of %d seconds greater than current mount/system default of %d seconds; max_ttl will be capped at login time", roleEntry.TokenMaxTTL/time.Second, systemMaxTTL/time.Second))
 	}
 
-	if roleEntry.Period > b.System().MaxLeaseTTL() {
-		return logical.ErrorResponse(fmt.Sprintf("'period' of '%s' is greater than the backend's maximum lease TTL of '%s'", roleEntry.Period.String(), b.System().MaxLeaseTTL().String())), nil
+	if roleEntry.TokenPeriod > systemMaxTTL {
+		return logical.ErrorResponse(fmt.Sprintf("'period' of '%s' is greater than the backend's maximum lease TTL of '%s'", roleEntry.TokenPeriod.String(), systemMaxTTL.String())), nil
 	}
 
 	roleTagStr, ok := data.GetOk("role_tag")
@@ -807,29 +791,32 @@ func (b *backend) pathRoleCreateUpdate(ctx context.Context, req *logical.Request
 
 // Struct to hold the information associated with a Vault role
 type awsRoleEntry struct {
-	AuthType                    string        `json:"auth_type" `
-	BoundAmiIDs                 []string      `json:"bound_ami_id_list"`
-	BoundAccountIDs             []string      `json:"bound_account_id_list"`
-	BoundEc2InstanceIDs         []string      `json:"bound_ec2_instance_id_list"`
-	BoundIamPrincipalARNs       []string      `json:"bound_iam_principal_arn_list"`
-	BoundIamPrincipalIDs        []string      `json:"bound_iam_principal_id_list"`
-	BoundIamRoleARNs            []string      `json:"bound_iam_role_arn_list"`
-	BoundIamInstanceProfileARNs []string      `json:"bound_iam_instance_profile_arn_list"`
-	BoundRegions                []string      `json:"bound_region_list"`
-	BoundSubnetIDs              []string      `json:"bound_subnet_id_list"`
-	BoundVpcIDs                 []string      `json:"bound_vpc_id_list"`
-	InferredEntityType          string        `json:"inferred_entity_type"`
-	InferredAWSRegion           string        `json:"inferred_aws_region"`
-	ResolveAWSUniqueIDs         bool          `json:"resolve_aws_unique_ids"`
-	RoleTag                     string        `json:"role_tag"`
-	AllowInstanceMigration      bool          `json:"allow_instance_migration"`
-	TTL                         time.Duration `json:"ttl"`
-	MaxTTL                      time.Duration `json:"max_ttl"`
-	Policies                    []string      `json:"policies"`
-	DisallowReauthentication    bool          `json:"disallow_reauthentication"`
-	HMACKey                     string        `json:"hmac_key"`
-	Period                      time.Duration `json:"period"`
-	Version                     int           `json:"version"`
+	framework.TokenParams
+
+	AuthType                    string   `json:"auth_type" `
+	BoundAmiIDs                 []string `json:"bound_ami_id_list"`
+	BoundAccountIDs             []string `json:"bound_account_id_list"`
+	BoundEc2InstanceIDs         []string `json:"bound_ec2_instance_id_list"`
+	BoundIamPrincipalARNs       []string `json:"bound_iam_principal_arn_list"`
+	BoundIamPrincipalIDs        []string `json:"bound_iam_principal_id_list"`
+	BoundIamRoleARNs            []string `json:"bound_iam_role_arn_list"`
+	BoundIamInstanceProfileARNs []string `json:"bound_iam_instance_profile_arn_list"`
+	BoundRegions                []string `json:"bound_region_list"`
+	BoundSubnetIDs              []string `json:"bound_subnet_id_list"`
+	BoundVpcIDs                 []string `json:"bound_vpc_id_list"`
+	InferredEntityType          string   `json:"inferred_entity_type"`
+	InferredAWSRegion           string   `json:"inferred_aws_region"`
+	ResolveAWSUniqueIDs         bool     `json:"resolve_aws_unique_ids"`
+	RoleTag                     string   `json:"role_tag"`
+	AllowInstanceMigration      bool     `json:"allow_instance_migration"`
+	DisallowReauthentication    bool     `json:"disallow_reauthentication"`
+	HMACKey                     string   `json:"hmac_key"`
+	Version                     int      `json:"version"`
+	// DEPRECATED -- these are the old token parameters before the common token fields
+	TTL      time.Duration `json:"ttl,omitempty"`
+	MaxTTL   time.Duration `json:"max_ttl,omitempty"`
+	Policies []string      `json:"policies,omitempty"`
+	Period   time.Duration `json:"period,omitempty"`
 	// DEPRECATED -- these are the old fields before we supported lists and exist for backwards compatibility
 	BoundAmiID                 string `json:"bound_ami_id,omitempty" `
 	BoundAccountID             string `json:"bound_account_id,omitempty"`
@@ -860,12 +847,13 @@ func (r *awsRoleEntry) ToResponseData() map[string]interface{} {
 		"resolve_aws_unique_ids":         r.ResolveAWSUniqueIDs,
 		"role_tag":                       r.RoleTag,
 		"allow_instance_migration":       r.AllowInstanceMigration,
-		"ttl":                       r.TTL / time.Second,
-		"max_ttl":                   r.MaxTTL / time.Second,
-		"policies":                  r.Policies,
+		"ttl":                       r.TokenTTL / time.Second,
+		"max_ttl":                   r.TokenMaxTTL / time.Second,
+		"policies":                  r.TokenPolicies,
 		"disallow_reauthentication": r.DisallowReauthentication,
-		"period":                    r.Period / time.Second,
+		"period":                    r.TokenPeriod / time.Second,
 	}
+	r.PopulateTokenData(responseData)
 
 	convertNilToEmptySlice := func(data map[string]interface{}, field string) {
 		if data[field] == nil || len(data[field].([]string)) == 0 {
@@ -618,6 +618,15 @@ func TestAwsEc2_RoleCrud(t *testing.T) {
 		"policies":                  []string{"testpolicy1", "testpolicy2"},
 		"disallow_reauthentication": false,
 		"period":                    time.Duration(60),
+		"token_bound_cidrs":         []string{},
+		"token_explicit_max_ttl":    int64(0),
+		"token_max_ttl":             int64(1200),
+		"token_no_default_policy":   false,
+		"token_num_uses":            0,
+		"token_period":              int64(60),
+		"token_policies":            []string{"testpolicy1", "testpolicy2"},
+		"token_ttl":                 int64(600),
+		"token_type":                "default",
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
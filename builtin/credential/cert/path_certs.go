@@ -8,8 +8,6 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-sockaddr"
-	"github.com/hashicorp/vault/helper/parseutil"
-	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -27,8 +25,18 @@ func pathListCerts(b *backend) *framework.Path {
 	}
 }
 
+// certTokenFieldAliases are the deprecated certificate fields aliasing token
+// fields
+var certTokenFieldAliases = map[string]string{
+	"policies":    "token_policies",
+	"ttl":         "token_ttl",
+	"max_ttl":     "token_max_ttl",
+	"period":      "token_period",
+	"bound_cidrs": "token_bound_cidrs",
+}
+
 func pathCerts(b *backend) *framework.Path {
-	return &framework.Path{
+	p := &framework.Path{
 		Pattern: "certs/" + framework.GenericNameRegex("name"),
 		Fields: map[string]*framework.FieldSchema{
 			"name": &framework.FieldSchema{
@@ -89,39 +97,32 @@ certificate.`,
 
 			"policies": &framework.FieldSchema{
 				Type:        framework.TypeCommaStringSlice,
-				Description: "Comma-separated list of policies.",
+				Description: `Deprecated: use "token_policies" instead.`,
 			},
 
 			"lease": &framework.FieldSchema{
-				Type: framework.TypeInt,
-				Description: `Deprecated: use "ttl" instead. TTL time in
-seconds. Defaults to system/backend default TTL.`,
+				Type:        framework.TypeInt,
+				Description: `Deprecated: use "token_ttl" instead.`,
 			},
 
 			"ttl": &framework.FieldSchema{
-				Type: framework.TypeDurationSecond,
-				Description: `TTL for tokens issued by this backend.
-Defaults to system/backend default TTL time.`,
+				Type:        framework.TypeDurationSecond,
+				Description: `Deprecated: use "token_ttl" instead.`,
 			},
 
 			"max_ttl": &framework.FieldSchema{
-				Type: framework.TypeDurationSecond,
-				Description: `Duration in either an integer number of seconds (3600) or
-an integer time unit (60m) after which the
-issued token can no longer be renewed.`,
+				Type:        framework.TypeDurationSecond,
+				Description: `Deprecated: use "token_max_ttl" instead.`,
 			},
 
 			"period": &framework.FieldSchema{
-				Type: framework.TypeDurationSecond,
-				Description: `If set, indicates that the token generated using this role
-should never expire. The token should be renewed within the
-duration specified by this value. At each renewal, the token's
-TTL will be set to the value of this parameter.`,
+				Type:        framework.TypeDurationSecond,
+				Description: `Deprecated: use "token_period" instead.`,
 			},
+
 			"bound_cidrs": &framework.FieldSchema{
-				Type: framework.TypeCommaStringSlice,
-				Description: `Comma separated string or list of CIDR blocks. If set, specifies the blocks of
-IP addresses which can perform the login operation.`,
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Deprecated: use "token_bound_cidrs" instead.`,
 			},
 		},
 
@@ -134,6 +135,9 @@ IP addresses which can perform the login operation.`,
 		HelpSynopsis:    pathCertHelpSyn,
 		HelpDescription: pathCertHelpDesc,
 	}
+
+	framework.AddTokenFields(p.Fields)
+	return p
 }
 
 func (b *backend) Cert(ctx context.Context, s logical.Storage, n string) (*CertEntry, error) {
@@ -149,6 +153,25 @@ func (b *backend) Cert(ctx context.Context, s logical.Storage, n string) (*CertE
 	if err := entry.DecodeJSON(&result); err != nil {
 		return nil, err
 	}
+
+	// Upgrade the deprecated token parameters
+	if len(result.TokenPolicies) == 0 && len(result.Policies) > 0 {
+		result.TokenPolicies = result.Policies
+	}
+	if result.TokenTTL == 0 && result.TTL > 0 {
+		result.TokenTTL = result.TTL
+	}
+	if result.TokenMaxTTL == 0 && result.MaxTTL > 0 {
+		result.TokenMaxTTL = result.MaxTTL
+	}
+	if result.TokenPeriod == 0 && result.Period > 0 {
+		result.TokenPeriod = result.Period
+	}
+	if len(result.TokenBoundCIDRs) == 0 && len(result.BoundCIDRs) > 0 {
+		result.TokenBoundCIDRs = result.BoundCIDRs
+	}
+	result.Policies, result.TTL, result.MaxTTL, result.Period, result.BoundCIDRs = nil, 0, 0, 0, nil
+
 	return &result, nil
 }
 
@@ -177,21 +200,25 @@ func (b *backend) pathCertRead(ctx context.Context, req *logical.Request, d *fra
 		return nil, nil
 	}
 
+	data := map[string]interface{}{
+		"certificate":          cert.Certificate,
+		"display_name":         cert.DisplayName,
+		"policies":             cert.TokenPolicies,
+		"ttl":                  cert.TokenTTL / time.Second,
+		"max_ttl":              cert.TokenMaxTTL / time.Second,
+		"period":               cert.TokenPeriod / time.Second,
+		"bound_cidrs":          cert.TokenBoundCIDRs,
+		"allowed_names":        cert.AllowedNames,
+		"allowed_common_names": cert.AllowedCommonNames,
+		"allowed_dns_sans":     cert.AllowedDNSSANs,
+		"allowed_email_sans":   cert.AllowedEmailSANs,
+		"allowed_uri_sans":     cert.AllowedURISANs,
+		"required_extensions":  cert.RequiredExtensions,
+	}
+	cert.PopulateTokenData(data)
+
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"certificate":          cert.Certificate,
-			"display_name":         cert.DisplayName,
-			"policies":             cert.Policies,
-			"ttl":                  cert.TTL / time.Second,
-			"max_ttl":              cert.MaxTTL / time.Second,
-			"period":               cert.Period / time.Second,
-			"allowed_names":        cert.AllowedNames,
-			"allowed_common_names": cert.AllowedCommonNames,
-			"allowed_dns_sans":     cert.AllowedDNSSANs,
-			"allowed_email_sans":   cert.AllowedEmailSANs,
-			"allowed_uri_sans":     cert.AllowedURISANs,
-			"required_extensions":  cert.RequiredExtensions,
-		},
+		Data: data,
 	}, nil
 }
 
@@ -199,7 +226,6 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 	name := strings.ToLower(d.Get("name").(string))
 	certificate := d.Get("certificate").(string)
 	displayName := d.Get("display_name").(string)
-	policies := policyutil.ParsePolicies(d.Get("policies"))
 	allowedNames := d.Get("allowed_names").([]string)
 	allowedCommonNames := d.Get("allowed_common_names").([]string)
 	allowedDNSSANs := d.Get("allowed_dns_sans").([]string)
@@ -207,45 +233,50 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 	allowedURISANs := d.Get("allowed_uri_sans").([]string)
 	requiredExtensions := d.Get("required_extensions").([]string)
 
-	var resp logical.Response
+	certEntry := &CertEntry{
+		Name:               name,
+		Certificate:        certificate,
+		DisplayName:        displayName,
+		AllowedNames:       allowedNames,
+		AllowedCommonNames: allowedCommonNames,
+		AllowedDNSSANs:     allowedDNSSANs,
+		AllowedEmailSANs:   allowedEmailSANs,
+		AllowedURISANs:     allowedURISANs,
+		RequiredExtensions: requiredExtensions,
+	}
 
-	// Parse the ttl (or lease duration)
-	systemDefaultTTL := b.System().DefaultLeaseTTL()
-	ttl := time.Duration(d.Get("ttl").(int)) * time.Second
-	if ttl == 0 {
-		ttl = time.Duration(d.Get("lease").(int)) * time.Second
+	if err := certEntry.ParseTokenFieldAliases(d, certTokenFieldAliases); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
-	if ttl > systemDefaultTTL {
-		resp.AddWarning(fmt.Sprintf("Given ttl of %d seconds is greater than current mount/system default of %d seconds", ttl/time.Second, systemDefaultTTL/time.Second))
+	if err := certEntry.ParseTokenFields(req, d); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
-	if ttl < time.Duration(0) {
+	// Fall back to the deprecated lease for the ttl
+	if certEntry.TokenTTL == 0 {
+		certEntry.TokenTTL = time.Duration(d.Get("lease").(int)) * time.Second
+	}
+	if certEntry.TokenTTL < time.Duration(0) {
 		return logical.ErrorResponse("ttl cannot be negative"), nil
 	}
-
-	// Parse max_ttl
-	systemMaxTTL := b.System().MaxLeaseTTL()
-	maxTTL := time.Duration(d.Get("max_ttl").(int)) * time.Second
-	if maxTTL > systemMaxTTL {
-		resp.AddWarning(fmt.Sprintf("Given max_ttl of %d seconds is greater than current mount/system default of %d seconds", maxTTL/time.Second, systemMaxTTL/time.Second))
+	if certEntry.TokenMaxTTL != 0 && certEntry.TokenTTL > certEntry.TokenMaxTTL {
+		return logical.ErrorResponse("ttl should be shorter than max_ttl"), nil
 	}
 
-	if maxTTL < time.Duration(0) {
-		return logical.ErrorResponse("max_ttl cannot be negative"), nil
-	}
+	var resp logical.Response
 
-	if maxTTL != 0 && ttl > maxTTL {
-		return logical.ErrorResponse("ttl should be shorter than max_ttl"), nil
+	systemDefaultTTL := b.System().DefaultLeaseTTL()
+	if certEntry.TokenTTL > systemDefaultTTL {
+		resp.AddWarning(fmt.Sprintf("Given ttl of %d seconds is greater than current mount/system default of %d seconds", certEntry.TokenTTL/time.Second, systemDefaultTTL/time.Second))
 	}
 
-	// Parse period
-	period := time.Duration(d.Get("period").(int)) * time.Second
-	if period > systemMaxTTL {
-		resp.AddWarning(fmt.Sprintf("Given period of %d seconds is greater than the backend's maximum TTL of %d seconds", period/time.Second, systemMaxTTL/time.Second))
+	systemMaxTTL := b.System().MaxLeaseTTL()
+	if certEntry.TokenMaxTTL > systemMaxTTL {
+		resp.AddWarning(fmt.Sprintf("Given max_ttl of %d seconds is greater than current mount/system default of %d seconds", certEntry.TokenMaxTTL/time.Second, systemMaxTTL/time.Second))
 	}
 
-	if period < time.Duration(0) {
-		return logical.ErrorResponse("period cannot be negative"), nil
+	if certEntry.TokenPeriod > systemMaxTTL {
+		resp.AddWarning(fmt.Sprintf("Given period of %d seconds is greater than the backend's maximum TTL of %d seconds", certEntry.TokenPeriod/time.Second, systemMaxTTL/time.Second))
 	}
 
 	// Default the display name to the certificate name if not given
@@ -272,28 +303,6 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 		}
 	}
 
-	parsedCIDRs, err := parseutil.ParseAddrs(d.Get("bound_cidrs"))
-	if err != nil {
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-	}
-
-	certEntry := &CertEntry{
-		Name:               name,
-		Certificate:        certificate,
-		DisplayName:        displayName,
-		Policies:           policies,
-		AllowedNames:       allowedNames,
-		AllowedCommonNames: allowedCommonNames,
-		AllowedDNSSANs:     allowedDNSSANs,
-		AllowedEmailSANs:   allowedEmailSANs,
-		AllowedURISANs:     allowedURISANs,
-		RequiredExtensions: requiredExtensions,
-		TTL:                ttl,
-		MaxTTL:             maxTTL,
-		Period:             period,
-		BoundCIDRs:         parsedCIDRs,
-	}
-
 	// Store it
 	entry, err := logical.StorageEntryJSON("cert/"+name, certEntry)
 	if err != nil {
@@ -311,20 +320,25 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 }
 
 type CertEntry struct {
+	framework.TokenParams
+
 	Name               string
 	Certificate        string
 	DisplayName        string
-	Policies           []string
-	TTL                time.Duration
-	MaxTTL             time.Duration
-	Period             time.Duration
 	AllowedNames       []string
 	AllowedCommonNames []string
 	AllowedDNSSANs     []string
 	AllowedEmailSANs   []string
 	AllowedURISANs     []string
 	RequiredExtensions []string
-	BoundCIDRs         []*sockaddr.SockAddrMarshaler
+
+	// Policies, TTL, MaxTTL, Period and BoundCIDRs are deprecated in favor
+	// of the token parameters, but are retained to upgrade older entries.
+	Policies   []string                      `json:",omitempty"`
+	TTL        time.Duration                 `json:",omitempty"`
+	MaxTTL     time.Duration                 `json:",omitempty"`
+	Period     time.Duration                 `json:",omitempty"`
+	BoundCIDRs []*sockaddr.SockAddrMarshaler `json:",omitempty"`
 }
 
 const pathCertHelpSyn = `
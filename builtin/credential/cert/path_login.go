@@ -83,33 +83,31 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 	skid := base64.StdEncoding.EncodeToString(clientCerts[0].SubjectKeyId)
 	akid := base64.StdEncoding.EncodeToString(clientCerts[0].AuthorityKeyId)
 
-	resp := &logical.Response{
-		Auth: &logical.Auth{
-			Period: matched.Entry.Period,
-			InternalData: map[string]interface{}{
-				"subject_key_id":   skid,
-				"authority_key_id": akid,
-			},
-			Policies:    matched.Entry.Policies,
-			DisplayName: matched.Entry.DisplayName,
-			Metadata: map[string]string{
-				"cert_name":        matched.Entry.Name,
-				"common_name":      clientCerts[0].Subject.CommonName,
-				"serial_number":    clientCerts[0].SerialNumber.String(),
-				"subject_key_id":   certutil.GetHexFormatted(clientCerts[0].SubjectKeyId, ":"),
-				"authority_key_id": certutil.GetHexFormatted(clientCerts[0].AuthorityKeyId, ":"),
-			},
-			LeaseOptions: logical.LeaseOptions{
-				Renewable: true,
-				TTL:       matched.Entry.TTL,
-				MaxTTL:    matched.Entry.MaxTTL,
-			},
-			Alias: &logical.Alias{
-				Name: clientCerts[0].Subject.CommonName,
-			},
-			BoundCIDRs: matched.Entry.BoundCIDRs,
+	auth := &logical.Auth{
+		InternalData: map[string]interface{}{
+			"subject_key_id":   skid,
+			"authority_key_id": akid,
+		},
+		DisplayName: matched.Entry.DisplayName,
+		Metadata: map[string]string{
+			"cert_name":        matched.Entry.Name,
+			"common_name":      clientCerts[0].Subject.CommonName,
+			"serial_number":    clientCerts[0].SerialNumber.String(),
+			"subject_key_id":   certutil.GetHexFormatted(clientCerts[0].SubjectKeyId, ":"),
+			"authority_key_id": certutil.GetHexFormatted(clientCerts[0].AuthorityKeyId, ":"),
+		},
+		LeaseOptions: logical.LeaseOptions{
+			Renewable: true,
+		},
+		Alias: &logical.Alias{
+			Name: clientCerts[0].Subject.CommonName,
 		},
 	}
+	matched.Entry.PopulateTokenAuth(auth)
+
+	resp := &logical.Response{
+		Auth: auth,
+	}
 
 	// Generate a response
 	return resp, nil
@@ -159,14 +157,15 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 		return nil, nil
 	}
 
-	if !policyutil.EquivalentPolicies(cert.Policies, req.Auth.Policies) {
+	if !policyutil.EquivalentPolicies(cert.TokenPolicies, req.Auth.Policies) {
 		return nil, fmt.Errorf("policies have changed, not renewing")
 	}
 
 	resp := &logical.Response{Auth: req.Auth}
-	resp.Auth.TTL = cert.TTL
-	resp.Auth.MaxTTL = cert.MaxTTL
-	resp.Auth.Period = cert.Period
+	resp.Auth.TTL = cert.TokenTTL
+	resp.Auth.MaxTTL = cert.TokenMaxTTL
+	resp.Auth.Period = cert.TokenPeriod
+	resp.Auth.ExplicitMaxTTL = cert.TokenExplicitMaxTTL
 	return resp, nil
 }
 
@@ -458,7 +457,7 @@ func (b *backend) checkForValidChain(chains [][]*x509.Certificate) bool {
 }
 
 func (b *backend) checkCIDR(cert *CertEntry, req *logical.Request) error {
-	if cidrutil.RemoteAddrIsOk(req.Connection.RemoteAddr, cert.BoundCIDRs) {
+	if cidrutil.RemoteAddrIsOk(req.Connection.RemoteAddr, cert.TokenBoundCIDRs) {
 		return nil
 	}
 	return logical.ErrPermissionDenied
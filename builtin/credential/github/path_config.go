@@ -12,7 +12,7 @@ import (
 )
 
 func pathConfig(b *backend) *framework.Path {
-	return &framework.Path{
+	p := &framework.Path{
 		Pattern: "config",
 		Fields: map[string]*framework.FieldSchema{
 			"organization": &framework.FieldSchema{
@@ -28,11 +28,11 @@ API-compatible authentication server.`,
 			},
 			"ttl": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: `Duration after which authentication will be expired`,
+				Description: `Deprecated: use "token_ttl" instead.`,
 			},
 			"max_ttl": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: `Maximum duration after which authentication will be expired`,
+				Description: `Deprecated: use "token_max_ttl" instead.`,
 			},
 		},
 
@@ -41,6 +41,9 @@ API-compatible authentication server.`,
 			logical.ReadOperation:   b.pathConfigRead,
 		},
 	}
+
+	framework.AddTokenFields(p.Fields)
+	return p
 }
 
 func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -53,36 +56,39 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 		}
 	}
 
-	var ttl time.Duration
+	c := &config{
+		Organization: organization,
+		BaseURL:      baseURL,
+	}
+
+	// The deprecated ttl and max_ttl are duration strings, so they are
+	// parsed here rather than as token field aliases
 	var err error
-	ttlRaw, ok := data.GetOk("ttl")
-	if !ok || len(ttlRaw.(string)) == 0 {
-		ttl = 0
-	} else {
-		ttl, err = time.ParseDuration(ttlRaw.(string))
+	if ttlRaw, ok := data.GetOk("ttl"); ok && len(ttlRaw.(string)) != 0 {
+		if _, ok := data.GetOk("token_ttl"); ok {
+			return logical.ErrorResponse(`"ttl" and "token_ttl" cannot both be set`), nil
+		}
+		c.TokenTTL, err = time.ParseDuration(ttlRaw.(string))
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf("Invalid 'ttl':%s", err)), nil
 		}
 	}
 
-	var maxTTL time.Duration
-	maxTTLRaw, ok := data.GetOk("max_ttl")
-	if !ok || len(maxTTLRaw.(string)) == 0 {
-		maxTTL = 0
-	} else {
-		maxTTL, err = time.ParseDuration(maxTTLRaw.(string))
+	if maxTTLRaw, ok := data.GetOk("max_ttl"); ok && len(maxTTLRaw.(string)) != 0 {
+		if _, ok := data.GetOk("token_max_ttl"); ok {
+			return logical.ErrorResponse(`"max_ttl" and "token_max_ttl" cannot both be set`), nil
+		}
+		c.TokenMaxTTL, err = time.ParseDuration(maxTTLRaw.(string))
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf("Invalid 'max_ttl':%s", err)), nil
 		}
 	}
 
-	entry, err := logical.StorageEntryJSON("config", config{
-		Organization: organization,
-		BaseURL:      baseURL,
-		TTL:          ttl,
-		MaxTTL:       maxTTL,
-	})
+	if err := c.ParseTokenFields(req, data); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
 
+	entry, err := logical.StorageEntryJSON("config", c)
 	if err != nil {
 		return nil, err
 	}
@@ -104,16 +110,16 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, data
 		return nil, fmt.Errorf("configuration object not found")
 	}
 
-	config.TTL /= time.Second
-	config.MaxTTL /= time.Second
+	respData := map[string]interface{}{
+		"organization": config.Organization,
+		"base_url":     config.BaseURL,
+		"ttl":          config.TokenTTL / time.Second,
+		"max_ttl":      config.TokenMaxTTL / time.Second,
+	}
+	config.PopulateTokenData(respData)
 
 	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"organization": config.Organization,
-			"base_url":     config.BaseURL,
-			"ttl":          config.TTL,
-			"max_ttl":      config.MaxTTL,
-		},
+		Data: respData,
 	}
 	return resp, nil
 }
@@ -132,12 +138,26 @@ func (b *backend) Config(ctx context.Context, s logical.Storage) (*config, error
 		}
 	}
 
+	// Upgrade the deprecated token parameters
+	if result.TokenTTL == 0 && result.TTL > 0 {
+		result.TokenTTL = result.TTL
+	}
+	if result.TokenMaxTTL == 0 && result.MaxTTL > 0 {
+		result.TokenMaxTTL = result.MaxTTL
+	}
+	result.TTL, result.MaxTTL = 0, 0
+
 	return &result, nil
 }
 
 type config struct {
-	Organization string        `json:"organization" structs:"organization" mapstructure:"organization"`
-	BaseURL      string        `json:"base_url" structs:"base_url" mapstructure:"base_url"`
-	TTL          time.Duration `json:"ttl" structs:"ttl" mapstructure:"ttl"`
-	MaxTTL       time.Duration `json:"max_ttl" structs:"max_ttl" mapstructure:"max_ttl"`
+	framework.TokenParams
+
+	Organization string `json:"organization" structs:"organization" mapstructure:"organization"`
+	BaseURL      string `json:"base_url" structs:"base_url" mapstructure:"base_url"`
+
+	// TTL and MaxTTL are deprecated in favor of the token parameters, but
+	// are retained to upgrade older entries.
+	TTL    time.Duration `json:"ttl,omitempty" structs:"ttl" mapstructure:"ttl"`
+	MaxTTL time.Duration `json:"max_ttl,omitempty" structs:"max_ttl" mapstructure:"max_ttl"`
 }
@@ -68,27 +68,30 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 		return nil, err
 	}
 
-	resp := &logical.Response{
-		Auth: &logical.Auth{
-			InternalData: map[string]interface{}{
-				"token": token,
-			},
-			Policies: verifyResp.Policies,
-			Metadata: map[string]string{
-				"username": *verifyResp.User.Login,
-				"org":      *verifyResp.Org.Login,
-			},
-			DisplayName: *verifyResp.User.Login,
-			LeaseOptions: logical.LeaseOptions{
-				TTL:       config.TTL,
-				MaxTTL:    config.MaxTTL,
-				Renewable: true,
-			},
-			Alias: &logical.Alias{
-				Name: *verifyResp.User.Login,
-			},
+	auth := &logical.Auth{
+		InternalData: map[string]interface{}{
+			"token": token,
+		},
+		Metadata: map[string]string{
+			"username": *verifyResp.User.Login,
+			"org":      *verifyResp.Org.Login,
+		},
+		DisplayName: *verifyResp.User.Login,
+		LeaseOptions: logical.LeaseOptions{
+			Renewable: true,
+		},
+		Alias: &logical.Alias{
+			Name: *verifyResp.User.Login,
 		},
 	}
+	config.PopulateTokenAuth(auth)
+
+	// Add the policies of the teams and the user to the configured ones
+	auth.Policies = append(auth.Policies, verifyResp.Policies...)
+
+	resp := &logical.Response{
+		Auth: auth,
+	}
 
 	for _, teamName := range verifyResp.TeamNames {
 		if teamName == "" {
@@ -121,18 +124,20 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 	} else {
 		verifyResp = verifyResponse
 	}
-	if !policyutil.EquivalentPolicies(verifyResp.Policies, req.Auth.Policies) {
-		return nil, fmt.Errorf("policies do not match")
-	}
-
 	config, err := b.Config(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
 
+	if !policyutil.EquivalentPolicies(append(config.TokenPolicies, verifyResp.Policies...), req.Auth.Policies) {
+		return nil, fmt.Errorf("policies do not match")
+	}
+
 	resp := &logical.Response{Auth: req.Auth}
-	resp.Auth.TTL = config.TTL
-	resp.Auth.MaxTTL = config.MaxTTL
+	resp.Auth.TTL = config.TokenTTL
+	resp.Auth.MaxTTL = config.TokenMaxTTL
+	resp.Auth.Period = config.TokenPeriod
+	resp.Auth.ExplicitMaxTTL = config.TokenExplicitMaxTTL
 
 	// Remove old aliases
 	resp.Auth.GroupAliases = nil
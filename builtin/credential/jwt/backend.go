@@ -0,0 +1,223 @@
+package jwt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
+)
+
+const (
+	configPath = "config"
+	rolePrefix = "role/"
+
+	// oidcStateTTL is how long the OIDC authentication requests wait for
+	// the callback
+	oidcStateTTL = 10 * time.Minute
+)
+
+// Factory is used by framework
+func Factory(ctx context.Context, c *logical.BackendConfig) (logical.Backend, error) {
+	b := backend()
+	if err := b.Setup(ctx, c); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+type jwtAuthBackend struct {
+	*framework.Backend
+
+	l            sync.RWMutex
+	provider     *oidc.Provider
+	keySet       oidc.KeySet
+	cachedConfig *jwtConfig
+
+	// providerCtx is used by the OIDC provider and the JWKS key set to fetch
+	// keys after the request that created them has returned
+	providerCtx       context.Context
+	providerCtxCancel context.CancelFunc
+
+	// oidcStates holds the pending OIDC authentication requests
+	oidcStates *cache.Cache
+}
+
+func backend() *jwtAuthBackend {
+	b := new(jwtAuthBackend)
+	b.providerCtx, b.providerCtxCancel = context.WithCancel(context.Background())
+	b.oidcStates = cache.New(oidcStateTTL, time.Minute)
+
+	b.Backend = &framework.Backend{
+		AuthRenew:   b.pathLoginRenew,
+		BackendType: logical.TypeCredential,
+		Invalidate:  b.invalidate,
+		Clean:       b.cleanup,
+		Help:        backendHelp,
+		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"login",
+				"oidc/auth_url",
+				"oidc/callback",
+			},
+			SealWrapStorage: []string{
+				configPath,
+			},
+		},
+		Paths: framework.PathAppend(
+			[]*framework.Path{
+				pathLogin(b),
+				pathRoleList(b),
+				pathRole(b),
+				pathConfig(b),
+			},
+			pathOIDC(b),
+		),
+	}
+
+	return b
+}
+
+func (b *jwtAuthBackend) cleanup(_ context.Context) {
+	b.l.Lock()
+	if b.providerCtxCancel != nil {
+		b.providerCtxCancel()
+	}
+	b.l.Unlock()
+}
+
+func (b *jwtAuthBackend) invalidate(ctx context.Context, key string) {
+	switch key {
+	case configPath:
+		b.reset()
+	}
+}
+
+// reset drops the cached configuration, provider and key set so that they
+// are rebuilt from storage
+func (b *jwtAuthBackend) reset() {
+	b.l.Lock()
+	b.provider = nil
+	b.keySet = nil
+	b.cachedConfig = nil
+	b.l.Unlock()
+}
+
+func (b *jwtAuthBackend) config(ctx context.Context, s logical.Storage) (*jwtConfig, error) {
+	b.l.RLock()
+	config := b.cachedConfig
+	b.l.RUnlock()
+	if config != nil {
+		return config, nil
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.cachedConfig != nil {
+		return b.cachedConfig, nil
+	}
+
+	config, err := loadConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	b.cachedConfig = config
+	return config, nil
+}
+
+// getProvider returns the OIDC provider of the configured discovery URL,
+// creating it on first use
+func (b *jwtAuthBackend) getProvider(config *jwtConfig) (*oidc.Provider, error) {
+	b.l.RLock()
+	provider := b.provider
+	b.l.RUnlock()
+	if provider != nil {
+		return provider, nil
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.provider != nil {
+		return b.provider, nil
+	}
+
+	provider, err := b.createProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	b.provider = provider
+	return provider, nil
+}
+
+func (b *jwtAuthBackend) createProvider(config *jwtConfig) (*oidc.Provider, error) {
+	ctx, err := b.createCAContext(b.providerCtx, config.OIDCDiscoveryCAPEM)
+	if err != nil {
+		return nil, err
+	}
+	return oidc.NewProvider(ctx, config.OIDCDiscoveryURL)
+}
+
+// getKeySet returns the key set of the configured JWKS URL, creating it on
+// first use
+func (b *jwtAuthBackend) getKeySet(config *jwtConfig) (oidc.KeySet, error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.keySet != nil {
+		return b.keySet, nil
+	}
+
+	ctx, err := b.createCAContext(b.providerCtx, config.JWKSCAPEM)
+	if err != nil {
+		return nil, err
+	}
+	b.keySet = oidc.NewRemoteKeySet(ctx, config.JWKSURL)
+	return b.keySet, nil
+}
+
+// createCAContext returns a context whose HTTP client trusts the given PEM
+// encoded CA certificates, or the system ones if empty
+func (b *jwtAuthBackend) createCAContext(ctx context.Context, caPEM string) (context.Context, error) {
+	var certPool *x509.CertPool
+	if caPEM != "" {
+		certPool = x509.NewCertPool()
+		if ok := certPool.AppendCertsFromPEM([]byte(caPEM)); !ok {
+			return nil, errors.New("could not parse the CA PEM value successfully")
+		}
+	}
+
+	tr := cleanhttp.DefaultPooledTransport()
+	if certPool != nil {
+		tr.TLSClientConfig = &tls.Config{
+			RootCAs: certPool,
+		}
+	}
+	tc := &http.Client{
+		Transport: tr,
+	}
+
+	return oidc.ClientContext(ctx, tc), nil
+}
+
+const backendHelp = `
+The JWT auth method allows authentication using JWTs, such as the service
+account tokens of Kubernetes or the ID tokens of OIDC providers, and the OIDC
+authorization code flow of the OIDC providers.
+
+The JWTs are verified with static public keys, the keys published at a JWKS URL
+or the keys of a provider found with OIDC discovery. Roles bind the claims of
+the JWTs, map them to the alias, groups and metadata of the logins and set the
+properties of the issued tokens.
+`
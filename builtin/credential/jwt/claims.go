@@ -0,0 +1,193 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// getClaim returns the value of a claim. Claims starting with "/" are JSON
+// pointers into the claims; other claims are top-level keys.
+func getClaim(allClaims map[string]interface{}, claim string) interface{} {
+	if !strings.HasPrefix(claim, "/") {
+		return allClaims[claim]
+	}
+
+	var val interface{} = allClaims
+	for _, token := range strings.Split(claim[1:], "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		val = obj[token]
+	}
+	return val
+}
+
+// claimValues returns the values of a claim, which is either a single value
+// or a list of values
+func claimValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := claimString(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	case []string:
+		return v, nil
+	}
+
+	s, err := claimString(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+// claimString returns the string representation of a scalar claim value
+func claimString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool, float64, json.Number, int, int64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("unsupported claim value type %T", value)
+}
+
+// validateBoundClaims checks that the claims match the bound claims. Each
+// bound claim must have one of its expected values.
+func validateBoundClaims(boundClaims map[string]interface{}, allClaims map[string]interface{}) error {
+	for claim, expected := range boundClaims {
+		actual := getClaim(allClaims, claim)
+		if actual == nil {
+			return fmt.Errorf("claim %q is missing", claim)
+		}
+
+		expectedValues, err := claimValues(expected)
+		if err != nil {
+			return err
+		}
+		actualValues, err := claimValues(actual)
+		if err != nil {
+			return fmt.Errorf("claim %q has an invalid value: %s", claim, err)
+		}
+
+		found := false
+		for _, a := range actualValues {
+			for _, e := range expectedValues {
+				if a == e {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("claim %q does not match any associated bound claim values", claim)
+		}
+	}
+	return nil
+}
+
+// extractMetadata builds the metadata of a login from the claims and the
+// claim mappings of its role
+func extractMetadata(allClaims map[string]interface{}, claimMappings map[string]string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for source, target := range claimMappings {
+		value := getClaim(allClaims, source)
+		if value == nil {
+			continue
+		}
+		s, err := claimString(value)
+		if err != nil {
+			return nil, fmt.Errorf("error converting claim %q to string: %s", source, err)
+		}
+		metadata[target] = s
+	}
+	return metadata, nil
+}
+
+// createAuth returns the auth of a login to the role with the given claims
+func (b *jwtAuthBackend) createAuth(roleName string, role *jwtRole, allClaims map[string]interface{}) (*logical.Auth, error) {
+	if err := validateBoundClaims(role.BoundClaims, allClaims); err != nil {
+		return nil, err
+	}
+
+	userName, ok := getClaim(allClaims, role.UserClaim).(string)
+	if !ok || userName == "" {
+		return nil, fmt.Errorf("claim %q not found in token", role.UserClaim)
+	}
+
+	metadata, err := extractMetadata(allClaims, role.ClaimMappings)
+	if err != nil {
+		return nil, err
+	}
+	metadata["role"] = roleName
+
+	var groupAliases []*logical.Alias
+	if role.GroupsClaim != "" {
+		groupsClaimRaw := getClaim(allClaims, role.GroupsClaim)
+		if groupsClaimRaw == nil {
+			return nil, fmt.Errorf("%q claim not found in token", role.GroupsClaim)
+		}
+		groups, err := claimValues(groupsClaimRaw)
+		if err != nil {
+			return nil, fmt.Errorf("%q claim could not be converted to a list of strings", role.GroupsClaim)
+		}
+		for _, groupName := range groups {
+			if groupName == "" {
+				continue
+			}
+			groupAliases = append(groupAliases, &logical.Alias{
+				Name: groupName,
+			})
+		}
+	}
+
+	auth := &logical.Auth{
+		DisplayName:  userName,
+		GroupAliases: groupAliases,
+		Alias: &logical.Alias{
+			Name:     userName,
+			Metadata: metadata,
+		},
+		InternalData: map[string]interface{}{
+			"role": roleName,
+		},
+		Metadata: metadata,
+		LeaseOptions: logical.LeaseOptions{
+			Renewable: true,
+		},
+	}
+	role.PopulateTokenAuth(auth)
+
+	return auth, nil
+}
+
+// validateAudience checks that the audience of a JWT contains one of the
+// bound audiences of the role, if any. In strict mode, a JWT with an audience
+// is rejected by a role without bound audiences.
+func validateAudience(boundAudiences, audience []string, strict bool) error {
+	if len(boundAudiences) == 0 {
+		if strict && len(audience) > 0 {
+			return errors.New("audience claim found in JWT but no audiences bound to the role")
+		}
+		return nil
+	}
+	for _, v := range boundAudiences {
+		for _, a := range audience {
+			if v == a {
+				return nil
+			}
+		}
+	}
+	return errors.New("aud claim does not match any bound audience")
+}
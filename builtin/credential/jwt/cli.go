@@ -0,0 +1,195 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	defaultMount         = "oidc"
+	defaultListenAddress = "localhost"
+	defaultPort          = "8250"
+
+	// callbackTimeout is how long the CLI waits for the OIDC provider to
+	// redirect the browser to the callback listener
+	callbackTimeout = 2 * time.Minute
+)
+
+// CLIHandler struct
+type CLIHandler struct{}
+
+type loginResp struct {
+	secret *api.Secret
+	err    error
+}
+
+// Auth cli method
+func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (*api.Secret, error) {
+	mount, ok := m["mount"]
+	if !ok {
+		mount = defaultMount
+	}
+
+	listenAddress, ok := m["listenaddress"]
+	if !ok {
+		listenAddress = defaultListenAddress
+	}
+
+	port, ok := m["port"]
+	if !ok {
+		port = defaultPort
+	}
+
+	role := m["role"]
+
+	redirectURI := fmt.Sprintf("http://%s:%s/oidc/callback", listenAddress, port)
+
+	secret, err := c.Logical().Write(fmt.Sprintf("auth/%s/oidc/auth_url", mount), map[string]interface{}{
+		"role":         role,
+		"redirect_uri": redirectURI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("empty response from credential provider")
+	}
+
+	authURL, ok := secret.Data["auth_url"].(string)
+	if !ok || authURL == "" {
+		return nil, fmt.Errorf("unable to authorize role %q", role)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(listenAddress, port))
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	doneCh := make(chan loginResp, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oidc/callback", callbackHandler(c, mount, doneCh))
+
+	fmt.Fprintf(os.Stderr, "Complete the login via your OIDC provider. Launching browser to:\n\n    %s\n\n", authURL)
+	if err := openURL(authURL); err != nil {
+		fmt.Fprintf(os.Stderr, "Error attempting to automatically open browser: '%s'.\nPlease visit the authorization URL manually.\n", err)
+	}
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil && err != http.ErrServerClosed {
+			doneCh <- loginResp{nil, err}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	select {
+	case s := <-doneCh:
+		return s.secret, s.err
+	case <-ctx.Done():
+		return nil, errors.New("timed out waiting for response from provider")
+	}
+}
+
+// callbackHandler completes the login with the code returned to the callback
+// listener
+func callbackHandler(c *api.Client, mount string, doneCh chan<- loginResp) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var response string
+
+		query := req.URL.Query()
+		secret, err := c.Logical().Write(fmt.Sprintf("auth/%s/oidc/callback", mount), map[string]interface{}{
+			"state": query.Get("state"),
+			"code":  query.Get("code"),
+		})
+		switch {
+		case err != nil:
+			response = fmt.Sprintf("Vault login failed.\n\n%s", err)
+		case secret == nil:
+			err = fmt.Errorf("empty response from credential provider")
+			response = fmt.Sprintf("Vault login failed.\n\n%s", err)
+		default:
+			response = "Vault login successful. You may now close this window."
+		}
+
+		w.Write([]byte(response))
+
+		// Only the first callback completes the login
+		select {
+		case doneCh <- loginResp{secret, err}:
+		default:
+		}
+	}
+}
+
+// openURL opens the URL in the default browser of the platform
+func openURL(url string) error {
+	var cmd string
+	var args []string
+
+	switch runtime.GOOS {
+	case "windows":
+		cmd = "rundll32"
+		args = []string{"url.dll,FileProtocolHandler", url}
+	case "darwin":
+		cmd = "open"
+		args = []string{url}
+	default:
+		cmd = "xdg-open"
+		args = []string{url}
+	}
+
+	return exec.Command(cmd, args...).Start()
+}
+
+// Help method for oidc cli
+func (h *CLIHandler) Help() string {
+	help := `
+Usage: vault login -method=oidc [CONFIG K=V...]
+
+  The OIDC auth method allows users to authenticate using an OIDC provider.
+  The provider must be configured as part of a role by the operator.
+
+  Authenticate using role "engineering":
+
+      $ vault login -method=oidc role=engineering
+      Complete the login via your OIDC provider. Launching browser to:
+
+          https://accounts.google.com/o/oauth2/v2/...
+
+  The default browser will be opened for the user to complete the login. A
+  local listener receives the response of the OIDC provider and completes the
+  login.
+
+Configuration:
+
+  role=<string>
+      Vault role of type "oidc" to use for authentication. If not provided,
+      the default role of the auth method is used.
+
+  mount=<string>
+      Path where the OIDC auth method is mounted. Defaults to "oidc".
+
+  listenaddress=<string>
+      Optional address to bind the OIDC callback listener to. Defaults to
+      "localhost".
+
+  port=<string>
+      Optional localhost port to use for the OIDC callback listener. Defaults
+      to "8250". The resulting redirect URI must be one of the allowed
+      redirect URIs of the role.
+`
+
+	return strings.TrimSpace(help)
+}
@@ -0,0 +1,83 @@
+package jwt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestConfig_Write(t *testing.T) {
+	b, s := getBackend(t)
+	_, pubPEM := generateKey(t)
+
+	cases := map[string]map[string]interface{}{
+		"no key source": {
+			"bound_issuer": "https://team-vault.auth0.com/",
+		},
+		"several key sources": {
+			"jwt_validation_pubkeys": pubPEM,
+			"jwks_url":               "https://team-vault.auth0.com/.well-known/jwks.json",
+		},
+		"invalid public key": {
+			"jwt_validation_pubkeys": "not a key",
+		},
+		"client id without discovery": {
+			"jwt_validation_pubkeys": pubPEM,
+			"oidc_client_id":         "abc",
+			"oidc_client_secret":     "def",
+		},
+		"invalid algorithm": {
+			"jwt_validation_pubkeys": pubPEM,
+			"jwt_supported_algs":     "HS256",
+		},
+		"invalid jwks ca": {
+			"jwks_url":    "https://team-vault.auth0.com/.well-known/jwks.json",
+			"jwks_ca_pem": "not a certificate",
+		},
+	}
+	for name, data := range cases {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      configPath,
+			Storage:   s,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error, got: %#v", name, resp)
+		}
+	}
+
+	writeConfig(t, b, s, map[string]interface{}{
+		"jwt_validation_pubkeys": pubPEM,
+		"bound_issuer":           "https://team-vault.auth0.com/",
+		"default_role":           "dev",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configPath,
+		Storage:   s,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["bound_issuer"] != "https://team-vault.auth0.com/" || resp.Data["default_role"] != "dev" {
+		t.Fatalf("bad config: %#v", resp.Data)
+	}
+	if keys := resp.Data["jwt_validation_pubkeys"].([]string); len(keys) != 1 || keys[0] != strings.TrimSpace(pubPEM) {
+		t.Fatalf("bad public keys: %#v", keys)
+	}
+
+	config, err := b.config(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.ParsedJWTPubKeys) != 1 || config.validationMode() != staticKeys {
+		t.Fatalf("bad parsed config: %#v", config)
+	}
+}
@@ -0,0 +1,230 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/cidrutil"
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func pathLogin(b *jwtAuthBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: `login$`,
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: "The role to log in against.",
+			},
+			"jwt": {
+				Type:        framework.TypeString,
+				Description: "The signed JWT to validate.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathLogin,
+		},
+
+		HelpSynopsis:    pathLoginHelpSyn,
+		HelpDescription: pathLoginHelpDesc,
+	}
+}
+
+func (b *jwtAuthBackend) pathLogin(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("could not load configuration"), nil
+	}
+
+	roleName := d.Get("role").(string)
+	if roleName == "" {
+		roleName = config.DefaultRole
+	}
+	if roleName == "" {
+		return logical.ErrorResponse("missing role"), nil
+	}
+
+	role, err := b.role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q could not be found", roleName)), nil
+	}
+	if role.RoleType == roleTypeOIDC {
+		return logical.ErrorResponse("role with oidc role_type is not allowed"), nil
+	}
+
+	token := d.Get("jwt").(string)
+	if len(token) == 0 {
+		return logical.ErrorResponse("missing token"), nil
+	}
+
+	if len(role.TokenBoundCIDRs) > 0 {
+		if req.Connection == nil {
+			b.Logger().Warn("token bound CIDRs found but no connection information available for validation")
+			return nil, logical.ErrPermissionDenied
+		}
+		if !cidrutil.RemoteAddrIsOk(req.Connection.RemoteAddr, role.TokenBoundCIDRs) {
+			return nil, logical.ErrPermissionDenied
+		}
+	}
+
+	allClaims, err := b.verifyJWT(ctx, config, role, token)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	auth, err := b.createAuth(roleName, role, allClaims)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return &logical.Response{
+		Auth: auth,
+	}, nil
+}
+
+// verifyJWT verifies the signature and the standard claims of a JWT, returning
+// all its claims
+func (b *jwtAuthBackend) verifyJWT(ctx context.Context, config *jwtConfig, role *jwtRole, token string) (map[string]interface{}, error) {
+	parsedJWT, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, errwrap.Wrapf("error parsing token: {{err}}", err)
+	}
+
+	supportedAlgs := config.JWTSupportedAlgs
+	if len(supportedAlgs) == 0 {
+		supportedAlgs = []string{oidc.RS256}
+	}
+	for _, header := range parsedJWT.Headers {
+		if !strutil.StrListContains(supportedAlgs, header.Algorithm) {
+			return nil, fmt.Errorf("token signed with unsupported algorithm %q", header.Algorithm)
+		}
+	}
+
+	claims := jwt.Claims{}
+	allClaims := make(map[string]interface{})
+
+	switch config.validationMode() {
+	case staticKeys:
+		valid := false
+		for _, key := range config.ParsedJWTPubKeys {
+			if err := parsedJWT.Claims(key, &claims, &allClaims); err == nil {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, errors.New("no known key successfully validated the token signature")
+		}
+
+	case jwksURL:
+		keySet, err := b.getKeySet(config)
+		if err != nil {
+			return nil, errwrap.Wrapf("error fetching jwks keyset: {{err}}", err)
+		}
+		payload, err := keySet.VerifySignature(ctx, token)
+		if err != nil {
+			return nil, errwrap.Wrapf("error verifying token signature: {{err}}", err)
+		}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &allClaims); err != nil {
+			return nil, err
+		}
+
+	case oidcDiscovery:
+		provider, err := b.getProvider(config)
+		if err != nil {
+			return nil, errwrap.Wrapf("error getting provider for login operation: {{err}}", err)
+		}
+		verifier := provider.Verifier(&oidc.Config{
+			SkipClientIDCheck:    true,
+			SupportedSigningAlgs: supportedAlgs,
+		})
+		idToken, err := verifier.Verify(ctx, token)
+		if err != nil {
+			return nil, errwrap.Wrapf("error validating signature: {{err}}", err)
+		}
+		if err := idToken.Claims(&claims); err != nil {
+			return nil, err
+		}
+		if err := idToken.Claims(&allClaims); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.New("unhandled case during login")
+	}
+
+	// Tokens without an expiration would be valid forever
+	if claims.Expiry == 0 {
+		return nil, errors.New("token has no expiration ('exp') claim")
+	}
+
+	expected := jwt.Expected{
+		Issuer:  config.BoundIssuer,
+		Subject: role.BoundSubject,
+		Time:    time.Now(),
+	}
+	if err := claims.ValidateWithLeeway(expected, role.ClockSkewLeeway); err != nil {
+		return nil, errwrap.Wrapf("error validating claims: {{err}}", err)
+	}
+
+	if err := validateAudience(role.BoundAudiences, claims.Audience, true); err != nil {
+		return nil, err
+	}
+
+	return allClaims, nil
+}
+
+func (b *jwtAuthBackend) pathLoginRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName, ok := req.Auth.InternalData["role"].(string)
+	if !ok || roleName == "" {
+		return nil, errors.New("failed to fetch role during renewal")
+	}
+
+	// Ensure that the Role still exists.
+	role, err := b.role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to validate role %q during renewal: {{err}}", roleName), err)
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %q does not exist during renewal", roleName)
+	}
+
+	if !policyutil.EquivalentPolicies(role.TokenPolicies, req.Auth.Policies) {
+		return nil, errors.New("policies on role have changed, not renewing")
+	}
+
+	resp := &logical.Response{Auth: req.Auth}
+	resp.Auth.TTL = role.TokenTTL
+	resp.Auth.MaxTTL = role.TokenMaxTTL
+	resp.Auth.Period = role.TokenPeriod
+	resp.Auth.ExplicitMaxTTL = role.TokenExplicitMaxTTL
+	return resp, nil
+}
+
+const (
+	pathLoginHelpSyn = `
+	Authenticates to Vault using a JWT (or OIDC) token.
+	`
+	pathLoginHelpDesc = `
+Authenticates JWTs.
+`
+)
@@ -0,0 +1,455 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/strutil"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func getBackend(t *testing.T) (*jwtAuthBackend, logical.Storage) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.(*jwtAuthBackend), config.StorageView
+}
+
+func generateKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	})
+	return priv, string(pubPEM)
+}
+
+func signToken(t *testing.T, key crypto.PrivateKey, claims ...interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := jwt.Signed(signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+	token, err := builder.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func defaultClaims() (jwt.Claims, map[string]interface{}) {
+	cl := jwt.Claims{
+		Subject:   "r3qXcK2bix9eFECzsU3Sbmh0K16fatW6@clients",
+		Issuer:    "https://team-vault.auth0.com/",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-5 * time.Second)),
+		Expiry:    jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+		Audience:  jwt.Audience{"https://vault.plugin.auth.jwt.test"},
+	}
+	privateCl := map[string]interface{}{
+		"https://vault/user":   "jeff",
+		"https://vault/groups": []string{"foo", "bar"},
+		"color":                "green",
+		"nested": map[string]interface{}{
+			"size": "medium",
+		},
+	}
+	return cl, privateCl
+}
+
+func writeConfig(t *testing.T, b *jwtAuthBackend, s logical.Storage, data map[string]interface{}) {
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   s,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+}
+
+func writeRole(t *testing.T, b *jwtAuthBackend, s logical.Storage, name string, data map[string]interface{}) {
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      rolePrefix + name,
+		Storage:   s,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+}
+
+func login(t *testing.T, b *jwtAuthBackend, s logical.Storage, role, token string) *logical.Response {
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"role": role,
+			"jwt":  token,
+		},
+		Connection: &logical.Connection{
+			RemoteAddr: "127.0.0.1",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("got nil response")
+	}
+	return resp
+}
+
+func setupStaticKeys(t *testing.T) (*jwtAuthBackend, logical.Storage, *ecdsa.PrivateKey) {
+	b, s := getBackend(t)
+	priv, pubPEM := generateKey(t)
+
+	writeConfig(t, b, s, map[string]interface{}{
+		"bound_issuer":           "https://team-vault.auth0.com/",
+		"jwt_validation_pubkeys": pubPEM,
+		"jwt_supported_algs":     "ES256",
+	})
+	writeRole(t, b, s, "plugin-test", map[string]interface{}{
+		"bound_audiences": "https://vault.plugin.auth.jwt.test",
+		"user_claim":      "https://vault/user",
+		"groups_claim":    "https://vault/groups",
+		"bound_claims": map[string]interface{}{
+			"color":        []interface{}{"green", "blue"},
+			"/nested/size": "medium",
+		},
+		"claim_mappings": map[string]interface{}{
+			"color": "favorite_color",
+		},
+		"policies":    "test",
+		"ttl":         "1h",
+		"max_ttl":     "2h",
+		"bound_cidrs": "127.0.0.0/8",
+	})
+
+	return b, s, priv
+}
+
+func TestLogin_StaticKeys(t *testing.T) {
+	b, s, priv := setupStaticKeys(t)
+
+	cl, privateCl := defaultClaims()
+	resp := login(t, b, s, "plugin-test", signToken(t, priv, cl, privateCl))
+	if resp.IsError() {
+		t.Fatalf("got error: %v", resp.Error())
+	}
+
+	auth := resp.Auth
+	switch {
+	case auth.Alias.Name != "jeff":
+		t.Fatalf("bad alias: %#v", auth.Alias)
+	case auth.DisplayName != "jeff":
+		t.Fatalf("bad display name: %s", auth.DisplayName)
+	case len(auth.Policies) != 1 || auth.Policies[0] != "test":
+		t.Fatalf("bad policies: %#v", auth.Policies)
+	case auth.TTL != time.Hour || auth.MaxTTL != 2*time.Hour:
+		t.Fatalf("bad ttls: %s %s", auth.TTL, auth.MaxTTL)
+	case auth.Metadata["role"] != "plugin-test" || auth.Metadata["favorite_color"] != "green":
+		t.Fatalf("bad metadata: %#v", auth.Metadata)
+	case len(auth.GroupAliases) != 2 || auth.GroupAliases[0].Name != "foo" || auth.GroupAliases[1].Name != "bar":
+		t.Fatalf("bad group aliases: %#v", auth.GroupAliases)
+	}
+}
+
+func TestLogin_StaticKeys_Failures(t *testing.T) {
+	b, s, priv := setupStaticKeys(t)
+	otherPriv, _ := generateKey(t)
+
+	cases := map[string]func(cl *jwt.Claims, privateCl map[string]interface{}) string{
+		"wrong key": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			return signToken(t, otherPriv, cl, privateCl)
+		},
+		"wrong issuer": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			cl.Issuer = "https://evil.example.com/"
+			return signToken(t, priv, cl, privateCl)
+		},
+		"wrong audience": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			cl.Audience = jwt.Audience{"https://other.test"}
+			return signToken(t, priv, cl, privateCl)
+		},
+		"expired": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			cl.Expiry = jwt.NewNumericDate(time.Now().Add(-5 * time.Minute))
+			return signToken(t, priv, cl, privateCl)
+		},
+		"no expiry": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			cl.Expiry = 0
+			return signToken(t, priv, cl, privateCl)
+		},
+		"bound claim mismatch": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			privateCl["color"] = "red"
+			return signToken(t, priv, cl, privateCl)
+		},
+		"nested bound claim missing": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			delete(privateCl, "nested")
+			return signToken(t, priv, cl, privateCl)
+		},
+		"missing user claim": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			delete(privateCl, "https://vault/user")
+			return signToken(t, priv, cl, privateCl)
+		},
+		"missing groups claim": func(cl *jwt.Claims, privateCl map[string]interface{}) string {
+			delete(privateCl, "https://vault/groups")
+			return signToken(t, priv, cl, privateCl)
+		},
+	}
+
+	for name, tc := range cases {
+		cl, privateCl := defaultClaims()
+		resp := login(t, b, s, "plugin-test", tc(&cl, privateCl))
+		if !resp.IsError() {
+			t.Fatalf("%s: expected error, got: %#v", name, resp)
+		}
+	}
+
+	// Logins from outside the bound CIDRs are denied
+	cl, privateCl := defaultClaims()
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"role": "plugin-test",
+			"jwt":  signToken(t, priv, cl, privateCl),
+		},
+		Connection: &logical.Connection{
+			RemoteAddr: "10.0.0.1",
+		},
+	})
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+
+	// Bound CIDRs don't bind the JWT
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      rolePrefix + "cidrs-only",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"user_claim":  "sub",
+			"bound_cidrs": "127.0.0.0/8",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v, %v", resp, err)
+	}
+
+	// Claims can't be mapped to the metadata key holding the role name
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      rolePrefix + "role-mapping",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"user_claim":      "sub",
+			"bound_audiences": "https://vault.plugin.auth.jwt.test",
+			"claim_mappings": map[string]interface{}{
+				"color": "role",
+			},
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v, %v", resp, err)
+	}
+}
+
+func TestLogin_DefaultRole(t *testing.T) {
+	b, s := getBackend(t)
+	priv, pubPEM := generateKey(t)
+
+	writeConfig(t, b, s, map[string]interface{}{
+		"jwt_validation_pubkeys": pubPEM,
+		"jwt_supported_algs":     "ES256",
+		"default_role":           "default",
+	})
+	writeRole(t, b, s, "default", map[string]interface{}{
+		"bound_subject": "r3qXcK2bix9eFECzsU3Sbmh0K16fatW6@clients",
+		"user_claim":    "sub",
+	})
+
+	// Without bound audiences, JWTs with an audience are rejected
+	cl, privateCl := defaultClaims()
+	resp := login(t, b, s, "", signToken(t, priv, cl, privateCl))
+	if !resp.IsError() || !strings.Contains(resp.Error().Error(), "no audiences bound to the role") {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	cl.Audience = nil
+	resp = login(t, b, s, "", signToken(t, priv, cl, privateCl))
+	if resp.IsError() {
+		t.Fatalf("got error: %v", resp.Error())
+	}
+	if resp.Auth.Alias.Name != cl.Subject || resp.Auth.Metadata["role"] != "default" {
+		t.Fatalf("bad auth: %#v", resp.Auth)
+	}
+}
+
+func TestLogin_JWKS(t *testing.T) {
+	priv, _ := generateKey(t)
+	jwks := jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				Key:       &priv.PublicKey,
+				Algorithm: string(jose.ES256),
+				Use:       "sig",
+			},
+		},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer ts.Close()
+
+	b, s := getBackend(t)
+	writeConfig(t, b, s, map[string]interface{}{
+		"jwks_url":           ts.URL,
+		"jwt_supported_algs": "ES256",
+	})
+	writeRole(t, b, s, "plugin-test", map[string]interface{}{
+		"bound_audiences": "https://vault.plugin.auth.jwt.test",
+		"user_claim":      "https://vault/user",
+	})
+
+	cl, privateCl := defaultClaims()
+	resp := login(t, b, s, "plugin-test", signToken(t, priv, cl, privateCl))
+	if resp.IsError() {
+		t.Fatalf("got error: %v", resp.Error())
+	}
+	if resp.Auth.Alias.Name != "jeff" {
+		t.Fatalf("bad alias: %#v", resp.Auth.Alias)
+	}
+
+	otherPriv, _ := generateKey(t)
+	resp = login(t, b, s, "plugin-test", signToken(t, otherPriv, cl, privateCl))
+	if !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
+
+func TestLogin_Renew(t *testing.T) {
+	b, s, priv := setupStaticKeys(t)
+
+	cl, privateCl := defaultClaims()
+	resp := login(t, b, s, "plugin-test", signToken(t, priv, cl, privateCl))
+	if resp.IsError() {
+		t.Fatalf("got error: %v", resp.Error())
+	}
+
+	req := &logical.Request{
+		Operation: logical.RenewOperation,
+		Path:      "login",
+		Storage:   s,
+		Auth:      resp.Auth,
+	}
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth.TTL != time.Hour {
+		t.Fatalf("bad ttl: %s", resp.Auth.TTL)
+	}
+
+	// Renewals fail once the policies of the role have changed
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      rolePrefix + "plugin-test",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"policies": "other",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	_, err = b.HandleRequest(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "policies on role have changed") {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
+
+func TestLogin_RenewToken(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"jwt": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	if err := client.Sys().EnableAuthWithOptions("jwt", &api.EnableAuthOptions{Type: "jwt"}); err != nil {
+		t.Fatal(err)
+	}
+	priv, pubPEM := generateKey(t)
+	if _, err := client.Logical().Write("auth/jwt/config", map[string]interface{}{
+		"jwt_validation_pubkeys": pubPEM,
+		"jwt_supported_algs":     "ES256",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("auth/jwt/role/test", map[string]interface{}{
+		"bound_audiences": "https://vault.plugin.auth.jwt.test",
+		"user_claim":      "https://vault/user",
+		"policies":        "test",
+		"ttl":             "1h",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cl, privateCl := defaultClaims()
+	secret, err := client.Logical().Write("auth/jwt/login", map[string]interface{}{
+		"role": "test",
+		"jwt":  signToken(t, priv, cl, privateCl),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token store adds the default policy, which doesn't prevent renewals
+	client.SetToken(secret.Auth.ClientToken)
+	secret, err = client.Auth().Token().RenewSelf(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strutil.StrListContains(secret.Auth.Policies, "default") || secret.Auth.LeaseDuration != 3600 {
+		t.Fatalf("bad: %#v", secret.Auth)
+	}
+}
@@ -0,0 +1,260 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/oauth2"
+)
+
+// oidcState is a pending OIDC authentication request, keyed by its state
+type oidcState struct {
+	roleName    string
+	nonce       string
+	redirectURI string
+}
+
+func pathOIDC(b *jwtAuthBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: `oidc/auth_url`,
+			Fields: map[string]*framework.FieldSchema{
+				"role": {
+					Type:        framework.TypeString,
+					Description: "The role to issue an OIDC authorization URL against.",
+				},
+				"redirect_uri": {
+					Type:        framework.TypeString,
+					Description: "The OAuth redirect_uri to use in the authorization URL.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.pathOIDCAuthURL,
+			},
+
+			HelpSynopsis:    oidcHelp["auth_url"][0],
+			HelpDescription: oidcHelp["auth_url"][1],
+		},
+		{
+			Pattern: `oidc/callback`,
+			Fields: map[string]*framework.FieldSchema{
+				"state": {
+					Type:        framework.TypeString,
+					Description: "The state returned by the OIDC provider.",
+				},
+				"code": {
+					Type:        framework.TypeString,
+					Description: "The authorization code returned by the OIDC provider.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.pathOIDCCallback,
+				logical.UpdateOperation: b.pathOIDCCallback,
+			},
+
+			HelpSynopsis:    oidcHelp["callback"][0],
+			HelpDescription: oidcHelp["callback"][1],
+		},
+	}
+}
+
+func (b *jwtAuthBackend) pathOIDCAuthURL(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("could not load configuration"), nil
+	}
+	if config.OIDCClientID == "" {
+		return logical.ErrorResponse("the OIDC login flow is not configured"), nil
+	}
+
+	roleName := d.Get("role").(string)
+	if roleName == "" {
+		roleName = config.DefaultRole
+	}
+	if roleName == "" {
+		return logical.ErrorResponse("missing role"), nil
+	}
+
+	role, err := b.role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q could not be found", roleName)), nil
+	}
+	if role.RoleType != roleTypeOIDC {
+		return logical.ErrorResponse(fmt.Sprintf("role %q is not an oidc role", roleName)), nil
+	}
+
+	redirectURI := d.Get("redirect_uri").(string)
+	if redirectURI == "" {
+		return logical.ErrorResponse("missing redirect_uri"), nil
+	}
+	if !strutil.StrListContains(role.AllowedRedirectURIs, redirectURI) {
+		return logical.ErrorResponse(fmt.Sprintf("unauthorized redirect_uri: %s", redirectURI)), nil
+	}
+
+	provider, err := b.getProvider(config)
+	if err != nil {
+		return nil, errwrap.Wrapf("error getting provider for the OIDC login flow: {{err}}", err)
+	}
+
+	state, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	b.oidcStates.SetDefault(state, &oidcState{
+		roleName:    roleName,
+		nonce:       nonce,
+		redirectURI: redirectURI,
+	})
+
+	oauth2Config := oauth2.Config{
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  redirectURI,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, role.OIDCScopes...),
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"auth_url": oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce)),
+		},
+	}, nil
+}
+
+func (b *jwtAuthBackend) pathOIDCCallback(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("could not load configuration"), nil
+	}
+
+	stateID := d.Get("state").(string)
+	stateRaw, ok := b.oidcStates.Get(stateID)
+	if !ok {
+		return logical.ErrorResponse("expired or missing OAuth state"), nil
+	}
+	// A state can only be used once
+	b.oidcStates.Delete(stateID)
+	state := stateRaw.(*oidcState)
+
+	code := d.Get("code").(string)
+	if code == "" {
+		return logical.ErrorResponse("missing code"), nil
+	}
+
+	role, err := b.role(ctx, req.Storage, state.roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q could not be found", state.roleName)), nil
+	}
+
+	provider, err := b.getProvider(config)
+	if err != nil {
+		return nil, errwrap.Wrapf("error getting provider for the OIDC login flow: {{err}}", err)
+	}
+
+	oidcCtx, err := b.createCAContext(ctx, config.OIDCDiscoveryCAPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	oauth2Config := oauth2.Config{
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  state.redirectURI,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, role.OIDCScopes...),
+	}
+
+	oauth2Token, err := oauth2Config.Exchange(oidcCtx, code)
+	if err != nil {
+		return logical.ErrorResponse(errwrap.Wrapf("error exchanging oidc code: {{err}}", err).Error()), nil
+	}
+
+	rawToken, ok := oauth2Token.Extra("id_token").(string)
+	if !ok {
+		return logical.ErrorResponse("no id_token found in response"), nil
+	}
+
+	supportedAlgs := config.JWTSupportedAlgs
+	if len(supportedAlgs) == 0 {
+		supportedAlgs = []string{oidc.RS256}
+	}
+	verifier := provider.Verifier(&oidc.Config{
+		ClientID:             config.OIDCClientID,
+		SupportedSigningAlgs: supportedAlgs,
+	})
+	idToken, err := verifier.Verify(oidcCtx, rawToken)
+	if err != nil {
+		return logical.ErrorResponse(errwrap.Wrapf("error validating id_token: {{err}}", err).Error()), nil
+	}
+
+	if idToken.Nonce != state.nonce {
+		return logical.ErrorResponse("invalid ID token nonce"), nil
+	}
+
+	// The audience of the ID token was checked against the client ID by the
+	// verifier
+	if err := validateAudience(role.BoundAudiences, idToken.Audience, false); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if role.BoundSubject != "" && idToken.Subject != role.BoundSubject {
+		return logical.ErrorResponse("sub claim does not match bound subject"), nil
+	}
+
+	allClaims := make(map[string]interface{})
+	if err := idToken.Claims(&allClaims); err != nil {
+		return nil, err
+	}
+
+	auth, err := b.createAuth(state.roleName, role, allClaims)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return &logical.Response{
+		Auth: auth,
+	}, nil
+}
+
+var oidcHelp = map[string][2]string{
+	"auth_url": {
+		"Request an authorization URL to start the OIDC login flow.",
+		`
+Returns the URL of the OIDC provider to which the user must be redirected to
+authenticate. The role must be an oidc role and the redirect_uri one of its
+allowed redirect URIs.
+`,
+	},
+	"callback": {
+		"Callback endpoint completing the OIDC login flow.",
+		`
+Exchanges the authorization code returned by the OIDC provider for an ID token,
+validates it against the role of the request and returns a Vault token.
+`,
+	},
+}
@@ -0,0 +1,271 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// oidcProvider is a minimal OIDC provider issuing ID tokens for a single
+// authorization code
+type oidcProvider struct {
+	server   *httptest.Server
+	priv     *ecdsa.PrivateKey
+	clientID string
+	code     string
+	nonce    string
+	claims   map[string]interface{}
+}
+
+func newOIDCProvider(t *testing.T) *oidcProvider {
+	priv, _ := generateKey(t)
+	p := &oidcProvider{
+		priv:     priv,
+		clientID: "abc",
+		code:     "deadbeef",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/auth",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/certs",
+		})
+	})
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{
+				{
+					Key:       &priv.PublicKey,
+					Algorithm: string(jose.ES256),
+					Use:       "sig",
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != p.code {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		cl := jwt.Claims{
+			Issuer:   p.server.URL,
+			Subject:  "r3qXcK2bix9eFECzsU3Sbmh0K16fatW6@clients",
+			Audience: jwt.Audience{p.clientID},
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			Expiry:   jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+		}
+		privateCl := map[string]interface{}{
+			"nonce": p.nonce,
+		}
+		for k, v := range p.claims {
+			privateCl[k] = v
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signToken(t, priv, cl, privateCl),
+		})
+	})
+	p.server = httptest.NewServer(mux)
+
+	return p
+}
+
+func setupOIDC(t *testing.T) (*jwtAuthBackend, logical.Storage, *oidcProvider) {
+	p := newOIDCProvider(t)
+	b, s := getBackend(t)
+
+	writeConfig(t, b, s, map[string]interface{}{
+		"oidc_discovery_url": p.server.URL,
+		"oidc_client_id":     p.clientID,
+		"oidc_client_secret": "def",
+		"jwt_supported_algs": "ES256",
+	})
+	writeRole(t, b, s, "test", map[string]interface{}{
+		"role_type":             "oidc",
+		"user_claim":            "email",
+		"groups_claim":          "groups",
+		"oidc_scopes":           "email,profile",
+		"allowed_redirect_uris": "http://localhost:8250/oidc/callback",
+		"bound_claims": map[string]interface{}{
+			"team": "vault",
+		},
+	})
+
+	return b, s, p
+}
+
+func authURL(t *testing.T, b *jwtAuthBackend, s logical.Storage, data map[string]interface{}) *logical.Response {
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "oidc/auth_url",
+		Storage:   s,
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("got nil response")
+	}
+	return resp
+}
+
+func callback(t *testing.T, b *jwtAuthBackend, s logical.Storage, state, code string) *logical.Response {
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/callback",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"state": state,
+			"code":  code,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("got nil response")
+	}
+	return resp
+}
+
+func TestOIDC_AuthURL(t *testing.T) {
+	b, s, p := setupOIDC(t)
+	defer p.server.Close()
+
+	resp := authURL(t, b, s, map[string]interface{}{
+		"role":         "test",
+		"redirect_uri": "http://localhost:8250/oidc/callback",
+	})
+	if resp.IsError() {
+		t.Fatalf("got error: %v", resp.Error())
+	}
+
+	u, err := url.Parse(resp.Data["auth_url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	switch {
+	case u.Path != "/auth":
+		t.Fatalf("bad path: %s", u.Path)
+	case q.Get("client_id") != p.clientID:
+		t.Fatalf("bad client_id: %s", q.Get("client_id"))
+	case q.Get("redirect_uri") != "http://localhost:8250/oidc/callback":
+		t.Fatalf("bad redirect_uri: %s", q.Get("redirect_uri"))
+	case q.Get("scope") != "openid email profile":
+		t.Fatalf("bad scope: %s", q.Get("scope"))
+	case q.Get("state") == "" || q.Get("nonce") == "":
+		t.Fatalf("missing state or nonce: %s", u)
+	}
+
+	cases := map[string]map[string]interface{}{
+		"unknown role": {
+			"role":         "other",
+			"redirect_uri": "http://localhost:8250/oidc/callback",
+		},
+		"unauthorized redirect_uri": {
+			"role":         "test",
+			"redirect_uri": "http://evil.example.com/oidc/callback",
+		},
+	}
+	for name, data := range cases {
+		if resp := authURL(t, b, s, data); !resp.IsError() {
+			t.Fatalf("%s: expected error, got: %#v", name, resp)
+		}
+	}
+
+	// jwt roles cannot use the OIDC login flow
+	writeRole(t, b, s, "jwt", map[string]interface{}{
+		"user_claim":    "sub",
+		"bound_subject": "foo",
+	})
+	resp = authURL(t, b, s, map[string]interface{}{
+		"role":         "jwt",
+		"redirect_uri": "http://localhost:8250/oidc/callback",
+	})
+	if !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
+
+func TestOIDC_Callback(t *testing.T) {
+	b, s, p := setupOIDC(t)
+	defer p.server.Close()
+
+	startLogin := func() string {
+		resp := authURL(t, b, s, map[string]interface{}{
+			"role":         "test",
+			"redirect_uri": "http://localhost:8250/oidc/callback",
+		})
+		if resp.IsError() {
+			t.Fatalf("got error: %v", resp.Error())
+		}
+		u, err := url.Parse(resp.Data["auth_url"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.nonce = u.Query().Get("nonce")
+		return u.Query().Get("state")
+	}
+
+	p.claims = map[string]interface{}{
+		"email":  "bob@example.com",
+		"groups": []string{"a", "b"},
+		"team":   "vault",
+	}
+
+	state := startLogin()
+	resp := callback(t, b, s, state, p.code)
+	if resp.IsError() {
+		t.Fatalf("got error: %v", resp.Error())
+	}
+	auth := resp.Auth
+	if auth.Alias.Name != "bob@example.com" || auth.Metadata["role"] != "test" {
+		t.Fatalf("bad auth: %#v", auth)
+	}
+	if len(auth.GroupAliases) != 2 || auth.GroupAliases[0].Name != "a" || auth.GroupAliases[1].Name != "b" {
+		t.Fatalf("bad group aliases: %#v", auth.GroupAliases)
+	}
+
+	// A state can only be used once
+	if resp := callback(t, b, s, state, p.code); !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// Invalid code
+	if resp := callback(t, b, s, startLogin(), "badcode"); !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// Nonce mismatch
+	state = startLogin()
+	p.nonce = "other"
+	if resp := callback(t, b, s, state, p.code); !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// Bound claims mismatch
+	p.claims["team"] = "other"
+	if resp := callback(t, b, s, startLogin(), p.code); !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
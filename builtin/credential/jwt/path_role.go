@@ -0,0 +1,392 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+var reservedMetadata = []string{"role"}
+
+const (
+	claimDefaultLeeway = 60
+
+	roleTypeJWT  = "jwt"
+	roleTypeOIDC = "oidc"
+)
+
+func pathRoleList(b *jwtAuthBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/?",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+		HelpSynopsis:    strings.TrimSpace(roleHelp["role-list"][0]),
+		HelpDescription: strings.TrimSpace(roleHelp["role-list"][1]),
+	}
+}
+
+// roleTokenFieldAliases are the deprecated role fields aliasing token fields
+var roleTokenFieldAliases = map[string]string{
+	"policies":    "token_policies",
+	"num_uses":    "token_num_uses",
+	"ttl":         "token_ttl",
+	"max_ttl":     "token_max_ttl",
+	"period":      "token_period",
+	"bound_cidrs": "token_bound_cidrs",
+}
+
+// pathRole returns the path configurations for the CRUD operations on roles
+func pathRole(b *jwtAuthBackend) *framework.Path {
+	p := &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+			"role_type": {
+				Type:        framework.TypeString,
+				Default:     roleTypeJWT,
+				Description: `Type of the role, either "jwt" or "oidc". "jwt" roles log in with a JWT at the login endpoint, "oidc" roles with the OIDC authorization code flow.`,
+			},
+			"policies": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Deprecated: use "token_policies" instead.`,
+			},
+			"num_uses": {
+				Type:        framework.TypeInt,
+				Description: `Deprecated: use "token_num_uses" instead.`,
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Deprecated: use "token_ttl" instead.`,
+			},
+			"max_ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Deprecated: use "token_max_ttl" instead.`,
+			},
+			"period": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Deprecated: use "token_period" instead.`,
+			},
+			"bound_cidrs": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Deprecated: use "token_bound_cidrs" instead.`,
+			},
+			"bound_audiences": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Comma-separated list of 'aud' claims that are valid for login; any match is sufficient`,
+			},
+			"bound_subject": {
+				Type:        framework.TypeString,
+				Description: `The 'sub' claim that is valid for login. Optional.`,
+			},
+			"bound_claims": {
+				Type:        framework.TypeMap,
+				Description: `Map of claims and their expected values, either a single value or a list of values any of which is accepted. Claims nested in objects are named with JSON pointers, for example "/a/b".`,
+			},
+			"claim_mappings": {
+				Type:        framework.TypeKVPairs,
+				Description: `Mappings of claims (key) that will be copied to a metadata field (value)`,
+			},
+			"user_claim": {
+				Type:        framework.TypeString,
+				Description: `The claim to use for the Identity entity alias name`,
+			},
+			"groups_claim": {
+				Type:        framework.TypeString,
+				Description: `The claim to use for the Identity group alias names`,
+			},
+			"oidc_scopes": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Comma-separated list of OIDC scopes requested in addition to "openid"`,
+			},
+			"allowed_redirect_uris": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Comma-separated list of allowed values for redirect_uri`,
+			},
+			"clock_skew_leeway": {
+				Type:        framework.TypeDurationSecond,
+				Default:     claimDefaultLeeway,
+				Description: `Duration in seconds of leeway when validating the time claims of a JWT to account for clock skew.`,
+			},
+		},
+		ExistenceCheck: b.pathRoleExistenceCheck,
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.CreateOperation: b.pathRoleCreateUpdate,
+			logical.UpdateOperation: b.pathRoleCreateUpdate,
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+		HelpSynopsis:    strings.TrimSpace(roleHelp["role"][0]),
+		HelpDescription: strings.TrimSpace(roleHelp["role"][1]),
+	}
+
+	framework.AddTokenFields(p.Fields)
+	return p
+}
+
+type jwtRole struct {
+	framework.TokenParams
+
+	RoleType string `json:"role_type"`
+
+	// Role binding properties
+	BoundAudiences []string               `json:"bound_audiences"`
+	BoundSubject   string                 `json:"bound_subject"`
+	BoundClaims    map[string]interface{} `json:"bound_claims"`
+	ClaimMappings  map[string]string      `json:"claim_mappings"`
+	UserClaim      string                 `json:"user_claim"`
+	GroupsClaim    string                 `json:"groups_claim"`
+
+	// OIDC login properties
+	OIDCScopes          []string `json:"oidc_scopes"`
+	AllowedRedirectURIs []string `json:"allowed_redirect_uris"`
+
+	ClockSkewLeeway time.Duration `json:"clock_skew_leeway"`
+}
+
+// role takes a storage backend and the name and returns the role's storage
+// entry
+func (b *jwtAuthBackend) role(ctx context.Context, s logical.Storage, name string) (*jwtRole, error) {
+	raw, err := s.Get(ctx, rolePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	role := new(jwtRole)
+	if err := raw.DecodeJSON(role); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// pathRoleExistenceCheck returns whether the role with the given name exists or not.
+func (b *jwtAuthBackend) pathRoleExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	role, err := b.role(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return role != nil, nil
+}
+
+// pathRoleList is used to list all the Roles registered with the backend.
+func (b *jwtAuthBackend) pathRoleList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roles, err := req.Storage.List(ctx, rolePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(roles), nil
+}
+
+// pathRoleRead grabs a read lock and reads the options set on the role from the storage
+func (b *jwtAuthBackend) pathRoleRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	role, err := b.role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	// Create a map of data to be returned
+	d := map[string]interface{}{
+		"role_type":             role.RoleType,
+		"policies":              role.TokenPolicies,
+		"num_uses":              role.TokenNumUses,
+		"period":                int64(role.TokenPeriod.Seconds()),
+		"ttl":                   int64(role.TokenTTL.Seconds()),
+		"max_ttl":               int64(role.TokenMaxTTL.Seconds()),
+		"bound_audiences":       role.BoundAudiences,
+		"bound_subject":         role.BoundSubject,
+		"bound_claims":          role.BoundClaims,
+		"claim_mappings":        role.ClaimMappings,
+		"user_claim":            role.UserClaim,
+		"groups_claim":          role.GroupsClaim,
+		"oidc_scopes":           role.OIDCScopes,
+		"allowed_redirect_uris": role.AllowedRedirectURIs,
+		"clock_skew_leeway":     int64(role.ClockSkewLeeway.Seconds()),
+	}
+
+	cidrs := make([]string, len(role.TokenBoundCIDRs))
+	for i, cidr := range role.TokenBoundCIDRs {
+		cidrs[i] = cidr.String()
+	}
+	d["bound_cidrs"] = cidrs
+	role.PopulateTokenData(d)
+
+	return &logical.Response{
+		Data: d,
+	}, nil
+}
+
+// pathRoleDelete removes the role from storage
+func (b *jwtAuthBackend) pathRoleDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("role name required"), nil
+	}
+
+	// Delete the role itself
+	if err := req.Storage.Delete(ctx, rolePrefix+roleName); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// pathRoleCreateUpdate registers a new role with the backend or updates the options
+// of an existing role
+func (b *jwtAuthBackend) pathRoleCreateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role name"), nil
+	}
+
+	// Check if the role already exists
+	role, err := b.role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a new entry object if this is a CreateOperation
+	if role == nil {
+		if req.Operation == logical.UpdateOperation {
+			return nil, errors.New("role entry not found during update operation")
+		}
+		role = &jwtRole{
+			RoleType:        data.Get("role_type").(string),
+			ClockSkewLeeway: time.Duration(data.Get("clock_skew_leeway").(int)) * time.Second,
+		}
+	}
+
+	if roleTypeRaw, ok := data.GetOk("role_type"); ok {
+		role.RoleType = roleTypeRaw.(string)
+	}
+	switch role.RoleType {
+	case roleTypeJWT, roleTypeOIDC:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("invalid 'role_type': %s", role.RoleType)), nil
+	}
+
+	if err := role.ParseTokenFieldAliases(data, roleTokenFieldAliases); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if err := role.ParseTokenFields(req, data); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if boundAudiences, ok := data.GetOk("bound_audiences"); ok {
+		role.BoundAudiences = boundAudiences.([]string)
+	}
+
+	if boundSubject, ok := data.GetOk("bound_subject"); ok {
+		role.BoundSubject = boundSubject.(string)
+	}
+
+	if boundClaims, ok := data.GetOk("bound_claims"); ok {
+		role.BoundClaims = boundClaims.(map[string]interface{})
+		for claim, value := range role.BoundClaims {
+			if _, err := claimValues(value); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid value of bound claim %q: %s", claim, err)), nil
+			}
+		}
+	}
+
+	if claimMappings, ok := data.GetOk("claim_mappings"); ok {
+		role.ClaimMappings = claimMappings.(map[string]string)
+
+		targets := make(map[string]bool)
+		for _, metadataKey := range role.ClaimMappings {
+			for _, reserved := range reservedMetadata {
+				if metadataKey == reserved {
+					return logical.ErrorResponse(fmt.Sprintf("metadata key %q is reserved and may not be a mapping destination", reserved)), nil
+				}
+			}
+			if targets[metadataKey] {
+				return logical.ErrorResponse(fmt.Sprintf("multiple keys are mapped to metadata key %q", metadataKey)), nil
+			}
+			targets[metadataKey] = true
+		}
+	}
+
+	if userClaim, ok := data.GetOk("user_claim"); ok {
+		role.UserClaim = userClaim.(string)
+	}
+	if role.UserClaim == "" {
+		return logical.ErrorResponse("a user claim must be defined on the role"), nil
+	}
+
+	if groupsClaim, ok := data.GetOk("groups_claim"); ok {
+		role.GroupsClaim = groupsClaim.(string)
+	}
+
+	if oidcScopes, ok := data.GetOk("oidc_scopes"); ok {
+		role.OIDCScopes = oidcScopes.([]string)
+	}
+
+	if allowedRedirectURIs, ok := data.GetOk("allowed_redirect_uris"); ok {
+		role.AllowedRedirectURIs = allowedRedirectURIs.([]string)
+	}
+
+	if clockSkewLeeway, ok := data.GetOk("clock_skew_leeway"); ok {
+		role.ClockSkewLeeway = time.Duration(clockSkewLeeway.(int)) * time.Second
+	}
+
+	switch role.RoleType {
+	case roleTypeJWT:
+		if len(role.BoundAudiences) == 0 && role.BoundSubject == "" && len(role.BoundClaims) == 0 {
+			return logical.ErrorResponse("must have at least one bound constraint when creating/updating a jwt role"), nil
+		}
+	case roleTypeOIDC:
+		if len(role.AllowedRedirectURIs) == 0 {
+			return logical.ErrorResponse("'allowed_redirect_uris' must be set for oidc roles"), nil
+		}
+	}
+
+	var resp *logical.Response
+	if role.TokenMaxTTL > b.System().MaxLeaseTTL() {
+		resp = &logical.Response{}
+		resp.AddWarning("max_ttl is greater than the system or backend mount's maximum TTL value; issued tokens' max TTL value will be truncated")
+	}
+
+	// Store the entry.
+	entry, err := logical.StorageEntryJSON(rolePrefix+roleName, role)
+	if err != nil {
+		return nil, err
+	}
+	if err = req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+var roleHelp = map[string][2]string{
+	"role-list": {
+		"Lists all the roles registered with the backend.",
+		"The list will contain the names of the roles.",
+	},
+	"role": {
+		"Register an role with the backend.",
+		`A role is required to authenticate with this backend. The role binds
+		JWT token information with token policies and settings.
+		The bindings, token polices and token settings can all be configured
+		using this endpoint`,
+	},
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func pathConfig(b *backend) *framework.Path {
-	return &framework.Path{
+	p := &framework.Path{
 		Pattern: `config`,
 		Fields:  ldaputil.ConfigFields(),
 
@@ -22,12 +22,22 @@ func pathConfig(b *backend) *framework.Path {
 		HelpSynopsis:    pathConfigHelpSyn,
 		HelpDescription: pathConfigHelpDesc,
 	}
+
+	framework.AddTokenFields(p.Fields)
+	return p
+}
+
+// ldapConfigEntry is the LDAP configuration along with the parameters of the
+// tokens issued by the backend
+type ldapConfigEntry struct {
+	framework.TokenParams
+	*ldaputil.ConfigEntry
 }
 
 /*
  * Construct ConfigEntry struct using stored configuration.
  */
-func (b *backend) Config(ctx context.Context, req *logical.Request) (*ldaputil.ConfigEntry, error) {
+func (b *backend) Config(ctx context.Context, req *logical.Request) (*ldapConfigEntry, error) {
 	// Schema for ConfigEntry
 	fd, err := b.getConfigFieldData()
 	if err != nil {
@@ -35,10 +45,13 @@ func (b *backend) Config(ctx context.Context, req *logical.Request) (*ldaputil.C
 	}
 
 	// Create a new ConfigEntry, filling in defaults where appropriate
-	result, err := ldaputil.NewConfigEntry(fd)
+	cfg, err := ldaputil.NewConfigEntry(fd)
 	if err != nil {
 		return nil, err
 	}
+	result := &ldapConfigEntry{
+		ConfigEntry: cfg,
+	}
 
 	storedConfig, err := req.Storage.Get(ctx, "config")
 	if err != nil {
@@ -89,18 +102,28 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *f
 		return nil, nil
 	}
 
+	data := cfg.PasswordlessMap()
+	cfg.PopulateTokenData(data)
+
 	resp := &logical.Response{
-		Data: cfg.PasswordlessMap(),
+		Data: data,
 	}
 	return resp, nil
 }
 
 func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	// Build a ConfigEntry struct out of the supplied FieldData
-	cfg, err := ldaputil.NewConfigEntry(d)
+	ldapCfg, err := ldaputil.NewConfigEntry(d)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
 	}
+	cfg := &ldapConfigEntry{
+		ConfigEntry: ldapCfg,
+	}
+
+	if err := cfg.ParseTokenFields(req, d); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
 
 	// On write, if not specified, use false. We do this here so upgrade logic
 	// works since it calls the same newConfigEntry function
@@ -6,6 +6,7 @@ import (
 	"sort"
 
 	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -68,10 +69,12 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		resp = &logical.Response{}
 	}
 
-	sort.Strings(policies)
+	cfg, err := b.Config(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
-	resp.Auth = &logical.Auth{
-		Policies: policies,
+	auth := &logical.Auth{
 		Metadata: map[string]string{
 			"username": username,
 		},
@@ -86,6 +89,13 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 			Name: username,
 		},
 	}
+	cfg.PopulateTokenAuth(auth)
+
+	// Add the policies of the groups and the user to the configured ones
+	auth.Policies = append(auth.Policies, policies...)
+	auth.Policies = strutil.RemoveDuplicates(auth.Policies, true)
+	sort.Strings(auth.Policies)
+	resp.Auth = auth
 
 	for _, groupName := range groupNames {
 		if groupName == "" {
@@ -107,11 +117,20 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 		return resp, err
 	}
 
-	if !policyutil.EquivalentPolicies(loginPolicies, req.Auth.Policies) {
+	cfg, err := b.Config(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !policyutil.EquivalentPolicies(append(cfg.TokenPolicies, loginPolicies...), req.Auth.Policies) {
 		return nil, fmt.Errorf("policies have changed, not renewing")
 	}
 
 	resp.Auth = req.Auth
+	resp.Auth.TTL = cfg.TokenTTL
+	resp.Auth.MaxTTL = cfg.TokenMaxTTL
+	resp.Auth.Period = cfg.TokenPeriod
+	resp.Auth.ExplicitMaxTTL = cfg.TokenExplicitMaxTTL
 
 	// Remove old aliases
 	resp.Auth.GroupAliases = nil
@@ -18,8 +18,15 @@ const (
 	previewBaseURL = "oktapreview.com"
 )
 
+// configTokenFieldAliases are the deprecated configuration fields aliasing
+// token fields
+var configTokenFieldAliases = map[string]string{
+	"ttl":     "token_ttl",
+	"max_ttl": "token_max_ttl",
+}
+
 func pathConfig(b *backend) *framework.Path {
-	return &framework.Path{
+	p := &framework.Path{
 		Pattern: `config`,
 		Fields: map[string]*framework.FieldSchema{
 			"organization": &framework.FieldSchema{
@@ -48,11 +55,11 @@ func pathConfig(b *backend) *framework.Path {
 			},
 			"ttl": &framework.FieldSchema{
 				Type:        framework.TypeDurationSecond,
-				Description: `Duration after which authentication will be expired`,
+				Description: `Deprecated: use "token_ttl" instead.`,
 			},
 			"max_ttl": &framework.FieldSchema{
 				Type:        framework.TypeDurationSecond,
-				Description: `Maximum duration after which authentication will be expired`,
+				Description: `Deprecated: use "token_max_ttl" instead.`,
 			},
 			"bypass_okta_mfa": &framework.FieldSchema{
 				Type:        framework.TypeBool,
@@ -70,6 +77,9 @@ func pathConfig(b *backend) *framework.Path {
 
 		HelpSynopsis: pathConfigHelp,
 	}
+
+	framework.AddTokenFields(p.Fields)
+	return p
 }
 
 // Config returns the configuration for this backend.
@@ -89,6 +99,15 @@ func (b *backend) Config(ctx context.Context, s logical.Storage) (*ConfigEntry,
 		}
 	}
 
+	// Upgrade the deprecated token parameters
+	if result.TokenTTL == 0 && result.TTL > 0 {
+		result.TokenTTL = result.TTL
+	}
+	if result.TokenMaxTTL == 0 && result.MaxTTL > 0 {
+		result.TokenMaxTTL = result.MaxTTL
+	}
+	result.TTL, result.MaxTTL = 0, 0
+
 	return &result, nil
 }
 
@@ -101,14 +120,17 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *f
 		return nil, nil
 	}
 
+	data := map[string]interface{}{
+		"organization":    cfg.Org,
+		"org_name":        cfg.Org,
+		"ttl":             cfg.TokenTTL.Seconds(),
+		"max_ttl":         cfg.TokenMaxTTL.Seconds(),
+		"bypass_okta_mfa": cfg.BypassOktaMFA,
+	}
+	cfg.PopulateTokenData(data)
+
 	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"organization":    cfg.Org,
-			"org_name":        cfg.Org,
-			"ttl":             cfg.TTL.Seconds(),
-			"max_ttl":         cfg.MaxTTL.Seconds(),
-			"bypass_okta_mfa": cfg.BypassOktaMFA,
-		},
+		Data: data,
 	}
 	if cfg.BaseURL != "" {
 		resp.Data["base_url"] = cfg.BaseURL
@@ -189,18 +211,11 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 		cfg.BypassOktaMFA = bypass.(bool)
 	}
 
-	ttl, ok := d.GetOk("ttl")
-	if ok {
-		cfg.TTL = time.Duration(ttl.(int)) * time.Second
-	} else if req.Operation == logical.CreateOperation {
-		cfg.TTL = time.Duration(d.Get("ttl").(int)) * time.Second
+	if err := cfg.ParseTokenFieldAliases(d, configTokenFieldAliases); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
-
-	maxTTL, ok := d.GetOk("max_ttl")
-	if ok {
-		cfg.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
-	} else if req.Operation == logical.CreateOperation {
-		cfg.MaxTTL = time.Duration(d.Get("max_ttl").(int)) * time.Second
+	if err := cfg.ParseTokenFields(req, d); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
 	jsonCfg, err := logical.StorageEntryJSON("config", cfg)
@@ -248,13 +263,18 @@ func (c *ConfigEntry) OktaClient() *okta.Client {
 
 // ConfigEntry for Okta
 type ConfigEntry struct {
-	Org           string        `json:"organization"`
-	Token         string        `json:"token"`
-	BaseURL       string        `json:"base_url"`
-	Production    *bool         `json:"is_production,omitempty"`
-	TTL           time.Duration `json:"ttl"`
-	MaxTTL        time.Duration `json:"max_ttl"`
-	BypassOktaMFA bool          `json:"bypass_okta_mfa"`
+	framework.TokenParams
+
+	Org           string `json:"organization"`
+	Token         string `json:"token"`
+	BaseURL       string `json:"base_url"`
+	Production    *bool  `json:"is_production,omitempty"`
+	BypassOktaMFA bool   `json:"bypass_okta_mfa"`
+
+	// TTL and MaxTTL are deprecated in favor of the token parameters, but
+	// are retained to upgrade older entries.
+	TTL    time.Duration `json:"ttl,omitempty"`
+	MaxTTL time.Duration `json:"max_ttl,omitempty"`
 }
 
 const pathConfigHelp = `
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-errors/errors"
 	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -70,32 +71,35 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		resp = &logical.Response{}
 	}
 
-	sort.Strings(policies)
-
 	cfg, err := b.getConfig(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	resp.Auth = &logical.Auth{
-		Policies: policies,
-		Metadata: map[string]string{
-			"username": username,
-			"policies": strings.Join(policies, ","),
-		},
+	auth := &logical.Auth{
 		InternalData: map[string]interface{}{
 			"password": password,
 		},
 		DisplayName: username,
 		LeaseOptions: logical.LeaseOptions{
-			TTL:       cfg.TTL,
-			MaxTTL:    cfg.MaxTTL,
 			Renewable: true,
 		},
 		Alias: &logical.Alias{
 			Name: username,
 		},
 	}
+	cfg.PopulateTokenAuth(auth)
+
+	// Add the policies of the groups and the user to the configured ones
+	auth.Policies = append(auth.Policies, policies...)
+	auth.Policies = strutil.RemoveDuplicates(auth.Policies, true)
+	sort.Strings(auth.Policies)
+
+	auth.Metadata = map[string]string{
+		"username": username,
+		"policies": strings.Join(auth.Policies, ","),
+	}
+	resp.Auth = auth
 
 	for _, groupName := range groupNames {
 		if groupName == "" {
@@ -118,18 +122,20 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 		return resp, err
 	}
 
-	if !policyutil.EquivalentPolicies(loginPolicies, req.Auth.Policies) {
-		return nil, fmt.Errorf("policies have changed, not renewing")
-	}
-
 	cfg, err := b.getConfig(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
+	if !policyutil.EquivalentPolicies(append(cfg.TokenPolicies, loginPolicies...), req.Auth.Policies) {
+		return nil, fmt.Errorf("policies have changed, not renewing")
+	}
+
 	resp.Auth = req.Auth
-	resp.Auth.TTL = cfg.TTL
-	resp.Auth.MaxTTL = cfg.MaxTTL
+	resp.Auth.TTL = cfg.TokenTTL
+	resp.Auth.MaxTTL = cfg.TokenMaxTTL
+	resp.Auth.Period = cfg.TokenPeriod
+	resp.Auth.ExplicitMaxTTL = cfg.TokenExplicitMaxTTL
 
 	// Remove old aliases
 	resp.Auth.GroupAliases = nil
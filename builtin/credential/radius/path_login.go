@@ -12,6 +12,7 @@ import (
 	. "layeh.com/radius/rfc2865"
 
 	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -88,12 +89,12 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		}
 	}
 
-	resp.Auth = &logical.Auth{
-		Policies: policies,
-		Metadata: map[string]string{
-			"username": username,
-			"policies": strings.Join(policies, ","),
-		},
+	cfg, err := b.Config(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &logical.Auth{
 		InternalData: map[string]interface{}{
 			"password": password,
 		},
@@ -105,6 +106,16 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 			Name: username,
 		},
 	}
+	cfg.PopulateTokenAuth(auth)
+
+	// Add the policies of the user to the configured ones
+	auth.Policies = strutil.RemoveDuplicates(append(auth.Policies, policies...), true)
+
+	auth.Metadata = map[string]string{
+		"username": username,
+		"policies": strings.Join(auth.Policies, ","),
+	}
+	resp.Auth = auth
 	return resp, nil
 }
 
@@ -122,11 +133,24 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 		return resp, err
 	}
 
-	if !policyutil.EquivalentPolicies(loginPolicies, req.Auth.Policies) {
+	cfg, err := b.Config(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("radius backend not configured")
+	}
+
+	if !policyutil.EquivalentPolicies(append(cfg.TokenPolicies, loginPolicies...), req.Auth.Policies) {
 		return nil, fmt.Errorf("policies have changed, not renewing")
 	}
 
-	return &logical.Response{Auth: req.Auth}, nil
+	resp = &logical.Response{Auth: req.Auth}
+	resp.Auth.TTL = cfg.TokenTTL
+	resp.Auth.MaxTTL = cfg.TokenMaxTTL
+	resp.Auth.Period = cfg.TokenPeriod
+	resp.Auth.ExplicitMaxTTL = cfg.TokenExplicitMaxTTL
+	return resp, nil
 }
 
 func (b *backend) RadiusLogin(ctx context.Context, req *logical.Request, username string, password string) ([]string, *logical.Response, error) {
@@ -43,7 +43,7 @@ func (b *backend) pathUserPoliciesUpdate(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("username does not exist")
 	}
 
-	userEntry.Policies = policyutil.ParsePolicies(d.Get("policies"))
+	userEntry.TokenPolicies = policyutil.ParsePolicies(d.Get("policies"))
 
 	return nil, b.setUser(ctx, req.Storage, username, userEntry)
 }
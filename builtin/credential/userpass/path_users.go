@@ -7,8 +7,6 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-sockaddr"
-	"github.com/hashicorp/vault/helper/parseutil"
-	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -26,8 +24,16 @@ func pathUsersList(b *backend) *framework.Path {
 	}
 }
 
+// userTokenFieldAliases are the deprecated user fields aliasing token fields
+var userTokenFieldAliases = map[string]string{
+	"policies":    "token_policies",
+	"ttl":         "token_ttl",
+	"max_ttl":     "token_max_ttl",
+	"bound_cidrs": "token_bound_cidrs",
+}
+
 func pathUsers(b *backend) *framework.Path {
-	return &framework.Path{
+	p := &framework.Path{
 		Pattern: "users/" + framework.GenericNameRegex("username"),
 		Fields: map[string]*framework.FieldSchema{
 			"username": &framework.FieldSchema{
@@ -42,23 +48,22 @@ func pathUsers(b *backend) *framework.Path {
 
 			"policies": &framework.FieldSchema{
 				Type:        framework.TypeCommaStringSlice,
-				Description: "Comma-separated list of policies",
+				Description: `Deprecated: use "token_policies" instead.`,
 			},
 
 			"ttl": &framework.FieldSchema{
 				Type:        framework.TypeDurationSecond,
-				Description: "Duration after which authentication will be expired",
+				Description: `Deprecated: use "token_ttl" instead.`,
 			},
 
 			"max_ttl": &framework.FieldSchema{
 				Type:        framework.TypeDurationSecond,
-				Description: "Maximum duration after which authentication will be expired",
+				Description: `Deprecated: use "token_max_ttl" instead.`,
 			},
 
 			"bound_cidrs": &framework.FieldSchema{
-				Type: framework.TypeCommaStringSlice,
-				Description: `Comma separated string or list of CIDR blocks. If set, specifies the blocks of
-IP addresses which can perform the login operation.`,
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Deprecated: use "token_bound_cidrs" instead.`,
 			},
 		},
 
@@ -74,6 +79,9 @@ IP addresses which can perform the login operation.`,
 		HelpSynopsis:    pathUserHelpSyn,
 		HelpDescription: pathUserHelpDesc,
 	}
+
+	framework.AddTokenFields(p.Fields)
+	return p
 }
 
 func (b *backend) userExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
@@ -103,6 +111,21 @@ func (b *backend) user(ctx context.Context, s logical.Storage, username string)
 		return nil, err
 	}
 
+	// Upgrade the deprecated token parameters
+	if len(result.TokenPolicies) == 0 && len(result.Policies) > 0 {
+		result.TokenPolicies = result.Policies
+	}
+	if result.TokenTTL == 0 && result.TTL > 0 {
+		result.TokenTTL = result.TTL
+	}
+	if result.TokenMaxTTL == 0 && result.MaxTTL > 0 {
+		result.TokenMaxTTL = result.MaxTTL
+	}
+	if len(result.TokenBoundCIDRs) == 0 && len(result.BoundCIDRs) > 0 {
+		result.TokenBoundCIDRs = result.BoundCIDRs
+	}
+	result.Policies, result.TTL, result.MaxTTL, result.BoundCIDRs = nil, 0, 0, nil
+
 	return &result, nil
 }
 
@@ -141,13 +164,16 @@ func (b *backend) pathUserRead(ctx context.Context, req *logical.Request, d *fra
 		return nil, nil
 	}
 
+	data := map[string]interface{}{
+		"policies":    user.TokenPolicies,
+		"ttl":         user.TokenTTL.Seconds(),
+		"max_ttl":     user.TokenMaxTTL.Seconds(),
+		"bound_cidrs": user.TokenBoundCIDRs,
+	}
+	user.PopulateTokenData(data)
+
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"policies":    user.Policies,
-			"ttl":         user.TTL.Seconds(),
-			"max_ttl":     user.MaxTTL.Seconds(),
-			"bound_cidrs": user.BoundCIDRs,
-		},
+		Data: data,
 	}, nil
 }
 
@@ -172,25 +198,12 @@ func (b *backend) userCreateUpdate(ctx context.Context, req *logical.Request, d
 		}
 	}
 
-	if policiesRaw, ok := d.GetOk("policies"); ok {
-		userEntry.Policies = policyutil.ParsePolicies(policiesRaw)
-	}
-
-	ttl, ok := d.GetOk("ttl")
-	if ok {
-		userEntry.TTL = time.Duration(ttl.(int)) * time.Second
-	}
-
-	maxTTL, ok := d.GetOk("max_ttl")
-	if ok {
-		userEntry.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
+	if err := userEntry.ParseTokenFieldAliases(d, userTokenFieldAliases); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
-
-	boundCIDRs, err := parseutil.ParseAddrs(d.Get("bound_cidrs"))
-	if err != nil {
+	if err := userEntry.ParseTokenFields(req, d); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
-	userEntry.BoundCIDRs = boundCIDRs
 
 	return nil, b.setUser(ctx, req.Storage, username, userEntry)
 }
@@ -204,6 +217,8 @@ func (b *backend) pathUserWrite(ctx context.Context, req *logical.Request, d *fr
 }
 
 type UserEntry struct {
+	framework.TokenParams
+
 	// Password is deprecated in Vault 0.2 in favor of
 	// PasswordHash, but is retained for backwards compatibility.
 	Password string
@@ -212,15 +227,17 @@ type UserEntry struct {
 	// used instead of the actual password in Vault 0.2+.
 	PasswordHash []byte
 
-	Policies []string
+	// Policies, TTL, MaxTTL and BoundCIDRs are deprecated in favor of the
+	// token parameters, but are retained to upgrade older entries.
+	Policies []string `json:",omitempty"`
 
 	// Duration after which the user will be revoked unless renewed
-	TTL time.Duration
+	TTL time.Duration `json:",omitempty"`
 
 	// Maximum duration for which user can be valid
-	MaxTTL time.Duration
+	MaxTTL time.Duration `json:",omitempty"`
 
-	BoundCIDRs []*sockaddr.SockAddrMarshaler
+	BoundCIDRs []*sockaddr.SockAddrMarshaler `json:",omitempty"`
 }
 
 const pathUserHelpSyn = `
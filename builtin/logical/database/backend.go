@@ -12,6 +12,9 @@ import (
 	"github.com/hashicorp/errwrap"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/queue"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
@@ -21,7 +24,7 @@ const databaseConfigPath = "database/config/"
 
 type dbPluginInstance struct {
 	sync.RWMutex
-	dbplugin.Database
+	database databaseVersionWrapper
 
 	id     string
 	name   string
@@ -37,7 +40,7 @@ func (dbi *dbPluginInstance) Close() error {
 	}
 	dbi.closed = true
 
-	return dbi.Database.Close()
+	return dbi.database.Close()
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -56,6 +59,8 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 		PathsSpecial: &logical.Paths{
 			SealWrapStorage: []string{
 				"config/*",
+				"role/*",
+				"static-role/*",
 			},
 		},
 
@@ -67,18 +72,26 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 			pathCredsCreate(&b),
 			pathResetConnection(&b),
 			pathRotateCredentials(&b),
+			pathListStaticRoles(&b),
+			pathStaticRoles(&b),
+			pathStaticCredsRead(&b),
+			pathRotateRole(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCreds(&b),
 		},
-		Clean:       b.closeAllDBs,
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		Clean:        b.closeAllDBs,
+		Invalidate:   b.invalidate,
+		PeriodicFunc: b.periodicFunc,
+		WALRollback:  b.walRollback,
+		BackendType:  logical.TypeLogical,
 	}
 
 	b.logger = conf.Logger
 	b.connections = make(map[string]*dbPluginInstance)
+	b.credRotationQueue = queue.New()
+	b.roleLocks = locksutil.CreateLocks()
 	return &b
 }
 
@@ -86,6 +99,17 @@ type databaseBackend struct {
 	connections map[string]*dbPluginInstance
 	logger      log.Logger
 
+	// credRotationQueue schedules the password rotations of static roles,
+	// by the Unix time of their next rotation
+	credRotationQueue *queue.PriorityQueue
+	queuePopulated    bool
+	queuePopulateLock sync.Mutex
+	roleLocks         []*locksutil.LockEntry
+
+	// staticAccountsLock serializes the creation of static roles, so that no
+	// two static roles can manage the same database user
+	staticAccountsLock sync.Mutex
+
 	*framework.Backend
 	sync.RWMutex
 }
@@ -199,14 +223,17 @@ func (b *databaseBackend) GetConnection(ctx context.Context, s logical.Storage,
 		return nil, err
 	}
 
-	dbp, err := dbplugin.PluginFactory(ctx, config.PluginName, b.System(), b.logger)
+	dbw, err := newDatabaseWrapper(ctx, config.PluginName, b.System(), b.logger)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = dbp.Init(ctx, config.ConnectionDetails, true)
+	_, err = dbw.Initialize(ctx, v5.InitializeRequest{
+		Config:           config.ConnectionDetails,
+		VerifyConnection: true,
+	})
 	if err != nil {
-		dbp.Close()
+		dbw.Close()
 		return nil, err
 	}
 
@@ -216,7 +243,7 @@ func (b *databaseBackend) GetConnection(ctx context.Context, s logical.Storage,
 	}
 
 	db = &dbPluginInstance{
-		Database: dbp,
+		database: dbw,
 		name:     name,
 		id:       id,
 	}
@@ -282,4 +309,8 @@ cassandra, mssql, mysql, postgres
 
 After mounting this backend, configure it using the endpoints within
 the "database/config/" path.
+
+Roles under "roles/" create database users on demand. Static roles under
+"static-roles/" instead manage the password of an existing database user,
+which is rotated on a schedule.
 `
@@ -216,6 +216,7 @@ func TestBackend_config_connection(t *testing.T) {
 		},
 		"allowed_roles":                      []string{"*"},
 		"root_credentials_rotate_statements": []string{},
+		"password_policy":                    "",
 	}
 	configReq.Operation = logical.ReadOperation
 	resp, err = b.HandleRequest(context.Background(), configReq)
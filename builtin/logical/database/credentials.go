@@ -0,0 +1,406 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
+	"github.com/hashicorp/vault/helper/template"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/mitchellh/mapstructure"
+)
+
+// rsaKeyConfig is the credential_config of roles issuing RSA private keys
+type rsaKeyConfig struct {
+	KeyBits int    `mapstructure:"key_bits"`
+	Format  string `mapstructure:"format"`
+}
+
+// clientCertificateConfig is the credential_config of roles issuing client
+// certificates
+type clientCertificateConfig struct {
+	CommonNameTemplate string `mapstructure:"common_name_template"`
+	CACert             string `mapstructure:"ca_cert"`
+	CAPrivateKey       string `mapstructure:"ca_private_key"`
+	KeyType            string `mapstructure:"key_type"`
+	KeyBits            int    `mapstructure:"key_bits"`
+	SignatureBits      int    `mapstructure:"signature_bits"`
+}
+
+// credential is a generated credential of a new user. Only the fields of its
+// type are set.
+type credential struct {
+	Password       string
+	PublicKey      []byte
+	PrivateKey     string
+	PrivateKeyType string
+	Certificate    string
+	Subject        string
+}
+
+// decodeCredentialConfig decodes the credential_config of a role, rejecting
+// unknown keys
+func decodeCredentialConfig(raw map[string]interface{}, result interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           result,
+	})
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(raw)
+}
+
+// validateCredentialConfig checks the credential type and configuration of a
+// role, filling in the defaults of the configuration
+func validateCredentialConfig(credType v5.CredentialType, raw map[string]interface{}) (map[string]interface{}, error) {
+	switch credType {
+	case v5.CredentialTypePassword:
+		if len(raw) > 0 {
+			return nil, errors.New("credential_config is not supported by the password credential type")
+		}
+		return nil, nil
+
+	case v5.CredentialTypeRSAPrivateKey:
+		config, err := parseRSAKeyConfig(raw)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"key_bits": config.KeyBits,
+			"format":   config.Format,
+		}, nil
+
+	case v5.CredentialTypeClientCertificate:
+		config, _, _, err := parseClientCertificateConfig(raw)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"common_name_template": config.CommonNameTemplate,
+			"ca_cert":              config.CACert,
+			"ca_private_key":       config.CAPrivateKey,
+			"key_type":             config.KeyType,
+			"key_bits":             config.KeyBits,
+			"signature_bits":       config.SignatureBits,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported credential_type %q", credType)
+	}
+}
+
+func parseRSAKeyConfig(raw map[string]interface{}) (*rsaKeyConfig, error) {
+	config := &rsaKeyConfig{}
+	if err := decodeCredentialConfig(raw, config); err != nil {
+		return nil, errwrap.Wrapf("invalid credential_config: {{err}}", err)
+	}
+
+	switch config.KeyBits {
+	case 0:
+		config.KeyBits = 2048
+	case 2048, 3072, 4096:
+	default:
+		return nil, fmt.Errorf("invalid key_bits %d; must be 2048, 3072 or 4096", config.KeyBits)
+	}
+
+	switch config.Format {
+	case "":
+		config.Format = "pkcs8"
+	case "pkcs8":
+	default:
+		return nil, fmt.Errorf("invalid format %q; only pkcs8 is supported", config.Format)
+	}
+
+	return config, nil
+}
+
+func parseClientCertificateConfig(raw map[string]interface{}) (*clientCertificateConfig, *x509.Certificate, crypto.Signer, error) {
+	config := &clientCertificateConfig{}
+	if err := decodeCredentialConfig(raw, config); err != nil {
+		return nil, nil, nil, errwrap.Wrapf("invalid credential_config: {{err}}", err)
+	}
+
+	if config.CommonNameTemplate == "" {
+		return nil, nil, nil, errors.New("common_name_template is required")
+	}
+	if _, err := template.NewTemplate(config.CommonNameTemplate); err != nil {
+		return nil, nil, nil, errwrap.Wrapf("invalid common_name_template: {{err}}", err)
+	}
+
+	caCert, caKey, err := parseCA(config.CACert, config.CAPrivateKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	switch config.KeyType {
+	case "", "rsa":
+		config.KeyType = "rsa"
+		switch config.KeyBits {
+		case 0:
+			config.KeyBits = 2048
+		case 2048, 3072, 4096:
+		default:
+			return nil, nil, nil, fmt.Errorf("invalid key_bits %d for RSA keys; must be 2048, 3072 or 4096", config.KeyBits)
+		}
+	case "ec":
+		switch config.KeyBits {
+		case 0:
+			config.KeyBits = 256
+		case 224, 256, 384, 521:
+		default:
+			return nil, nil, nil, fmt.Errorf("invalid key_bits %d for EC keys; must be 224, 256, 384 or 521", config.KeyBits)
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("invalid key_type %q; must be rsa or ec", config.KeyType)
+	}
+
+	switch config.SignatureBits {
+	case 0:
+		config.SignatureBits = 256
+	case 256, 384, 512:
+	default:
+		return nil, nil, nil, fmt.Errorf("invalid signature_bits %d; must be 256, 384 or 512", config.SignatureBits)
+	}
+
+	return config, caCert, caKey, nil
+}
+
+// parseCA parses the PEM-encoded CA certificate and private key used to sign
+// client certificates
+func parseCA(certPEM, keyPEM string) (*x509.Certificate, crypto.Signer, error) {
+	certBlock, _ := pem.Decode([]byte(certPEM))
+	if certBlock == nil {
+		return nil, nil, errors.New("ca_cert must be a PEM-encoded certificate")
+	}
+	caCert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("unable to parse ca_cert: {{err}}", err)
+	}
+	if !caCert.IsCA {
+		return nil, nil, errors.New("ca_cert is not a CA certificate")
+	}
+
+	keyBlock, _ := pem.Decode([]byte(keyPEM))
+	if keyBlock == nil {
+		return nil, nil, errors.New("ca_private_key must be a PEM-encoded private key")
+	}
+	var caKey crypto.Signer
+	switch keyBlock.Type {
+	case "RSA PRIVATE KEY":
+		caKey, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	case "EC PRIVATE KEY":
+		caKey, err = x509.ParseECPrivateKey(keyBlock.Bytes)
+	case "PRIVATE KEY":
+		var key interface{}
+		key, err = x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+		if err == nil {
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, nil, errors.New("unsupported ca_private_key type")
+			}
+			caKey = signer
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported ca_private_key PEM type %q", keyBlock.Type)
+	}
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("unable to parse ca_private_key: {{err}}", err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(caKey.Public())
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("unable to parse ca_private_key: {{err}}", err)
+	}
+	if !bytes.Equal(publicDER, caCert.RawSubjectPublicKeyInfo) {
+		return nil, nil, errors.New("ca_private_key does not match ca_cert")
+	}
+
+	return caCert, caKey, nil
+}
+
+// generatePassword generates a password from the given password policy, or a
+// random alphanumeric password if no policy is set
+func (b *databaseBackend) generatePassword(ctx context.Context, passwordPolicy string) (string, error) {
+	if passwordPolicy == "" {
+		return credsutil.RandomAlphaNumeric(20, true)
+	}
+
+	password, err := b.System().GeneratePasswordFromPolicy(ctx, passwordPolicy)
+	if err != nil {
+		return "", errwrap.Wrapf("unable to generate password: {{err}}", err)
+	}
+	return password, nil
+}
+
+// generateCredential generates a credential of the type of the role for a
+// new user. Passwords follow the given password policy and client
+// certificates are valid until notAfter.
+func (b *databaseBackend) generateCredential(ctx context.Context, role *roleEntry, passwordPolicy string, usernameConfig v5.UsernameMetadata, notAfter time.Time) (*credential, error) {
+	switch role.credentialType() {
+	case v5.CredentialTypePassword:
+		password, err := b.generatePassword(ctx, passwordPolicy)
+		if err != nil {
+			return nil, err
+		}
+		return &credential{
+			Password: password,
+		}, nil
+
+	case v5.CredentialTypeRSAPrivateKey:
+		config, err := parseRSAKeyConfig(role.CredentialConfig)
+		if err != nil {
+			return nil, err
+		}
+		return generateRSAKeyCredential(config)
+
+	case v5.CredentialTypeClientCertificate:
+		config, caCert, caKey, err := parseClientCertificateConfig(role.CredentialConfig)
+		if err != nil {
+			return nil, err
+		}
+		return generateClientCertificateCredential(config, caCert, caKey, usernameConfig, notAfter)
+
+	default:
+		return nil, fmt.Errorf("unsupported credential_type %q", role.CredentialType)
+	}
+}
+
+func generateRSAKeyCredential(config *rsaKeyConfig) (*credential, error) {
+	key, err := rsa.GenerateKey(rand.Reader, config.KeyBits)
+	if err != nil {
+		return nil, errwrap.Wrapf("error generating RSA key: {{err}}", err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &credential{
+		PublicKey:      pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+		PrivateKey:     string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		PrivateKeyType: "rsa",
+	}, nil
+}
+
+func generateClientCertificateCredential(config *clientCertificateConfig, caCert *x509.Certificate, caKey crypto.Signer, usernameConfig v5.UsernameMetadata, notAfter time.Time) (*credential, error) {
+	tmpl, err := template.NewTemplate(config.CommonNameTemplate)
+	if err != nil {
+		return nil, err
+	}
+	commonName, err := tmpl.Generate(usernameConfig)
+	if err != nil {
+		return nil, err
+	}
+	if commonName == "" {
+		return nil, errors.New("common_name_template generated an empty common name")
+	}
+
+	var key crypto.Signer
+	var privateBlock *pem.Block
+	switch config.KeyType {
+	case "rsa":
+		rsaKey, err := rsa.GenerateKey(rand.Reader, config.KeyBits)
+		if err != nil {
+			return nil, errwrap.Wrapf("error generating RSA key: {{err}}", err)
+		}
+		key = rsaKey
+		privateBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}
+	case "ec":
+		var curve elliptic.Curve
+		switch config.KeyBits {
+		case 224:
+			curve = elliptic.P224()
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			curve = elliptic.P521()
+		}
+		ecKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, errwrap.Wrapf("error generating EC key: {{err}}", err)
+		}
+		marshaled, err := x509.MarshalECPrivateKey(ecKey)
+		if err != nil {
+			return nil, err
+		}
+		key = ecKey
+		privateBlock = &pem.Block{Type: "EC PRIVATE KEY", Bytes: marshaled}
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	certTemplate := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore:          time.Now().Add(-30 * time.Second),
+		NotAfter:           notAfter,
+		KeyUsage:           x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		SignatureAlgorithm: signatureAlgorithm(caKey, config.SignatureBits),
+	}
+	if notAfter.After(caCert.NotAfter) {
+		certTemplate.NotAfter = caCert.NotAfter
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, certTemplate, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("error signing client certificate: {{err}}", err)
+	}
+
+	return &credential{
+		PrivateKey:     string(pem.EncodeToMemory(privateBlock)),
+		PrivateKeyType: config.KeyType,
+		Certificate:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})),
+		Subject:        certTemplate.Subject.String(),
+	}, nil
+}
+
+func signatureAlgorithm(caKey crypto.Signer, bits int) x509.SignatureAlgorithm {
+	if _, ok := caKey.Public().(*ecdsa.PublicKey); ok {
+		switch bits {
+		case 384:
+			return x509.ECDSAWithSHA384
+		case 512:
+			return x509.ECDSAWithSHA512
+		default:
+			return x509.ECDSAWithSHA256
+		}
+	}
+
+	switch bits {
+	case 384:
+		return x509.SHA384WithRSA
+	case 512:
+		return x509.SHA512WithRSA
+	default:
+		return x509.SHA256WithRSA
+	}
+}
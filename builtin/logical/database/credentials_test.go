@@ -0,0 +1,509 @@
+package database
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
+	"github.com/hashicorp/vault/helper/pluginutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// catalogSystemView serves builtin plugins from the given factories
+type catalogSystemView struct {
+	*logical.StaticSystemView
+	plugins map[string]func() (interface{}, error)
+}
+
+func (s catalogSystemView) LookupPlugin(_ context.Context, name string) (*pluginutil.PluginRunner, error) {
+	factory, ok := s.plugins[name]
+	if !ok {
+		return nil, fmt.Errorf("no plugin found with name %q", name)
+	}
+	return &pluginutil.PluginRunner{
+		Name:           name,
+		Builtin:        true,
+		BuiltinFactory: factory,
+	}, nil
+}
+
+// mockV4Database is a version 4 plugin that can only be initialized
+type mockV4Database struct {
+	dbplugin.Database
+}
+
+func (m *mockV4Database) Init(ctx context.Context, config map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	return config, nil
+}
+
+func (m *mockV4Database) Type() (string, error) { return "mock-v4", nil }
+
+func (m *mockV4Database) Close() error { return nil }
+
+// mockV4PasswordDatabase is a version 4 plugin that records the passwords of
+// its users
+type mockV4PasswordDatabase struct {
+	mockV4Database
+	passwords map[string]string
+
+	// setCredentialsUnsupported makes SetCredentials fail like the plugins
+	// that cannot set the credentials of users
+	setCredentialsUnsupported bool
+}
+
+func (m *mockV4PasswordDatabase) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (string, string, error) {
+	username := usernameConfig.RoleName + "-" + usernameConfig.DisplayName
+	m.passwords[username] = "plugin-password"
+	return username, "plugin-password", nil
+}
+
+func (m *mockV4PasswordDatabase) RevokeUser(ctx context.Context, statements dbplugin.Statements, username string) error {
+	delete(m.passwords, username)
+	return nil
+}
+
+func (m *mockV4PasswordDatabase) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (string, string, error) {
+	if m.setCredentialsUnsupported {
+		// The error is not preserved by the error sanitizer
+		return "", "", errors.New(dbplugin.ErrSetCredentialsUnsupported.Error())
+	}
+	m.passwords[staticUser.Username] = staticUser.Password
+	return staticUser.Username, staticUser.Password, nil
+}
+
+func (m *mockV4PasswordDatabase) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
+	m.passwords["root"] = "plugin-password"
+	return map[string]interface{}{"username": "root", "password": "plugin-password"}, nil
+}
+
+func testCA(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestBackend_CredentialTypes(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := b.(*databaseBackend)
+	defer backend.Cleanup(context.Background())
+
+	mockDB := &mockV5Database{
+		passwords:   map[string]string{},
+		expirations: map[string]time.Time{},
+		publicKeys:  map[string][]byte{},
+		subjects:    map[string]string{},
+	}
+	backend.connections["plugin-test"] = &dbPluginInstance{
+		database: databaseVersionWrapper{v5: mockDB},
+		name:     "plugin-test",
+		id:       "plugin-test-id",
+	}
+	entry, err := logical.StorageEntryJSON("config/plugin-test", &DatabaseConfig{
+		PluginName:   "mock",
+		AllowedRoles: []string{"*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   op,
+			Path:        path,
+			Storage:     config.StorageView,
+			Data:        data,
+			DisplayName: "token",
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	// Invalid configurations are rejected
+	for _, data := range []map[string]interface{}{
+		{"credential_type": "bogus"},
+		{"credential_type": "password", "credential_config": map[string]interface{}{"key_bits": 2048}},
+		{"credential_type": "rsa_private_key", "credential_config": map[string]interface{}{"key_bits": 1024}},
+		{"credential_type": "rsa_private_key", "credential_config": map[string]interface{}{"unknown": true}},
+		{"credential_type": "client_certificate", "credential_config": map[string]interface{}{"common_name_template": "{{.RoleName}}"}},
+	} {
+		data["db_name"] = "plugin-test"
+		if resp := doReq(logical.UpdateOperation, "roles/invalid", data); resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %#v, got: %#v", data, resp)
+		}
+	}
+
+	// RSA private keys
+	doReq(logical.UpdateOperation, "roles/rsa", map[string]interface{}{
+		"db_name":             "plugin-test",
+		"creation_statements": "create",
+		"credential_type":     "rsa_private_key",
+		"credential_config": map[string]interface{}{
+			"key_bits": "2048",
+		},
+	})
+	resp := doReq(logical.ReadOperation, "creds/rsa", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if _, ok := resp.Data["password"]; ok {
+		t.Fatal("unexpected password in the response")
+	}
+	block, _ := pem.Decode([]byte(resp.Data["rsa_private_key"].(string)))
+	if block == nil {
+		t.Fatal("expected a PEM-encoded private key")
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ = pem.Decode(mockDB.publicKeys["rsa-token"])
+	if block == nil {
+		t.Fatal("expected the public key to be given to the plugin")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if privateKey.(*rsa.PrivateKey).PublicKey.N.Cmp(publicKey.(*rsa.PublicKey).N) != 0 {
+		t.Fatal("public key does not match the private key")
+	}
+
+	// Client certificates
+	caCert, caKey := testCA(t)
+	_, otherKey := testCA(t)
+	resp = doReq(logical.UpdateOperation, "roles/cert", map[string]interface{}{
+		"db_name":             "plugin-test",
+		"creation_statements": "create",
+		"credential_type":     "client_certificate",
+		"credential_config": map[string]interface{}{
+			"common_name_template": "{{.RoleName}}",
+			"ca_cert":              caCert,
+			"ca_private_key":       otherKey,
+		},
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for a CA key not matching the certificate, got: %#v", resp)
+	}
+
+	doReq(logical.UpdateOperation, "roles/cert", map[string]interface{}{
+		"db_name":             "plugin-test",
+		"creation_statements": "create",
+		"max_ttl":             "2h",
+		"credential_type":     "client_certificate",
+		"credential_config": map[string]interface{}{
+			"common_name_template": "{{.RoleName}}.{{.DisplayName}}",
+			"ca_cert":              caCert,
+			"ca_private_key":       caKey,
+			"key_type":             "ec",
+		},
+	})
+	resp = doReq(logical.ReadOperation, "roles/cert", nil)
+	credentialConfig := resp.Data["credential_config"].(map[string]interface{})
+	if _, ok := credentialConfig["ca_private_key"]; ok {
+		t.Fatal("the private key of the CA must not be returned")
+	}
+	if fmt.Sprint(credentialConfig["key_bits"]) != "256" || resp.Data["credential_type"] != "client_certificate" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = doReq(logical.ReadOperation, "creds/cert", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Data["private_key_type"] != "ec" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	block, _ = pem.Decode([]byte(resp.Data["client_certificate"].(string)))
+	if block == nil {
+		t.Fatal("expected a PEM-encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "cert.token" {
+		t.Fatalf("bad common name: %s", cert.Subject.CommonName)
+	}
+	if mockDB.subjects["cert-token"] != "CN=cert.token" {
+		t.Fatalf("bad subject: %q", mockDB.subjects["cert-token"])
+	}
+	if cert.NotAfter.After(time.Now().Add(2 * time.Hour)) {
+		t.Fatalf("certificate outlives the max TTL: %s", cert.NotAfter)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(caCert))
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 4 plugins only support passwords
+	_, _, err = databaseVersionWrapper{v4: &mockStaticDatabase{}}.NewUser(context.Background(), v5.NewUserRequest{
+		CredentialType: v5.CredentialTypeRSAPrivateKey,
+	})
+	if err == nil {
+		t.Fatal("expected error for version 4 plugins")
+	}
+}
+
+func TestBackend_CredentialTypes_PluginVersion(t *testing.T) {
+	mockDB := &mockV5Database{
+		passwords:   map[string]string{},
+		expirations: map[string]time.Time{},
+		publicKeys:  map[string][]byte{},
+		subjects:    map[string]string{},
+	}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = catalogSystemView{
+		StaticSystemView: config.System.(*logical.StaticSystemView),
+		plugins: map[string]func() (interface{}, error){
+			"mock-v5": func() (interface{}, error) { return mockDB, nil },
+			"mock-v4": func() (interface{}, error) { return &mockV4Database{}, nil },
+		},
+	}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.(*databaseBackend).Cleanup(context.Background())
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   op,
+			Path:        path,
+			Storage:     config.StorageView,
+			Data:        data,
+			DisplayName: "token",
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	for _, name := range []string{"mock-v5", "mock-v4"} {
+		resp := doReq(logical.UpdateOperation, "config/"+name, map[string]interface{}{
+			"plugin_name":   name,
+			"allowed_roles": "*",
+		})
+		if resp != nil && resp.IsError() {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+
+	caCert, caKey := testCA(t)
+	roleData := func(dbName string) map[string]interface{} {
+		return map[string]interface{}{
+			"db_name":             dbName,
+			"creation_statements": "create",
+			"credential_type":     "client_certificate",
+			"credential_config": map[string]interface{}{
+				"common_name_template": "{{.RoleName}}",
+				"ca_cert":              caCert,
+				"ca_private_key":       caKey,
+			},
+		}
+	}
+
+	// Version 4 plugins are rejected when the role is written
+	if resp := doReq(logical.UpdateOperation, "roles/cert", roleData("mock-v4")); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for a version 4 plugin, got: %#v", resp)
+	}
+
+	if resp := doReq(logical.UpdateOperation, "roles/cert", roleData("mock-v5")); resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp := doReq(logical.ReadOperation, "creds/cert", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Data["client_certificate"] == nil || mockDB.subjects["cert-token"] != "CN=cert" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestBackend_PasswordPolicy(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System.(*logical.StaticSystemView).PasswordPolicies = map[string]logical.PasswordGenerator{
+		"fixed": func() (string, error) { return "policy-password", nil },
+	}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := b.(*databaseBackend)
+	defer backend.Cleanup(context.Background())
+
+	mockDB := &mockV5Database{
+		passwords:   map[string]string{},
+		expirations: map[string]time.Time{},
+	}
+	backend.connections["plugin-test"] = &dbPluginInstance{
+		database: databaseVersionWrapper{v5: mockDB},
+		name:     "plugin-test",
+		id:       "plugin-test-id",
+	}
+	entry, err := logical.StorageEntryJSON("config/plugin-test", &DatabaseConfig{
+		PluginName:     "mock",
+		AllowedRoles:   []string{"*"},
+		PasswordPolicy: "fixed",
+		ConnectionDetails: map[string]interface{}{
+			"username": "root",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   op,
+			Path:        path,
+			Storage:     config.StorageView,
+			Data:        data,
+			DisplayName: "token",
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s resp: %#v err: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	doReq(logical.UpdateOperation, "roles/test", map[string]interface{}{
+		"db_name":             "plugin-test",
+		"creation_statements": "create",
+	})
+	resp := doReq(logical.ReadOperation, "creds/test", nil)
+	if resp.Data["password"] != "policy-password" || mockDB.passwords["test-token"] != "policy-password" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	doReq(logical.UpdateOperation, "rotate-root/plugin-test", nil)
+	if mockDB.passwords["root"] != "policy-password" {
+		t.Fatalf("bad root password: %q", mockDB.passwords["root"])
+	}
+
+	// The passwords generated by version 4 plugins are replaced
+	mockV4DB := &mockV4PasswordDatabase{
+		passwords: map[string]string{},
+	}
+	backend.connections["plugin-v4"] = &dbPluginInstance{
+		database: databaseVersionWrapper{v4: mockV4DB},
+		name:     "plugin-v4",
+		id:       "plugin-v4-id",
+	}
+	entry, err = logical.StorageEntryJSON("config/plugin-v4", &DatabaseConfig{
+		PluginName:     "mock-v4",
+		AllowedRoles:   []string{"*"},
+		PasswordPolicy: "fixed",
+		ConnectionDetails: map[string]interface{}{
+			"username": "root",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	doReq(logical.UpdateOperation, "roles/test-v4", map[string]interface{}{
+		"db_name":             "plugin-v4",
+		"creation_statements": "create",
+	})
+	resp = doReq(logical.ReadOperation, "creds/test-v4", nil)
+	if resp.Data["password"] != "policy-password" || mockV4DB.passwords["test-v4-token"] != "policy-password" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	doReq(logical.UpdateOperation, "rotate-root/plugin-v4", nil)
+	if mockV4DB.passwords["root"] != "policy-password" {
+		t.Fatalf("bad root password: %q", mockV4DB.passwords["root"])
+	}
+	dbConfig, err := backend.DatabaseConfig(context.Background(), config.StorageView, "plugin-v4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbConfig.ConnectionDetails["password"] != "policy-password" {
+		t.Fatalf("bad connection details: %#v", dbConfig.ConnectionDetails)
+	}
+
+	// Plugins that cannot set credentials keep the passwords they generate
+	mockV4DB.setCredentialsUnsupported = true
+	backend.connections["plugin-v4"] = &dbPluginInstance{
+		database: databaseVersionWrapper{v4: mockV4DB},
+		name:     "plugin-v4",
+		id:       "plugin-v4-id",
+	}
+	resp = doReq(logical.ReadOperation, "creds/test-v4", nil)
+	if resp.Data["password"] != "plugin-password" || mockV4DB.passwords["test-v4-token"] != "plugin-password" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	doReq(logical.UpdateOperation, "rotate-root/plugin-v4", nil)
+	dbConfig, err = backend.DatabaseConfig(context.Background(), config.StorageView, "plugin-v4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mockV4DB.passwords["root"] != "plugin-password" || dbConfig.ConnectionDetails["password"] != "plugin-password" {
+		t.Fatalf("bad connection details: %#v", dbConfig.ConnectionDetails)
+	}
+}
@@ -48,6 +48,12 @@ func (ds *databasePluginRPCServer) RotateRootCredentials(args *RotateRootCredent
 	return err
 }
 
+func (ds *databasePluginRPCServer) SetCredentials(args *SetCredentialsRequestRPC, resp *SetCredentialsResponse) error {
+	var err error
+	resp.Username, resp.Password, err = ds.impl.SetCredentials(context.Background(), args.Statements, args.StaticUserConfig)
+	return err
+}
+
 func (ds *databasePluginRPCServer) Initialize(args *InitializeRequestRPC, _ *struct{}) error {
 	return ds.Init(&InitRequestRPC{
 		Config:           args.Config,
@@ -127,6 +133,21 @@ func (dr *databasePluginRPCClient) RotateRootCredentials(_ context.Context, stat
 	return saveConf, err
 }
 
+func (dr *databasePluginRPCClient) SetCredentials(_ context.Context, statements Statements, staticConfig StaticUserConfig) (username string, password string, err error) {
+	req := SetCredentialsRequestRPC{
+		Statements:       statements,
+		StaticUserConfig: staticConfig,
+	}
+
+	var resp SetCredentialsResponse
+	err = dr.client.Call("Plugin.SetCredentials", req, &resp)
+	if err != nil && strings.Contains(err.Error(), "can't find method Plugin.SetCredentials") {
+		return "", "", ErrSetCredentialsUnsupported
+	}
+
+	return resp.Username, resp.Password, err
+}
+
 func (dr *databasePluginRPCClient) Initialize(_ context.Context, conf map[string]interface{}, verifyConnection bool) error {
 	_, err := dr.Init(nil, conf, verifyConnection)
 	return err
@@ -195,3 +216,8 @@ type RevokeUserRequestRPC struct {
 type RotateRootCredentialsRequestRPC struct {
 	Statements []string
 }
+
+type SetCredentialsRequestRPC struct {
+	Statements       Statements
+	StaticUserConfig StaticUserConfig
+}
@@ -23,6 +23,10 @@ type Database interface {
 
 	RotateRootCredentials(ctx context.Context, statements []string) (config map[string]interface{}, err error)
 
+	// SetCredentials sets the password of an existing, static user to the
+	// given password, using the rotation statements if provided.
+	SetCredentials(ctx context.Context, statements Statements, staticConfig StaticUserConfig) (username string, password string, err error)
+
 	Init(ctx context.Context, config map[string]interface{}, verifyConnection bool) (saveConfig map[string]interface{}, err error)
 	Close() error
 
@@ -39,44 +43,50 @@ func PluginFactory(ctx context.Context, pluginName string, sys pluginutil.LookRu
 		return nil, err
 	}
 
-	namedLogger := logger.Named(pluginName)
-
-	var transport string
-	var db Database
 	if pluginRunner.Builtin {
 		// Plugin is builtin so we can retrieve an instance of the interface
-		// from the pluginRunner. Then cast it to a Database.
+		// from the pluginRunner.
 		dbRaw, err := pluginRunner.BuiltinFactory()
 		if err != nil {
 			return nil, errwrap.Wrapf("error initializing plugin: {{err}}", err)
 		}
 
-		var ok bool
-		db, ok = dbRaw.(Database)
-		if !ok {
-			return nil, fmt.Errorf("unsupported database type: %q", pluginName)
-		}
+		return NewBuiltinDatabase(pluginName, dbRaw, logger)
+	}
 
-		transport = "builtin"
+	namedLogger := logger.Named(pluginName)
 
-	} else {
-		// create a DatabasePluginClient instance
-		db, err = newPluginClient(ctx, sys, pluginRunner, namedLogger)
-		if err != nil {
-			return nil, err
-		}
+	// create a DatabasePluginClient instance
+	db, err := newPluginClient(ctx, sys, pluginRunner, namedLogger)
+	if err != nil {
+		return nil, err
+	}
 
-		// Switch on the underlying database client type to get the transport
-		// method.
-		switch db.(*DatabasePluginClient).Database.(type) {
-		case *gRPCClient:
-			transport = "gRPC"
-		case *databasePluginRPCClient:
-			transport = "netRPC"
-		}
+	// Switch on the underlying database client type to get the transport
+	// method.
+	var transport string
+	switch db.(*DatabasePluginClient).Database.(type) {
+	case *gRPCClient:
+		transport = "gRPC"
+	case *databasePluginRPCClient:
+		transport = "netRPC"
+	}
 
+	return wrapDatabase(db, transport, namedLogger)
+}
+
+// NewBuiltinDatabase casts an instance returned by the factory of a builtin
+// plugin to a Database, and wraps it in a logging and metrics middleware.
+func NewBuiltinDatabase(pluginName string, dbRaw interface{}, logger log.Logger) (Database, error) {
+	db, ok := dbRaw.(Database)
+	if !ok {
+		return nil, fmt.Errorf("unsupported database type: %q", pluginName)
 	}
 
+	return wrapDatabase(db, "builtin", logger.Named(pluginName))
+}
+
+func wrapDatabase(db Database, transport string, logger log.Logger) (Database, error) {
 	typeStr, err := db.Type()
 	if err != nil {
 		return nil, errwrap.Wrapf("error getting plugin type: {{err}}", err)
@@ -89,10 +99,10 @@ func PluginFactory(ctx context.Context, pluginName string, sys pluginutil.LookRu
 	}
 
 	// Wrap with tracing middleware
-	if namedLogger.IsTrace() {
+	if logger.IsTrace() {
 		db = &databaseTracingMiddleware{
 			next:   db,
-			logger: namedLogger.With("transport", transport),
+			logger: logger.With("transport", transport),
 		}
 	}
 
@@ -64,6 +64,14 @@ func (m *mockPlugin) RevokeUser(_ context.Context, statements dbplugin.Statement
 func (m *mockPlugin) RotateRootCredentials(_ context.Context, statements []string) (map[string]interface{}, error) {
 	return nil, nil
 }
+func (m *mockPlugin) SetCredentials(_ context.Context, statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (username string, password string, err error) {
+	if _, ok := m.users[staticConfig.Username]; !ok {
+		return "", "", errors.New("err")
+	}
+
+	m.users[staticConfig.Username] = []string{staticConfig.Password}
+	return staticConfig.Username, staticConfig.Password, nil
+}
 func (m *mockPlugin) Init(_ context.Context, conf map[string]interface{}, _ bool) (map[string]interface{}, error) {
 	err := errors.New("err")
 	if len(conf) != 1 {
@@ -282,6 +290,54 @@ func TestPlugin_RevokeUser(t *testing.T) {
 	}
 }
 
+func TestPlugin_SetCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin", sys, log.NewNullLogger())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	connectionDetails := map[string]interface{}{
+		"test": 1,
+	}
+	_, err = db.Init(context.Background(), connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	usernameConf := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	us, _, err := db.CreateUser(context.Background(), dbplugin.Statements{}, usernameConf, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	staticConf := dbplugin.StaticUserConfig{
+		Username: us,
+		Password: "newpassword",
+	}
+	us, pw, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, staticConf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if us != "test" || pw != "newpassword" {
+		t.Fatalf("bad: username %q password %q", us, pw)
+	}
+
+	// Setting the credentials of an unknown user should fail
+	staticConf.Username = "unknown"
+	_, _, err = db.SetCredentials(context.Background(), dbplugin.Statements{}, staticConf)
+	if err == nil {
+		t.Fatal("expected an error for an unknown user")
+	}
+}
+
 // Test the code is still compatible with an old netRPC plugin
 func TestPlugin_NetRPC_Init(t *testing.T) {
 	cluster, sys := getCluster(t)
@@ -421,3 +477,51 @@ func TestPlugin_NetRPC_RevokeUser(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestPlugin_NetRPC_SetCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin-netRPC", sys, log.NewNullLogger())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	connectionDetails := map[string]interface{}{
+		"test": 1,
+	}
+	_, err = db.Init(context.Background(), connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	usernameConf := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	us, _, err := db.CreateUser(context.Background(), dbplugin.Statements{}, usernameConf, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	staticConf := dbplugin.StaticUserConfig{
+		Username: us,
+		Password: "newpassword",
+	}
+	us, pw, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, staticConf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if us != "test" || pw != "newpassword" {
+		t.Fatalf("bad: username %q password %q", us, pw)
+	}
+
+	// Setting the credentials of an unknown user should fail
+	staticConf.Username = "unknown"
+	_, _, err = db.SetCredentials(context.Background(), dbplugin.Statements{}, staticConf)
+	if err == nil {
+		t.Fatal("expected an error for an unknown user")
+	}
+}
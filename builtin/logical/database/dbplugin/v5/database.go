@@ -0,0 +1,153 @@
+// Package dbplugin defines version 5 of the database plugin interface.
+//
+// Unlike the version 4 interface in the parent package, every method takes a
+// request struct and returns a response struct, so that fields can be added
+// without breaking existing plugins. Usernames are generated by the plugins
+// from the "username_template" of the connection configuration, and
+// passwords are generated by Vault.
+//
+// Version 5 plugins are currently only supported as builtin plugins; plugins
+// run as a separate process must implement the version 4 interface, which
+// the database backend shims to this one.
+package dbplugin
+
+import (
+	"context"
+	"time"
+)
+
+// UsernameTemplateKey is the key of the username template in the
+// configuration of a connection
+const UsernameTemplateKey = "username_template"
+
+// Database is the interface that version 5 database plugins implement
+type Database interface {
+	// Initialize sets up the connection to the database using the given
+	// configuration, and returns the configuration to store.
+	Initialize(ctx context.Context, req InitializeRequest) (InitializeResponse, error)
+
+	// NewUser creates a user with the password of the request, returning
+	// its username.
+	NewUser(ctx context.Context, req NewUserRequest) (NewUserResponse, error)
+
+	// UpdateUser changes the password or the expiration of an existing user.
+	UpdateUser(ctx context.Context, req UpdateUserRequest) (UpdateUserResponse, error)
+
+	// DeleteUser removes a user from the database.
+	DeleteUser(ctx context.Context, req DeleteUserRequest) (DeleteUserResponse, error)
+
+	// Type returns the type of the database, such as "postgres".
+	Type() (string, error)
+
+	// Close closes the connection to the database.
+	Close() error
+}
+
+// InitializeRequest is the request of Database.Initialize
+type InitializeRequest struct {
+	// Config is the configuration of the connection, including the
+	// username template, if any.
+	Config map[string]interface{}
+
+	// VerifyConnection requests that the connection be checked before
+	// returning.
+	VerifyConnection bool
+}
+
+// InitializeResponse is the response of Database.Initialize
+type InitializeResponse struct {
+	// Config is the configuration of the connection to store. It is given
+	// back to Initialize when the plugin is next started.
+	Config map[string]interface{}
+}
+
+// Statements is a set of database statements, whose format is specific to
+// each plugin
+type Statements struct {
+	Commands []string
+}
+
+// UsernameMetadata is the data available to username templates
+type UsernameMetadata struct {
+	// DisplayName is the display name of the token requesting the
+	// credentials.
+	DisplayName string
+
+	// RoleName is the name of the role the credentials are created for.
+	RoleName string
+}
+
+// CredentialType is the type of credential a user authenticates with
+type CredentialType string
+
+const (
+	CredentialTypePassword          CredentialType = "password"
+	CredentialTypeRSAPrivateKey     CredentialType = "rsa_private_key"
+	CredentialTypeClientCertificate CredentialType = "client_certificate"
+)
+
+// NewUserRequest is the request of Database.NewUser
+type NewUserRequest struct {
+	UsernameConfig UsernameMetadata
+
+	// Statements create the user; RollbackStatements undo a partial
+	// creation.
+	Statements         Statements
+	RollbackStatements Statements
+
+	// CredentialType selects which of Password, PublicKey and Subject is
+	// set. Plugins should reject the types they do not support.
+	CredentialType CredentialType
+
+	// Password is the password of the new user.
+	Password string
+
+	// PublicKey is the PEM-encoded PKIX public key of the new user, whose
+	// private key is only known to the client.
+	PublicKey []byte
+
+	// Subject is the distinguished name of the client certificate the new
+	// user authenticates with.
+	Subject string
+
+	// Expiration is when the user should expire, for databases that
+	// support it.
+	Expiration time.Time
+}
+
+// NewUserResponse is the response of Database.NewUser
+type NewUserResponse struct {
+	Username string
+}
+
+// ChangePassword is a password change of an UpdateUserRequest
+type ChangePassword struct {
+	NewPassword string
+	Statements  Statements
+}
+
+// ChangeExpiration is an expiration change of an UpdateUserRequest
+type ChangeExpiration struct {
+	NewExpiration time.Time
+	Statements    Statements
+}
+
+// UpdateUserRequest is the request of Database.UpdateUser. At least one of
+// Password and Expiration is set.
+type UpdateUserRequest struct {
+	Username   string
+	Password   *ChangePassword
+	Expiration *ChangeExpiration
+}
+
+// UpdateUserResponse is the response of Database.UpdateUser
+type UpdateUserResponse struct{}
+
+// DeleteUserRequest is the request of Database.DeleteUser
+type DeleteUserRequest struct {
+	Username   string
+	Statements Statements
+}
+
+// DeleteUserResponse is the response of Database.DeleteUser
+type DeleteUserResponse struct{}
@@ -9,7 +9,8 @@ import (
 
 	"github.com/fatih/structs"
 	uuid "github.com/hashicorp/go-uuid"
-	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
+	"github.com/hashicorp/vault/helper/template"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -29,6 +30,10 @@ type DatabaseConfig struct {
 	AllowedRoles      []string               `json:"allowed_roles" structs:"allowed_roles" mapstructure:"allowed_roles"`
 
 	RootCredentialsRotateStatements []string `json:"root_credentials_rotate_statements" structs:"root_credentials_rotate_statements" mapstructure:"root_credentials_rotate_statements"`
+
+	// PasswordPolicy is the name of the password policy used to generate
+	// passwords
+	PasswordPolicy string `json:"password_policy" structs:"password_policy" mapstructure:"password_policy"`
 }
 
 // pathResetConnection configures a path to reset a plugin.
@@ -106,6 +111,22 @@ func pathConfigurePluginConnection(b *databaseBackend) *framework.Path {
 				roles are allowed. If "*" all roles are allowed.`,
 			},
 
+			"username_template": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Template of the usernames of the users created for
+				roles, as a Go template. The display name of the token and the
+				name of the role are available as {{.DisplayName}} and
+				{{.RoleName}}. If empty, the default format of the plugin is
+				used.`,
+			},
+
+			"password_policy": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Name of the password policy, configured at
+				sys/policies/password, used to generate passwords. If empty,
+				random alphanumeric passwords are generated.`,
+			},
+
 			"root_rotation_statements": &framework.FieldSchema{
 				Type: framework.TypeStringSlice,
 				Description: `Specifies the database statements to be executed
@@ -224,9 +245,16 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 			return logical.ErrorResponse(respErrEmptyName), nil
 		}
 
+		if usernameTemplate := data.Get("username_template").(string); usernameTemplate != "" {
+			if _, err := template.NewTemplate(usernameTemplate); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid username_template: %s", err)), nil
+			}
+		}
+
 		verifyConnection := data.Get("verify_connection").(bool)
 		allowedRoles := data.Get("allowed_roles").([]string)
 		rootRotationStatements := data.Get("root_rotation_statements").([]string)
+		passwordPolicy := data.Get("password_policy").(string)
 
 		// Remove these entries from the data before we store it keyed under
 		// ConnectionDetails.
@@ -235,13 +263,17 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 		delete(data.Raw, "allowed_roles")
 		delete(data.Raw, "verify_connection")
 		delete(data.Raw, "root_rotation_statements")
+		delete(data.Raw, "password_policy")
 
 		// Create a database plugin and initialize it.
-		db, err := dbplugin.PluginFactory(ctx, pluginName, b.System(), b.logger)
+		db, err := newDatabaseWrapper(ctx, pluginName, b.System(), b.logger)
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", err)), nil
 		}
-		connDetails, err := db.Init(ctx, data.Raw, verifyConnection)
+		initResp, err := db.Initialize(ctx, v5.InitializeRequest{
+			Config:           data.Raw,
+			VerifyConnection: verifyConnection,
+		})
 		if err != nil {
 			db.Close()
 			return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", err)), nil
@@ -259,17 +291,18 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 		}
 
 		b.connections[name] = &dbPluginInstance{
-			Database: db,
+			database: db,
 			name:     name,
 			id:       id,
 		}
 
 		// Store it
 		config := &DatabaseConfig{
-			ConnectionDetails:               connDetails,
+			ConnectionDetails:               initResp.Config,
 			PluginName:                      pluginName,
 			AllowedRoles:                    allowedRoles,
 			RootCredentialsRotateStatements: rootRotationStatements,
+			PasswordPolicy:                  passwordPolicy,
 		}
 		entry, err := logical.StorageEntryJSON(fmt.Sprintf("config/%s", name), config)
 		if err != nil {
@@ -315,6 +348,21 @@ accepts:
 	* "verify_connection" (default: true) - A boolean value denoting if the plugin should verify
 	   it is able to connect to the database using the provided connection
        details.
+
+	* "username_template" - A Go template for the usernames of the users
+	   created for roles, such as:
+
+	   {{printf "v-%s-%s-%s" (.RoleName | truncate 8) (random 20) (unix_time) | truncate 63}}
+
+	   The functions available in templates include "random", "truncate",
+	   "unix_time", "uppercase", "lowercase" and "replace".
+
+	* "password_policy" - The name of a password policy of
+	   sys/policies/password used to generate the passwords of users. Version
+	   4 plugins apply the passwords of the policy to new users and to the
+	   root user after creating or rotating them; the MongoDB, Cassandra and
+	   HANA plugins, which cannot set the credentials of users, ignore the
+	   policy and keep the passwords they generate.
 `
 
 const pathResetConnectionHelpSyn = `
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -63,6 +63,12 @@ func (b *databaseBackend) pathCredsCreateRead() framework.OperationFunc {
 		db.RLock()
 		defer db.RUnlock()
 
+		// The plugin may have been replaced by a version 4 plugin since the
+		// role was written
+		if role.credentialType() != v5.CredentialTypePassword && !db.database.isV5() {
+			return logical.ErrorResponse(fmt.Sprintf("credential type %q is not supported by the plugin of database %q", role.credentialType(), role.DBName)), nil
+		}
+
 		ttl, _, err := framework.CalculateTTL(b.System(), 0, role.DefaultTTL, 0, role.MaxTTL, 0, time.Time{})
 		if err != nil {
 			return nil, err
@@ -72,23 +78,65 @@ func (b *databaseBackend) pathCredsCreateRead() framework.OperationFunc {
 		// to ensure the database credential does not expire before the lease
 		expiration = expiration.Add(5 * time.Second)
 
-		usernameConfig := dbplugin.UsernameConfig{
+		usernameConfig := v5.UsernameMetadata{
 			DisplayName: req.DisplayName,
 			RoleName:    name,
 		}
 
+		// Client certificates can't be renewed, so they are valid for as long
+		// as the lease may be
+		maxTTL := role.MaxTTL
+		if maxTTL == 0 || maxTTL > b.System().MaxLeaseTTL() {
+			maxTTL = b.System().MaxLeaseTTL()
+		}
+		cred, err := b.generateCredential(ctx, role, dbConfig.PasswordPolicy, usernameConfig, time.Now().Add(maxTTL))
+		if err != nil {
+			return nil, err
+		}
+		// Version 4 plugins generate their own passwords, which are only
+		// replaced to follow a password policy
+		if !db.database.isV5() && dbConfig.PasswordPolicy == "" {
+			cred.Password = ""
+		}
+
+		newUserReq := v5.NewUserRequest{
+			UsernameConfig: usernameConfig,
+			Statements: v5.Statements{
+				Commands: role.Statements.Creation,
+			},
+			RollbackStatements: v5.Statements{
+				Commands: role.Statements.Rollback,
+			},
+			CredentialType: role.credentialType(),
+			Password:       cred.Password,
+			PublicKey:      cred.PublicKey,
+			Subject:        cred.Subject,
+			Expiration:     expiration,
+		}
+
 		// Create the user
-		username, password, err := db.CreateUser(ctx, role.Statements, usernameConfig, expiration)
+		newUserResp, password, err := db.database.NewUser(ctx, newUserReq)
 		if err != nil {
 			b.CloseIfShutdown(db, err)
 			return nil, err
 		}
 
-		resp := b.Secret(SecretCredsType).Response(map[string]interface{}{
-			"username": username,
-			"password": password,
-		}, map[string]interface{}{
-			"username": username,
+		respData := map[string]interface{}{
+			"username": newUserResp.Username,
+		}
+		switch role.credentialType() {
+		case v5.CredentialTypePassword:
+			respData["password"] = password
+		case v5.CredentialTypeRSAPrivateKey:
+			respData["rsa_private_key"] = cred.PrivateKey
+		case v5.CredentialTypeClientCertificate:
+			respData["client_certificate"] = cred.Certificate
+			respData["private_key"] = cred.PrivateKey
+			respData["private_key_type"] = cred.PrivateKeyType
+		}
+
+		resp := b.Secret(SecretCredsType).Response(respData, map[string]interface{}{
+			"username": newUserResp.Username,
 			"role":     name,
 		})
 		resp.Secret.TTL = role.DefaultTTL
@@ -97,6 +145,49 @@ func (b *databaseBackend) pathCredsCreateRead() framework.OperationFunc {
 	}
 }
 
+func pathStaticCredsRead(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathStaticCredsRead(),
+		},
+
+		HelpSynopsis:    pathStaticCredsReadHelpSyn,
+		HelpDescription: pathStaticCredsReadHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathStaticCredsRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		role, err := b.StaticRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil || role.StaticAccount == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"username":            role.StaticAccount.Username,
+				"password":            role.StaticAccount.Password,
+				"ttl":                 role.StaticAccount.CredentialTTL().Seconds(),
+				"rotation_period":     role.StaticAccount.RotationPeriod.Seconds(),
+				"last_vault_rotation": role.StaticAccount.LastVaultRotation,
+			},
+		}, nil
+	}
+}
+
 const pathCredsCreateReadHelpSyn = `
 Request database credentials for a certain role.
 `
@@ -105,4 +196,18 @@ const pathCredsCreateReadHelpDesc = `
 This path reads database credentials for a certain role. The
 database credentials will be generated on demand and will be automatically
 revoked when the lease is up.
+
+Depending on the credential type of the role, the username is returned along
+with a "password", an "rsa_private_key", or a "client_certificate" and its
+"private_key".
+`
+
+const pathStaticCredsReadHelpSyn = `
+Request the database credentials of a static role.
+`
+
+const pathStaticCredsReadHelpDesc = `
+This path reads the credentials of the database user of a static role. The
+credentials are not leased; the "ttl" value is the time remaining until the
+password is next rotated.
 `
@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -72,6 +74,20 @@ func pathRoles(b *databaseBackend) *framework.Path {
 				Type:        framework.TypeDurationSecond,
 				Description: "Maximum time a credential is valid for",
 			},
+
+			"credential_type": {
+				Type:    framework.TypeString,
+				Default: string(v5.CredentialTypePassword),
+				Description: `Type of credential issued to the users of the
+				role: "password", "rsa_private_key" or "client_certificate".
+				Defaults to "password".`,
+			},
+
+			"credential_config": {
+				Type: framework.TypeMap,
+				Description: `Configuration of the generation of the
+				credentials of the credential type.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -106,6 +122,14 @@ func (b *databaseBackend) pathRoleRead() framework.OperationFunc {
 			return nil, nil
 		}
 
+		// The private key of the CA is never returned
+		credentialConfig := make(map[string]interface{}, len(role.CredentialConfig))
+		for k, v := range role.CredentialConfig {
+			if k != "ca_private_key" {
+				credentialConfig[k] = v
+			}
+		}
+
 		return &logical.Response{
 			Data: map[string]interface{}{
 				"db_name":               role.DBName,
@@ -115,6 +139,8 @@ func (b *databaseBackend) pathRoleRead() framework.OperationFunc {
 				"renew_statements":      role.Statements.Renewal,
 				"default_ttl":           role.DefaultTTL.Seconds(),
 				"max_ttl":               role.MaxTTL.Seconds(),
+				"credential_type":       string(role.credentialType()),
+				"credential_config":     credentialConfig,
 			},
 		}, nil
 	}
@@ -162,12 +188,32 @@ func (b *databaseBackend) pathRoleCreate() framework.OperationFunc {
 			Renewal:    renewStmts,
 		}
 
+		credentialType := v5.CredentialType(data.Get("credential_type").(string))
+		credentialConfig, err := validateCredentialConfig(credentialType, data.Get("credential_config").(map[string]interface{}))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		// Only version 5 plugins can create users for the other credential
+		// types
+		if credentialType != "" && credentialType != v5.CredentialTypePassword {
+			db, err := b.GetConnection(ctx, req.Storage, dbName)
+			if err != nil {
+				return nil, err
+			}
+			if !db.database.isV5() {
+				return logical.ErrorResponse(fmt.Sprintf("credential type %q is not supported by the plugin of database %q", credentialType, dbName)), nil
+			}
+		}
+
 		// Store it
 		entry, err := logical.StorageEntryJSON("role/"+name, &roleEntry{
-			DBName:     dbName,
-			Statements: statements,
-			DefaultTTL: defaultTTL,
-			MaxTTL:     maxTTL,
+			DBName:           dbName,
+			Statements:       statements,
+			DefaultTTL:       defaultTTL,
+			MaxTTL:           maxTTL,
+			CredentialType:   credentialType,
+			CredentialConfig: credentialConfig,
 		})
 		if err != nil {
 			return nil, err
@@ -185,6 +231,21 @@ type roleEntry struct {
 	Statements dbplugin.Statements `json:"statements"`
 	DefaultTTL time.Duration       `json:"default_ttl"`
 	MaxTTL     time.Duration       `json:"max_ttl"`
+
+	CredentialType   v5.CredentialType      `json:"credential_type"`
+	CredentialConfig map[string]interface{} `json:"credential_config"`
+
+	// StaticAccount is only set for static roles
+	StaticAccount *staticAccount `json:"static_account,omitempty"`
+}
+
+// credentialType returns the type of the credentials of the role; roles
+// written before credential types were introduced use passwords
+func (r *roleEntry) credentialType() v5.CredentialType {
+	if r.CredentialType == "" {
+		return v5.CredentialTypePassword
+	}
+	return r.CredentialType
 }
 
 const pathRoleHelpSyn = `
@@ -228,6 +289,28 @@ database plugin:
 
 The "renew_statements" parameter customizes the statement string used to renew a
 user.
+
 The "rollback_statements' parameter customizes the statement string used to
 rollback a change if needed.
+
+The "credential_type" parameter selects the credential issued to users:
+
+  * "password" (default) - A random password.
+
+  * "rsa_private_key" - An RSA key pair; the public key is given to the
+    plugin and the private key is returned. "credential_config" accepts
+    "key_bits" (2048, 3072 or 4096) and "format" ("pkcs8").
+
+  * "client_certificate" - A client certificate signed by the given CA; the
+    subject of the certificate is given to the plugin, and the certificate
+    and its private key are returned. "credential_config" accepts
+    "common_name_template" (required), "ca_cert" and "ca_private_key"
+    (required, PEM-encoded), "key_type" ("rsa" or "ec"), "key_bits" and
+    "signature_bits" (256, 384 or 512).
+
+Credential types other than "password" are only supported by version 5
+plugins; roles using them cannot be written for a database whose plugin is a
+version 4 plugin. The builtin PostgreSQL plugin supports "client_certificate":
+the role it creates is named after the common name of the certificate, for
+use with the "cert" authentication method of PostgreSQL.
 `
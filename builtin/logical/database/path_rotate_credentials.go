@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -52,10 +53,35 @@ func (b *databaseBackend) pathRotateCredentialsUpdate() framework.OperationFunc
 		db.Lock()
 		defer db.Unlock()
 
-		connectionDetails, err := db.RotateRootCredentials(ctx, config.RootCredentialsRotateStatements)
+		// Without a password policy, version 4 plugins pick the new password
+		// themselves and return the connection details to store
+		var newPassword string
+		rootUsername, _ := config.ConnectionDetails["username"].(string)
+		if db.database.isV5() || config.PasswordPolicy != "" {
+			if rootUsername == "" {
+				return logical.ErrorResponse("unable to rotate root credentials: no username in connection configuration"), nil
+			}
+			newPassword, err = b.generatePassword(ctx, config.PasswordPolicy)
+			if err != nil {
+				return nil, err
+			}
+		}
+		connectionDetails, err := db.database.UpdateUser(ctx, v5.UpdateUserRequest{
+			Username: rootUsername,
+			Password: &v5.ChangePassword{
+				NewPassword: newPassword,
+				Statements: v5.Statements{
+					Commands: config.RootCredentialsRotateStatements,
+				},
+			},
+		}, true)
 		if err != nil {
 			return nil, err
 		}
+		if connectionDetails == nil {
+			connectionDetails = config.ConnectionDetails
+			connectionDetails["password"] = newPassword
+		}
 
 		config.ConnectionDetails = connectionDetails
 		entry, err := logical.StorageEntryJSON(fmt.Sprintf("config/%s", name), config)
@@ -68,7 +94,7 @@ func (b *databaseBackend) pathRotateCredentialsUpdate() framework.OperationFunc
 
 		// Close the plugin
 		db.closed = true
-		if err := db.Database.Close(); err != nil {
+		if err := db.database.Close(); err != nil {
 			b.Logger().Error("error closing the database plugin connection", "err", err)
 		}
 		// Even on error, still remove the connection
@@ -78,6 +104,54 @@ func (b *databaseBackend) pathRotateCredentialsUpdate() framework.OperationFunc
 	}
 }
 
+func pathRotateRole(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRoleCredentialsUpdate(),
+		},
+
+		HelpSynopsis:    pathRotateRoleCredentialsUpdateHelpSyn,
+		HelpDescription: pathRotateRoleCredentialsUpdateHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathRotateRoleCredentialsUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse(respErrEmptyName), nil
+		}
+
+		role, err := b.StaticRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil || role.StaticAccount == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+		}
+
+		// Reuse the WAL of a failed scheduled rotation, if any
+		var walID string
+		if item, err := b.credRotationQueue.PopByKey(name); err == nil {
+			walID, _ = item.Value.(string)
+		}
+
+		if err := b.rotateStaticRole(ctx, req.Storage, name, walID); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
 const pathRotateCredentialsUpdateHelpSyn = `
 Request to rotate the root credentials for a certain database connection.
 `
@@ -85,3 +159,12 @@ Request to rotate the root credentials for a certain database connection.
 const pathRotateCredentialsUpdateHelpDesc = `
 This path attempts to rotate the root credentials for the given database. 
 `
+
+const pathRotateRoleCredentialsUpdateHelpSyn = `
+Request to rotate the credentials of a static role.
+`
+
+const pathRotateRoleCredentialsUpdateHelpDesc = `
+This path rotates the password of the database user of a static role right
+away, and schedules the next rotation one rotation period later.
+`
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// minRotationPeriod is the shortest rotation period of a static role;
+// rotations are processed by the periodic function, which runs every minute
+const minRotationPeriod = time.Minute
+
+func pathListStaticRoles(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathStaticRoleList(),
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func pathStaticRoles(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"db_name": {
+				Type:        framework.TypeString,
+				Description: "Name of the database this role acts on.",
+			},
+			"username": {
+				Type: framework.TypeString,
+				Description: `Name of the existing database user whose password
+				is managed by this role. Cannot be changed after creation.`,
+			},
+			"rotation_period": {
+				Type: framework.TypeDurationSecond,
+				Description: `Period between rotations of the password of the
+				user. Must be at least one minute.`,
+			},
+			"rotation_statements": {
+				Type: framework.TypeStringSlice,
+				Description: `Specifies the database statements to be executed
+				to set the password of the user. See the plugin's API page for
+				more information on support and formatting for this
+				parameter.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathStaticRoleRead(),
+			logical.UpdateOperation: b.pathStaticRoleCreateUpdate(),
+			logical.DeleteOperation: b.pathStaticRoleDelete(),
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		lock := b.roleLock(name)
+		lock.Lock()
+		defer lock.Unlock()
+
+		// The password of the user is left as last set
+		b.credRotationQueue.PopByKey(name)
+
+		err := req.Storage.Delete(ctx, staticRolePrefix+name)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		role, err := b.StaticRole(ctx, req.Storage, data.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if role == nil || role.StaticAccount == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"db_name":             role.DBName,
+				"username":            role.StaticAccount.Username,
+				"rotation_period":     role.StaticAccount.RotationPeriod.Seconds(),
+				"rotation_statements": role.Statements.Rotation,
+				"last_vault_rotation": role.StaticAccount.LastVaultRotation,
+			},
+		}, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		entries, err := req.Storage.List(ctx, staticRolePrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		return logical.ListResponse(entries), nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleCreateUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse("empty role name attribute given"), nil
+		}
+
+		lock := b.roleLock(name)
+		lock.Lock()
+		defer lock.Unlock()
+
+		role, err := b.StaticRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		createRole := role == nil
+		if createRole {
+			role = &roleEntry{
+				StaticAccount: &staticAccount{},
+			}
+		}
+
+		if dbNameRaw, ok := data.GetOk("db_name"); ok {
+			dbName := dbNameRaw.(string)
+			if !createRole && dbName != role.DBName {
+				return logical.ErrorResponse("cannot update the database name of a static role"), nil
+			}
+			role.DBName = dbName
+		}
+		if role.DBName == "" {
+			return logical.ErrorResponse("empty database name attribute given"), nil
+		}
+
+		if usernameRaw, ok := data.GetOk("username"); ok {
+			username := usernameRaw.(string)
+			if !createRole && username != role.StaticAccount.Username {
+				return logical.ErrorResponse("cannot update the username of a static role"), nil
+			}
+			role.StaticAccount.Username = username
+		}
+		if role.StaticAccount.Username == "" {
+			return logical.ErrorResponse("empty username attribute given"), nil
+		}
+
+		if rotationPeriodRaw, ok := data.GetOk("rotation_period"); ok {
+			role.StaticAccount.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+		}
+		if role.StaticAccount.RotationPeriod < minRotationPeriod {
+			return logical.ErrorResponse(fmt.Sprintf("rotation_period must be at least %s", minRotationPeriod)), nil
+		}
+
+		if rotationStmtsRaw, ok := data.GetOk("rotation_statements"); ok {
+			role.Statements.Rotation = rotationStmtsRaw.([]string)
+		}
+
+		dbConfig, err := b.DatabaseConfig(ctx, req.Storage, role.DBName)
+		if err != nil {
+			return nil, err
+		}
+
+		// If role name isn't in the database's allowed roles, send back a
+		// permission denied.
+		if !strutil.StrListContains(dbConfig.AllowedRoles, "*") && !strutil.StrListContainsGlob(dbConfig.AllowedRoles, name) {
+			return nil, logical.ErrPermissionDenied
+		}
+
+		if createRole {
+			b.staticAccountsLock.Lock()
+			defer b.staticAccountsLock.Unlock()
+
+			existing, err := b.staticRoleForUser(ctx, req.Storage, role.DBName, role.StaticAccount.Username)
+			if err != nil {
+				return nil, err
+			}
+			if existing != "" {
+				return logical.ErrorResponse(fmt.Sprintf("username %q is already managed by static role %q", role.StaticAccount.Username, existing)), nil
+			}
+
+			// Set the password right away so that the role is usable, which
+			// also stores the role
+			walID, err := b.setStaticAccount(ctx, req.Storage, name, role, "")
+			if err != nil {
+				framework.DeleteWAL(ctx, req.Storage, walID)
+				return nil, err
+			}
+		} else {
+			if err := storeStaticRole(ctx, req.Storage, name, role); err != nil {
+				return nil, err
+			}
+		}
+
+		// The next rotation depends on the rotation period, which may have
+		// changed
+		if err := b.pushStaticRole(name, role, "", role.StaticAccount.NextRotationTime()); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+// staticRoleForUser returns the name of the static role managing the given
+// user of a database, if any
+func (b *databaseBackend) staticRoleForUser(ctx context.Context, s logical.Storage, dbName, username string) (string, error) {
+	names, err := s.List(ctx, staticRolePrefix)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range names {
+		role, err := b.StaticRole(ctx, s, name)
+		if err != nil {
+			return "", err
+		}
+		if role == nil || role.StaticAccount == nil {
+			continue
+		}
+		if role.DBName == dbName && role.StaticAccount.Username == username {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+const pathStaticRoleHelpSyn = `
+Manage the static roles that can be created with this backend.
+`
+
+const pathStaticRoleHelpDesc = `
+This path lets you manage the static roles of this backend. A static role
+maps to an existing database user whose password is owned by Vault: the
+password is set when the role is created, and rotated every
+"rotation_period". The current credentials are read from the
+"static-creds/<name>" path.
+
+The "db_name" and "username" parameters are required and cannot be changed
+once the role is created. A database user can only be managed by a single
+static role.
+
+The "rotation_statements" parameter customizes the statements used to set the
+password of the user. The following variables are replaced:
+
+  * "name" and "username" - The name of the database user.
+
+  * "password" - The new password of the database user.
+
+Example of a decent rotation_statements for a postgresql database plugin:
+
+	ALTER USER "{{name}}" WITH PASSWORD '{{password}}';
+`
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// mockStaticDatabase records the passwords set by static roles
+type mockStaticDatabase struct {
+	dbplugin.Database
+
+	passwords map[string]string
+	fail      bool
+}
+
+func (m *mockStaticDatabase) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (string, string, error) {
+	if m.fail {
+		return "", "", errors.New("set credentials failed")
+	}
+	m.passwords[staticConfig.Username] = staticConfig.Password
+	return staticConfig.Username, staticConfig.Password, nil
+}
+
+func (m *mockStaticDatabase) Close() error { return nil }
+
+func TestBackend_StaticRole_Rotation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := b.(*databaseBackend)
+	defer backend.Cleanup(context.Background())
+
+	// Use a mock connection rather than a real database
+	mockDB := &mockStaticDatabase{passwords: map[string]string{}}
+	backend.connections["plugin-test"] = &dbPluginInstance{
+		database: databaseVersionWrapper{v4: mockDB},
+		name:     "plugin-test",
+		id:       "plugin-test-id",
+	}
+	entry, err := logical.StorageEntryJSON("config/plugin-test", &DatabaseConfig{
+		PluginName:   "mock",
+		AllowedRoles: []string{"static-*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+	readCreds := func() map[string]interface{} {
+		t.Helper()
+		resp := doReq(logical.ReadOperation, "static-creds/static-test", nil)
+		if resp == nil || resp.IsError() {
+			t.Fatalf("bad: %#v", resp)
+		}
+		return resp.Data
+	}
+
+	// Invalid roles are rejected
+	resp := doReq(logical.UpdateOperation, "static-roles/static-test", map[string]interface{}{
+		"db_name":         "plugin-test",
+		"username":        "static-user",
+		"rotation_period": "10s",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for short rotation period, got: %#v", resp)
+	}
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-roles/other",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"db_name":         "plugin-test",
+			"username":        "static-user",
+			"rotation_period": "1h",
+		},
+	})
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied for role outside of allowed_roles, got: %v", err)
+	}
+
+	// Creating the role sets the password right away
+	resp = doReq(logical.UpdateOperation, "static-roles/static-test", map[string]interface{}{
+		"db_name":         "plugin-test",
+		"username":        "static-user",
+		"rotation_period": "1h",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	creds := readCreds()
+	password := creds["password"].(string)
+	if password == "" || mockDB.passwords["static-user"] != password || creds["username"] != "static-user" {
+		t.Fatalf("bad: %#v", creds)
+	}
+	if ttl := creds["ttl"].(float64); ttl <= 0 || ttl > 3600 {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+
+	resp = doReq(logical.UpdateOperation, "static-roles/static-test", map[string]interface{}{
+		"username": "other-user",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error when changing the username, got: %#v", resp)
+	}
+
+	// A user can only be managed by one static role
+	resp = doReq(logical.UpdateOperation, "static-roles/static-other", map[string]interface{}{
+		"db_name":         "plugin-test",
+		"username":        "static-user",
+		"rotation_period": "1h",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for duplicate username, got: %#v", resp)
+	}
+
+	resp = doReq(logical.ListOperation, "static-roles/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "static-test" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Manual rotation
+	doReq(logical.UpdateOperation, "rotate-role/static-test", nil)
+	if creds := readCreds(); creds["password"] == password || mockDB.passwords["static-user"] != creds["password"] {
+		t.Fatalf("password was not rotated: %#v", creds)
+	}
+	password = readCreds()["password"].(string)
+
+	// The periodic function leaves roles that are not due alone
+	doReq(logical.RollbackOperation, "", nil)
+	if readCreds()["password"] != password {
+		t.Fatal("password rotated before it was due")
+	}
+
+	// Make the role due for rotation, with a failing database
+	role, err := backend.StaticRole(context.Background(), config.StorageView, "static-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role.StaticAccount.LastVaultRotation = time.Now().Add(-2 * time.Hour)
+	if err := storeStaticRole(context.Background(), config.StorageView, "static-test", role); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.pushStaticRole("static-test", role, "", role.StaticAccount.NextRotationTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	mockDB.fail = true
+	doReq(logical.RollbackOperation, "", nil)
+	if readCreds()["password"] != password {
+		t.Fatal("password changed despite the failed rotation")
+	}
+	item, err := backend.credRotationQueue.PopByKey("static-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	walID := item.Value.(string)
+	wal, err := backend.findStaticWAL(context.Background(), config.StorageView, walID)
+	if err != nil || wal == nil {
+		t.Fatalf("expected WAL for the failed rotation, err: %v", err)
+	}
+
+	// The retry reuses the password of the WAL
+	mockDB.fail = false
+	item.Priority = time.Now().Unix()
+	if err := backend.credRotationQueue.Push(item); err != nil {
+		t.Fatal(err)
+	}
+	doReq(logical.RollbackOperation, "", nil)
+	if creds := readCreds(); creds["password"] != wal.NewPassword || mockDB.passwords["static-user"] != wal.NewPassword {
+		t.Fatalf("WAL password was not used: %#v", creds)
+	}
+	if entry, err := framework.GetWAL(context.Background(), config.StorageView, walID); err != nil || entry != nil {
+		t.Fatalf("expected WAL to be deleted, got: %#v, err: %v", entry, err)
+	}
+
+	// Deleting the role unschedules it
+	doReq(logical.DeleteOperation, "static-roles/static-test", nil)
+	if _, err := backend.credRotationQueue.PopByKey("static-test"); err == nil {
+		t.Fatal("expected role to be removed from the rotation queue")
+	}
+	resp = doReq(logical.ReadOperation, "static-creds/static-test", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for deleted role, got: %#v", resp)
+	}
+}
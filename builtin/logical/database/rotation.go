@@ -0,0 +1,345 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/queue"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// staticWALKey is the WAL kind of static account password changes
+	staticWALKey = "staticRotationKey"
+
+	// staticRolePrefix is the storage prefix of static roles
+	staticRolePrefix = "static-role/"
+
+	// rotationRetryInterval is how long to wait before retrying a failed
+	// rotation
+	rotationRetryInterval = 10 * time.Second
+)
+
+// setCredentialsWAL records a password change of a static account before it
+// is sent to the database, so that the password is not lost if the change is
+// applied by the database but Vault fails before storing it
+type setCredentialsWAL struct {
+	RoleName          string    `json:"role_name"`
+	Username          string    `json:"username"`
+	NewPassword       string    `json:"new_password"`
+	LastVaultRotation time.Time `json:"last_vault_rotation"`
+}
+
+// staticAccount is the database account whose password is owned by a static
+// role
+type staticAccount struct {
+	Username          string        `json:"username"`
+	Password          string        `json:"password"`
+	LastVaultRotation time.Time     `json:"last_vault_rotation"`
+	RotationPeriod    time.Duration `json:"rotation_period"`
+}
+
+// NextRotationTime returns the time at which the password is due to be
+// rotated
+func (s *staticAccount) NextRotationTime() time.Time {
+	return s.LastVaultRotation.Add(s.RotationPeriod)
+}
+
+// CredentialTTL returns the time remaining until the next rotation
+func (s *staticAccount) CredentialTTL() time.Duration {
+	ttl := s.NextRotationTime().Sub(time.Now())
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+func (b *databaseBackend) roleLock(name string) *locksutil.LockEntry {
+	return locksutil.LockForKey(b.roleLocks, name)
+}
+
+// StaticRole returns the static role with the given name
+func (b *databaseBackend) StaticRole(ctx context.Context, s logical.Storage, name string) (*roleEntry, error) {
+	entry, err := s.Get(ctx, staticRolePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func storeStaticRole(ctx context.Context, s logical.Storage, name string, role *roleEntry) error {
+	entry, err := logical.StorageEntryJSON(staticRolePrefix+name, role)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// pushStaticRole schedules the next rotation of a static role, replacing
+// any rotation already scheduled. The ID of the WAL of a failed rotation is
+// kept with the item so that the rotation can be retried with the same
+// password.
+func (b *databaseBackend) pushStaticRole(name string, role *roleEntry, walID string, priority time.Time) error {
+	b.credRotationQueue.PopByKey(name)
+	return b.credRotationQueue.Push(&queue.Item{
+		Key:      name,
+		Value:    walID,
+		Priority: priority.Unix(),
+	})
+}
+
+// populateQueue schedules the rotation of all the static roles in storage.
+// It is run when the backend first handles a periodic function call, as
+// storage is not available when the backend is created, and on the following
+// calls until it succeeds.
+func (b *databaseBackend) populateQueue(ctx context.Context, s logical.Storage) error {
+	b.queuePopulateLock.Lock()
+	defer b.queuePopulateLock.Unlock()
+
+	if b.queuePopulated {
+		return nil
+	}
+
+	names, err := s.List(ctx, staticRolePrefix)
+	if err != nil {
+		return errwrap.Wrapf("unable to list static roles: {{err}}", err)
+	}
+
+	for _, name := range names {
+		role, err := b.StaticRole(ctx, s, name)
+		if err != nil {
+			return err
+		}
+		if role == nil || role.StaticAccount == nil {
+			continue
+		}
+
+		// Roles scheduled since the backend was created are already in the
+		// queue
+		err = b.credRotationQueue.Push(&queue.Item{
+			Key:      name,
+			Priority: role.StaticAccount.NextRotationTime().Unix(),
+		})
+		if err != nil && err != queue.ErrDuplicateItem {
+			return err
+		}
+	}
+
+	b.queuePopulated = true
+	return nil
+}
+
+// periodicFunc rotates the passwords of the static roles that are due
+func (b *databaseBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	if err := b.populateQueue(ctx, req.Storage); err != nil {
+		return errwrap.Wrapf("unable to populate the rotation queue: {{err}}", err)
+	}
+
+	for {
+		item, err := b.credRotationQueue.Pop()
+		if err == queue.ErrEmpty {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// The queue is ordered by rotation time, so no other role is due
+		if item.Priority > time.Now().Unix() {
+			return b.credRotationQueue.Push(item)
+		}
+
+		walID, _ := item.Value.(string)
+		if err := b.rotateStaticRole(ctx, req.Storage, item.Key, walID); err != nil {
+			b.logger.Error("unable to rotate static role password", "role", item.Key, "error", err)
+		}
+	}
+}
+
+// rotateStaticRole sets a new password for the account of a static role and
+// schedules its next rotation. A failed rotation is retried after
+// rotationRetryInterval.
+func (b *databaseBackend) rotateStaticRole(ctx context.Context, s logical.Storage, name, walID string) error {
+	lock := b.roleLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.StaticRole(ctx, s, name)
+	if err != nil {
+		if pushErr := b.pushStaticRole(name, nil, walID, time.Now().Add(rotationRetryInterval)); pushErr != nil {
+			b.logger.Error("unable to reschedule static role rotation", "role", name, "error", pushErr)
+		}
+		return err
+	}
+	// The role was deleted
+	if role == nil || role.StaticAccount == nil {
+		return nil
+	}
+
+	walID, err = b.setStaticAccount(ctx, s, name, role, walID)
+	if err != nil {
+		if pushErr := b.pushStaticRole(name, role, walID, time.Now().Add(rotationRetryInterval)); pushErr != nil {
+			b.logger.Error("unable to reschedule static role rotation", "role", name, "error", pushErr)
+		}
+		return err
+	}
+
+	return b.pushStaticRole(name, role, "", role.StaticAccount.NextRotationTime())
+}
+
+// setStaticAccount sets a new password for the account of a static role and
+// stores it in the role. If the ID of the WAL of a previous, failed attempt
+// is given, its password is reused, as the database may have applied it. The
+// ID of the WAL is returned if the attempt fails. The role lock must be held.
+func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storage, name string, role *roleEntry, walID string) (string, error) {
+	var password string
+	if walID != "" {
+		wal, err := b.findStaticWAL(ctx, s, walID)
+		if err != nil {
+			return walID, err
+		}
+		switch {
+		case wal == nil:
+			walID = ""
+		case wal.RoleName != name || !wal.LastVaultRotation.Equal(role.StaticAccount.LastVaultRotation):
+			// The role has been rotated since the WAL was written
+			framework.DeleteWAL(ctx, s, walID)
+			walID = ""
+		default:
+			password = wal.NewPassword
+		}
+	}
+
+	if password == "" {
+		dbConfig, err := b.DatabaseConfig(ctx, s, role.DBName)
+		if err != nil {
+			return "", err
+		}
+		password, err = b.generatePassword(ctx, dbConfig.PasswordPolicy)
+		if err != nil {
+			return "", err
+		}
+
+		walID, err = framework.PutWAL(ctx, s, staticWALKey, &setCredentialsWAL{
+			RoleName:          name,
+			Username:          role.StaticAccount.Username,
+			NewPassword:       password,
+			LastVaultRotation: role.StaticAccount.LastVaultRotation,
+		})
+		if err != nil {
+			return "", errwrap.Wrapf("unable to write WAL entry: {{err}}", err)
+		}
+	}
+
+	if err := b.applyStaticPassword(ctx, s, name, role, password); err != nil {
+		return walID, err
+	}
+
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		b.logger.Warn("unable to delete WAL entry", "role", name, "error", err)
+	}
+
+	return "", nil
+}
+
+// applyStaticPassword sets the password of the account of a static role in
+// the database and stores it in the role
+func (b *databaseBackend) applyStaticPassword(ctx context.Context, s logical.Storage, name string, role *roleEntry, password string) error {
+	db, err := b.GetConnection(ctx, s, role.DBName)
+	if err != nil {
+		return err
+	}
+
+	db.RLock()
+	_, err = db.database.UpdateUser(ctx, v5.UpdateUserRequest{
+		Username: role.StaticAccount.Username,
+		Password: &v5.ChangePassword{
+			NewPassword: password,
+			Statements: v5.Statements{
+				Commands: role.Statements.Rotation,
+			},
+		},
+	}, false)
+	db.RUnlock()
+	if err != nil {
+		b.CloseIfShutdown(db, err)
+		return errwrap.Wrapf(fmt.Sprintf("unable to set the password of static role %q: {{err}}", name), err)
+	}
+
+	role.StaticAccount.Password = password
+	role.StaticAccount.LastVaultRotation = time.Now()
+	return storeStaticRole(ctx, s, name, role)
+}
+
+func (b *databaseBackend) findStaticWAL(ctx context.Context, s logical.Storage, id string) (*setCredentialsWAL, error) {
+	entry, err := framework.GetWAL(ctx, s, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil || entry.Kind != staticWALKey {
+		return nil, nil
+	}
+
+	return decodeStaticWAL(entry.Data)
+}
+
+func decodeStaticWAL(data interface{}) (*setCredentialsWAL, error) {
+	raw, err := jsonutil.EncodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var wal setCredentialsWAL
+	if err := jsonutil.DecodeJSON(raw, &wal); err != nil {
+		return nil, err
+	}
+
+	return &wal, nil
+}
+
+// walRollback finishes static account password changes left incomplete, by
+// setting the password once more, unless the role has since been rotated or
+// deleted.
+func (b *databaseBackend) walRollback(ctx context.Context, req *logical.Request, kind string, data interface{}) error {
+	if kind != staticWALKey {
+		return fmt.Errorf("unknown type to rollback: %q", kind)
+	}
+
+	wal, err := decodeStaticWAL(data)
+	if err != nil {
+		return err
+	}
+
+	lock := b.roleLock(wal.RoleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.StaticRole(ctx, req.Storage, wal.RoleName)
+	if err != nil {
+		return err
+	}
+	if role == nil || role.StaticAccount == nil || !wal.LastVaultRotation.Equal(role.StaticAccount.LastVaultRotation) {
+		return nil
+	}
+
+	if err := b.applyStaticPassword(ctx, req.Storage, wal.RoleName, role, wal.NewPassword); err != nil {
+		return err
+	}
+
+	return b.pushStaticRole(wal.RoleName, role, "", role.StaticAccount.NextRotationTime())
+}
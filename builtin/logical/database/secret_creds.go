@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -62,7 +63,15 @@ func (b *databaseBackend) secretCredsRenew() framework.OperationFunc {
 			// Adding a small buffer since the TTL will be calculated again after this call
 			// to ensure the database credential does not expire before the lease
 			expireTime = expireTime.Add(5 * time.Second)
-			err := db.RenewUser(ctx, role.Statements, username, expireTime)
+			_, err := db.database.UpdateUser(ctx, v5.UpdateUserRequest{
+				Username: username,
+				Expiration: &v5.ChangeExpiration{
+					NewExpiration: expireTime,
+					Statements: v5.Statements{
+						Commands: role.Statements.Renewal,
+					},
+				},
+			}, false)
 			if err != nil {
 				b.CloseIfShutdown(db, err)
 				return nil, err
@@ -108,7 +117,13 @@ func (b *databaseBackend) secretCredsRevoke() framework.OperationFunc {
 		db.RLock()
 		defer db.RUnlock()
 
-		if err := db.RevokeUser(ctx, role.Statements, username); err != nil {
+		_, err = db.database.DeleteUser(ctx, v5.DeleteUserRequest{
+			Username: username,
+			Statements: v5.Statements{
+				Commands: role.Statements.Revocation,
+			},
+		})
+		if err != nil {
 			b.CloseIfShutdown(db, err)
 			return nil, err
 		}
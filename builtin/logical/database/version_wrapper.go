@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp/errwrap"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
+	"github.com/hashicorp/vault/helper/pluginutil"
+)
+
+// databaseVersionWrapper exposes the version 5 database interface for both
+// version 5 plugins and the version 4 plugins it shims. Exactly one of v4
+// and v5 is set.
+type databaseVersionWrapper struct {
+	v4 dbplugin.Database
+	v5 v5.Database
+}
+
+// newDatabaseWrapper creates an instance of the given plugin. Builtin plugins
+// implementing the version 5 interface are used as is; all other plugins
+// are version 4 plugins. Builtin plugins are only instantiated once.
+func newDatabaseWrapper(ctx context.Context, pluginName string, sys pluginutil.LookRunnerUtil, logger log.Logger) (databaseVersionWrapper, error) {
+	pluginRunner, err := sys.LookupPlugin(ctx, pluginName)
+	if err != nil {
+		return databaseVersionWrapper{}, err
+	}
+
+	if pluginRunner.Builtin {
+		dbRaw, err := pluginRunner.BuiltinFactory()
+		if err != nil {
+			return databaseVersionWrapper{}, errwrap.Wrapf("error initializing plugin: {{err}}", err)
+		}
+		if db, ok := dbRaw.(v5.Database); ok {
+			return databaseVersionWrapper{v5: db}, nil
+		}
+
+		db, err := dbplugin.NewBuiltinDatabase(pluginName, dbRaw, logger)
+		if err != nil {
+			return databaseVersionWrapper{}, err
+		}
+		return databaseVersionWrapper{v4: db}, nil
+	}
+
+	db, err := dbplugin.PluginFactory(ctx, pluginName, sys, logger)
+	if err != nil {
+		return databaseVersionWrapper{}, err
+	}
+
+	return databaseVersionWrapper{v4: db}, nil
+}
+
+func (d databaseVersionWrapper) isV5() bool {
+	return d.v5 != nil
+}
+
+// Initialize sets up the connection to the database and returns the
+// configuration to store
+func (d databaseVersionWrapper) Initialize(ctx context.Context, req v5.InitializeRequest) (v5.InitializeResponse, error) {
+	if d.isV5() {
+		return d.v5.Initialize(ctx, req)
+	}
+
+	config, err := d.v4.Init(ctx, req.Config, req.VerifyConnection)
+	return v5.InitializeResponse{
+		Config: config,
+	}, err
+}
+
+// NewUser creates a user, returning its username and password. Version 5
+// plugins are given the credential of the request, while version 4 plugins
+// generate the password and the username themselves and only support
+// passwords; if the request has a password, it then replaces the generated
+// one, unless the plugin cannot set the credentials of users.
+func (d databaseVersionWrapper) NewUser(ctx context.Context, req v5.NewUserRequest) (v5.NewUserResponse, string, error) {
+	if d.isV5() {
+		resp, err := d.v5.NewUser(ctx, req)
+		return resp, req.Password, err
+	}
+
+	if req.CredentialType != "" && req.CredentialType != v5.CredentialTypePassword {
+		return v5.NewUserResponse{}, "", fmt.Errorf("credential type %q is not supported by version 4 plugins", req.CredentialType)
+	}
+
+	statements := dbplugin.Statements{
+		Creation: req.Statements.Commands,
+		Rollback: req.RollbackStatements.Commands,
+	}
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: req.UsernameConfig.DisplayName,
+		RoleName:    req.UsernameConfig.RoleName,
+	}
+	username, password, err := d.v4.CreateUser(ctx, statements, usernameConfig, req.Expiration)
+	if err != nil {
+		return v5.NewUserResponse{}, "", err
+	}
+
+	if req.Password != "" {
+		_, _, err = d.v4.SetCredentials(ctx, dbplugin.Statements{}, dbplugin.StaticUserConfig{
+			Username: username,
+			Password: req.Password,
+		})
+		switch {
+		case isSetCredentialsUnsupported(err):
+			// Keep the password generated by the plugin
+		case err != nil:
+			// Don't leave behind a user whose password is never returned
+			if revokeErr := d.v4.RevokeUser(ctx, dbplugin.Statements{}, username); revokeErr != nil {
+				err = multierror.Append(err, revokeErr)
+			}
+			return v5.NewUserResponse{}, "", errwrap.Wrapf("unable to set the password of the new user: {{err}}", err)
+		default:
+			password = req.Password
+		}
+	}
+
+	return v5.NewUserResponse{
+		Username: username,
+	}, password, nil
+}
+
+// UpdateUser changes the password or the expiration of a user. If no new
+// password is given for the root user of a version 4 plugin, or if the plugin
+// cannot set the credentials of users, the plugin picks its own root password
+// and the new connection configuration to store is returned; otherwise, the
+// password of the request is used and the returned configuration is nil.
+func (d databaseVersionWrapper) UpdateUser(ctx context.Context, req v5.UpdateUserRequest, isRootUser bool) (map[string]interface{}, error) {
+	if req.Password == nil && req.Expiration == nil {
+		return nil, fmt.Errorf("no changes requested")
+	}
+
+	if d.isV5() {
+		_, err := d.v5.UpdateUser(ctx, req)
+		return nil, err
+	}
+
+	if req.Password != nil {
+		if isRootUser && req.Password.NewPassword == "" {
+			return d.v4.RotateRootCredentials(ctx, req.Password.Statements.Commands)
+		}
+
+		_, _, err := d.v4.SetCredentials(ctx, dbplugin.Statements{
+			Rotation: req.Password.Statements.Commands,
+		}, dbplugin.StaticUserConfig{
+			Username: req.Username,
+			Password: req.Password.NewPassword,
+		})
+		if isRootUser && isSetCredentialsUnsupported(err) {
+			return d.v4.RotateRootCredentials(ctx, req.Password.Statements.Commands)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Expiration != nil {
+		err := d.v4.RenewUser(ctx, dbplugin.Statements{
+			Renewal: req.Expiration.Statements.Commands,
+		}, req.Username, req.Expiration.NewExpiration)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// DeleteUser removes a user from the database
+func (d databaseVersionWrapper) DeleteUser(ctx context.Context, req v5.DeleteUserRequest) (v5.DeleteUserResponse, error) {
+	if d.isV5() {
+		return d.v5.DeleteUser(ctx, req)
+	}
+
+	err := d.v4.RevokeUser(ctx, dbplugin.Statements{
+		Revocation: req.Statements.Commands,
+	}, req.Username)
+	return v5.DeleteUserResponse{}, err
+}
+
+// Type returns the type of the database
+func (d databaseVersionWrapper) Type() (string, error) {
+	if d.isV5() {
+		return d.v5.Type()
+	}
+
+	return d.v4.Type()
+}
+
+// Close closes the connection to the database
+func (d databaseVersionWrapper) Close() error {
+	if d.isV5() {
+		return d.v5.Close()
+	}
+
+	return d.v4.Close()
+}
+
+// isSetCredentialsUnsupported returns whether the error is returned by a
+// version 4 plugin that cannot set the credentials of users. The error is
+// compared by message, as it is not preserved by the error sanitizer and the
+// plugin transports.
+func isSetCredentialsUnsupported(err error) bool {
+	return err != nil && strings.Contains(err.Error(), dbplugin.ErrSetCredentialsUnsupported.Error())
+}
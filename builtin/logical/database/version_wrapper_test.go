@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
+	"github.com/hashicorp/vault/logical"
+)
+
+// mockV5Database records the users managed through the version 5 interface
+type mockV5Database struct {
+	passwords   map[string]string
+	expirations map[string]time.Time
+	publicKeys  map[string][]byte
+	subjects    map[string]string
+	statements  []string
+}
+
+var _ v5.Database = &mockV5Database{}
+
+func (m *mockV5Database) Initialize(ctx context.Context, req v5.InitializeRequest) (v5.InitializeResponse, error) {
+	return v5.InitializeResponse{Config: req.Config}, nil
+}
+
+func (m *mockV5Database) NewUser(ctx context.Context, req v5.NewUserRequest) (v5.NewUserResponse, error) {
+	username := req.UsernameConfig.RoleName + "-" + req.UsernameConfig.DisplayName
+	switch req.CredentialType {
+	case v5.CredentialTypePassword:
+		if req.Password == "" {
+			return v5.NewUserResponse{}, errors.New("missing password")
+		}
+		m.passwords[username] = req.Password
+	case v5.CredentialTypeRSAPrivateKey:
+		if len(req.PublicKey) == 0 {
+			return v5.NewUserResponse{}, errors.New("missing public key")
+		}
+		m.publicKeys[username] = req.PublicKey
+	case v5.CredentialTypeClientCertificate:
+		if req.Subject == "" {
+			return v5.NewUserResponse{}, errors.New("missing subject")
+		}
+		m.subjects[username] = req.Subject
+	default:
+		return v5.NewUserResponse{}, errors.New("unsupported credential type")
+	}
+	m.expirations[username] = req.Expiration
+	m.statements = append(m.statements, req.Statements.Commands...)
+	return v5.NewUserResponse{Username: username}, nil
+}
+
+func (m *mockV5Database) UpdateUser(ctx context.Context, req v5.UpdateUserRequest) (v5.UpdateUserResponse, error) {
+	if req.Password != nil {
+		m.passwords[req.Username] = req.Password.NewPassword
+		m.statements = append(m.statements, req.Password.Statements.Commands...)
+	}
+	if req.Expiration != nil {
+		m.expirations[req.Username] = req.Expiration.NewExpiration
+		m.statements = append(m.statements, req.Expiration.Statements.Commands...)
+	}
+	return v5.UpdateUserResponse{}, nil
+}
+
+func (m *mockV5Database) DeleteUser(ctx context.Context, req v5.DeleteUserRequest) (v5.DeleteUserResponse, error) {
+	delete(m.passwords, req.Username)
+	m.statements = append(m.statements, req.Statements.Commands...)
+	return v5.DeleteUserResponse{}, nil
+}
+
+func (m *mockV5Database) Type() (string, error) { return "mock", nil }
+
+func (m *mockV5Database) Close() error { return nil }
+
+func TestBackend_V5Plugin(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := b.(*databaseBackend)
+	defer backend.Cleanup(context.Background())
+
+	mockDB := &mockV5Database{
+		passwords:   map[string]string{},
+		expirations: map[string]time.Time{},
+		publicKeys:  map[string][]byte{},
+		subjects:    map[string]string{},
+	}
+	backend.connections["plugin-test"] = &dbPluginInstance{
+		database: databaseVersionWrapper{v5: mockDB},
+		name:     "plugin-test",
+		id:       "plugin-test-id",
+	}
+	entry, err := logical.StorageEntryJSON("config/plugin-test", &DatabaseConfig{
+		PluginName: "mock",
+		ConnectionDetails: map[string]interface{}{
+			"username": "root",
+			"password": "initial",
+		},
+		AllowedRoles:                    []string{"*"},
+		RootCredentialsRotateStatements: []string{"rotate root"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(req *logical.Request) *logical.Response {
+		t.Helper()
+		req.Storage = config.StorageView
+		resp, err := b.HandleRequest(context.Background(), req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s resp: %#v err: %v", req.Path, resp, err)
+		}
+		return resp
+	}
+
+	doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/plugin-role-test",
+		Data: map[string]interface{}{
+			"db_name":               "plugin-test",
+			"creation_statements":   "create",
+			"revocation_statements": "revoke",
+			"renew_statements":      "renew",
+			"default_ttl":           "1h",
+		},
+	})
+
+	// The password is generated by the backend and given to the plugin
+	resp := doReq(&logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "creds/plugin-role-test",
+		DisplayName: "token",
+	})
+	username := resp.Data["username"].(string)
+	password := resp.Data["password"].(string)
+	if username != "plugin-role-test-token" || password == "" || mockDB.passwords[username] != password {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if expiration := mockDB.expirations[username]; time.Until(expiration) < 59*time.Minute {
+		t.Fatalf("bad expiration: %s", expiration)
+	}
+
+	secret := resp.Secret
+	secret.IssueTime = time.Now()
+	secret.Increment = 2 * time.Hour
+	doReq(&logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    secret,
+	})
+	doReq(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    secret,
+	})
+	if _, ok := mockDB.passwords[username]; ok {
+		t.Fatal("expected user to be deleted")
+	}
+	expected := []string{"create", "renew", "revoke"}
+	if len(mockDB.statements) != len(expected) {
+		t.Fatalf("bad statements: %v", mockDB.statements)
+	}
+	for i, statement := range expected {
+		if mockDB.statements[i] != statement {
+			t.Fatalf("bad statements: %v", mockDB.statements)
+		}
+	}
+
+	// The new root password is stored in the connection configuration
+	doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "rotate-root/plugin-test",
+	})
+	dbConfig, err := backend.DatabaseConfig(context.Background(), config.StorageView, "plugin-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPassword := dbConfig.ConnectionDetails["password"]
+	if newPassword == "initial" || newPassword != mockDB.passwords["root"] {
+		t.Fatalf("bad root password: %v", newPassword)
+	}
+}
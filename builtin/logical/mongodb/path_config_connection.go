@@ -22,6 +22,10 @@ func pathConfigConnection(b *backend) *framework.Path {
 				Default:     true,
 				Description: `If set, uri is verified by actually connecting to the database`,
 			},
+			"password_policy": {
+				Type:        framework.TypeString,
+				Description: "Name of the password policy used to generate passwords; if empty, UUIDs are used",
+			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation:   b.pathConnectionRead,
@@ -74,7 +78,8 @@ func (b *backend) pathConnectionWrite(ctx context.Context, req *logical.Request,
 
 	// Store it
 	entry, err := logical.StorageEntryJSON("config/connection", connectionConfig{
-		URI: uri,
+		URI:            uri,
+		PasswordPolicy: data.Get("password_policy").(string),
 	})
 	if err != nil {
 		return nil, err
@@ -94,6 +99,10 @@ func (b *backend) pathConnectionWrite(ctx context.Context, req *logical.Request,
 
 type connectionConfig struct {
 	URI string `json:"uri" structs:"uri" mapstructure:"uri"`
+
+	// PasswordPolicy is the name of the password policy used to generate
+	// the passwords of users
+	PasswordPolicy string `json:"password_policy" structs:"password_policy" mapstructure:"password_policy"`
 }
 
 const pathConfigConnectionHelpSyn = `
@@ -109,4 +118,7 @@ A MongoDB URI looks like:
 See https://docs.mongodb.org/manual/reference/connection-string/ for detailed documentation of the URI format.
 
 When configuring the connection string, the backend will verify its validity.
+
+The "password_policy" parameter names a password policy of sys/policies/password used to
+generate the passwords of users.
 `
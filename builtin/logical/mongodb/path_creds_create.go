@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -28,6 +29,31 @@ func pathCredsCreate(b *backend) *framework.Path {
 	}
 }
 
+// generatePassword generates a password from the password policy of the
+// connection configuration, or a UUID if no policy is set
+func (b *backend) generatePassword(ctx context.Context, s logical.Storage) (string, error) {
+	entry, err := s.Get(ctx, "config/connection")
+	if err != nil {
+		return "", err
+	}
+	var connConfig connectionConfig
+	if entry != nil {
+		if err := entry.DecodeJSON(&connConfig); err != nil {
+			return "", err
+		}
+	}
+
+	if connConfig.PasswordPolicy == "" {
+		return uuid.GenerateUUID()
+	}
+
+	password, err := b.System().GeneratePasswordFromPolicy(ctx, connConfig.PasswordPolicy)
+	if err != nil {
+		return "", errwrap.Wrapf("unable to generate password: {{err}}", err)
+	}
+	return password, nil
+}
+
 func (b *backend) pathCredsCreateRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
@@ -62,7 +88,7 @@ func (b *backend) pathCredsCreateRead(ctx context.Context, req *logical.Request,
 
 	username := fmt.Sprintf("vault-%s%s", displayName, userUUID)
 
-	password, err := uuid.GenerateUUID()
+	password, err := b.generatePassword(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
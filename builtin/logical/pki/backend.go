@@ -33,17 +33,20 @@ func Backend() *backend {
 				"ca",
 				"crl/pem",
 				"crl",
+				"est/*",
 			},
 
 			LocalStorage: []string{
 				"revoked/",
 				"crl",
 				"certs/",
+				"cert_metadata/",
 			},
 
 			Root: []string{
 				"root",
 				"root/sign-self-issued",
+				"root/cross-sign",
 			},
 
 			SealWrapStorage: []string{
@@ -57,12 +60,15 @@ func Backend() *backend {
 			pathGenerateRoot(&b),
 			pathSignIntermediate(&b),
 			pathSignSelfIssued(&b),
+			pathCrossSign(&b),
 			pathDeleteRoot(&b),
 			pathGenerateIntermediate(&b),
 			pathSetSignedIntermediate(&b),
 			pathConfigCA(&b),
+			pathConfigCACrossSigned(&b),
 			pathConfigCRL(&b),
 			pathConfigURLs(&b),
+			pathConfigEST(&b),
 			pathSignVerbatim(&b),
 			pathSign(&b),
 			pathIssue(&b),
@@ -73,8 +79,15 @@ func Backend() *backend {
 			pathFetchCRLViaCertPath(&b),
 			pathFetchValid(&b),
 			pathFetchListCerts(&b),
+			pathFetchListRevokedCerts(&b),
+			pathFetchCertMetadata(&b),
+			pathSearchCerts(&b),
 			pathRevoke(&b),
 			pathTidy(&b),
+			pathESTCACerts(&b),
+			pathESTSimpleEnroll(&b),
+			pathESTSimpleReenroll(&b),
+			pathESTCSRAttrs(&b),
 		},
 
 		Secrets: []*framework.Secret{
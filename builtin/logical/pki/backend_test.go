@@ -2665,9 +2665,11 @@ func TestBackend_Permitted_DNS_Domains(t *testing.T) {
 		}
 	}
 
-	// Check issuing and signing against root's permitted domains
+	// Check issuing and signing against root's permitted domains. As in RFC
+	// 5280, constraints without a leading period also permit subdomains.
 	checkIssue(false, "common_name", "zipzap.com")
-	checkIssue(false, "common_name", "host.foobar.com")
+	checkIssue(false, "common_name", "host.abc.com")
+	checkIssue(true, "common_name", "host.foobar.com")
 	checkIssue(true, "common_name", "host.zipzap.com")
 	checkIssue(true, "common_name", "foobar.com")
 
@@ -2716,7 +2718,7 @@ func TestBackend_Permitted_DNS_Domains(t *testing.T) {
 
 	// Check enforcement with the intermediate's set values
 	path = "int/"
-	checkIssue(false, "common_name", "host.abc.com")
+	checkIssue(true, "common_name", "host.abc.com")
 	checkIssue(false, "common_name", "xyz.com")
 	checkIssue(true, "common_name", "abc.com")
 	checkIssue(true, "common_name", "host.xyz.com")
@@ -26,6 +26,7 @@ import (
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 	"github.com/ryanuber/go-glob"
 	"golang.org/x/crypto/cryptobyte"
 	cbbasn1 "golang.org/x/crypto/cryptobyte/asn1"
@@ -69,6 +70,10 @@ type creationParameters struct {
 	// Only used when signing a CA cert
 	UseCSRValues        bool
 	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+	PermittedIPRanges   []*net.IPNet
+	ExcludedIPRanges    []*net.IPNet
+	CustomExtensions    []pkix.Extension
 
 	// URLs to encode into the certificate
 	URLs *urlEntries
@@ -80,6 +85,10 @@ type creationParameters struct {
 type caInfoBundle struct {
 	certutil.ParsedCertBundle
 	URLs *urlEntries
+
+	// CrossSigned holds the cross-signed versions of the CA certificate
+	// along with the certificates of their issuers
+	CrossSigned []*certutil.CertBlock
 }
 
 func (b *caInfoBundle) GetCAChain() []*certutil.CertBlock {
@@ -100,9 +109,132 @@ func (b *caInfoBundle) GetCAChain() []*certutil.CertBlock {
 		}
 	}
 
+	if len(b.CrossSigned) == 0 {
+		return chain
+	}
+
+	// When cross-signed certificates are configured, serve every currently
+	// valid certificate so that clients can build a path to either root.
+	// Each cross-signed certificate is followed by its own issuers.
+	if len(chain) == 0 {
+		chain = append(chain, &certutil.CertBlock{
+			Certificate: b.Certificate,
+			Bytes:       b.CertificateBytes,
+		})
+	}
+	now := time.Now()
+	valid := func(block *certutil.CertBlock) bool {
+		return !now.Before(block.Certificate.NotBefore) && !now.After(block.Certificate.NotAfter)
+	}
+	contains := func(block *certutil.CertBlock) bool {
+		for _, existing := range chain {
+			if bytes.Equal(existing.Bytes, block.Bytes) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, alternate := range b.CrossSigned {
+		if !isAlternateCACert(b.Certificate, alternate.Certificate) || !valid(alternate) || contains(alternate) {
+			continue
+		}
+		chain = append(chain, alternate)
+		for current := alternate; !isSelfSigned(current.Certificate); {
+			var next *certutil.CertBlock
+			for _, issuer := range b.CrossSigned {
+				if valid(issuer) && !contains(issuer) &&
+					bytes.Equal(issuer.Certificate.RawSubject, current.Certificate.RawIssuer) &&
+					current.Certificate.CheckSignatureFrom(issuer.Certificate) == nil {
+					next = issuer
+					break
+				}
+			}
+			if next == nil {
+				break
+			}
+			chain = append(chain, next)
+			current = next
+		}
+	}
+
 	return chain
 }
 
+// latestChainExpiry returns the latest time until which at least one chain
+// from the CA certificate, or one of its cross-signed versions, up to a
+// self-signed root (or the topmost issuer known to the backend) remains
+// entirely valid
+func (b *caInfoBundle) latestChainExpiry() time.Time {
+	var pool []*x509.Certificate
+	for _, block := range b.CAChain {
+		pool = append(pool, block.Certificate)
+	}
+	for _, block := range b.CrossSigned {
+		pool = append(pool, block.Certificate)
+	}
+
+	latest := chainExpiry(b.Certificate, pool, b.Certificate.NotAfter, 0)
+	for _, block := range b.CrossSigned {
+		if !isAlternateCACert(b.Certificate, block.Certificate) {
+			continue
+		}
+		if expiry := chainExpiry(block.Certificate, pool, block.Certificate.NotAfter, 0); expiry.After(latest) {
+			latest = expiry
+		}
+	}
+
+	return latest
+}
+
+// chainExpiry walks every path from cert to a self-signed root through the
+// given pool and returns the latest expiry of any of the paths, where the
+// expiry of a path is that of its first certificate to expire
+func chainExpiry(cert *x509.Certificate, pool []*x509.Certificate, expiry time.Time, depth int) time.Time {
+	if cert.NotAfter.Before(expiry) {
+		expiry = cert.NotAfter
+	}
+	if isSelfSigned(cert) || depth > 10 {
+		return expiry
+	}
+
+	var latest time.Time
+	found := false
+	for _, issuer := range pool {
+		if !bytes.Equal(issuer.RawSubject, cert.RawIssuer) || bytes.Equal(issuer.Raw, cert.Raw) {
+			continue
+		}
+		if cert.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		found = true
+		if pathExpiry := chainExpiry(issuer, pool, expiry, depth+1); pathExpiry.After(latest) {
+			latest = pathExpiry
+		}
+	}
+
+	// The issuer is not known to the backend, so the path is assumed to end
+	// at a trust anchor held by the client
+	if !found {
+		return expiry
+	}
+
+	return latest
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// isAlternateCACert returns whether cert carries the same subject and public
+// key as the CA certificate while being issued by a different CA
+func isAlternateCACert(caCert, cert *x509.Certificate) bool {
+	if !bytes.Equal(caCert.RawSubject, cert.RawSubject) || bytes.Equal(caCert.RawIssuer, cert.RawIssuer) {
+		return false
+	}
+	equal, err := certutil.ComparePublicKeys(caCert.PublicKey, cert.PublicKey)
+	return err == nil && equal
+}
+
 var (
 	// A note on hostnameRegex: although we set the StrictDomainName option
 	// when doing the idna conversion, this appears to only affect output, not
@@ -110,6 +242,21 @@ var (
 	// we still need to use this to check the output.
 	hostnameRegex                = regexp.MustCompile(`^(\*\.)?(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])$`)
 	oidExtensionBasicConstraints = []int{2, 5, 29, 19}
+
+	// Extensions that are built from other parameters and so cannot be
+	// supplied as custom extensions
+	reservedExtensionOIDs = []asn1.ObjectIdentifier{
+		{2, 5, 29, 14},              // subject key identifier
+		{2, 5, 29, 15},              // key usage
+		{2, 5, 29, 17},              // subject alternative name
+		{2, 5, 29, 19},              // basic constraints
+		{2, 5, 29, 30},              // name constraints
+		{2, 5, 29, 31},              // CRL distribution points
+		{2, 5, 29, 32},              // certificate policies
+		{2, 5, 29, 35},              // authority key identifier
+		{2, 5, 29, 37},              // extended key usage
+		{1, 3, 6, 1, 5, 5, 7, 1, 1}, // authority information access
+	}
 )
 
 func oidInExtensions(oid asn1.ObjectIdentifier, extensions []pkix.Extension) bool {
@@ -154,6 +301,8 @@ func validateKeyTypeLength(keyType string, keyBits int) *logical.Response {
 			return logical.ErrorResponse(fmt.Sprintf(
 				"unsupported bit length for EC key: %d", keyBits))
 		}
+	case "ed25519":
+		// Ed25519 keys have a fixed size, so key_bits is ignored
 	case "any":
 	default:
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -188,7 +337,7 @@ func fetchCAInfo(ctx context.Context, req *logical.Request) (*caInfoBundle, erro
 		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
 	}
 
-	caInfo := &caInfoBundle{*parsedBundle, nil}
+	caInfo := &caInfoBundle{ParsedCertBundle: *parsedBundle}
 
 	entries, err := getURLs(ctx, req)
 	if err != nil {
@@ -203,6 +352,11 @@ func fetchCAInfo(ctx context.Context, req *logical.Request) (*caInfoBundle, erro
 	}
 	caInfo.URLs = entries
 
+	caInfo.CrossSigned, err = fetchCrossSignedCerts(ctx, req.Storage)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch cross-signed certificates: %v", err)}
+	}
+
 	return caInfo, nil
 }
 
@@ -545,7 +699,9 @@ func generateCert(ctx context.Context,
 	if isCA {
 		data.params.IsCA = isCA
 
-		data.params.PermittedDNSDomains = data.apiData.Get("permitted_dns_domains").([]string)
+		if err := addCAConstraintParams(data); err != nil {
+			return nil, err
+		}
 
 		if data.signingBundle == nil {
 			// Generating a self-signed root certificate
@@ -667,6 +823,14 @@ func signCert(b *backend,
 				pubKey.Params().BitSize)}
 		}
 
+	case "ed25519":
+		// Verify that the key matches the role type
+		if csr.PublicKeyAlgorithm != x509.Ed25519 {
+			return nil, errutil.UserError{Err: fmt.Sprintf(
+				"role requires keys of type %s",
+				data.role.KeyType)}
+		}
+
 	case "any":
 		// We only care about running RSA < 2048 bit checks, so if not RSA
 		// break out
@@ -699,7 +863,9 @@ func signCert(b *backend,
 	data.params.UseCSRValues = useCSRValues
 
 	if isCA {
-		data.params.PermittedDNSDomains = data.apiData.Get("permitted_dns_domains").([]string)
+		if err := addCAConstraintParams(data); err != nil {
+			return nil, err
+		}
 	}
 
 	parsedBundle, err := signCertificate(data)
@@ -1088,10 +1254,9 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 	}
 
 	// This will only be filled in from the generation paths
-	if len(data.params.PermittedDNSDomains) > 0 {
-		certTemplate.PermittedDNSDomains = data.params.PermittedDNSDomains
-		certTemplate.PermittedDNSDomainsCritical = true
-	}
+	addNameConstraints(data, certTemplate)
+
+	certTemplate.ExtraExtensions = append(certTemplate.ExtraExtensions, data.params.CustomExtensions...)
 
 	addPolicyIdentifiers(data, certTemplate)
 
@@ -1110,12 +1275,14 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 			certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 		case certutil.ECPrivateKey:
 			certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+		case certutil.Ed25519PrivateKey:
+			certTemplate.SignatureAlgorithm = x509.PureEd25519
 		}
 
 		caCert := data.signingBundle.Certificate
 		certTemplate.AuthorityKeyId = caCert.SubjectKeyId
 
-		err = checkPermittedDNSDomains(certTemplate, caCert)
+		err = checkNameConstraints(certTemplate, data.signingBundle)
 		if err != nil {
 			return nil, errutil.UserError{Err: err.Error()}
 		}
@@ -1135,6 +1302,8 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 			certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 		case "ec":
 			certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+		case "ed25519":
+			certTemplate.SignatureAlgorithm = x509.PureEd25519
 		}
 
 		certTemplate.AuthorityKeyId = subjKeyID
@@ -1153,17 +1322,7 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 	}
 
 	if data.signingBundle != nil {
-		if len(data.signingBundle.Certificate.AuthorityKeyId) > 0 &&
-			!bytes.Equal(data.signingBundle.Certificate.AuthorityKeyId, data.signingBundle.Certificate.SubjectKeyId) {
-
-			result.CAChain = []*certutil.CertBlock{
-				&certutil.CertBlock{
-					Certificate: data.signingBundle.Certificate,
-					Bytes:       data.signingBundle.CertificateBytes,
-				},
-			}
-			result.CAChain = append(result.CAChain, data.signingBundle.CAChain...)
-		}
+		result.CAChain = data.signingBundle.GetCAChain()
 	}
 
 	return result, nil
@@ -1215,6 +1374,8 @@ func createCSR(data *dataBundle) (*certutil.ParsedCSRBundle, error) {
 		csrTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 	case "ec":
 		csrTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case "ed25519":
+		csrTemplate.SignatureAlgorithm = x509.PureEd25519
 	}
 
 	csr, err := x509.CreateCertificateRequest(rand.Reader, csrTemplate, result.PrivateKey)
@@ -1279,6 +1440,8 @@ func signCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 		certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 	case certutil.ECPrivateKey:
 		certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case certutil.Ed25519PrivateKey:
+		certTemplate.SignatureAlgorithm = x509.PureEd25519
 	}
 
 	if data.params.UseCSRValues {
@@ -1334,11 +1497,11 @@ func signCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 		certTemplate.IsCA = false
 	}
 
-	if len(data.params.PermittedDNSDomains) > 0 {
-		certTemplate.PermittedDNSDomains = data.params.PermittedDNSDomains
-		certTemplate.PermittedDNSDomainsCritical = true
-	}
-	err = checkPermittedDNSDomains(certTemplate, caCert)
+	addNameConstraints(data, certTemplate)
+
+	certTemplate.ExtraExtensions = append(certTemplate.ExtraExtensions, data.params.CustomExtensions...)
+
+	err = checkNameConstraints(certTemplate, data.signingBundle)
 	if err != nil {
 		return nil, errutil.UserError{Err: err.Error()}
 	}
@@ -1360,40 +1523,183 @@ func signCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 	return result, nil
 }
 
-func checkPermittedDNSDomains(template, ca *x509.Certificate) error {
-	if len(ca.PermittedDNSDomains) == 0 {
-		return nil
+// checkNameConstraints verifies that the names in the template are allowed
+// by the name constraints of the issuing CA and of every CA certificate in
+// its chain
+func checkNameConstraints(template *x509.Certificate, signingBundle *caInfoBundle) error {
+	cas := []*x509.Certificate{signingBundle.Certificate}
+	for _, ca := range signingBundle.CAChain {
+		if ca.Certificate != nil {
+			cas = append(cas, ca.Certificate)
+		}
 	}
 
-	namesToCheck := map[string]struct{}{
-		template.Subject.CommonName: struct{}{},
+	namesToCheck := map[string]struct{}{}
+	if cn := template.Subject.CommonName; cn != "" && !strings.Contains(cn, "@") {
+		namesToCheck[cn] = struct{}{}
 	}
 	for _, name := range template.DNSNames {
 		namesToCheck[name] = struct{}{}
 	}
 
-	var badName string
-NameCheck:
-	for name := range namesToCheck {
-		for _, perm := range ca.PermittedDNSDomains {
-			switch {
-			case strings.HasPrefix(perm, ".") && strings.HasSuffix(name, perm):
-				// .example.com matches my.host.example.com and
-				// host.example.com but does not match example.com
-				break NameCheck
-			case perm == name:
-				break NameCheck
+	for _, ca := range cas {
+		for name := range namesToCheck {
+			if len(ca.PermittedDNSDomains) > 0 && !dnsNameMatchesConstraints(name, ca.PermittedDNSDomains) {
+				return fmt.Errorf("name %q disallowed by CA's permitted DNS domains", name)
+			}
+			if dnsNameMatchesConstraints(name, ca.ExcludedDNSDomains) {
+				return fmt.Errorf("name %q disallowed by CA's excluded DNS domains", name)
+			}
+		}
+
+		for _, ip := range template.IPAddresses {
+			if len(ca.PermittedIPRanges) > 0 && !ipMatchesConstraints(ip, ca.PermittedIPRanges) {
+				return fmt.Errorf("IP address %q disallowed by CA's permitted IP ranges", ip.String())
+			}
+			if ipMatchesConstraints(ip, ca.ExcludedIPRanges) {
+				return fmt.Errorf("IP address %q disallowed by CA's excluded IP ranges", ip.String())
 			}
 		}
-		badName = name
-		break
 	}
 
-	if badName == "" {
-		return nil
+	return nil
+}
+
+// dnsNameMatchesConstraints returns whether the name matches any of the given
+// DNS name constraints. As in RFC 5280, a constraint with a leading period,
+// e.g. .example.com, matches my.host.example.com and host.example.com but not
+// example.com; any other constraint matches the name itself and all of its
+// subdomains.
+func dnsNameMatchesConstraints(name string, constraints []string) bool {
+	name = strings.ToLower(name)
+	for _, constraint := range constraints {
+		constraint = strings.ToLower(constraint)
+		switch {
+		case strings.HasPrefix(constraint, "."):
+			if strings.HasSuffix(name, constraint) {
+				return true
+			}
+		case name == constraint || strings.HasSuffix(name, "."+constraint):
+			return true
+		}
+	}
+	return false
+}
+
+func ipMatchesConstraints(ip net.IP, constraints []*net.IPNet) bool {
+	for _, constraint := range constraints {
+		if constraint.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addNameConstraints adds the name constraints extension to CA certificates
+func addNameConstraints(data *dataBundle, certTemplate *x509.Certificate) {
+	certTemplate.PermittedDNSDomains = data.params.PermittedDNSDomains
+	certTemplate.ExcludedDNSDomains = data.params.ExcludedDNSDomains
+	certTemplate.PermittedIPRanges = data.params.PermittedIPRanges
+	certTemplate.ExcludedIPRanges = data.params.ExcludedIPRanges
+
+	if len(certTemplate.PermittedDNSDomains) > 0 ||
+		len(certTemplate.ExcludedDNSDomains) > 0 ||
+		len(certTemplate.PermittedIPRanges) > 0 ||
+		len(certTemplate.ExcludedIPRanges) > 0 {
+		certTemplate.PermittedDNSDomainsCritical = true
+	}
+}
+
+// addCAConstraintParams reads the name constraints, policies and custom
+// extensions that may only be set when generating or signing a CA
+// certificate
+func addCAConstraintParams(data *dataBundle) error {
+	data.params.PermittedDNSDomains = data.apiData.Get("permitted_dns_domains").([]string)
+	data.params.ExcludedDNSDomains = data.apiData.Get("excluded_dns_domains").([]string)
+
+	var err error
+	data.params.PermittedIPRanges, err = parseIPRanges(data.apiData.Get("permitted_ip_ranges").([]string))
+	if err != nil {
+		return errutil.UserError{Err: fmt.Sprintf("invalid permitted_ip_ranges: %v", err)}
+	}
+	data.params.ExcludedIPRanges, err = parseIPRanges(data.apiData.Get("excluded_ip_ranges").([]string))
+	if err != nil {
+		return errutil.UserError{Err: fmt.Sprintf("invalid excluded_ip_ranges: %v", err)}
+	}
+
+	for _, oidstr := range data.apiData.Get("policy_identifiers").([]string) {
+		if _, err := stringToOid(oidstr); err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("invalid policy identifier %q: %v", oidstr, err)}
+		}
+		data.params.PolicyIdentifiers = append(data.params.PolicyIdentifiers, oidstr)
+	}
+
+	data.params.CustomExtensions, err = parseCustomExtensions(data.apiData.Get("custom_extensions").([]interface{}))
+	if err != nil {
+		return errutil.UserError{Err: fmt.Sprintf("invalid custom_extensions: %v", err)}
 	}
 
-	return fmt.Errorf("name %q disallowed by CA's permitted DNS domains", badName)
+	return nil
+}
+
+func parseIPRanges(ranges []string) ([]*net.IPNet, error) {
+	var ret []*net.IPNet
+	for _, r := range ranges {
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, ipNet)
+	}
+	return ret, nil
+}
+
+// parseCustomExtensions parses a list of objects with "oid", "critical" and
+// base64-encoded DER "value" keys into certificate extensions
+func parseCustomExtensions(input []interface{}) ([]pkix.Extension, error) {
+	var ret []pkix.Extension
+	for _, raw := range input {
+		var ext struct {
+			OID      string `mapstructure:"oid"`
+			Critical bool   `mapstructure:"critical"`
+			Value    string `mapstructure:"value"`
+		}
+		if err := mapstructure.WeakDecode(raw, &ext); err != nil {
+			return nil, err
+		}
+
+		oid, err := stringToOid(ext.OID)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse OID %q: %v", ext.OID, err)
+		}
+		for _, reserved := range reservedExtensionOIDs {
+			if oid.Equal(reserved) {
+				return nil, fmt.Errorf("extension %s is managed by Vault and cannot be set directly", ext.OID)
+			}
+		}
+		for _, existing := range ret {
+			if oid.Equal(existing.Id) {
+				return nil, fmt.Errorf("extension %s specified more than once", ext.OID)
+			}
+		}
+
+		value, err := base64.StdEncoding.DecodeString(ext.Value)
+		if err != nil {
+			return nil, fmt.Errorf("value of extension %s is not valid base64: %v", ext.OID, err)
+		}
+		var check asn1.RawValue
+		rest, err := asn1.Unmarshal(value, &check)
+		if err != nil || len(rest) > 0 {
+			return nil, fmt.Errorf("value of extension %s is not a single DER-encoded value", ext.OID)
+		}
+
+		ret = append(ret, pkix.Extension{
+			Id:       oid,
+			Critical: ext.Critical,
+			Value:    value,
+		})
+	}
+	return ret, nil
 }
 
 func convertRespToPKCS8(resp *logical.Response) error {
@@ -1436,6 +1742,9 @@ func convertRespToPKCS8(resp *logical.Response) error {
 		signer, err = x509.ParsePKCS1PrivateKey(keyData)
 	case certutil.ECPrivateKey:
 		signer, err = x509.ParseECPrivateKey(keyData)
+	case certutil.Ed25519PrivateKey:
+		// Ed25519 keys are always stored in PKCS8 form already
+		return nil
 	default:
 		return fmt.Errorf("unknown private key type %q", privKeyType)
 	}
@@ -2,11 +2,19 @@ package pki
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"testing"
 
 	"strings"
 
+	"github.com/hashicorp/vault/helper/certutil"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -123,3 +131,267 @@ func TestPki_FetchCertBySerial(t *testing.T) {
 		}
 	}
 }
+
+func TestPki_CANameConstraintsAndExtensions(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/internal",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name":           "myvault.com",
+			"ttl":                   "40h",
+			"permitted_dns_domains": ".myvault.com",
+			"excluded_dns_domains":  ".secret.myvault.com",
+			"excluded_ip_ranges":    "10.0.0.0/8",
+			"policy_identifiers":    "1.3.6.1.4.1.7.8",
+			"custom_extensions": []interface{}{
+				map[string]interface{}{
+					"oid": "1.3.6.1.4.1.7.9",
+					// DER encoding of the UTF8String "vault"
+					"value": "DAV2YXVsdA==",
+				},
+			},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	caInfo, err := fetchCAInfo(context.Background(), &logical.Request{Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := caInfo.Certificate
+	if len(ca.PermittedDNSDomains) != 1 || ca.PermittedDNSDomains[0] != ".myvault.com" {
+		t.Fatalf("bad permitted DNS domains: %v", ca.PermittedDNSDomains)
+	}
+	if len(ca.ExcludedDNSDomains) != 1 || ca.ExcludedDNSDomains[0] != ".secret.myvault.com" {
+		t.Fatalf("bad excluded DNS domains: %v", ca.ExcludedDNSDomains)
+	}
+	if len(ca.ExcludedIPRanges) != 1 || ca.ExcludedIPRanges[0].String() != "10.0.0.0/8" {
+		t.Fatalf("bad excluded IP ranges: %v", ca.ExcludedIPRanges)
+	}
+	if len(ca.PolicyIdentifiers) != 1 || ca.PolicyIdentifiers[0].String() != "1.3.6.1.4.1.7.8" {
+		t.Fatalf("bad policy identifiers: %v", ca.PolicyIdentifiers)
+	}
+	var found bool
+	for _, ext := range ca.Extensions {
+		if ext.Id.String() == "1.3.6.1.4.1.7.9" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("custom extension not found in CA certificate")
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/example",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"allow_any_name": true,
+			"allow_ip_sans":  true,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	checkIssue := func(valid bool, data map[string]interface{}) {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "issue/example",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case valid && resp.IsError():
+			t.Fatalf("expected success for %v, got: %#v", data, resp)
+		case !valid && !resp.IsError():
+			t.Fatalf("expected error for %v", data)
+		}
+	}
+
+	checkIssue(true, map[string]interface{}{"common_name": "host.myvault.com"})
+	checkIssue(false, map[string]interface{}{"common_name": "host.example.com"})
+	checkIssue(false, map[string]interface{}{"common_name": "db.secret.myvault.com"})
+	checkIssue(false, map[string]interface{}{"common_name": "host.myvault.com", "alt_names": "db.secret.myvault.com"})
+	checkIssue(true, map[string]interface{}{"common_name": "host.myvault.com", "ip_sans": "192.168.1.1"})
+	checkIssue(false, map[string]interface{}{"common_name": "host.myvault.com", "ip_sans": "10.1.2.3"})
+
+	// Constraints without a leading period also cover the subdomains
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "root",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/internal",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name":           "myvault.com",
+			"ttl":                   "40h",
+			"permitted_dns_domains": "myvault.com",
+			"excluded_dns_domains":  "secret.myvault.com",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	checkIssue(true, map[string]interface{}{"common_name": "myvault.com"})
+	checkIssue(true, map[string]interface{}{"common_name": "host.myvault.com"})
+	checkIssue(false, map[string]interface{}{"common_name": "notmyvault.com"})
+	checkIssue(false, map[string]interface{}{"common_name": "secret.myvault.com"})
+	checkIssue(false, map[string]interface{}{"common_name": "db.secret.myvault.com"})
+	checkIssue(false, map[string]interface{}{"common_name": "host.myvault.com", "alt_names": "a.b.SECRET.myvault.com"})
+
+	// Extensions managed by Vault cannot be overridden
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "int.myvault.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/sign-intermediate",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: csr,
+			})),
+			"custom_extensions": []interface{}{
+				map[string]interface{}{
+					"oid":   "2.5.29.19",
+					"value": "MAA=",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsError() || !strings.Contains(resp.Data["error"].(string), "managed by Vault") {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
+
+func TestPki_Ed25519(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	resp := doReq("root/generate/exported", map[string]interface{}{
+		"common_name":        "myvault.com",
+		"ttl":                "40h",
+		"key_type":           "ed25519",
+		"private_key_format": "pkcs8",
+	})
+	if resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["private_key"].(string)))
+	if block == nil || block.Type != "PRIVATE KEY" {
+		t.Fatalf("bad private key: %#v", resp.Data["private_key"])
+	}
+	caInfo, err := fetchCAInfo(context.Background(), &logical.Request{Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caInfo.Certificate.SignatureAlgorithm != x509.PureEd25519 {
+		t.Fatalf("bad CA signature algorithm: %s", caInfo.Certificate.SignatureAlgorithm)
+	}
+
+	resp = doReq("roles/example", map[string]interface{}{
+		"allow_any_name": true,
+		"key_type":       "ed25519",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = doReq("issue/example", map[string]interface{}{
+		"common_name": "leaf.myvault.com",
+	})
+	if resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Data["private_key_type"] != certutil.Ed25519PrivateKey {
+		t.Fatalf("bad private key type: %#v", resp.Data["private_key_type"])
+	}
+	block, _ = pem.Decode([]byte(resp.Data["certificate"].(string)))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.PublicKeyAlgorithm != x509.Ed25519 || cert.SignatureAlgorithm != x509.PureEd25519 {
+		t.Fatalf("bad certificate algorithms: %s, %s", cert.PublicKeyAlgorithm, cert.SignatureAlgorithm)
+	}
+	if err := cert.CheckSignatureFrom(caInfo.Certificate); err != nil {
+		t.Fatal(err)
+	}
+
+	// CSRs must match the role's key type
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		key    interface{}
+		errStr string
+	}{
+		{ecKey, "role requires keys of type ed25519"},
+		{edKey, ""},
+	} {
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject: pkix.Name{CommonName: "csr.myvault.com"},
+		}, tc.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp = doReq("sign/example", map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: csr,
+			})),
+		})
+		switch {
+		case tc.errStr == "" && resp.IsError():
+			t.Fatalf("bad: %#v", resp)
+		case tc.errStr != "" && (!resp.IsError() || !strings.Contains(resp.Data["error"].(string), tc.errStr)):
+			t.Fatalf("expected error %q, got: %#v", tc.errStr, resp)
+		}
+	}
+}
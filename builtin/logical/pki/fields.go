@@ -201,8 +201,8 @@ the key_type.`,
 	fields["key_type"] = &framework.FieldSchema{
 		Type:    framework.TypeString,
 		Default: "rsa",
-		Description: `The type of key to use; defaults to RSA. "rsa"
-and "ec" are the only valid values.`,
+		Description: `The type of key to use; defaults to RSA. "rsa",
+"ec" and "ed25519" are the only valid values.`,
 	}
 
 	return fields
@@ -222,5 +222,30 @@ func addCAIssueFields(fields map[string]*framework.FieldSchema) map[string]*fram
 		Description: `Domains for which this certificate is allowed to sign or issue child certificates. If set, all DNS names (subject and alt) on child certs must be exact matches or subsets of the given domains (see https://tools.ietf.org/html/rfc5280#section-4.2.1.10).`,
 	}
 
+	fields["excluded_dns_domains"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `Domains for which this certificate is not allowed to sign or issue child certificates. If set, no DNS names (subject and alt) on child certs may be exact matches or subsets of the given domains (see https://tools.ietf.org/html/rfc5280#section-4.2.1.10).`,
+	}
+
+	fields["permitted_ip_ranges"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `IP ranges, in CIDR notation, for which this certificate is allowed to sign or issue child certificates. If set, all IP SANs on child certs must be contained in one of the given ranges.`,
+	}
+
+	fields["excluded_ip_ranges"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `IP ranges, in CIDR notation, for which this certificate is not allowed to sign or issue child certificates. If set, no IP SANs on child certs may be contained in any of the given ranges.`,
+	}
+
+	fields["policy_identifiers"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `A comma-separated string or list of policy OIDs to encode in the certificate policies extension.`,
+	}
+
+	fields["custom_extensions"] = &framework.FieldSchema{
+		Type:        framework.TypeSlice,
+		Description: `A list of additional extensions to encode in the certificate. Each entry is an object with an "oid", a base64-encoded DER "value" and an optional "critical" flag. Extensions managed by Vault, such as key usage, basic constraints or name constraints, cannot be set this way.`,
+	}
+
 	return fields
 }
@@ -0,0 +1,317 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/ryanuber/go-glob"
+)
+
+// certMetadata holds the issuance information stored alongside each
+// certificate in the certificate store
+type certMetadata struct {
+	SerialNumber string    `json:"serial_number"`
+	Role         string    `json:"role"`
+	EntityID     string    `json:"entity_id"`
+	CommonName   string    `json:"common_name"`
+	AltNames     []string  `json:"alt_names"`
+	IPSANs       []string  `json:"ip_sans"`
+	IsCA         bool      `json:"is_ca"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	IssuedAt     time.Time `json:"issued_at"`
+}
+
+func (m *certMetadata) ToResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"serial_number": m.SerialNumber,
+		"role":          m.Role,
+		"entity_id":     m.EntityID,
+		"common_name":   m.CommonName,
+		"alt_names":     m.AltNames,
+		"ip_sans":       m.IPSANs,
+		"is_ca":         m.IsCA,
+		"not_before":    m.NotBefore.Format(time.RFC3339),
+		"not_after":     m.NotAfter.Format(time.RFC3339),
+		"issued_at":     m.IssuedAt.Format(time.RFC3339),
+	}
+}
+
+// storeCertMetadata records the issuance metadata of the given certificate.
+// It should be called whenever a certificate is written to "certs/".
+func storeCertMetadata(ctx context.Context, req *logical.Request, roleName string, cert *x509.Certificate) error {
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+
+	metadata := &certMetadata{
+		SerialNumber: serial,
+		Role:         roleName,
+		EntityID:     req.EntityID,
+		CommonName:   cert.Subject.CommonName,
+		AltNames:     append(append([]string{}, cert.DNSNames...), cert.EmailAddresses...),
+		IPSANs:       []string{},
+		IsCA:         cert.IsCA,
+		NotBefore:    cert.NotBefore.UTC(),
+		NotAfter:     cert.NotAfter.UTC(),
+		IssuedAt:     time.Now().UTC(),
+	}
+	for _, ip := range cert.IPAddresses {
+		metadata.IPSANs = append(metadata.IPSANs, ip.String())
+	}
+
+	entry, err := logical.StorageEntryJSON("cert_metadata/"+normalizeSerial(serial), metadata)
+	if err != nil {
+		return err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("unable to store certificate metadata: {{err}}", err)
+	}
+
+	return nil
+}
+
+func fetchCertMetadata(ctx context.Context, s logical.Storage, serial string) (*certMetadata, error) {
+	entry, err := s.Get(ctx, "cert_metadata/"+normalizeSerial(serial))
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error fetching metadata for certificate %s: {{err}}", serial), err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result certMetadata
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error decoding metadata for certificate %s: {{err}}", serial), err)
+	}
+
+	return &result, nil
+}
+
+// Returns the issuance metadata of a certificate
+func pathFetchCertMetadata(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `cert_metadata/(?P<serial>[0-9A-Fa-f-:]+)`,
+		Fields: map[string]*framework.FieldSchema{
+			"serial": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Certificate serial number, in colon- or
+hyphen-separated octal`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchCertMetadataRead,
+		},
+
+		HelpSynopsis:    pathFetchCertMetadataHelpSyn,
+		HelpDescription: pathFetchCertMetadataHelpDesc,
+	}
+}
+
+// Returns the certificates whose metadata matches the given attributes
+func pathSearchCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/search",
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Only return certificates issued by this role`,
+			},
+
+			"entity_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Only return certificates requested by this entity`,
+			},
+
+			"common_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates whose common name
+matches this value; globs are supported`,
+			},
+
+			"san": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates with a DNS, email or
+IP subject alternative name matching this value;
+globs are supported`,
+			},
+
+			"expires_within": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `Only return certificates that expire within
+this duration from now`,
+			},
+
+			"include_expired": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: `Whether to return certificates that have already expired`,
+			},
+
+			"revoked": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, only return certificates that are
+(true) or are not (false) revoked`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathSearchCertsRead,
+			logical.UpdateOperation: b.pathSearchCertsRead,
+		},
+
+		HelpSynopsis:    pathSearchCertsHelpSyn,
+		HelpDescription: pathSearchCertsHelpDesc,
+	}
+}
+
+func (b *backend) pathFetchCertMetadataRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	serial := data.Get("serial").(string)
+	if len(serial) == 0 {
+		return logical.ErrorResponse("The serial number must be provided"), nil
+	}
+
+	metadata, err := fetchCertMetadata(ctx, req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		return nil, nil
+	}
+
+	respData := metadata.ToResponseData()
+	revInfo, err := fetchRevocationInfo(ctx, req, serial)
+	if err != nil {
+		return nil, err
+	}
+	respData["revoked"] = revInfo != nil
+	if revInfo != nil {
+		respData["revocation_time"] = revInfo.RevocationTime
+	}
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+func (b *backend) pathSearchCertsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+	entityID := data.Get("entity_id").(string)
+	commonName := data.Get("common_name").(string)
+	san := data.Get("san").(string)
+	expiresWithin := time.Duration(data.Get("expires_within").(int)) * time.Second
+	includeExpired := data.Get("include_expired").(bool)
+	revokedRaw, filterRevoked := data.GetOk("revoked")
+
+	serials, err := req.Storage.List(ctx, "cert_metadata/")
+	if err != nil {
+		return nil, errwrap.Wrapf("error fetching list of certificate metadata: {{err}}", err)
+	}
+
+	now := time.Now()
+	keys := []string{}
+	keyInfo := map[string]interface{}{}
+	for _, serial := range serials {
+		metadata, err := fetchCertMetadata(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, err
+		}
+		if metadata == nil {
+			continue
+		}
+
+		switch {
+		case roleName != "" && metadata.Role != roleName:
+			continue
+		case entityID != "" && metadata.EntityID != entityID:
+			continue
+		case commonName != "" && !glob.Glob(commonName, metadata.CommonName):
+			continue
+		case san != "" && !certMetadataHasSAN(metadata, san):
+			continue
+		case !includeExpired && now.After(metadata.NotAfter):
+			continue
+		case expiresWithin > 0 && metadata.NotAfter.After(now.Add(expiresWithin)):
+			continue
+		}
+
+		revInfo, err := fetchRevocationInfo(ctx, req, serial)
+		if err != nil {
+			return nil, err
+		}
+		if filterRevoked && revokedRaw.(bool) != (revInfo != nil) {
+			continue
+		}
+
+		info := metadata.ToResponseData()
+		info["revoked"] = revInfo != nil
+		keys = append(keys, serial)
+		keyInfo[serial] = info
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+// fetchRevocationInfo returns the revocation information of the given serial,
+// or nil if the certificate has not been revoked
+func fetchRevocationInfo(ctx context.Context, req *logical.Request, serial string) (*revocationInfo, error) {
+	revokedEntry, err := fetchCertBySerial(ctx, req, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revokedEntry == nil {
+		return nil, nil
+	}
+
+	var revInfo revocationInfo
+	if err := revokedEntry.DecodeJSON(&revInfo); err != nil {
+		return nil, fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
+	}
+
+	return &revInfo, nil
+}
+
+func certMetadataHasSAN(metadata *certMetadata, pattern string) bool {
+	for _, name := range metadata.AltNames {
+		if glob.Glob(pattern, name) {
+			return true
+		}
+	}
+	for _, ip := range metadata.IPSANs {
+		if glob.Glob(pattern, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+const pathFetchCertMetadataHelpSyn = `
+Fetch the issuance metadata of a certificate.
+`
+
+const pathFetchCertMetadataHelpDesc = `
+This endpoint returns the metadata recorded when the certificate with the
+given serial number was issued: the role and entity that requested it, its
+subject names and its validity period, as well as whether it has been
+revoked. Metadata is only available for certificates that were stored in the
+backend.
+`
+
+const pathSearchCertsHelpSyn = `
+Search the certificate store by issuance metadata.
+`
+
+const pathSearchCertsHelpDesc = `
+This endpoint returns the serial numbers, along with the issuance metadata,
+of stored certificates matching all of the given filters. Expired
+certificates that have not yet been tidied are only returned if
+'include_expired' is set.
+
+Certificates issued before metadata was recorded by this backend are not
+returned by this endpoint, but can still be listed via the "certs/" path.
+`
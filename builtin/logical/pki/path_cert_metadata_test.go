@@ -0,0 +1,111 @@
+package pki
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_CertMetadataSearch(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(op logical.Operation, path, entityID string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+			EntityID:  entityID,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	doReq(logical.UpdateOperation, "root/generate/internal", "", map[string]interface{}{
+		"common_name": "myvault.com",
+		"ttl":         "40h",
+	})
+	for _, role := range []string{"web", "db"} {
+		doReq(logical.UpdateOperation, "roles/"+role, "", map[string]interface{}{
+			"allowed_domains":  "myvault.com",
+			"allow_subdomains": true,
+			"allow_ip_sans":    true,
+			"ttl":              "10h",
+		})
+	}
+
+	issued := map[string]string{}
+	for _, tc := range []struct {
+		role       string
+		entityID   string
+		commonName string
+		ipSANs     string
+	}{
+		{"web", "entity1", "www.myvault.com", ""},
+		{"web", "entity2", "api.myvault.com", "10.0.0.1"},
+		{"db", "entity1", "db.myvault.com", ""},
+	} {
+		resp := doReq(logical.UpdateOperation, "issue/"+tc.role, tc.entityID, map[string]interface{}{
+			"common_name": tc.commonName,
+			"ip_sans":     tc.ipSANs,
+		})
+		issued[tc.commonName] = normalizeSerial(resp.Data["serial_number"].(string))
+	}
+
+	search := func(data map[string]interface{}, expected ...string) {
+		t.Helper()
+		resp := doReq(logical.ReadOperation, "certs/search", "", data)
+		var expectedSerials []string
+		for _, cn := range expected {
+			expectedSerials = append(expectedSerials, issued[cn])
+		}
+		keys, _ := resp.Data["keys"].([]string)
+		sort.Strings(keys)
+		sort.Strings(expectedSerials)
+		if len(keys) != len(expectedSerials) {
+			t.Fatalf("search %v: expected %v, got %v", data, expectedSerials, keys)
+		}
+		for i := range keys {
+			if keys[i] != expectedSerials[i] {
+				t.Fatalf("search %v: expected %v, got %v", data, expectedSerials, keys)
+			}
+		}
+	}
+
+	search(map[string]interface{}{"role": "web"}, "www.myvault.com", "api.myvault.com")
+	search(map[string]interface{}{"role": "web", "entity_id": "entity1"}, "www.myvault.com")
+	search(map[string]interface{}{"common_name": "*.myvault.com", "role": "db"}, "db.myvault.com")
+	search(map[string]interface{}{"san": "10.0.0.*"}, "api.myvault.com")
+	search(map[string]interface{}{"expires_within": "1h", "role": "web"})
+	search(map[string]interface{}{"expires_within": "12h", "role": "web"}, "www.myvault.com", "api.myvault.com")
+
+	resp := doReq(logical.ReadOperation, "cert_metadata/"+issued["api.myvault.com"], "", nil)
+	if resp.Data["role"] != "web" || resp.Data["entity_id"] != "entity2" || resp.Data["revoked"] != false {
+		t.Fatalf("bad metadata: %#v", resp.Data)
+	}
+	if ipSANs := resp.Data["ip_sans"].([]string); len(ipSANs) != 1 || ipSANs[0] != "10.0.0.1" {
+		t.Fatalf("bad ip sans: %#v", resp.Data)
+	}
+
+	doReq(logical.UpdateOperation, "revoke", "", map[string]interface{}{
+		"serial_number": issued["www.myvault.com"],
+	})
+
+	resp = doReq(logical.ListOperation, "revoked/", "", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != issued["www.myvault.com"] {
+		t.Fatalf("bad revoked list: %#v", resp.Data)
+	}
+
+	search(map[string]interface{}{"revoked": true}, "www.myvault.com")
+	search(map[string]interface{}{"revoked": false, "role": "web"}, "api.myvault.com")
+
+	resp = doReq(logical.ReadOperation, "cert_metadata/"+issued["www.myvault.com"], "", nil)
+	if resp.Data["revoked"] != true || resp.Data["revocation_time"] == nil {
+		t.Fatalf("bad metadata: %#v", resp.Data)
+	}
+}
@@ -2,6 +2,10 @@ package pki
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/certutil"
@@ -88,6 +92,144 @@ func (b *backend) pathCAWrite(ctx context.Context, req *logical.Request, data *f
 	return nil, err
 }
 
+func pathConfigCACrossSigned(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca_cross_signed",
+		Fields: map[string]*framework.FieldSchema{
+			"pem_bundle": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-format, concatenated cross-signed versions
+of the CA certificate, optionally followed by the
+certificates of their issuers.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathCACrossSignedRead,
+			logical.UpdateOperation: b.pathCACrossSignedWrite,
+			logical.DeleteOperation: b.pathCACrossSignedDelete,
+		},
+
+		HelpSynopsis:    pathConfigCACrossSignedHelpSyn,
+		HelpDescription: pathConfigCACrossSignedHelpDesc,
+	}
+}
+
+// crossSignedEntry is the storage format of the cross-signed certificates
+// of the CA, in DER form
+type crossSignedEntry struct {
+	Certificates [][]byte `json:"certificates"`
+}
+
+func fetchCrossSignedCerts(ctx context.Context, s logical.Storage) ([]*certutil.CertBlock, error) {
+	entry, err := s.Get(ctx, "config/ca_cross_signed")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var crossSigned crossSignedEntry
+	if err := entry.DecodeJSON(&crossSigned); err != nil {
+		return nil, err
+	}
+
+	var result []*certutil.CertBlock
+	for _, certBytes := range crossSigned.Certificates {
+		cert, err := x509.ParseCertificate(certBytes)
+		if err != nil {
+			return nil, errwrap.Wrapf("unable to parse stored cross-signed certificate: {{err}}", err)
+		}
+		result = append(result, &certutil.CertBlock{
+			Certificate: cert,
+			Bytes:       certBytes,
+		})
+	}
+
+	return result, nil
+}
+
+func (b *backend) pathCACrossSignedRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	crossSigned, err := fetchCrossSignedCerts(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if crossSigned == nil {
+		return nil, nil
+	}
+
+	certs := []string{}
+	for _, block := range crossSigned {
+		certs = append(certs, strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: block.Bytes,
+		}))))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"certificates": certs,
+		},
+	}, nil
+}
+
+func (b *backend) pathCACrossSignedWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pemBundle := data.Get("pem_bundle").(string)
+	if pemBundle == "" {
+		return logical.ErrorResponse("'pem_bundle' was empty"), nil
+	}
+
+	caInfo, err := fetchCAInfo(ctx, req)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	var crossSigned crossSignedEntry
+	foundAlternate := false
+	pemBytes := []byte(pemBundle)
+	for len(strings.TrimSpace(string(pemBytes))) > 0 {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			return logical.ErrorResponse("unable to PEM-decode the given bundle"), nil
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error parsing certificate: %s", err)), nil
+		}
+		if !cert.IsCA {
+			return logical.ErrorResponse(fmt.Sprintf("certificate %q is not a CA certificate", cert.Subject.CommonName)), nil
+		}
+		if isAlternateCACert(caInfo.Certificate, cert) {
+			foundAlternate = true
+		}
+		crossSigned.Certificates = append(crossSigned.Certificates, block.Bytes)
+	}
+	if !foundAlternate {
+		return logical.ErrorResponse("no cross-signed version of the CA certificate was found in the bundle"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config/ca_cross_signed", crossSigned)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathCACrossSignedDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return nil, req.Storage.Delete(ctx, "config/ca_cross_signed")
+}
+
 const pathConfigCAHelpSyn = `
 Set the CA certificate and private key used for generated credentials.
 `
@@ -133,3 +275,18 @@ generated by the certificate's destination mount.
 Use the "config/ca/set" endpoint to load the signed certificate
 into Vault another Vault mount.
 `
+
+const pathConfigCACrossSignedHelpSyn = `
+Configure cross-signed versions of the CA certificate.
+`
+
+const pathConfigCACrossSignedHelpDesc = `
+This sets the cross-signed versions of this mount's CA certificate, as
+produced by the "root/cross-sign" endpoint of the mount holding the other
+CA, optionally followed by the certificates of their issuers.
+
+Every currently valid certificate configured here is served in the CA chain
+of this mount and of the certificates it issues, so that clients trusting
+either root are able to build a path to it. When issuing certificates that
+would outlive every available chain, a warning is returned.
+`
@@ -0,0 +1,179 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	estAuthenticatorCert     = "cert"
+	estAuthenticatorUserpass = "userpass"
+)
+
+// estConfig holds the EST (RFC 7030) enrollment configuration
+type estConfig struct {
+	Enabled        bool              `json:"enabled" mapstructure:"enabled" structs:"enabled"`
+	DefaultRole    string            `json:"default_role" mapstructure:"default_role" structs:"default_role"`
+	LabelToRole    map[string]string `json:"label_to_role" mapstructure:"label_to_role" structs:"label_to_role"`
+	Authenticators map[string]string `json:"authenticators" mapstructure:"authenticators" structs:"authenticators"`
+}
+
+func pathConfigEST(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/est",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `Whether the EST endpoints are enabled`,
+			},
+
+			"default_role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The role used for EST requests that do
+not specify a label`,
+			},
+
+			"label_to_role": &framework.FieldSchema{
+				Type: framework.TypeKVPairs,
+				Description: `Mapping of EST labels to the roles used
+for enrollment under that label`,
+			},
+
+			"authenticators": &framework.FieldSchema{
+				Type: framework.TypeKVPairs,
+				Description: `Mapping of authenticator type ("cert" or
+"userpass") to the accessor of the auth mount used to
+authenticate EST clients`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathESTConfigRead,
+			logical.UpdateOperation: b.pathESTConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigESTHelpSyn,
+		HelpDescription: pathConfigESTHelpDesc,
+	}
+}
+
+func (b *backend) estConfig(ctx context.Context, s logical.Storage) (*estConfig, error) {
+	entry, err := s.Get(ctx, "config/est")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result estConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathESTConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.estConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":        config.Enabled,
+			"default_role":   config.DefaultRole,
+			"label_to_role":  config.LabelToRole,
+			"authenticators": config.Authenticators,
+		},
+	}, nil
+}
+
+func (b *backend) pathESTConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.estConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &estConfig{}
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if defaultRoleRaw, ok := data.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRoleRaw.(string)
+	}
+	if labelToRoleRaw, ok := data.GetOk("label_to_role"); ok {
+		config.LabelToRole = labelToRoleRaw.(map[string]string)
+	}
+	if authenticatorsRaw, ok := data.GetOk("authenticators"); ok {
+		config.Authenticators = authenticatorsRaw.(map[string]string)
+	}
+
+	for authType := range config.Authenticators {
+		switch authType {
+		case estAuthenticatorCert, estAuthenticatorUserpass:
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("unsupported authenticator type %q", authType)), nil
+		}
+	}
+
+	roleNames := []string{}
+	if config.DefaultRole != "" {
+		roleNames = append(roleNames, config.DefaultRole)
+	}
+	for _, roleName := range config.LabelToRole {
+		roleNames = append(roleNames, roleName)
+	}
+	for _, roleName := range roleNames {
+		role, err := b.getRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", roleName)), nil
+		}
+	}
+
+	if config.Enabled && len(config.Authenticators) == 0 {
+		return logical.ErrorResponse("at least one authenticator must be configured to enable EST"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config/est", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigESTHelpSyn = `
+Configure the EST (RFC 7030) enrollment endpoints.
+`
+
+const pathConfigESTHelpDesc = `
+This endpoint configures the EST endpoints served under the "est/" path.
+EST labels are mapped to roles, which constrain the certificates that can
+be enrolled under that label; requests without a label use the default
+role.
+
+EST clients are authenticated against existing auth mounts rather than
+with Vault tokens. The "authenticators" field maps an authenticator type
+to the accessor of the auth mount to use: "userpass" mounts are checked
+using HTTP Basic credentials, and "cert" mounts using the TLS client
+certificate. Note that HTTP Basic credentials are only visible to this
+backend if "Authorization" is listed in the mount's
+"passthrough_request_headers".
+`
@@ -0,0 +1,546 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fullsailor/pkcs7"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+var (
+	oidPublicKeyRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidPublicKeyECDSA   = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidPublicKeyEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+	oidNamedCurveP224 = asn1.ObjectIdentifier{1, 3, 132, 0, 33}
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidNamedCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// estAttribute is the Attribute arm of the AttrOrOID choice in a CSR
+// attributes response, as defined in RFC 7030 section 4.5.2
+type estAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.ObjectIdentifier `asn1:"set"`
+}
+
+func estPattern(operation string) string {
+	return "est/(" + framework.GenericNameRegex("label") + "/)?" + operation
+}
+
+func estFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["label"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `The EST label, which selects the role used
+for enrollment. If empty, the default role is used.`,
+	}
+	return fields
+}
+
+func pathESTCACerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("cacerts"),
+		Fields:  estFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathESTCACerts,
+		},
+
+		HelpSynopsis:    pathESTHelpSyn,
+		HelpDescription: pathESTHelpDesc,
+	}
+}
+
+func pathESTSimpleEnroll(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("simpleenroll"),
+		Fields: estFields(map[string]*framework.FieldSchema{
+			"csr": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Base64-encoded DER PKCS#10 certificate request.`,
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathESTSimpleEnroll,
+		},
+
+		HelpSynopsis:    pathESTHelpSyn,
+		HelpDescription: pathESTHelpDesc,
+	}
+}
+
+func pathESTSimpleReenroll(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("simplereenroll"),
+		Fields: estFields(map[string]*framework.FieldSchema{
+			"csr": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Base64-encoded DER PKCS#10 certificate request.`,
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathESTSimpleReenroll,
+		},
+
+		HelpSynopsis:    pathESTHelpSyn,
+		HelpDescription: pathESTHelpDesc,
+	}
+}
+
+func pathESTCSRAttrs(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("csrattrs"),
+		Fields:  estFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathESTCSRAttrs,
+		},
+
+		HelpSynopsis:    pathESTHelpSyn,
+		HelpDescription: pathESTHelpDesc,
+	}
+}
+
+// estRawResponse builds a raw HTTP response as expected by EST clients; the
+// body is base64-encoded as required by RFC 7030 section 4
+func estRawResponse(status int, contentType string, body []byte) *logical.Response {
+	data := map[string]interface{}{
+		logical.HTTPStatusCode: status,
+	}
+	if len(body) > 0 {
+		data[logical.HTTPContentType] = contentType
+		data[logical.HTTPRawBody] = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	return &logical.Response{
+		Data: data,
+	}
+}
+
+func estErrorResponse(status int, msg string) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  status,
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPRawBody:     []byte(msg),
+		},
+	}
+}
+
+// estRole returns the EST configuration and the role mapped to the label of
+// the request, or an error response suitable for an EST client
+func (b *backend) estRole(ctx context.Context, req *logical.Request, data *framework.FieldData) (*estConfig, *roleEntry, *logical.Response, error) {
+	config, err := b.estConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if config == nil || !config.Enabled {
+		return nil, nil, estErrorResponse(http.StatusNotFound, "EST is not enabled on this mount"), nil
+	}
+
+	label := data.Get("label").(string)
+	roleName := config.DefaultRole
+	if label != "" {
+		roleName = config.LabelToRole[label]
+	}
+	if roleName == "" {
+		return nil, nil, estErrorResponse(http.StatusNotFound, fmt.Sprintf("unknown EST label: %s", label)), nil
+	}
+
+	role, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if role == nil {
+		return nil, nil, estErrorResponse(http.StatusNotFound, fmt.Sprintf("unknown role: %s", roleName)), nil
+	}
+
+	return config, role, nil, nil
+}
+
+// estAuthenticate authenticates the EST client against the configured auth
+// mounts and checks that its policies allow it to sign certificates with the
+// role. HTTP Basic credentials are checked against the userpass mount and a
+// TLS client certificate against the cert mount.
+func (b *backend) estAuthenticate(ctx context.Context, req *logical.Request, config *estConfig, role *roleEntry) (*logical.Auth, error) {
+	authenticator, ok := b.System().(logical.LoginAuthenticator)
+	if !ok {
+		return nil, fmt.Errorf("EST client authentication is not supported by the system view")
+	}
+
+	auth, err := b.estLogin(ctx, authenticator, req, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enrolling is signing a certificate with the role of the label, so the
+	// client must be allowed to do so through the sign endpoint
+	allowed, err := authenticator.AuthorizeLogin(ctx, auth, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      req.MountPoint + "sign/" + role.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	return auth, nil
+}
+
+// estLogin logs the EST client in to the first configured auth mount it has
+// credentials for
+func (b *backend) estLogin(ctx context.Context, authenticator logical.LoginAuthenticator, req *logical.Request, config *estConfig) (*logical.Auth, error) {
+	if accessor := config.Authenticators[estAuthenticatorUserpass]; accessor != "" {
+		if username, password, ok := estBasicAuth(req.Headers); ok {
+			return authenticator.AuthenticateLogin(ctx, accessor, &logical.Request{
+				ID:         req.ID,
+				Path:       "login/" + username,
+				Connection: req.Connection,
+				Data: map[string]interface{}{
+					"password": password,
+				},
+			})
+		}
+	}
+
+	if accessor := config.Authenticators[estAuthenticatorCert]; accessor != "" {
+		if req.Connection != nil && req.Connection.ConnState != nil && len(req.Connection.ConnState.PeerCertificates) > 0 {
+			return authenticator.AuthenticateLogin(ctx, accessor, &logical.Request{
+				ID:         req.ID,
+				Path:       "login",
+				Connection: req.Connection,
+			})
+		}
+	}
+
+	return nil, logical.ErrPermissionDenied
+}
+
+// estBasicAuth parses HTTP Basic credentials out of the passed-through
+// request headers
+func estBasicAuth(headers map[string][]string) (string, string, bool) {
+	for name, values := range headers {
+		if !strings.EqualFold(name, "Authorization") || len(values) == 0 {
+			continue
+		}
+		r := &http.Request{
+			Header: http.Header{
+				"Authorization": values,
+			},
+		}
+		return r.BasicAuth()
+	}
+	return "", "", false
+}
+
+// estParseCSR decodes the base64-encoded DER CSR sent by an EST client and
+// verifies its self-signature
+func estParseCSR(data *framework.FieldData) (*x509.CertificateRequest, error) {
+	csrString := strings.Join(strings.Fields(data.Get("csr").(string)), "")
+	if csrString == "" {
+		return nil, errutil.UserError{Err: "no certificate request provided"}
+	}
+
+	der, err := base64.StdEncoding.DecodeString(csrString)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("certificate request is not valid base64: %v", err)}
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("certificate request could not be parsed: %v", err)}
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("certificate request signature is invalid: %v", err)}
+	}
+
+	return csr, nil
+}
+
+func (b *backend) pathESTCACerts(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	_, _, errResp, err := b.estRole(ctx, req, data)
+	if err != nil || errResp != nil {
+		return errResp, err
+	}
+
+	caInfo, err := fetchCAInfo(ctx, req)
+	switch err.(type) {
+	case errutil.UserError:
+		return estErrorResponse(http.StatusNotFound, err.Error()), nil
+	case errutil.InternalError:
+		return nil, err
+	}
+
+	// The chain only includes the issuing CA itself when it isn't a root
+	var certs bytes.Buffer
+	certs.Write(caInfo.CertificateBytes)
+	for _, ca := range caInfo.GetCAChain() {
+		if bytes.Equal(ca.Bytes, caInfo.CertificateBytes) {
+			continue
+		}
+		certs.Write(ca.Bytes)
+	}
+
+	p7, err := pkcs7.DegenerateCertificate(certs.Bytes())
+	if err != nil {
+		return nil, errwrap.Wrapf("error building PKCS#7 certificate bundle: {{err}}", err)
+	}
+
+	return estRawResponse(http.StatusOK, "application/pkcs7-mime", p7), nil
+}
+
+func (b *backend) pathESTSimpleEnroll(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, role, errResp, err := b.estRole(ctx, req, data)
+	if err != nil || errResp != nil {
+		return errResp, err
+	}
+
+	if _, err := b.estAuthenticate(ctx, req, config, role); err != nil {
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("EST client authentication failed", "error", err)
+		}
+		return estErrorResponse(http.StatusUnauthorized, "authentication failed"), nil
+	}
+
+	csr, err := estParseCSR(data)
+	if err != nil {
+		return estErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	return b.estSignCSR(ctx, req, role, csr)
+}
+
+func (b *backend) pathESTSimpleReenroll(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	_, role, errResp, err := b.estRole(ctx, req, data)
+	if err != nil || errResp != nil {
+		return errResp, err
+	}
+
+	csr, err := estParseCSR(data)
+	if err != nil {
+		return estErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	// Re-enrollment is authenticated by the certificate being renewed, which
+	// must have been issued by this mount and still be valid
+	if req.Connection == nil || req.Connection.ConnState == nil || len(req.Connection.ConnState.PeerCertificates) == 0 {
+		return estErrorResponse(http.StatusUnauthorized, "re-enrollment requires a TLS client certificate"), nil
+	}
+	current := req.Connection.ConnState.PeerCertificates[0]
+
+	caInfo, err := fetchCAInfo(ctx, req)
+	switch err.(type) {
+	case errutil.UserError:
+		return estErrorResponse(http.StatusNotFound, err.Error()), nil
+	case errutil.InternalError:
+		return nil, err
+	}
+
+	if err := current.CheckSignatureFrom(caInfo.Certificate); err != nil {
+		return estErrorResponse(http.StatusUnauthorized, "client certificate was not issued by this CA"), nil
+	}
+	if time.Now().After(current.NotAfter) {
+		return estErrorResponse(http.StatusUnauthorized, "client certificate has expired"), nil
+	}
+
+	serial := certutil.GetHexFormatted(current.SerialNumber.Bytes(), ":")
+	revokedEntry, err := fetchCertBySerial(ctx, req, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revokedEntry != nil {
+		return estErrorResponse(http.StatusUnauthorized, "client certificate has been revoked"), nil
+	}
+
+	// RFC 7030 section 4.2.2: the subject and subject alternative names must
+	// be identical to those of the certificate being renewed
+	if !bytes.Equal(csr.RawSubject, current.RawSubject) ||
+		!estStringsEqual(csr.DNSNames, current.DNSNames) ||
+		!estStringsEqual(csr.EmailAddresses, current.EmailAddresses) ||
+		len(csr.IPAddresses) != len(current.IPAddresses) {
+		return estErrorResponse(http.StatusBadRequest, "certificate request subject does not match the current certificate"), nil
+	}
+	for i, ip := range csr.IPAddresses {
+		if !ip.Equal(current.IPAddresses[i]) {
+			return estErrorResponse(http.StatusBadRequest, "certificate request subject does not match the current certificate"), nil
+		}
+	}
+
+	return b.estSignCSR(ctx, req, role, csr)
+}
+
+func (b *backend) pathESTCSRAttrs(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	_, role, errResp, err := b.estRole(ctx, req, data)
+	if err != nil || errResp != nil {
+		return errResp, err
+	}
+
+	var attrs []asn1.RawValue
+	var attr interface{}
+	switch role.KeyType {
+	case "rsa":
+		attr = oidPublicKeyRSA
+	case "ec":
+		var curve asn1.ObjectIdentifier
+		switch role.KeyBits {
+		case 224:
+			curve = oidNamedCurveP224
+		case 384:
+			curve = oidNamedCurveP384
+		case 521:
+			curve = oidNamedCurveP521
+		default:
+			curve = oidNamedCurveP256
+		}
+		attr = estAttribute{
+			Type:   oidPublicKeyECDSA,
+			Values: []asn1.ObjectIdentifier{curve},
+		}
+	case "ed25519":
+		attr = oidPublicKeyEd25519
+	}
+	if attr != nil {
+		raw, err := asn1.Marshal(attr)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, asn1.RawValue{FullBytes: raw})
+	}
+
+	if len(attrs) == 0 {
+		return estRawResponse(http.StatusNoContent, "", nil), nil
+	}
+
+	body, err := asn1.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	return estRawResponse(http.StatusOK, "application/csrattrs", body), nil
+}
+
+// estSignCSR signs the given CSR subject to the restrictions of the role and
+// returns the issued certificate as a PKCS#7 certs-only response
+func (b *backend) estSignCSR(ctx context.Context, req *logical.Request, role *roleEntry, csr *x509.CertificateRequest) (*logical.Response, error) {
+	signingBundle, caErr := fetchCAInfo(ctx, req)
+	switch caErr.(type) {
+	case errutil.UserError:
+		return estErrorResponse(http.StatusNotFound, caErr.Error()), nil
+	case errutil.InternalError:
+		return nil, caErr
+	}
+
+	altNames := make([]string, 0, len(csr.DNSNames)+len(csr.EmailAddresses))
+	altNames = append(altNames, csr.DNSNames...)
+	altNames = append(altNames, csr.EmailAddresses...)
+
+	var ipSANs []string
+	for _, ip := range csr.IPAddresses {
+		ipSANs = append(ipSANs, ip.String())
+	}
+
+	fields := addNonCACommonFields(map[string]*framework.FieldSchema{})
+	fields["csr"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+	}
+	apiData := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: csr.Raw,
+			})),
+			"common_name": csr.Subject.CommonName,
+			"alt_names":   strings.Join(altNames, ","),
+			"ip_sans":     strings.Join(ipSANs, ","),
+		},
+		Schema: fields,
+	}
+
+	input := &dataBundle{
+		req:           req,
+		apiData:       apiData,
+		role:          role,
+		signingBundle: signingBundle,
+	}
+	parsedBundle, err := signCert(b, input, false, false)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return estErrorResponse(http.StatusBadRequest, err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	if !role.NoStore {
+		serial := certutil.GetHexFormatted(parsedBundle.Certificate.SerialNumber.Bytes(), ":")
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   "certs/" + normalizeSerial(serial),
+			Value: parsedBundle.CertificateBytes,
+		})
+		if err != nil {
+			return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
+		}
+		if err := storeCertMetadata(ctx, req, role.Name, parsedBundle.Certificate); err != nil {
+			return nil, err
+		}
+	}
+
+	p7, err := pkcs7.DegenerateCertificate(parsedBundle.CertificateBytes)
+	if err != nil {
+		return nil, errwrap.Wrapf("error building PKCS#7 certificate response: {{err}}", err)
+	}
+
+	return estRawResponse(http.StatusOK, "application/pkcs7-mime; smime-type=certs-only", p7), nil
+}
+
+func estStringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+const pathESTHelpSyn = `
+EST (RFC 7030) certificate enrollment endpoints.
+`
+
+const pathESTHelpDesc = `
+These endpoints implement the EST "cacerts", "simpleenroll",
+"simplereenroll" and "csrattrs" operations. An optional label may be given
+before the operation (e.g. "est/<label>/simpleenroll") to select the role
+used for enrollment; see "config/est".
+
+Requests and responses use the EST wire formats rather than JSON:
+certificate requests are base64-encoded DER sent with the
+"application/pkcs10" content type, and certificates are returned as
+base64-encoded PKCS#7 certs-only structures.
+
+Enrollment requests are authenticated against the auth mounts configured in
+"config/est", and the policies of the client must allow "update" on the
+"sign/<role>" path of this mount for the role of the label. Re-enrollment requests must present the certificate being
+renewed as the TLS client certificate, and the request subject must match
+that certificate.
+`
@@ -0,0 +1,179 @@
+package pki
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/fullsailor/pkcs7"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_EST(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	sysView := config.System.(*logical.StaticSystemView)
+
+	b := Backend()
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	storage := config.StorageView
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}, headers map[string][]string) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+			Headers:   headers,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	doReq(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "myvault.com",
+		"ttl":         "40h",
+	}, nil)
+	doReq(logical.UpdateOperation, "roles/devices", map[string]interface{}{
+		"allowed_domains":  "devices.myvault.com",
+		"allow_subdomains": true,
+		"key_type":         "ec",
+		"key_bits":         256,
+		"ttl":              "1h",
+	}, nil)
+
+	// EST endpoints are disabled until configured
+	resp := doReq(logical.ReadOperation, "est/cacerts", nil, nil)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusNotFound {
+		t.Fatalf("expected 404 before configuration, got %#v", resp.Data)
+	}
+
+	doReq(logical.UpdateOperation, "config/est", map[string]interface{}{
+		"enabled":        true,
+		"label_to_role":  map[string]interface{}{"iot": "devices"},
+		"authenticators": map[string]interface{}{"userpass": "auth_userpass_1234"},
+	}, nil)
+
+	resp = doReq(logical.ReadOperation, "config/est", nil, nil)
+	if resp.Data["label_to_role"].(map[string]string)["iot"] != "devices" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// An unknown role is rejected
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/est",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"default_role": "missing",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unknown role, got: err: %v resp: %#v", err, resp)
+	}
+
+	// cacerts returns the CA in a PKCS#7 bundle
+	resp = doReq(logical.ReadOperation, "est/iot/cacerts", nil, nil)
+	certs := parseESTCerts(t, resp)
+	if len(certs) != 1 || certs[0].Subject.CommonName != "myvault.com" {
+		t.Fatalf("bad CA certs: %#v", certs)
+	}
+
+	// Only the EC key type is requested by the role
+	resp = doReq(logical.ReadOperation, "est/iot/csrattrs", nil, nil)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusOK || resp.Data[logical.HTTPContentType] != "application/csrattrs" {
+		t.Fatalf("bad csrattrs response: %#v", resp.Data)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "sensor1.devices.myvault.com"},
+		DNSNames: []string{"sensor1.devices.myvault.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollData := map[string]interface{}{
+		"csr": base64.StdEncoding.EncodeToString(csr),
+	}
+	basicAuth := map[string][]string{
+		"Authorization": []string{"Basic " + base64.StdEncoding.EncodeToString([]byte("device:secret"))},
+	}
+
+	// Without credentials the enrollment is rejected
+	resp = doReq(logical.UpdateOperation, "est/iot/simpleenroll", enrollData, nil)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %#v", resp.Data)
+	}
+
+	// Failed authentication against the auth mount is rejected
+	resp = doReq(logical.UpdateOperation, "est/iot/simpleenroll", enrollData, basicAuth)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %#v", resp.Data)
+	}
+
+	// Authenticated clients must also be allowed to sign with the role
+	sysView.AuthVal = &logical.Auth{}
+	resp = doReq(logical.UpdateOperation, "est/iot/simpleenroll", enrollData, basicAuth)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %#v", resp.Data)
+	}
+
+	sysView.AuthorizedPaths = []string{"sign/devices"}
+	resp = doReq(logical.UpdateOperation, "est/iot/simpleenroll", enrollData, basicAuth)
+	certs = parseESTCerts(t, resp)
+	if len(certs) != 1 || certs[0].Subject.CommonName != "sensor1.devices.myvault.com" {
+		t.Fatalf("bad issued certs: %#v", certs)
+	}
+
+	serial := normalizeSerial(certutil.GetHexFormatted(certs[0].SerialNumber.Bytes(), ":"))
+	entry, err := storage.Get(context.Background(), "certs/"+serial)
+	if err != nil || entry == nil {
+		t.Fatalf("issued certificate was not stored: %v", err)
+	}
+
+	// Names outside of the role are rejected
+	csr, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "www.example.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = doReq(logical.UpdateOperation, "est/iot/simpleenroll", map[string]interface{}{
+		"csr": base64.StdEncoding.EncodeToString(csr),
+	}, basicAuth)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %#v", resp.Data)
+	}
+}
+
+func parseESTCerts(t *testing.T, resp *logical.Response) []*x509.Certificate {
+	t.Helper()
+	if resp.Data[logical.HTTPStatusCode] != http.StatusOK {
+		t.Fatalf("bad response: %#v", resp.Data)
+	}
+	der, err := base64.StdEncoding.DecodeString(string(resp.Data[logical.HTTPRawBody].([]byte)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p7.Certificates
+}
@@ -221,6 +221,9 @@ func (b *backend) pathSetSignedIntermediate(ctx context.Context, req *logical.Re
 	if err != nil {
 		return nil, err
 	}
+	if err := storeCertMetadata(ctx, req, "", inputBundle.Certificate); err != nil {
+		return nil, err
+	}
 
 	// For ease of later use, also store just the certificate at a known
 	// location
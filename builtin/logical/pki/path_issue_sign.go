@@ -154,6 +154,7 @@ func (b *backend) pathSignVerbatim(ctx context.Context, req *logical.Request, da
 			*entry.GenerateLease = *role.GenerateLease
 		}
 		entry.NoStore = role.NoStore
+		entry.Name = role.Name
 	}
 
 	if entry.MaxTTL > 0 && entry.TTL > entry.MaxTTL {
@@ -292,6 +293,13 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 		if err != nil {
 			return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 		}
+		if err := storeCertMetadata(ctx, req, role.Name, parsedBundle.Certificate); err != nil {
+			return nil, err
+		}
+	}
+
+	if expiry := signingBundle.latestChainExpiry(); parsedBundle.Certificate.NotAfter.After(expiry) {
+		resp.AddWarning(fmt.Sprintf("the certificate outlives every known chain from the issuing CA to a root, the latest of which is valid until %s; clients may be unable to build a valid chain for it", expiry.Format(time.RFC3339)))
 	}
 
 	if useCSR {
@@ -148,8 +148,8 @@ protection use. Defaults to false.`,
 			"key_type": &framework.FieldSchema{
 				Type:    framework.TypeString,
 				Default: "rsa",
-				Description: `The type of key to use; defaults to RSA. "rsa"
-and "ec" are the only valid values.`,
+				Description: `The type of key to use; defaults to RSA. "rsa",
+"ec" and "ed25519" are the only valid values.`,
 			},
 
 			"key_bits": &framework.FieldSchema{
@@ -296,6 +296,7 @@ func (b *backend) getRole(ctx context.Context, s logical.Storage, n string) (*ro
 	if err := entry.DecodeJSON(&result); err != nil {
 		return nil, err
 	}
+	result.Name = n
 
 	// Migrate existing saved entries and save back if changed
 	modified := false
@@ -615,6 +616,9 @@ type roleEntry struct {
 
 	// Used internally for signing intermediates
 	AllowExpirationPastCA bool
+
+	// Name is the name the role is stored under; it is not persisted
+	Name string `json:"-"`
 }
 
 func (r *roleEntry) ToResponseData() map[string]interface{} {
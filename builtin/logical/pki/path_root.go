@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/certutil"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -111,7 +112,41 @@ func pathSignSelfIssued(b *backend) *framework.Path {
 	return ret
 }
 
+func pathCrossSign(b *backend) *framework.Path {
+	ret := &framework.Path{
+		Pattern: "root/cross-sign",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCACrossSign,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"certificate": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `PEM-format self-signed root certificate to be cross-signed.`,
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The requested Time To Live for the cross-signed
+certificate. If not set, the validity period of
+the given certificate is kept. In both cases the
+certificate is capped to the expiration of this
+mount's CA certificate.`,
+			},
+		},
+
+		HelpSynopsis:    pathCrossSignHelpSyn,
+		HelpDescription: pathCrossSignHelpDesc,
+	}
+
+	return ret
+}
+
 func (b *backend) pathCADeleteRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, "config/ca_cross_signed"); err != nil {
+		return nil, err
+	}
 	return nil, req.Storage.Delete(ctx, "config/ca_bundle")
 }
 
@@ -221,6 +256,9 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 	if err != nil {
 		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 	}
+	if err := storeCertMetadata(ctx, req, "", parsedBundle.Certificate); err != nil {
+		return nil, err
+	}
 
 	// For ease of later use, also store just the certificate at a known
 	// location
@@ -371,6 +409,9 @@ func (b *backend) pathCASignIntermediate(ctx context.Context, req *logical.Reque
 	if err != nil {
 		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 	}
+	if err := storeCertMetadata(ctx, req, "", parsedBundle.Certificate); err != nil {
+		return nil, err
+	}
 
 	if parsedBundle.Certificate.MaxPathLen == 0 {
 		resp.AddWarning("Max path length of the signed certificate is zero. This certificate cannot be used to issue intermediate CA certificates.")
@@ -447,6 +488,106 @@ func (b *backend) pathCASignSelfIssued(ctx context.Context, req *logical.Request
 	}, nil
 }
 
+func (b *backend) pathCACrossSign(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	certPem := data.Get("certificate").(string)
+	block, _ := pem.Decode([]byte(certPem))
+	if block == nil || len(block.Bytes) == 0 {
+		return logical.ErrorResponse("certificate could not be PEM-decoded"), nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("error parsing certificate: %s", err)), nil
+	}
+	if !cert.IsCA {
+		return logical.ErrorResponse("given certificate is not a CA certificate"), nil
+	}
+	if !isSelfSigned(cert) {
+		return logical.ErrorResponse("given certificate is not a self-signed root certificate"), nil
+	}
+
+	signingBundle, caErr := fetchCAInfo(ctx, req)
+	switch caErr.(type) {
+	case errutil.UserError:
+		return nil, errutil.UserError{Err: fmt.Sprintf(
+			"could not fetch the CA certificate (was one set?): %s", caErr)}
+	case errutil.InternalError:
+		return nil, errutil.InternalError{Err: fmt.Sprintf(
+			"error fetching CA certificate: %s", caErr)}
+	}
+
+	if equal, _ := certutil.ComparePublicKeys(cert.PublicKey, signingBundle.Certificate.PublicKey); equal {
+		return logical.ErrorResponse("given certificate uses the same key as this mount's CA; use root/sign-self-issued instead"), nil
+	}
+
+	serialNumber, err := certutil.GenerateSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	// The cross-signed certificate keeps the subject, key and extensions of
+	// the given root, but is issued by this mount's CA
+	template := *cert
+	template.SerialNumber = serialNumber
+	template.AuthorityKeyId = signingBundle.Certificate.SubjectKeyId
+	template.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+	if ttl := data.Get("ttl").(int); ttl > 0 {
+		template.NotBefore = time.Now().Add(-30 * time.Second)
+		template.NotAfter = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+
+	var resp logical.Response
+	if template.NotAfter.After(signingBundle.Certificate.NotAfter) {
+		template.NotAfter = signingBundle.Certificate.NotAfter
+		resp.AddWarning(fmt.Sprintf("the cross-signed certificate has been capped to the expiration of this mount's CA certificate (%s)", template.NotAfter.Format(time.RFC3339)))
+	}
+
+	urls := &urlEntries{}
+	if signingBundle.URLs != nil {
+		urls = signingBundle.URLs
+	}
+	template.IssuingCertificateURL = urls.IssuingCertificates
+	template.CRLDistributionPoints = urls.CRLDistributionPoints
+	template.OCSPServer = urls.OCSPServers
+
+	newCert, err := x509.CreateCertificate(rand.Reader, &template, signingBundle.Certificate, cert.PublicKey, signingBundle.PrivateKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("error cross-signing certificate: {{err}}", err)
+	}
+	parsedCert, err := x509.ParseCertificate(newCert)
+	if err != nil {
+		return nil, errwrap.Wrapf("error parsing cross-signed certificate: {{err}}", err)
+	}
+
+	serial := certutil.GetHexFormatted(parsedCert.SerialNumber.Bytes(), ":")
+	err = req.Storage.Put(ctx, &logical.StorageEntry{
+		Key:   "certs/" + normalizeSerial(serial),
+		Value: newCert,
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
+	}
+	if err := storeCertMetadata(ctx, req, "", parsedCert); err != nil {
+		return nil, err
+	}
+
+	signingCB, err := signingBundle.ToCertBundle()
+	if err != nil {
+		return nil, errwrap.Wrapf("error converting raw signing bundle to cert bundle: {{err}}", err)
+	}
+
+	resp.Data = map[string]interface{}{
+		"certificate": strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: newCert,
+		}))),
+		"issuing_ca":    signingCB.Certificate,
+		"serial_number": serial,
+		"expiration":    parsedCert.NotAfter.Unix(),
+	}
+
+	return &resp, nil
+}
+
 const pathGenerateRootHelpSyn = `
 Generate a new CA certificate and private key used for signing.
 `
@@ -482,3 +623,22 @@ Note that this is a very privileged operation and should be extremely restricted
 
 Configured URLs for CRLs/OCSP/etc. will be copied over and the issuer will be this mount's CA cert. Other than that, all other values will be used verbatim.
 `
+
+const pathCrossSignHelpSyn = `
+Cross-signs another root CA certificate with this mount's CA.
+`
+
+const pathCrossSignHelpDesc = `
+Cross-signs another root CA certificate with this mount's CA, which is most
+often used when rotating roots: the resulting certificate carries the
+subject and key of the new root but chains to the root of this mount, so
+that certificates issued under the new root are trusted by clients that
+only know about the current one.
+
+The resulting certificate should then be configured on the mount holding the
+new root via its "config/ca_cross_signed" endpoint, along with this mount's
+CA certificate, so that the complete set of chains is served to clients.
+
+Note that this is a very privileged operation and should be extremely
+restricted in terms of who is allowed to use it.
+`
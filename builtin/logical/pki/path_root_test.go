@@ -0,0 +1,241 @@
+package pki
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_CrossSign(t *testing.T) {
+	oldBackend, oldStorage := createBackendWithStorage(t)
+	newBackend, newStorage := createBackendWithStorage(t)
+
+	doReq := func(b *backend, s logical.Storage, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   s,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	resp := doReq(oldBackend, oldStorage, logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "old.myvault.com",
+		"ttl":         "10h",
+	})
+	oldRoot := parseCertPEM(t, resp.Data["certificate"].(string))
+	resp = doReq(newBackend, newStorage, logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "new.myvault.com",
+		"ttl":         "40h",
+	})
+	newRootPEM := resp.Data["certificate"].(string)
+	newRoot := parseCertPEM(t, newRootPEM)
+
+	// A mount cannot cross-sign its own root
+	resp = doReq(oldBackend, oldStorage, logical.UpdateOperation, "root/cross-sign", map[string]interface{}{
+		"certificate": strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: oldRoot.Raw}))),
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error when cross-signing the mount's own root, got: %#v", resp)
+	}
+
+	resp = doReq(oldBackend, oldStorage, logical.UpdateOperation, "root/cross-sign", map[string]interface{}{
+		"certificate": newRootPEM,
+	})
+	if resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if len(resp.Warnings) != 1 {
+		t.Fatalf("expected a warning about the capped expiration, got: %#v", resp.Warnings)
+	}
+	crossSignedPEM := resp.Data["certificate"].(string)
+	crossSigned := parseCertPEM(t, crossSignedPEM)
+	if err := crossSigned.CheckSignatureFrom(oldRoot); err != nil {
+		t.Fatal(err)
+	}
+	if crossSigned.Subject.CommonName != "new.myvault.com" || !crossSigned.NotAfter.Equal(oldRoot.NotAfter) {
+		t.Fatalf("bad cross-signed certificate: subject %s, not after %s", crossSigned.Subject, crossSigned.NotAfter)
+	}
+	if equal, err := certutil.ComparePublicKeys(crossSigned.PublicKey, newRoot.PublicKey); err != nil || !equal {
+		t.Fatalf("cross-signed certificate does not carry the new root's key: %v", err)
+	}
+
+	// The cross-signed certificate must match the mount's CA
+	resp = doReq(oldBackend, oldStorage, logical.UpdateOperation, "config/ca_cross_signed", map[string]interface{}{
+		"pem_bundle": crossSignedPEM,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unrelated cross-signed certificate, got: %#v", resp)
+	}
+
+	oldRootPEM := strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: oldRoot.Raw})))
+	resp = doReq(newBackend, newStorage, logical.UpdateOperation, "config/ca_cross_signed", map[string]interface{}{
+		"pem_bundle": oldRootPEM + "\n" + crossSignedPEM,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The chain leads to both roots
+	resp = doReq(newBackend, newStorage, logical.ReadOperation, "cert/ca_chain", nil)
+	chain := resp.Data["certificate"].(string)
+	var subjects []string
+	for rest := []byte(chain); len(rest) > 0; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjects = append(subjects, cert.Subject.CommonName+"/"+cert.Issuer.CommonName)
+	}
+	expected := []string{
+		"new.myvault.com/new.myvault.com",
+		"new.myvault.com/old.myvault.com",
+		"old.myvault.com/old.myvault.com",
+	}
+	if strings.Join(subjects, ",") != strings.Join(expected, ",") {
+		t.Fatalf("bad chain: expected %v, got %v", expected, subjects)
+	}
+
+	doReq(newBackend, newStorage, logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allow_any_name": true,
+	})
+	resp = doReq(newBackend, newStorage, logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "leaf.myvault.com",
+		"ttl":         "20h",
+	})
+	if resp.IsError() || len(resp.Warnings) != 0 {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if chain, ok := resp.Data["ca_chain"].([]string); !ok || len(chain) != 3 {
+		t.Fatalf("bad ca_chain: %#v", resp.Data["ca_chain"])
+	}
+
+	// EST clients get the cross-signed chain as well
+	resp = doReq(newBackend, newStorage, logical.UpdateOperation, "config/est", map[string]interface{}{
+		"enabled":        true,
+		"default_role":   "example",
+		"authenticators": map[string]interface{}{"userpass": "auth_userpass_1234"},
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if certs := parseESTCerts(t, doReq(newBackend, newStorage, logical.ReadOperation, "est/cacerts", nil)); len(certs) != 3 {
+		t.Fatalf("bad EST CA certs: %#v", certs)
+	}
+
+	// Deleting the root also removes the cross-signed certificates
+	doReq(newBackend, newStorage, logical.DeleteOperation, "root", nil)
+	if resp = doReq(newBackend, newStorage, logical.ReadOperation, "config/ca_cross_signed", nil); resp != nil {
+		t.Fatalf("expected cross-signed certificates to be removed, got: %#v", resp)
+	}
+}
+
+func TestPki_LatestChainExpiry(t *testing.T) {
+	now := time.Now()
+	oldRoot, oldRootKey := createTestCACert(t, "old-root", now.Add(2*time.Hour), nil, nil, nil)
+	newRoot, newRootKey := createTestCACert(t, "new-root", now.Add(300*time.Hour), nil, nil, nil)
+	intermediate, intermediateKey := createTestCACert(t, "intermediate", now.Add(100*time.Hour), nil, oldRoot, oldRootKey)
+	crossSigned, _ := createTestCACert(t, "intermediate", now.Add(150*time.Hour), intermediateKey, newRoot, newRootKey)
+
+	caInfo := &caInfoBundle{
+		ParsedCertBundle: certutil.ParsedCertBundle{
+			Certificate:      intermediate,
+			CertificateBytes: intermediate.Raw,
+			CAChain: []*certutil.CertBlock{
+				{Certificate: oldRoot, Bytes: oldRoot.Raw},
+			},
+		},
+	}
+	if expiry := caInfo.latestChainExpiry(); !expiry.Equal(oldRoot.NotAfter) {
+		t.Fatalf("expected chain expiry %s, got %s", oldRoot.NotAfter, expiry)
+	}
+
+	caInfo.CrossSigned = []*certutil.CertBlock{
+		{Certificate: crossSigned, Bytes: crossSigned.Raw},
+		{Certificate: newRoot, Bytes: newRoot.Raw},
+	}
+	if expiry := caInfo.latestChainExpiry(); !expiry.Equal(crossSigned.NotAfter) {
+		t.Fatalf("expected chain expiry %s, got %s", crossSigned.NotAfter, expiry)
+	}
+
+	chain := caInfo.GetCAChain()
+	if len(chain) != 4 || chain[2].Certificate != crossSigned || chain[3].Certificate != newRoot {
+		t.Fatalf("bad chain: %#v", chain)
+	}
+}
+
+func parseCertPEM(t *testing.T, certPEM string) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		t.Fatal("unable to decode certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// createTestCACert creates a CA certificate with the given key, or a new one
+// if nil, signed by the given parent or self-signed if nil
+func createTestCACert(t *testing.T, cn string, notAfter time.Time, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	var err error
+	if key == nil {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	subjKeyID, err := certutil.GetSubjKeyID(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := certutil.GenerateSerialNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		SubjectKeyId:          subjKeyID,
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              notAfter.Truncate(time.Second),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
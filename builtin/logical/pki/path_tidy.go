@@ -102,6 +102,9 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from storage: {{err}}", serial), err)
 				}
+				if err := req.Storage.Delete(ctx, "cert_metadata/"+normalizeSerial(serial)); err != nil {
+					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting metadata for serial %q from storage: {{err}}", serial), err)
+				}
 			}
 		}
 	}
@@ -158,6 +161,9 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 				if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
 					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from revoked list: {{err}}", serial), err)
 				}
+				if err := req.Storage.Delete(ctx, "cert_metadata/"+normalizeSerial(serial)); err != nil {
+					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting metadata for serial %q from storage: {{err}}", serial), err)
+				}
 				tidiedRevoked = true
 			}
 		}
@@ -196,5 +202,5 @@ will be checked when this endpoint is hit. The expiration of the
 certificate/revocation information of each certificate being held in
 certificate storage or in revocation information will then be checked. If the
 current time, minus the value of 'safety_buffer', is greater than the
-expiration, it will be removed.
+expiration, it will be removed, along with its issuance metadata.
 `
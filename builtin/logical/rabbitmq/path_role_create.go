@@ -31,6 +31,31 @@ func pathCreds(b *backend) *framework.Path {
 	}
 }
 
+// generatePassword generates a password from the password policy of the
+// connection configuration, or a UUID if no policy is set
+func (b *backend) generatePassword(ctx context.Context, s logical.Storage) (string, error) {
+	entry, err := s.Get(ctx, "config/connection")
+	if err != nil {
+		return "", err
+	}
+	var connConfig connectionConfig
+	if entry != nil {
+		if err := entry.DecodeJSON(&connConfig); err != nil {
+			return "", err
+		}
+	}
+
+	if connConfig.PasswordPolicy == "" {
+		return uuid.GenerateUUID()
+	}
+
+	password, err := b.System().GeneratePasswordFromPolicy(ctx, connConfig.PasswordPolicy)
+	if err != nil {
+		return "", errwrap.Wrapf("unable to generate password: {{err}}", err)
+	}
+	return password, nil
+}
+
 // Issues the credential based on the role name
 func (b *backend) pathCredsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
@@ -54,7 +79,7 @@ func (b *backend) pathCredsRead(ctx context.Context, req *logical.Request, d *fr
 	}
 	username := fmt.Sprintf("%s-%s", req.DisplayName, uuidVal)
 
-	password, err := uuid.GenerateUUID()
+	password, err := b.generatePassword(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
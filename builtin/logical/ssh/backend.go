@@ -38,6 +38,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			Unauthenticated: []string{
 				"verify",
 				"public_key",
+				"krl",
 			},
 
 			LocalStorage: []string{
@@ -47,6 +48,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			SealWrapStorage: []string{
 				caPrivateKey,
 				caPrivateKeyStoragePath,
+				caKeysStoragePrefix,
 				"keys/",
 			},
 		},
@@ -60,8 +62,15 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathLookup(&b),
 			pathVerify(&b),
 			pathConfigCA(&b),
+			pathListCAKeys(&b),
+			pathCAKeys(&b),
+			pathConfigCAActiveKey(&b),
 			pathSign(&b),
+			pathIssue(&b),
 			pathFetchPublicKey(&b),
+			pathRevoke(&b),
+			pathFetchKRL(&b),
+			pathTidy(&b),
 		},
 
 		Secrets: []*framework.Secret{
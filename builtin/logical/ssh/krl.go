@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"time"
+)
+
+// The OpenSSH key revocation list format is described in PROTOCOL.krl in the
+// OpenSSH source tree.
+const (
+	krlMagic         uint64 = 0x5353484b524c0a00
+	krlFormatVersion uint32 = 1
+
+	krlSectionCertificates = 1
+
+	krlSectionCertSerialList = 0x20
+)
+
+// krl is a key revocation list holding the revoked certificate serial
+// numbers, indexed by the wire format of the public key of the CA that
+// signed them
+type krl struct {
+	Version     uint64
+	GeneratedAt time.Time
+	Comment     string
+	Serials     map[string][]uint64
+}
+
+// Marshal returns the binary OpenSSH encoding of the KRL, which can be used
+// with the RevokedKeys option of sshd
+func (k *krl) Marshal() []byte {
+	var buf bytes.Buffer
+
+	krlWriteUint64(&buf, krlMagic)
+	krlWriteUint32(&buf, krlFormatVersion)
+	krlWriteUint64(&buf, k.Version)
+	krlWriteUint64(&buf, uint64(k.GeneratedAt.Unix()))
+	krlWriteUint64(&buf, 0) // flags
+	krlWriteString(&buf, nil)
+	krlWriteString(&buf, []byte(k.Comment))
+
+	caKeys := make([]string, 0, len(k.Serials))
+	for caKey := range k.Serials {
+		caKeys = append(caKeys, caKey)
+	}
+	sort.Strings(caKeys)
+
+	for _, caKey := range caKeys {
+		serials := append([]uint64{}, k.Serials[caKey]...)
+		sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+
+		var data bytes.Buffer
+		for _, serial := range serials {
+			krlWriteUint64(&data, serial)
+		}
+
+		var section bytes.Buffer
+		krlWriteString(&section, []byte(caKey))
+		krlWriteString(&section, nil)
+		section.WriteByte(krlSectionCertSerialList)
+		krlWriteString(&section, data.Bytes())
+
+		buf.WriteByte(krlSectionCertificates)
+		krlWriteString(&buf, section.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+func krlWriteUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func krlWriteUint64(buf *bytes.Buffer, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	buf.Write(b[:])
+}
+
+func krlWriteString(buf *bytes.Buffer, s []byte) {
+	krlWriteUint32(buf, uint32(len(s)))
+	buf.Write(s)
+}
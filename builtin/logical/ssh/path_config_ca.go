@@ -83,6 +83,10 @@ func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, da
 }
 
 func (b *backend) pathConfigCADelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if errResp, err := b.prepareCAKeyDelete(ctx, req.Storage, defaultCAKeyID); errResp != nil || err != nil {
+		return errResp, err
+	}
+
 	if err := req.Storage.Delete(ctx, caPrivateKeyStoragePath); err != nil {
 		return nil, err
 	}
@@ -145,60 +149,9 @@ func caKey(ctx context.Context, storage logical.Storage, keyType string) (*keySt
 }
 
 func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	var err error
-	publicKey := data.Get("public_key").(string)
-	privateKey := data.Get("private_key").(string)
-
-	var generateSigningKey bool
-
-	generateSigningKeyRaw, ok := data.GetOk("generate_signing_key")
-	switch {
-	// explicitly set true
-	case ok && generateSigningKeyRaw.(bool):
-		if publicKey != "" || privateKey != "" {
-			return logical.ErrorResponse("public_key and private_key must not be set when generate_signing_key is set to true"), nil
-		}
-
-		generateSigningKey = true
-
-	// explicitly set to false, or not set and we have both a public and private key
-	case ok, publicKey != "" && privateKey != "":
-		if publicKey == "" {
-			return logical.ErrorResponse("missing public_key"), nil
-		}
-
-		if privateKey == "" {
-			return logical.ErrorResponse("missing private_key"), nil
-		}
-
-		_, err := ssh.ParsePrivateKey([]byte(privateKey))
-		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
-		}
-
-		_, err = parsePublicSSHKey(publicKey)
-		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
-		}
-
-	// not set and no public/private key provided so generate
-	case publicKey == "" && privateKey == "":
-		generateSigningKey = true
-
-	// not set, but one or the other supplied
-	default:
-		return logical.ErrorResponse("only one of public_key and private_key set; both must be set to use, or both must be blank to auto-generate"), nil
-	}
-
-	if generateSigningKey {
-		publicKey, privateKey, err = generateSSHKeyPair()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	if publicKey == "" || privateKey == "" {
-		return nil, fmt.Errorf("failed to generate or parse the keys")
+	publicKey, privateKey, generateSigningKey, errResp, err := caKeyPairFromInput(data)
+	if errResp != nil || err != nil {
+		return errResp, err
 	}
 
 	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
@@ -265,6 +218,69 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 	return nil, nil
 }
 
+// caKeyPairFromInput returns the CA key pair described by the
+// "public_key", "private_key" and "generate_signing_key" fields, generating
+// a new pair if requested, along with whether the pair was generated.
+func caKeyPairFromInput(data *framework.FieldData) (string, string, bool, *logical.Response, error) {
+	var err error
+	publicKey := data.Get("public_key").(string)
+	privateKey := data.Get("private_key").(string)
+
+	var generateSigningKey bool
+
+	generateSigningKeyRaw, ok := data.GetOk("generate_signing_key")
+	switch {
+	// explicitly set true
+	case ok && generateSigningKeyRaw.(bool):
+		if publicKey != "" || privateKey != "" {
+			return "", "", false, logical.ErrorResponse("public_key and private_key must not be set when generate_signing_key is set to true"), nil
+		}
+
+		generateSigningKey = true
+
+	// explicitly set to false, or not set and we have both a public and private key
+	case ok, publicKey != "" && privateKey != "":
+		if publicKey == "" {
+			return "", "", false, logical.ErrorResponse("missing public_key"), nil
+		}
+
+		if privateKey == "" {
+			return "", "", false, logical.ErrorResponse("missing private_key"), nil
+		}
+
+		_, err := ssh.ParsePrivateKey([]byte(privateKey))
+		if err != nil {
+			return "", "", false, logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
+		}
+
+		_, err = parsePublicSSHKey(publicKey)
+		if err != nil {
+			return "", "", false, logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
+		}
+
+	// not set and no public/private key provided so generate
+	case publicKey == "" && privateKey == "":
+		generateSigningKey = true
+
+	// not set, but one or the other supplied
+	default:
+		return "", "", false, logical.ErrorResponse("only one of public_key and private_key set; both must be set to use, or both must be blank to auto-generate"), nil
+	}
+
+	if generateSigningKey {
+		publicKey, privateKey, err = generateSSHKeyPair()
+		if err != nil {
+			return "", "", false, nil, err
+		}
+	}
+
+	if publicKey == "" || privateKey == "" {
+		return "", "", false, nil, fmt.Errorf("failed to generate or parse the keys")
+	}
+
+	return publicKey, privateKey, generateSigningKey, nil, nil
+}
+
 func generateSSHKeyPair() (string, string, error) {
 	privateSeed, err := rsa.GenerateKey(rand.Reader, 4096)
 	if err != nil {
@@ -0,0 +1,490 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// defaultCAKeyID is the ID of the CA key managed through "config/ca",
+	// which is stored at the original CA key storage paths
+	defaultCAKeyID = "default"
+
+	caKeysStoragePrefix    = "config/ca_keys/"
+	caActiveKeyStoragePath = "config/ca_active_key"
+)
+
+type caKeyEntry struct {
+	PublicKey  string `json:"public_key" structs:"public_key" mapstructure:"public_key"`
+	PrivateKey string `json:"private_key" structs:"private_key" mapstructure:"private_key"`
+}
+
+type caActiveKeyEntry struct {
+	KeyID string `json:"key_id" structs:"key_id" mapstructure:"key_id"`
+}
+
+func pathListCAKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca/keys/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathCAKeysList,
+		},
+
+		HelpSynopsis:    pathCAKeysHelpSyn,
+		HelpDescription: pathCAKeysHelpDesc,
+	}
+}
+
+func pathCAKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca/keys/" + framework.GenericNameRegex("key_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"key_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the CA key.`,
+			},
+			"private_key": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Private half of the SSH key that will be used to sign certificates.`,
+			},
+			"public_key": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Public half of the SSH key that will be used to sign certificates.`,
+			},
+			"generate_signing_key": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `Generate SSH key pair internally rather than use the private_key and public_key fields.`,
+				Default:     true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathCAKeyRead,
+			logical.UpdateOperation: b.pathCAKeyWrite,
+			logical.DeleteOperation: b.pathCAKeyDelete,
+		},
+
+		HelpSynopsis:    pathCAKeysHelpSyn,
+		HelpDescription: pathCAKeysHelpDesc,
+	}
+}
+
+func pathConfigCAActiveKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca/active_key",
+		Fields: map[string]*framework.FieldSchema{
+			"key_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the CA key used to sign certificates for roles that do not set "ca_key_id".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigCAActiveKeyRead,
+			logical.UpdateOperation: b.pathConfigCAActiveKeyWrite,
+		},
+
+		HelpSynopsis:    pathConfigCAActiveKeyHelpSyn,
+		HelpDescription: pathConfigCAActiveKeyHelpDesc,
+	}
+}
+
+// fetchCAKeyPair returns the CA key pair with the given ID, or nil if it
+// doesn't exist
+func fetchCAKeyPair(ctx context.Context, s logical.Storage, keyID string) (*caKeyEntry, error) {
+	if keyID == defaultCAKeyID {
+		publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to read CA public key: {{err}}", err)
+		}
+		privateKeyEntry, err := caKey(ctx, s, caPrivateKey)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to read CA private key: {{err}}", err)
+		}
+		if publicKeyEntry == nil || publicKeyEntry.Key == "" || privateKeyEntry == nil || privateKeyEntry.Key == "" {
+			return nil, nil
+		}
+
+		return &caKeyEntry{
+			PublicKey:  publicKeyEntry.Key,
+			PrivateKey: privateKeyEntry.Key,
+		}, nil
+	}
+
+	entry, err := s.Get(ctx, caKeysStoragePrefix+keyID)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to read CA key %q: {{err}}", keyID), err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result caKeyEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// caKeyIDs returns the IDs of all configured CA keys, in sorted order
+func caKeyIDs(ctx context.Context, s logical.Storage) ([]string, error) {
+	keyIDs, err := s.List(ctx, caKeysStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultKey, err := fetchCAKeyPair(ctx, s, defaultCAKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if defaultKey != nil {
+		keyIDs = append(keyIDs, defaultCAKeyID)
+	}
+
+	sort.Strings(keyIDs)
+	return keyIDs, nil
+}
+
+// activeCAKeyID returns the ID of the CA key used to sign certificates for
+// roles that don't pin a key
+func activeCAKeyID(ctx context.Context, s logical.Storage) (string, error) {
+	entry, err := s.Get(ctx, caActiveKeyStoragePath)
+	if err != nil {
+		return "", errwrap.Wrapf("failed to read active CA key: {{err}}", err)
+	}
+	if entry == nil {
+		return defaultCAKeyID, nil
+	}
+
+	var result caActiveKeyEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return "", err
+	}
+
+	return result.KeyID, nil
+}
+
+func setActiveCAKeyID(ctx context.Context, s logical.Storage, keyID string) error {
+	entry, err := logical.StorageEntryJSON(caActiveKeyStoragePath, &caActiveKeyEntry{
+		KeyID: keyID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// caSigner returns the signer of the CA key with the given ID, or of the
+// active CA key if the ID is empty
+func caSigner(ctx context.Context, s logical.Storage, keyID string) (ssh.Signer, error) {
+	if keyID == "" {
+		var err error
+		keyID, err = activeCAKeyID(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keyPair, err := fetchCAKeyPair(ctx, s, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if keyPair == nil {
+		return nil, fmt.Errorf("CA key %q is not configured", keyID)
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(keyPair.PrivateKey))
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to parse stored private key of CA key %q: {{err}}", keyID), err)
+	}
+
+	return signer, nil
+}
+
+func (b *backend) pathCAKeysList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyIDs, err := caKeyIDs(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	activeKeyID, err := activeCAKeyID(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := map[string]interface{}{}
+	for _, keyID := range keyIDs {
+		keyPair, err := fetchCAKeyPair(ctx, req.Storage, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if keyPair == nil {
+			continue
+		}
+		keyInfo[keyID] = map[string]interface{}{
+			"public_key": keyPair.PublicKey,
+			"active":     keyID == activeKeyID,
+		}
+	}
+
+	return logical.ListResponseWithInfo(keyIDs, keyInfo), nil
+}
+
+func (b *backend) pathCAKeyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyID := data.Get("key_id").(string)
+
+	keyPair, err := fetchCAKeyPair(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if keyPair == nil {
+		return nil, nil
+	}
+
+	activeKeyID, err := activeCAKeyID(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": keyPair.PublicKey,
+			"active":     keyID == activeKeyID,
+		},
+	}, nil
+}
+
+func (b *backend) pathCAKeyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyID := data.Get("key_id").(string)
+	if keyID == "" {
+		return logical.ErrorResponse("missing key_id"), nil
+	}
+
+	// The default key keeps being stored where "config/ca" has always
+	// stored it
+	if keyID == defaultCAKeyID {
+		return b.pathConfigCAUpdate(ctx, req, data)
+	}
+
+	publicKey, privateKey, generateSigningKey, errResp, err := caKeyPairFromInput(data)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	existing, err := fetchCAKeyPair(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return logical.ErrorResponse(fmt.Sprintf("CA key %q is already configured; delete it before reconfiguring", keyID)), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(caKeysStoragePrefix+keyID, &caKeyEntry{
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	// If there is no usable active key, which is the case when this is the
+	// first key of the mount, the new key becomes the active one
+	activeKeyID, err := activeCAKeyID(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	activeKey, err := fetchCAKeyPair(ctx, req.Storage, activeKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if activeKey == nil {
+		if err := setActiveCAKeyID(ctx, req.Storage, keyID); err != nil {
+			return nil, err
+		}
+	}
+
+	if generateSigningKey {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"public_key": publicKey,
+			},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathCAKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyID := data.Get("key_id").(string)
+
+	if keyID == defaultCAKeyID {
+		return b.pathConfigCADelete(ctx, req, data)
+	}
+
+	if errResp, err := b.prepareCAKeyDelete(ctx, req.Storage, keyID); errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	if err := req.Storage.Delete(ctx, caKeysStoragePrefix+keyID); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// prepareCAKeyDelete returns an error response if the CA key with the given
+// ID cannot be deleted, which is the case if it is the active key while other
+// keys are configured or if a role pins it. If the last key is about to be
+// deleted, the active key is reset to the default one.
+func (b *backend) prepareCAKeyDelete(ctx context.Context, s logical.Storage, keyID string) (*logical.Response, error) {
+	roleNames, err := s.List(ctx, "roles/")
+	if err != nil {
+		return nil, err
+	}
+	for _, roleName := range roleNames {
+		role, err := b.getRole(ctx, s, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && role.CAKeyID == keyID {
+			return logical.ErrorResponse(fmt.Sprintf("cannot delete CA key %q since it is used by role %q", keyID, roleName)), nil
+		}
+	}
+
+	activeKeyID, err := activeCAKeyID(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if keyID != activeKeyID {
+		return nil, nil
+	}
+
+	keyIDs, err := caKeyIDs(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, otherKeyID := range keyIDs {
+		if otherKeyID != keyID {
+			return logical.ErrorResponse("cannot delete the active CA key; activate another key first"), nil
+		}
+	}
+
+	if err := s.Delete(ctx, caActiveKeyStoragePath); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigCAActiveKeyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	activeKeyID, err := activeCAKeyID(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id": activeKeyID,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigCAActiveKeyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyID := data.Get("key_id").(string)
+	if keyID == "" {
+		return logical.ErrorResponse("missing key_id"), nil
+	}
+
+	keyPair, err := fetchCAKeyPair(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if keyPair == nil {
+		return logical.ErrorResponse(fmt.Sprintf("CA key %q is not configured", keyID)), nil
+	}
+
+	if err := setActiveCAKeyID(ctx, req.Storage, keyID); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// trustedCAPublicKeys returns the public keys of all configured CA keys, the
+// active key first, in authorized_keys format
+func trustedCAPublicKeys(ctx context.Context, s logical.Storage) (string, error) {
+	keyIDs, err := caKeyIDs(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	activeKeyID, err := activeCAKeyID(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	var publicKeys []string
+	for _, keyID := range keyIDs {
+		keyPair, err := fetchCAKeyPair(ctx, s, keyID)
+		if err != nil {
+			return "", err
+		}
+		if keyPair == nil {
+			continue
+		}
+
+		publicKey := strings.TrimSpace(keyPair.PublicKey)
+		if keyID == activeKeyID {
+			publicKeys = append([]string{publicKey}, publicKeys...)
+		} else {
+			publicKeys = append(publicKeys, publicKey)
+		}
+	}
+	if len(publicKeys) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(publicKeys, "\n") + "\n", nil
+}
+
+const pathCAKeysHelpSyn = `
+Manage the CA keys used to sign certificates.
+`
+
+const pathCAKeysHelpDesc = `
+A mount can hold several CA keys so that the signing key can be rotated
+without invalidating certificates signed by the previous key. The key
+configured through "config/ca" has the ID "default".
+
+All configured keys are served by the "public_key" endpoint, so that hosts
+trust certificates signed by any of them during the overlap period.
+Certificates are signed with the active key, see "config/ca/active_key",
+unless the role pins a key with "ca_key_id".
+
+For security reasons, the private keys cannot be retrieved later. The
+active key cannot be deleted while other keys are configured, and keys
+pinned by a role cannot be deleted.
+`
+
+const pathConfigCAActiveKeyHelpSyn = `
+Configure the CA key used to sign certificates.
+`
+
+const pathConfigCAActiveKeyHelpDesc = `
+This endpoint sets the CA key used to sign certificates for roles that do
+not pin a key with "ca_key_id". If not configured, the "default" key
+managed through "config/ca" is used.
+`
@@ -1,10 +1,13 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestSSH_ConfigCAStorageUpgrade(t *testing.T) {
@@ -162,3 +165,172 @@ func TestSSH_ConfigCAUpdateDelete(t *testing.T) {
 		t.Fatalf("bad: err: %v, resp:%v", err, resp)
 	}
 }
+
+func TestSSH_ConfigCAKeyRotation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	doReq(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	})
+	resp := doReq(logical.UpdateOperation, "config/ca/keys/next", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	nextPublicKey := resp.Data["public_key"].(string)
+
+	resp = doReq(logical.ListOperation, "config/ca/keys/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 || keys[0] != "default" || keys[1] != "next" {
+		t.Fatalf("bad keys: %#v", resp.Data)
+	}
+
+	// Both keys are trusted, the active one first
+	fetchPublicKeys := func() []string {
+		t.Helper()
+		resp := doReq(logical.ReadOperation, "public_key", nil)
+		if resp == nil {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(string(resp.Data[logical.HTTPRawBody].([]byte))), "\n")
+	}
+	if keys := fetchPublicKeys(); len(keys) != 2 || keys[0] != strings.TrimSpace(publicKey) || keys[1] != strings.TrimSpace(nextPublicKey) {
+		t.Fatalf("bad public keys: %#v", keys)
+	}
+
+	// A role can only pin an existing key
+	resp = doReq(logical.UpdateOperation, "roles/pinned", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"ca_key_id":               "missing",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unknown CA key, got: %#v", resp)
+	}
+	doReq(logical.UpdateOperation, "roles/pinned", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"ca_key_id":               "next",
+	})
+	doReq(logical.UpdateOperation, "roles/unpinned", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+	})
+
+	signedBy := func(role string) []byte {
+		t.Helper()
+		resp := doReq(logical.UpdateOperation, "sign/"+role, map[string]interface{}{
+			"public_key":       publicKey2,
+			"valid_principals": "tuber",
+		})
+		if resp == nil || resp.IsError() {
+			t.Fatalf("bad: %#v", resp)
+		}
+		parsed, err := parsePublicSSHKey(resp.Data["signed_key"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.(*ssh.Certificate).SignatureKey.Marshal()
+	}
+	mustParse := func(key string) []byte {
+		t.Helper()
+		parsed, err := parsePublicSSHKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.Marshal()
+	}
+
+	if !bytes.Equal(signedBy("unpinned"), mustParse(publicKey)) {
+		t.Fatal("expected certificate to be signed by the default key")
+	}
+	if !bytes.Equal(signedBy("pinned"), mustParse(nextPublicKey)) {
+		t.Fatal("expected certificate to be signed by the pinned key")
+	}
+
+	// Rotate to the new key
+	resp = doReq(logical.UpdateOperation, "config/ca/active_key", map[string]interface{}{
+		"key_id": "missing",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unknown CA key, got: %#v", resp)
+	}
+	doReq(logical.UpdateOperation, "config/ca/active_key", map[string]interface{}{
+		"key_id": "next",
+	})
+	if !bytes.Equal(signedBy("unpinned"), mustParse(nextPublicKey)) {
+		t.Fatal("expected certificate to be signed by the active key")
+	}
+	if keys := fetchPublicKeys(); len(keys) != 2 || keys[0] != strings.TrimSpace(nextPublicKey) {
+		t.Fatalf("bad public keys: %#v", keys)
+	}
+
+	// A configured key can't be overwritten
+	resp = doReq(logical.UpdateOperation, "config/ca/keys/next", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error overwriting a key, got: %#v", resp)
+	}
+
+	// The active key cannot be deleted, but the old one can
+	resp = doReq(logical.DeleteOperation, "config/ca/keys/next", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error deleting the active key, got: %#v", resp)
+	}
+	doReq(logical.UpdateOperation, "config/ca/active_key", map[string]interface{}{
+		"key_id": "default",
+	})
+	resp = doReq(logical.DeleteOperation, "config/ca", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error deleting the active key, got: %#v", resp)
+	}
+	doReq(logical.UpdateOperation, "config/ca/active_key", map[string]interface{}{
+		"key_id": "next",
+	})
+
+	// Keys pinned by a role cannot be deleted
+	doReq(logical.UpdateOperation, "roles/pinned", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"ca_key_id":               "default",
+	})
+	resp = doReq(logical.DeleteOperation, "config/ca/keys/default", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error deleting a pinned key, got: %#v", resp)
+	}
+	resp = doReq(logical.DeleteOperation, "config/ca", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error deleting a pinned key, got: %#v", resp)
+	}
+	doReq(logical.DeleteOperation, "roles/pinned", nil)
+
+	doReq(logical.DeleteOperation, "config/ca/keys/default", nil)
+	if keys := fetchPublicKeys(); len(keys) != 1 || keys[0] != strings.TrimSpace(nextPublicKey) {
+		t.Fatalf("bad public keys: %#v", keys)
+	}
+	if resp = doReq(logical.ReadOperation, "config/ca/keys/default", nil); resp != nil {
+		t.Fatalf("expected default key to be deleted, got: %#v", resp)
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"strings"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -62,6 +62,15 @@ func (b *backend) pathCredsCreateWrite(ctx context.Context, req *logical.Request
 		return logical.ErrorResponse(fmt.Sprintf("Role %q not found", roleName)), nil
 	}
 
+	role, err = b.resolveRoleTemplates(req, role)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+
 	// username is an optional parameter.
 	username := d.Get("username").(string)
 
@@ -73,9 +82,10 @@ func (b *backend) pathCredsCreateWrite(ctx context.Context, req *logical.Request
 		username = role.DefaultUser
 	}
 
-	if role.AllowedUsers != "" {
+	allowedUsers := role.allowedUsersList()
+	if len(allowedUsers) > 0 {
 		// Check if the username is present in allowed users list.
-		err := validateUsername(username, role.AllowedUsers)
+		err := validateUsername(username, role.AllowedUsers == "*", allowedUsers)
 
 		// If username is not present in allowed users list, check if it
 		// is the default username in the role. If neither is true, then
@@ -294,19 +304,18 @@ func validateIP(ip, roleName, cidrList, excludeCidrList string, zeroAddressRoles
 
 // Checks if the username supplied by the user is present in the list of
 // allowed users registered which creation of role.
-func validateUsername(username, allowedUsers string) error {
-	if allowedUsers == "" {
+func validateUsername(username string, allowAny bool, allowedUsers []string) error {
+	if len(allowedUsers) == 0 {
 		return fmt.Errorf("username not in allowed users list")
 	}
 
 	// Role was explicitly configured to allow any username.
-	if allowedUsers == "*" {
+	if allowAny {
 		return nil
 	}
 
-	userList := strings.Split(allowedUsers, ",")
-	for _, user := range userList {
-		if strings.TrimSpace(user) == username {
+	for _, user := range allowedUsers {
+		if user == username {
 			return nil
 		}
 	}
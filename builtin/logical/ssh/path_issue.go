@@ -0,0 +1,258 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+func pathIssue(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issue/" + framework.GenericNameRegex("role"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathIssue,
+		},
+
+		Fields: addSignCommonFields(map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The desired role with configuration for this request.`,
+			},
+			"key_type": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Type of the key pair to generate. The key pair is always of the
+type configured on the role; if set, this must match it.`,
+			},
+			"key_bits": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `Number of bits of the key pair to generate. The key pair always
+has the length configured on the role; if set, this must match it.`,
+			},
+		}),
+
+		HelpSynopsis: `Request a new SSH key pair signed using a certain role with the provided details.`,
+		HelpDescription: `This path generates a new SSH key pair and signs its public key according to the policy of the given role.
+The private key is returned along with the certificate and is not stored.`,
+	}
+}
+
+func (b *backend) pathIssue(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+
+	// Get the role
+	role, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("Unknown role: %s", roleName)), nil
+	}
+
+	role, err = b.resolveRoleTemplates(req, role)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+	if role.KeyType != KeyTypeCA {
+		return logical.ErrorResponse("key pairs can only be issued by roles with key type 'ca'"), nil
+	}
+
+	// The key pair always follows the role, which was validated when it was
+	// written; roles written before issued key types existed use RSA
+	keyType := role.IssuedKeyType
+	if keyType == "" {
+		keyType = "rsa"
+	}
+	keyBits, err := issuedKeyBits(keyType, role.KeyBits)
+	if err != nil {
+		return nil, err
+	}
+	if keyTypeRaw, ok := data.GetOk("key_type"); ok && keyTypeRaw.(string) != keyType {
+		return logical.ErrorResponse(fmt.Sprintf("key_type %q does not match the role's issued key type %q", keyTypeRaw.(string), keyType)), nil
+	}
+	if keyBitsRaw, ok := data.GetOk("key_bits"); ok && keyBitsRaw.(int) != 0 && keyBitsRaw.(int) != keyBits {
+		return logical.ErrorResponse(fmt.Sprintf("key_bits %d does not match the role's key bits %d", keyBitsRaw.(int), keyBits)), nil
+	}
+
+	publicKey, privateKey, err := generateIssuedKeyPair(keyType, keyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.signPublicKey(ctx, req, data, role, publicKey)
+	if err != nil || resp.IsError() {
+		return resp, err
+	}
+
+	resp.Data["private_key"] = privateKey
+	resp.Data["private_key_type"] = keyType
+	resp.Data["public_key"] = string(ssh.MarshalAuthorizedKey(publicKey))
+
+	return resp, nil
+}
+
+// issuedKeyBits validates the type and length of the key pairs generated by
+// the issue endpoint, returning the length with the default of the key type
+// applied
+func issuedKeyBits(keyType string, keyBits int) (int, error) {
+	switch keyType {
+	case "rsa":
+		if keyBits == 0 {
+			return 2048, nil
+		}
+		if keyBits < 2048 {
+			return 0, fmt.Errorf("RSA keys must be at least 2048 bits")
+		}
+	case "ec":
+		switch keyBits {
+		case 0:
+			return 256, nil
+		case 256, 384, 521:
+		default:
+			return 0, fmt.Errorf("unsupported bit length for EC key: %d", keyBits)
+		}
+	case "ed25519":
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unsupported key type %q", keyType)
+	}
+
+	return keyBits, nil
+}
+
+// generateIssuedKeyPair generates a key pair of the given type and length,
+// which must have been validated with issuedKeyBits, returning the public key
+// and the PEM-encoded private key
+func generateIssuedKeyPair(keyType string, keyBits int) (ssh.PublicKey, string, error) {
+	var signer interface{}
+	var privateBlock *pem.Block
+
+	switch keyType {
+	case "rsa":
+		privateKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+		if err != nil {
+			return nil, "", errwrap.Wrapf("error generating RSA key pair: {{err}}", err)
+		}
+		signer = privateKey
+		privateBlock = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}
+
+	case "ec":
+		var curve elliptic.Curve
+		switch keyBits {
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			curve = elliptic.P256()
+		}
+		privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, "", errwrap.Wrapf("error generating EC key pair: {{err}}", err)
+		}
+		marshaled, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, "", errwrap.Wrapf("error marshaling EC private key: {{err}}", err)
+		}
+		signer = privateKey
+		privateBlock = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: marshaled,
+		}
+
+	case "ed25519":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, "", errwrap.Wrapf("error generating ed25519 key pair: {{err}}", err)
+		}
+		signer = privateKey
+		privateBlock = &pem.Block{
+			Type:  "OPENSSH PRIVATE KEY",
+			Bytes: marshalED25519PrivateKey(privateKey),
+		}
+
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %q", keyType)
+	}
+
+	sshSigner, err := ssh.NewSignerFromKey(signer)
+	if err != nil {
+		return nil, "", errwrap.Wrapf("error creating SSH public key: {{err}}", err)
+	}
+
+	return sshSigner.PublicKey(), string(pem.EncodeToMemory(privateBlock)), nil
+}
+
+// marshalED25519PrivateKey encodes an ed25519 private key in the unencrypted
+// OpenSSH private key format, the only format OpenSSH supports for ed25519
+// keys. The format is described in PROTOCOL.key in the OpenSSH source tree.
+func marshalED25519PrivateKey(key ed25519.PrivateKey) []byte {
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	var check [4]byte
+	rand.Read(check[:])
+	checkInt := binary.BigEndian.Uint32(check[:])
+
+	privateBlock := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+	}{
+		Check1:  checkInt,
+		Check2:  checkInt,
+		Keytype: ssh.KeyAlgoED25519,
+		Pub:     publicKey,
+		Priv:    key,
+	})
+	// The private block is padded to the cipher block size, which is 8 for
+	// unencrypted keys
+	for i := 1; len(privateBlock)%8 != 0; i++ {
+		privateBlock = append(privateBlock, byte(i))
+	}
+
+	wirePublicKey := ssh.Marshal(struct {
+		Keytype string
+		Pub     []byte
+	}{
+		Keytype: ssh.KeyAlgoED25519,
+		Pub:     publicKey,
+	})
+
+	return append([]byte("openssh-key-v1\x00"), ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       wirePublicKey,
+		PrivKeyBlock: privateBlock,
+	})...)
+}
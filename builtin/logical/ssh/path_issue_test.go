@@ -0,0 +1,159 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_Issue(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	doReq := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	doReq("config/ca", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	})
+	writeRole := func(keyType string, keyBits int) *logical.Response {
+		t.Helper()
+		return doReq("roles/testing", map[string]interface{}{
+			"key_type":                "ca",
+			"issued_key_type":         keyType,
+			"key_bits":                keyBits,
+			"allow_user_certificates": true,
+			"allowed_users":           "tuber",
+			"default_user":            "tuber",
+			"default_extensions": map[string]interface{}{
+				"permit-pty": "",
+			},
+		})
+	}
+
+	for _, tc := range []struct {
+		keyType string
+		keyBits int
+		algo    string
+	}{
+		{"", 0, ssh.KeyAlgoRSA},
+		{"ec", 384, ssh.KeyAlgoECDSA384},
+		{"ed25519", 0, ssh.KeyAlgoED25519},
+	} {
+		if resp := writeRole(tc.keyType, tc.keyBits); resp != nil && resp.IsError() {
+			t.Fatalf("%s: bad: %#v", tc.keyType, resp)
+		}
+		if tc.keyType == "" {
+			tc.keyType = "rsa"
+		}
+
+		resp := doReq("issue/testing", nil)
+		if resp == nil || resp.IsError() {
+			t.Fatalf("%s: bad: %#v", tc.keyType, resp)
+		}
+		if resp.Data["private_key_type"] != tc.keyType {
+			t.Fatalf("%s: bad private key type: %#v", tc.keyType, resp.Data)
+		}
+
+		signer, err := ssh.ParsePrivateKey([]byte(resp.Data["private_key"].(string)))
+		if err != nil {
+			t.Fatalf("%s: failed to parse private key: %v", tc.keyType, err)
+		}
+		if signer.PublicKey().Type() != tc.algo {
+			t.Fatalf("%s: bad key algorithm %s", tc.keyType, signer.PublicKey().Type())
+		}
+
+		parsed, err := parsePublicSSHKey(resp.Data["signed_key"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := parsed.(*ssh.Certificate)
+		if !bytes.Equal(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+			t.Fatalf("%s: certificate was not issued for the generated key", tc.keyType)
+		}
+		if len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "tuber" {
+			t.Fatalf("%s: bad principals: %v", tc.keyType, cert.ValidPrincipals)
+		}
+		if _, ok := cert.Extensions["permit-pty"]; !ok {
+			t.Fatalf("%s: bad extensions: %v", tc.keyType, cert.Extensions)
+		}
+
+		publicKeyResp, err := parsePublicSSHKey(resp.Data["public_key"].(string))
+		if err != nil || !bytes.Equal(publicKeyResp.Marshal(), signer.PublicKey().Marshal()) {
+			t.Fatalf("%s: bad public key: %v", tc.keyType, err)
+		}
+	}
+
+	// Role constraints still apply
+	resp := doReq("issue/testing", map[string]interface{}{
+		"valid_principals": "root",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for disallowed principal, got: %#v", resp)
+	}
+
+	// The key pair always follows the role
+	resp = doReq("issue/testing", map[string]interface{}{
+		"key_type": "ed25519",
+	})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	for _, data := range []map[string]interface{}{
+		{"key_type": "rsa"},
+		{"key_type": "ec", "key_bits": 521},
+	} {
+		resp = doReq("issue/testing", data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %v, got: %#v", data, resp)
+		}
+	}
+
+	writeRole("ec", 0)
+	resp = doReq("issue/testing", map[string]interface{}{
+		"key_type": "ec",
+		"key_bits": 256,
+	})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = doReq("issue/testing", map[string]interface{}{
+		"key_bits": 384,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for key bits not matching the role, got: %#v", resp)
+	}
+
+	for _, tc := range []struct {
+		keyType string
+		keyBits int
+	}{
+		{"dsa", 0},
+		{"rsa", 1024},
+		{"ec", 224},
+	} {
+		if resp = writeRole(tc.keyType, tc.keyBits); resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for key type %q with %d bits, got: %#v", tc.keyType, tc.keyBits, resp)
+		}
+	}
+}
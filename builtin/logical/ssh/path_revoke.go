@@ -0,0 +1,271 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ssh"
+)
+
+// signedCertEntry records a certificate signed by this backend so that it
+// can later be revoked
+type signedCertEntry struct {
+	SerialNumber   string    `json:"serial_number" structs:"serial_number" mapstructure:"serial_number"`
+	KeyID          string    `json:"key_id" structs:"key_id" mapstructure:"key_id"`
+	CAPublicKey    string    `json:"ca_public_key" structs:"ca_public_key" mapstructure:"ca_public_key"`
+	ValidBefore    time.Time `json:"valid_before" structs:"valid_before" mapstructure:"valid_before"`
+	RevocationTime time.Time `json:"revocation_time" structs:"revocation_time" mapstructure:"revocation_time"`
+}
+
+func pathRevoke(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke",
+		Fields: map[string]*framework.FieldSchema{
+			"serial_number": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate to revoke, in hex, as returned when it was signed.`,
+			},
+			"key_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Revoke all unexpired certificates signed with this key ID.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRevokeWrite,
+		},
+
+		HelpSynopsis:    pathRevokeHelpSyn,
+		HelpDescription: pathRevokeHelpDesc,
+	}
+}
+
+func pathFetchKRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "krl",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchKRL,
+		},
+
+		HelpSynopsis:    pathFetchKRLHelpSyn,
+		HelpDescription: pathFetchKRLHelpDesc,
+	}
+}
+
+// normalizeSerial returns the canonical hex representation of an SSH
+// certificate serial number
+func normalizeSerial(serial string) (string, error) {
+	parsed, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(serial), "0x"), 16, 64)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(parsed, 16), nil
+}
+
+// storeSignedCert records the given certificate, signed by this backend, in
+// the certificate store
+func storeSignedCert(ctx context.Context, s logical.Storage, cert *ssh.Certificate) error {
+	serial := strconv.FormatUint(cert.Serial, 16)
+	entry, err := logical.StorageEntryJSON("certs/"+serial, &signedCertEntry{
+		SerialNumber: serial,
+		KeyID:        cert.KeyId,
+		CAPublicKey:  strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert.SignatureKey))),
+		ValidBefore:  time.Unix(int64(cert.ValidBefore), 0).UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("unable to store signed certificate: {{err}}", err)
+	}
+
+	return nil
+}
+
+func fetchSignedCert(ctx context.Context, s logical.Storage, prefix, serial string) (*signedCertEntry, error) {
+	entry, err := s.Get(ctx, prefix+serial)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error fetching certificate %s: {{err}}", serial), err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result signedCertEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error decoding certificate %s: {{err}}", serial), err)
+	}
+
+	return &result, nil
+}
+
+func (b *backend) revokeSignedCert(ctx context.Context, s logical.Storage, cert *signedCertEntry) error {
+	// Certificates that were already revoked keep their original revocation
+	// time
+	revoked, err := fetchSignedCert(ctx, s, "revoked/", cert.SerialNumber)
+	if err != nil {
+		return err
+	}
+	if revoked != nil {
+		return nil
+	}
+
+	cert.RevocationTime = time.Now().UTC()
+	entry, err := logical.StorageEntryJSON("revoked/"+cert.SerialNumber, cert)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("error storing revocation of certificate %s: {{err}}", cert.SerialNumber), err)
+	}
+
+	return nil
+}
+
+func (b *backend) pathRevokeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	serial := data.Get("serial_number").(string)
+	keyID := data.Get("key_id").(string)
+
+	var certs []*signedCertEntry
+	switch {
+	case serial != "" && keyID != "":
+		return logical.ErrorResponse("only one of serial_number and key_id can be set"), nil
+
+	case serial != "":
+		normalized, err := normalizeSerial(serial)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid serial_number %q", serial)), nil
+		}
+		cert, err := fetchSignedCert(ctx, req.Storage, "certs/", normalized)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil {
+			return logical.ErrorResponse(fmt.Sprintf("certificate with serial %s not found", serial)), nil
+		}
+		certs = append(certs, cert)
+
+	case keyID != "":
+		serials, err := req.Storage.List(ctx, "certs/")
+		if err != nil {
+			return nil, errwrap.Wrapf("error fetching list of certs: {{err}}", err)
+		}
+		now := time.Now()
+		for _, serial := range serials {
+			cert, err := fetchSignedCert(ctx, req.Storage, "certs/", serial)
+			if err != nil {
+				return nil, err
+			}
+			if cert == nil || cert.KeyID != keyID || now.After(cert.ValidBefore) {
+				continue
+			}
+			certs = append(certs, cert)
+		}
+		if len(certs) == 0 {
+			return logical.ErrorResponse(fmt.Sprintf("no unexpired certificates found with key ID %q", keyID)), nil
+		}
+
+	default:
+		return logical.ErrorResponse("one of serial_number or key_id must be set"), nil
+	}
+
+	revokedSerials := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		if err := b.revokeSignedCert(ctx, req.Storage, cert); err != nil {
+			return nil, err
+		}
+		revokedSerials = append(revokedSerials, cert.SerialNumber)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"serial_numbers": revokedSerials,
+		},
+	}, nil
+}
+
+// buildKRL returns the key revocation list of all revoked certificates that
+// have not yet expired
+func buildKRL(ctx context.Context, s logical.Storage) (*krl, error) {
+	serials, err := s.List(ctx, "revoked/")
+	if err != nil {
+		return nil, errwrap.Wrapf("error fetching list of revoked certs: {{err}}", err)
+	}
+
+	now := time.Now()
+	result := &krl{
+		GeneratedAt: now,
+		Serials:     map[string][]uint64{},
+	}
+	for _, serial := range serials {
+		cert, err := fetchSignedCert(ctx, s, "revoked/", serial)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil || now.After(cert.ValidBefore) {
+			continue
+		}
+
+		caKey, err := parsePublicSSHKey(cert.CAPublicKey)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error parsing CA public key of revoked certificate %s: {{err}}", serial), err)
+		}
+		parsedSerial, err := strconv.ParseUint(cert.SerialNumber, 16, 64)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error parsing serial of revoked certificate %s: {{err}}", serial), err)
+		}
+
+		wireKey := string(caKey.Marshal())
+		result.Serials[wireKey] = append(result.Serials[wireKey], parsedSerial)
+
+		// The version increases with every revocation
+		if version := uint64(cert.RevocationTime.UnixNano()); version > result.Version {
+			result.Version = version
+		}
+	}
+
+	return result, nil
+}
+
+func (b *backend) pathFetchKRL(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	revocationList, err := buildKRL(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/octet-stream",
+			logical.HTTPRawBody:     revocationList.Marshal(),
+			logical.HTTPStatusCode:  200,
+		},
+	}, nil
+}
+
+const pathRevokeHelpSyn = `
+Revoke certificates signed by this backend.
+`
+
+const pathRevokeHelpDesc = `
+This endpoint revokes a signed certificate given its serial number, or all
+unexpired certificates signed with the given key ID. Revoked certificates
+are published in the key revocation list served by the "krl" endpoint.
+`
+
+const pathFetchKRLHelpSyn = `
+Fetch the key revocation list of revoked certificates.
+`
+
+const pathFetchKRLHelpDesc = `
+This endpoint returns the list of revoked, unexpired certificates in the
+binary OpenSSH KRL format. Hosts can periodically fetch it and reference it
+through the "RevokedKeys" option of sshd. This endpoint does not require
+authentication.
+`
@@ -0,0 +1,208 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestSSH_RevokeAndKRL(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	doReq(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	})
+	doReq(logical.UpdateOperation, "roles/testing", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"allow_user_key_ids":      true,
+	})
+
+	sign := func(keyID string) string {
+		t.Helper()
+		resp := doReq(logical.UpdateOperation, "sign/testing", map[string]interface{}{
+			"public_key":       publicKey2,
+			"valid_principals": "tuber",
+			"key_id":           keyID,
+		})
+		if resp == nil || resp.IsError() {
+			t.Fatalf("bad: %#v", resp)
+		}
+		return resp.Data["serial_number"].(string)
+	}
+	first := sign("alice")
+	second := sign("bob")
+	third := sign("bob")
+
+	fetchKRL := func() []uint64 {
+		t.Helper()
+		resp := doReq(logical.ReadOperation, "krl", nil)
+		return parseTestKRL(t, resp.Data[logical.HTTPRawBody].([]byte), publicKey)
+	}
+	if serials := fetchKRL(); len(serials) != 0 {
+		t.Fatalf("expected empty KRL, got: %v", serials)
+	}
+
+	resp := doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": "ffff",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unknown serial, got: %#v", resp)
+	}
+
+	doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": first,
+	})
+	if serials := fetchKRL(); len(serials) != 1 || strconv.FormatUint(serials[0], 16) != first {
+		t.Fatalf("bad KRL serials: %v", serials)
+	}
+
+	resp = doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"key_id": "bob",
+	})
+	if revoked := resp.Data["serial_numbers"].([]string); len(revoked) != 2 {
+		t.Fatalf("bad revoked serials: %#v", resp.Data)
+	}
+	expected := map[string]bool{first: true, second: true, third: true}
+	serials := fetchKRL()
+	if len(serials) != 3 {
+		t.Fatalf("bad KRL serials: %v", serials)
+	}
+	for _, serial := range serials {
+		if !expected[strconv.FormatUint(serial, 16)] {
+			t.Fatalf("unexpected serial %x in KRL", serial)
+		}
+	}
+
+	// Unexpired certificates are kept by tidy
+	doReq(logical.UpdateOperation, "tidy", map[string]interface{}{
+		"tidy_cert_store":      true,
+		"tidy_revocation_list": true,
+	})
+	if serials := fetchKRL(); len(serials) != 3 {
+		t.Fatalf("bad KRL serials after tidy: %v", serials)
+	}
+
+	// Expire a revoked certificate
+	cert, err := fetchSignedCert(context.Background(), config.StorageView, "revoked/", first)
+	if err != nil || cert == nil {
+		t.Fatalf("revoked certificate not found: %v", err)
+	}
+	cert.ValidBefore = time.Now().Add(-time.Minute)
+	entry, err := logical.StorageEntryJSON("revoked/"+first, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	if serials := fetchKRL(); len(serials) != 2 {
+		t.Fatalf("expected expired certificate to be left out of the KRL, got: %v", serials)
+	}
+
+	doReq(logical.UpdateOperation, "tidy", map[string]interface{}{
+		"tidy_revocation_list": true,
+		"safety_buffer":        1,
+	})
+	if cert, err := fetchSignedCert(context.Background(), config.StorageView, "revoked/", first); err != nil || cert != nil {
+		t.Fatalf("expected expired revocation entry to be tidied, got: %#v, err: %v", cert, err)
+	}
+}
+
+// parseTestKRL returns the serials revoked for the given CA key in an
+// OpenSSH KRL
+func parseTestKRL(t *testing.T, data []byte, caPublicKey string) []uint64 {
+	t.Helper()
+	caKey, err := parsePublicSSHKey(caPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(data)
+	readUint64 := func() uint64 {
+		var v uint64
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	readString := func(r *bytes.Reader) []byte {
+		var l uint32
+		if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+			t.Fatal(err)
+		}
+		s := make([]byte, l)
+		if _, err := r.Read(s); err != nil && l > 0 {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if readUint64() != krlMagic {
+		t.Fatal("bad KRL magic")
+	}
+	var formatVersion uint32
+	binary.Read(r, binary.BigEndian, &formatVersion)
+	if formatVersion != krlFormatVersion {
+		t.Fatalf("bad KRL format version %d", formatVersion)
+	}
+	readUint64() // krl_version
+	readUint64() // generated_date
+	readUint64() // flags
+	readString(r)
+	readString(r)
+
+	var serials []uint64
+	for r.Len() > 0 {
+		sectionType, _ := r.ReadByte()
+		section := bytes.NewReader(readString(r))
+		if sectionType != krlSectionCertificates {
+			t.Fatalf("unexpected section type %d", sectionType)
+		}
+		if !bytes.Equal(readString(section), caKey.Marshal()) {
+			t.Fatal("unexpected CA key in KRL")
+		}
+		readString(section)
+		for section.Len() > 0 {
+			certSectionType, _ := section.ReadByte()
+			certSection := bytes.NewReader(readString(section))
+			if certSectionType != krlSectionCertSerialList {
+				t.Fatalf("unexpected certificate section type %d", certSectionType)
+			}
+			for certSection.Len() > 0 {
+				var serial uint64
+				binary.Read(certSection, binary.BigEndian, &serial)
+				serials = append(serials, serial)
+			}
+		}
+	}
+
+	return serials
+}
@@ -9,7 +9,10 @@ import (
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/cidrutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/identitytpl"
 	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -48,6 +51,17 @@ type sshRole struct {
 	AllowSubdomains        bool              `mapstructure:"allow_subdomains" json:"allow_subdomains"`
 	AllowUserKeyIDs        bool              `mapstructure:"allow_user_key_ids" json:"allow_user_key_ids"`
 	KeyIDFormat            string            `mapstructure:"key_id_format" json:"key_id_format"`
+	CAKeyID                string            `mapstructure:"ca_key_id" json:"ca_key_id"`
+	AllowedUsersTemplate   bool              `mapstructure:"allowed_users_template" json:"allowed_users_template"`
+	DefaultUserTemplate    bool              `mapstructure:"default_user_template" json:"default_user_template"`
+	AllowedDomainsTemplate bool              `mapstructure:"allowed_domains_template" json:"allowed_domains_template"`
+	IssuedKeyType          string            `mapstructure:"issued_key_type" json:"issued_key_type"`
+
+	// resolvedAllowedUsers and resolvedAllowedDomains hold the templated
+	// lists resolved against the entity of the request. They are only set on
+	// the copies returned by resolveRoleTemplates and are never stored.
+	resolvedAllowedUsers   []string
+	resolvedAllowedDomains []string
 }
 
 func pathListRoles(b *backend) *framework.Path {
@@ -132,8 +146,10 @@ func pathRoles(b *backend) *framework.Path {
 			"key_bits": &framework.FieldSchema{
 				Type: framework.TypeInt,
 				Description: `
-				[Optional for Dynamic type] [Not applicable for OTP type] [Not applicable for CA type]
-				Length of the RSA dynamic key in bits. It is 1024 by default or it can be 2048.`,
+				[Optional for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				Length of the RSA dynamic key in bits. It is 1024 by default or it can be 2048.
+				For CA type, length of the key pairs generated by the issue endpoint. It is
+				2048 by default for RSA keys and 256 for EC keys, and ignored for ed25519 keys.`,
 			},
 			"install_script": &framework.FieldSchema{
 				Type: framework.TypeString,
@@ -167,6 +183,35 @@ func pathRoles(b *backend) *framework.Path {
 				valid host. If only certain domains are allowed, then this list enforces it.
 				`,
 			},
+			"allowed_users_template": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Optional for OTP type] [Optional for CA type]
+				If set, each entry of "allowed_users" can contain identity templates, such as
+				'{{identity.entity.aliases.<mount accessor>.name}}' or '{{identity.entity.metadata.<key>}}',
+				which are resolved from the entity of the requesting token. Entries that cannot
+				be resolved are ignored. Requests are rejected if an entry resolves to '*' or to
+				a value containing a comma; only a literal '*' allows any user.
+				`,
+			},
+			"default_user_template": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Optional for OTP type] [Optional for CA type]
+				If set, "default_user" can contain identity templates which are resolved from
+				the entity of the requesting token. If it cannot be resolved, there is no
+				default user for the request.
+				`,
+			},
+			"allowed_domains_template": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set, each entry of "allowed_domains" can contain identity templates which are
+				resolved from the entity of the requesting token. Entries that cannot be resolved
+				are ignored.
+				`,
+			},
 			"key_option_specs": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `
@@ -279,6 +324,22 @@ func pathRoles(b *backend) *framework.Path {
 				'{{public_key_hash}}' - A SHA256 checksum of the public key that is being signed.
 				`,
 			},
+			"ca_key_id": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				ID of the CA key used to sign certificates for this role. When not set, the
+				active CA key of the mount is used.
+				`,
+			},
+			"issued_key_type": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				Type of the key pairs generated by the issue endpoint; either 'rsa', 'ec' or
+				'ed25519'. It is 'rsa' by default.
+				`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -352,12 +413,14 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, d *fr
 
 		// Below are the only fields used from the role structure for OTP type.
 		roleEntry = sshRole{
-			DefaultUser:     defaultUser,
-			CIDRList:        cidrList,
-			ExcludeCIDRList: excludeCidrList,
-			KeyType:         KeyTypeOTP,
-			Port:            port,
-			AllowedUsers:    allowedUsers,
+			DefaultUser:          defaultUser,
+			CIDRList:             cidrList,
+			ExcludeCIDRList:      excludeCidrList,
+			KeyType:              KeyTypeOTP,
+			Port:                 port,
+			AllowedUsers:         allowedUsers,
+			AllowedUsersTemplate: d.Get("allowed_users_template").(bool),
+			DefaultUserTemplate:  d.Get("default_user_template").(bool),
 		}
 	} else if keyType == KeyTypeDynamic {
 		defaultUser := d.Get("default_user").(string)
@@ -418,11 +481,24 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, d *fr
 		if errorResponse != nil {
 			return errorResponse, nil
 		}
+		if role.CAKeyID != "" {
+			keyPair, err := fetchCAKeyPair(ctx, req.Storage, role.CAKeyID)
+			if err != nil {
+				return nil, err
+			}
+			if keyPair == nil {
+				return logical.ErrorResponse(fmt.Sprintf("CA key %q is not configured", role.CAKeyID)), nil
+			}
+		}
 		roleEntry = *role
 	} else {
 		return logical.ErrorResponse("invalid key type"), nil
 	}
 
+	if err := roleEntry.validateTemplates(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	entry, err := logical.StorageEntryJSON(fmt.Sprintf("roles/%s", roleName), roleEntry)
 	if err != nil {
 		return nil, err
@@ -449,9 +525,23 @@ func (b *backend) createCARole(allowedUsers, defaultUser string, data *framework
 		AllowSubdomains:        data.Get("allow_subdomains").(bool),
 		AllowUserKeyIDs:        data.Get("allow_user_key_ids").(bool),
 		KeyIDFormat:            data.Get("key_id_format").(string),
+		CAKeyID:                data.Get("ca_key_id").(string),
+		AllowedUsersTemplate:   data.Get("allowed_users_template").(bool),
+		DefaultUserTemplate:    data.Get("default_user_template").(bool),
+		AllowedDomainsTemplate: data.Get("allowed_domains_template").(bool),
+		IssuedKeyType:          data.Get("issued_key_type").(string),
 		KeyType:                KeyTypeCA,
 	}
 
+	if role.IssuedKeyType == "" {
+		role.IssuedKeyType = "rsa"
+	}
+	keyBits, err := issuedKeyBits(role.IssuedKeyType, data.Get("key_bits").(int))
+	if err != nil {
+		return nil, logical.ErrorResponse(err.Error())
+	}
+	role.KeyBits = keyBits
+
 	if !role.AllowUserCertificates && !role.AllowHostCertificates {
 		return nil, logical.ErrorResponse("Either 'allow_user_certificates' or 'allow_host_certificates' must be set to 'true'")
 	}
@@ -490,6 +580,129 @@ func (b *backend) getRole(ctx context.Context, s logical.Storage, n string) (*ss
 	return &result, nil
 }
 
+// validateTemplates checks that the templated fields of the role only use
+// supported identity templates
+func (r *sshRole) validateTemplates() error {
+	var fields []string
+	var values []string
+	if r.AllowedUsersTemplate {
+		fields = append(fields, "allowed_users")
+		values = append(values, r.AllowedUsers)
+	}
+	if r.DefaultUserTemplate {
+		fields = append(fields, "default_user")
+		values = append(values, r.DefaultUser)
+	}
+	if r.AllowedDomainsTemplate {
+		fields = append(fields, "allowed_domains")
+		values = append(values, r.AllowedDomains)
+	}
+
+	// Resolving against an empty entity only fails on malformed templates
+	for i, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			_, _, err := identitytpl.PopulateString(&logical.Entity{}, strings.TrimSpace(item))
+			if err != nil && err != identitytpl.ErrTemplateValueNotFound {
+				return fmt.Errorf("invalid template in %q: %v", fields[i], err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveRoleTemplates returns a copy of the role with its templated fields
+// resolved against the entity of the requesting token
+func (b *backend) resolveRoleTemplates(req *logical.Request, role *sshRole) (*sshRole, error) {
+	if !role.AllowedUsersTemplate && !role.DefaultUserTemplate && !role.AllowedDomainsTemplate {
+		return role, nil
+	}
+
+	entity, err := b.System().EntityInfo(req.EntityID)
+	if err != nil {
+		return nil, errwrap.Wrapf("error fetching entity of the request: {{err}}", err)
+	}
+
+	resolved := *role
+	if role.AllowedUsersTemplate {
+		resolved.resolvedAllowedUsers, err = resolveTemplatedList(entity, role.AllowedUsers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if role.DefaultUserTemplate {
+		resolved.DefaultUser, err = resolveTemplate(entity, role.DefaultUser)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if role.AllowedDomainsTemplate {
+		resolved.resolvedAllowedDomains, err = resolveTemplatedList(entity, role.AllowedDomains)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &resolved, nil
+}
+
+// resolveTemplate resolves the identity templates in the given value,
+// returning an empty string if the entity lacks any of the templated values.
+// Identity values often come from the users themselves, e.g. through the
+// claims of a JWT, so values that would be read as a list or as the wildcard
+// are rejected.
+func resolveTemplate(entity *logical.Entity, value string) (string, error) {
+	subst, resolved, err := identitytpl.PopulateString(entity, value)
+	switch err {
+	case nil:
+	case identitytpl.ErrTemplateValueNotFound, identitytpl.ErrNoEntityAttachedToToken:
+		return "", nil
+	default:
+		return "", err
+	}
+
+	if subst && (resolved == "*" || strings.Contains(resolved, ",")) {
+		return "", errutil.UserError{Err: fmt.Sprintf("template %q resolved to the invalid value %q", value, resolved)}
+	}
+
+	return resolved, nil
+}
+
+// resolveTemplatedList resolves the identity templates in each item of the
+// given comma-separated list, leaving out the items that cannot be resolved
+func resolveTemplatedList(entity *logical.Entity, value string) ([]string, error) {
+	var items []string
+	for _, item := range strutil.ParseStringSlice(value, ",") {
+		resolved, err := resolveTemplate(entity, item)
+		if err != nil {
+			return nil, err
+		}
+		if resolved != "" {
+			items = append(items, resolved)
+		}
+	}
+
+	return strutil.RemoveDuplicates(items, false), nil
+}
+
+// allowedUsersList returns the items of allowed_users, resolved against the
+// entity of the request if templated
+func (r *sshRole) allowedUsersList() []string {
+	if r.AllowedUsersTemplate {
+		return r.resolvedAllowedUsers
+	}
+	return strutil.RemoveDuplicates(strutil.ParseStringSlice(r.AllowedUsers, ","), false)
+}
+
+// allowedDomainsList returns the items of allowed_domains, resolved against
+// the entity of the request if templated
+func (r *sshRole) allowedDomainsList() []string {
+	if r.AllowedDomainsTemplate {
+		return r.resolvedAllowedDomains
+	}
+	return strutil.RemoveDuplicates(strutil.ParseStringSlice(r.AllowedDomains, ","), false)
+}
+
 // parseRole converts a sshRole object into its map[string]interface representation,
 // with appropriate values for each KeyType. If the KeyType is invalid, it will return
 // an error.
@@ -499,12 +712,14 @@ func (b *backend) parseRole(role *sshRole) (map[string]interface{}, error) {
 	switch role.KeyType {
 	case KeyTypeOTP:
 		result = map[string]interface{}{
-			"default_user":      role.DefaultUser,
-			"cidr_list":         role.CIDRList,
-			"exclude_cidr_list": role.ExcludeCIDRList,
-			"key_type":          role.KeyType,
-			"port":              role.Port,
-			"allowed_users":     role.AllowedUsers,
+			"default_user":           role.DefaultUser,
+			"cidr_list":              role.CIDRList,
+			"exclude_cidr_list":      role.ExcludeCIDRList,
+			"key_type":               role.KeyType,
+			"port":                   role.Port,
+			"allowed_users":          role.AllowedUsers,
+			"allowed_users_template": role.AllowedUsersTemplate,
+			"default_user_template":  role.DefaultUserTemplate,
 		}
 	case KeyTypeCA:
 		ttl, err := parseutil.ParseDurationSecond(role.TTL)
@@ -530,8 +745,13 @@ func (b *backend) parseRole(role *sshRole) (map[string]interface{}, error) {
 			"allow_subdomains":         role.AllowSubdomains,
 			"allow_user_key_ids":       role.AllowUserKeyIDs,
 			"key_id_format":            role.KeyIDFormat,
+			"ca_key_id":                role.CAKeyID,
+			"allowed_users_template":   role.AllowedUsersTemplate,
+			"default_user_template":    role.DefaultUserTemplate,
+			"allowed_domains_template": role.AllowedDomainsTemplate,
 			"key_type":                 role.KeyType,
 			"key_bits":                 role.KeyBits,
+			"issued_key_type":          role.IssuedKeyType,
 			"default_critical_options": role.DefaultCriticalOptions,
 			"default_extensions":       role.DefaultExtensions,
 		}
@@ -0,0 +1,174 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_RoleTemplates(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	sysView := config.System.(*logical.StaticSystemView)
+	sysView.EntityVal = &logical.Entity{
+		ID:   "entityID",
+		Name: "jdoe",
+		Metadata: map[string]string{
+			"team": "ops",
+		},
+		Aliases: []*logical.Alias{
+			{
+				MountAccessor: "auth_userpass_1234",
+				Name:          "john",
+			},
+		},
+	}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	doReq := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+			EntityID:  "entityID",
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	// Malformed templates are rejected
+	resp := doReq("roles/invalid", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "{{identity.entity.name",
+		"allowed_users_template":  true,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for malformed template, got: %#v", resp)
+	}
+
+	doReq("config/ca", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	})
+	doReq("roles/ca", map[string]interface{}{
+		"key_type":                 "ca",
+		"allow_user_certificates":  true,
+		"allow_host_certificates":  true,
+		"allowed_users":            "{{identity.entity.aliases.auth_userpass_1234.name}},{{identity.entity.metadata.team}}-admin,{{identity.entity.metadata.missing}}",
+		"allowed_users_template":   true,
+		"default_user":             "{{identity.entity.aliases.auth_userpass_1234.name}}",
+		"default_user_template":    true,
+		"allowed_domains":          "{{identity.entity.name}}.example.com",
+		"allowed_domains_template": true,
+		"allow_bare_domains":       true,
+	})
+
+	sign := func(data map[string]interface{}) (*logical.Response, *ssh.Certificate) {
+		t.Helper()
+		data["public_key"] = publicKey2
+		resp := doReq("sign/ca", data)
+		if resp == nil || resp.IsError() {
+			return resp, nil
+		}
+		parsed, err := parsePublicSSHKey(resp.Data["signed_key"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, parsed.(*ssh.Certificate)
+	}
+
+	// The default user is resolved from the alias
+	if _, cert := sign(map[string]interface{}{}); cert == nil || len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "john" {
+		t.Fatalf("bad certificate: %#v", cert)
+	}
+	if _, cert := sign(map[string]interface{}{"valid_principals": "john,ops-admin"}); cert == nil || len(cert.ValidPrincipals) != 2 {
+		t.Fatalf("bad certificate: %#v", cert)
+	}
+	if resp, _ := sign(map[string]interface{}{"valid_principals": "root"}); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for principal outside of the templated list, got: %#v", resp)
+	}
+	if _, cert := sign(map[string]interface{}{"cert_type": "host", "valid_principals": "jdoe.example.com"}); cert == nil {
+		t.Fatal("expected host certificate for the templated domain")
+	}
+	if resp, _ := sign(map[string]interface{}{"cert_type": "host", "valid_principals": "other.example.com"}); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for domain outside of the templated list, got: %#v", resp)
+	}
+
+	// OTP roles resolve their users the same way
+	doReq("roles/otp", map[string]interface{}{
+		"key_type":               "otp",
+		"default_user":           "{{identity.entity.aliases.auth_userpass_1234.name}}",
+		"default_user_template":  true,
+		"allowed_users":          "{{identity.entity.metadata.team}}",
+		"allowed_users_template": true,
+		"cidr_list":              "10.0.0.0/8",
+	})
+	resp = doReq("creds/otp", map[string]interface{}{
+		"ip": "10.0.0.1",
+	})
+	if resp == nil || resp.IsError() || resp.Data["username"] != "john" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = doReq("creds/otp", map[string]interface{}{
+		"ip":       "10.0.0.1",
+		"username": "ops",
+	})
+	if resp == nil || resp.IsError() || resp.Data["username"] != "ops" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = doReq("creds/otp", map[string]interface{}{
+		"ip":       "10.0.0.1",
+		"username": "root",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for username outside of the templated list, got: %#v", resp)
+	}
+
+	// Identity values that would expand to several principals or to any of
+	// them are rejected
+	for _, team := range []string{"root,admin", "*"} {
+		sysView.EntityVal.Metadata["team"] = team
+		if resp, _ := sign(map[string]interface{}{"valid_principals": "root"}); resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error for principal outside of the templated list, got: %#v", team, resp)
+		}
+		sysView.EntityVal.Aliases[0].Name = team
+		if resp, _ := sign(map[string]interface{}{}); resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error for templated default user, got: %#v", team, resp)
+		}
+		sysView.EntityVal.Aliases[0].Name = "john"
+
+		resp = doReq("creds/otp", map[string]interface{}{
+			"ip":       "10.0.0.1",
+			"username": "root",
+		})
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error for username outside of the templated list, got: %#v", team, resp)
+		}
+	}
+
+	// A literal wildcard still allows any principal
+	doReq("roles/any", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"allowed_users_template":  true,
+	})
+	resp = doReq("sign/any", map[string]interface{}{
+		"public_key":       publicKey2,
+		"valid_principals": "root,admin",
+	})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
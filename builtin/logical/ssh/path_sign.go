@@ -10,8 +10,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
@@ -39,51 +39,57 @@ func pathSign(b *backend) *framework.Path {
 			logical.UpdateOperation: b.pathSign,
 		},
 
-		Fields: map[string]*framework.FieldSchema{
+		Fields: addSignCommonFields(map[string]*framework.FieldSchema{
 			"role": &framework.FieldSchema{
 				Type:        framework.TypeString,
 				Description: `The desired role with configuration for this request.`,
 			},
-			"ttl": &framework.FieldSchema{
-				Type: framework.TypeDurationSecond,
-				Description: `The requested Time To Live for the SSH certificate;
-sets the expiration date. If not specified
-the role default, backend default, or system
-default TTL is used, in that order. Cannot
-be later than the role max TTL.`,
-			},
 			"public_key": &framework.FieldSchema{
 				Type:        framework.TypeString,
 				Description: `SSH public key that should be signed.`,
 			},
-			"valid_principals": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Valid principals, either usernames or hostnames, that the certificate should be signed for.`,
-			},
-			"cert_type": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Type of certificate to be created; either "user" or "host".`,
-				Default:     "user",
-			},
-			"key_id": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Key id that the created certificate should have. If not specified, the display name of the token will be used.`,
-			},
-			"critical_options": &framework.FieldSchema{
-				Type:        framework.TypeMap,
-				Description: `Critical options that the certificate should be signed for.`,
-			},
-			"extensions": &framework.FieldSchema{
-				Type:        framework.TypeMap,
-				Description: `Extensions that the certificate should be signed for.`,
-			},
-		},
+		}),
 
 		HelpSynopsis:    `Request signing an SSH key using a certain role with the provided details.`,
 		HelpDescription: `This path allows SSH keys to be signed according to the policy of the given role.`,
 	}
 }
 
+// addSignCommonFields adds the fields common to the sign and issue paths
+func addSignCommonFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["ttl"] = &framework.FieldSchema{
+		Type: framework.TypeDurationSecond,
+		Description: `The requested Time To Live for the SSH certificate;
+sets the expiration date. If not specified
+the role default, backend default, or system
+default TTL is used, in that order. Cannot
+be later than the role max TTL.`,
+	}
+	fields["valid_principals"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Valid principals, either usernames or hostnames, that the certificate should be signed for.`,
+	}
+	fields["cert_type"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Type of certificate to be created; either "user" or "host".`,
+		Default:     "user",
+	}
+	fields["key_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Key id that the created certificate should have. If not specified, the display name of the token will be used.`,
+	}
+	fields["critical_options"] = &framework.FieldSchema{
+		Type:        framework.TypeMap,
+		Description: `Critical options that the certificate should be signed for.`,
+	}
+	fields["extensions"] = &framework.FieldSchema{
+		Type:        framework.TypeMap,
+		Description: `Extensions that the certificate should be signed for.`,
+	}
+
+	return fields
+}
+
 func (b *backend) pathSign(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	roleName := data.Get("role").(string)
 
@@ -96,6 +102,15 @@ func (b *backend) pathSign(ctx context.Context, req *logical.Request, data *fram
 		return logical.ErrorResponse(fmt.Sprintf("Unknown role: %s", roleName)), nil
 	}
 
+	role, err = b.resolveRoleTemplates(req, role)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+
 	return b.pathSignCertificate(ctx, req, data, role)
 }
 
@@ -110,6 +125,12 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse(fmt.Sprintf("failed to parse public_key as SSH key: %s", err)), nil
 	}
 
+	return b.signPublicKey(ctx, req, data, role, userPublicKey)
+}
+
+// signPublicKey signs the given public key according to the role and the
+// request parameters
+func (b *backend) signPublicKey(ctx context.Context, req *logical.Request, data *framework.FieldData, role *sshRole, userPublicKey ssh.PublicKey) (*logical.Response, error) {
 	// Note that these various functions always return "user errors" so we pass
 	// them as 4xx values
 	keyId, err := b.calculateKeyId(data, req, role, userPublicKey)
@@ -124,12 +145,12 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 
 	var parsedPrincipals []string
 	if certificateType == ssh.HostCert {
-		parsedPrincipals, err = b.calculateValidPrincipals(data, "", role.AllowedDomains, validateValidPrincipalForHosts(role))
+		parsedPrincipals, err = b.calculateValidPrincipals(data, "", role.AllowedDomains == "*", role.allowedDomainsList(), validateValidPrincipalForHosts(role))
 		if err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
 	} else {
-		parsedPrincipals, err = b.calculateValidPrincipals(data, role.DefaultUser, role.AllowedUsers, strutil.StrListContains)
+		parsedPrincipals, err = b.calculateValidPrincipals(data, role.DefaultUser, role.AllowedUsers == "*", role.allowedUsersList(), strutil.StrListContains)
 		if err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
@@ -150,17 +171,9 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
-	if err != nil {
-		return nil, errwrap.Wrapf("failed to read CA private key: {{err}}", err)
-	}
-	if privateKeyEntry == nil || privateKeyEntry.Key == "" {
-		return nil, fmt.Errorf("failed to read CA private key")
-	}
-
-	signer, err := ssh.ParsePrivateKey([]byte(privateKeyEntry.Key))
+	signer, err := caSigner(ctx, req.Storage, role.CAKeyID)
 	if err != nil {
-		return nil, errwrap.Wrapf("failed to parse stored CA private key: {{err}}", err)
+		return nil, err
 	}
 
 	cBundle := creationBundle{
@@ -180,6 +193,10 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 		return nil, err
 	}
 
+	if err := storeSignedCert(ctx, req.Storage, certificate); err != nil {
+		return nil, err
+	}
+
 	signedSSHCertificate := ssh.MarshalAuthorizedKey(certificate)
 	if len(signedSSHCertificate) == 0 {
 		return nil, fmt.Errorf("error marshaling signed certificate")
@@ -195,7 +212,10 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 	return response, nil
 }
 
-func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPrincipal, principalsAllowedByRole string, validatePrincipal func([]string, string) bool) ([]string, error) {
+// calculateValidPrincipals returns the principals of the request, or the
+// default one, after checking them against the principals allowed by the
+// role. allowAny is only set when the role is literally configured with "*".
+func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPrincipal string, allowAny bool, allowedPrincipals []string, validatePrincipal func([]string, string) bool) ([]string, error) {
 	validPrincipals := ""
 	validPrincipalsRaw, ok := data.GetOk("valid_principals")
 	if ok {
@@ -205,7 +225,6 @@ func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPri
 	}
 
 	parsedPrincipals := strutil.RemoveDuplicates(strutil.ParseStringSlice(validPrincipals, ","), false)
-	allowedPrincipals := strutil.RemoveDuplicates(strutil.ParseStringSlice(principalsAllowedByRole, ","), false)
 	switch {
 	case len(parsedPrincipals) == 0:
 		// There is nothing to process
@@ -216,7 +235,7 @@ func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPri
 		return nil, fmt.Errorf("role is not configured to allow any principles")
 	default:
 		// Role was explicitly configured to allow any principal.
-		if principalsAllowedByRole == "*" {
+		if allowAny {
 			return parsedPrincipals, nil
 		}
 
@@ -0,0 +1,110 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy",
+		Fields: map[string]*framework.FieldSchema{
+			"tidy_cert_store": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to enable tidying up
+the certificate store`,
+				Default: false,
+			},
+
+			"tidy_revocation_list": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to enable tidying up
+the revocation list`,
+				Default: false,
+			},
+
+			"safety_buffer": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of extra time that must have passed
+beyond certificate expiration before it is removed
+from the backend storage and/or revocation list.
+Defaults to 72 hours.`,
+				Default: 259200, //72h, but TypeDurationSecond currently requires defaults to be int
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTidyWrite,
+		},
+
+		HelpSynopsis:    pathTidyHelpSyn,
+		HelpDescription: pathTidyHelpDesc,
+	}
+}
+
+func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	safetyBuffer := d.Get("safety_buffer").(int)
+	tidyCertStore := d.Get("tidy_cert_store").(bool)
+	tidyRevocationList := d.Get("tidy_revocation_list").(bool)
+
+	if safetyBuffer < 1 {
+		return logical.ErrorResponse("safety_buffer must be greater than zero"), nil
+	}
+
+	bufferDuration := time.Duration(safetyBuffer) * time.Second
+
+	var prefixes []string
+	if tidyCertStore {
+		prefixes = append(prefixes, "certs/")
+	}
+	if tidyRevocationList {
+		prefixes = append(prefixes, "revoked/")
+	}
+
+	for _, prefix := range prefixes {
+		serials, err := req.Storage.List(ctx, prefix)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error fetching list of %s entries: {{err}}", prefix), err)
+		}
+
+		for _, serial := range serials {
+			cert, err := fetchSignedCert(ctx, req.Storage, prefix, serial)
+			if err != nil {
+				return nil, err
+			}
+
+			if cert == nil || time.Now().After(cert.ValidBefore.Add(bufferDuration)) {
+				if err := req.Storage.Delete(ctx, prefix+serial); err != nil {
+					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting entry %s%s: {{err}}", prefix, serial), err)
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+const pathTidyHelpSyn = `
+Tidy up the backend by removing expired certificates and revocation entries.
+`
+
+const pathTidyHelpDesc = `
+This endpoint allows expired certificates and entries of the revocation
+list to be removed from the backend, freeing up storage and shortening
+the key revocation list.
+
+For safety, this function is a noop if called without parameters; cleanup
+from normal certificate storage must be enabled with 'tidy_cert_store' and
+cleanup from revocation list entries must be enabled with
+'tidy_revocation_list'.
+
+The 'safety_buffer' parameter is useful to ensure that clock skew amongst
+your hosts cannot lead to a certificate being removed from the revocation
+list while it is still considered valid by other hosts. Defaults to 72
+hours. The value is specified in seconds.
+`
@@ -3,11 +3,11 @@ package totp
 import (
 	"context"
 	"strings"
-	"time"
+	"sync"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
-	cache "github.com/patrickmn/go-cache"
 )
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -33,13 +33,16 @@ func Backend() *backend {
 			pathListKeys(&b),
 			pathKeys(&b),
 			pathCode(&b),
+			pathRecoveryCodes(&b),
+			pathValidateRecoveryCode(&b),
+			pathResync(&b),
 		},
 
 		Secrets:     []*framework.Secret{},
 		BackendType: logical.TypeLogical,
 	}
 
-	b.usedCodes = cache.New(0, 30*time.Second)
+	b.validationLocks = locksutil.CreateLocks()
 
 	return &b
 }
@@ -47,7 +50,11 @@ func Backend() *backend {
 type backend struct {
 	*framework.Backend
 
-	usedCodes *cache.Cache
+	// validationLocks protect the validation state of the keys
+	validationLocks []*locksutil.LockEntry
+
+	recoveryCodesLock sync.Mutex
+	counterLock       sync.Mutex
 }
 
 const backendHelp = `
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	otplib "github.com/pquerna/otp"
@@ -48,6 +49,10 @@ func (b *backend) pathReadCode(ctx context.Context, req *logical.Request, data *
 		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
 	}
 
+	if key.keyType() == keyTypeHOTP {
+		return b.readHOTPCode(ctx, req.Storage, name)
+	}
+
 	// Generate password using totp library
 	totpToken, err := totplib.GenerateCodeCustom(key.Key, time.Now(), totplib.ValidateOpts{
 		Period:    key.Period,
@@ -84,10 +89,23 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
 	}
 
-	usedName := fmt.Sprintf("%s_%s", name, code)
+	lock := locksutil.LockForKey(b.validationLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := b.validationState(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if state.attemptsExceeded(key) {
+		return logical.ErrorResponse("maximum validation attempts exceeded; wait before trying again"), nil
+	}
+
+	if key.keyType() == keyTypeHOTP {
+		return b.validateHOTPCode(ctx, req.Storage, name, code, state)
+	}
 
-	_, ok := b.usedCodes.Get(usedName)
-	if ok {
+	if _, ok := state.UsedCodes[code]; ok {
 		return logical.ErrorResponse("code already used; wait until the next time period"), nil
 	}
 
@@ -101,14 +119,13 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse("an error occured while validating the code"), err
 	}
 
-	// Take the key skew, add two for behind and in front, and multiple that by
-	// the period to cover the full possibility of the validity of the key
-	err = b.usedCodes.Add(usedName, nil, time.Duration(
-		int64(time.Second)*
-			int64(key.Period)*
-			int64((2+key.Skew))))
-	if err != nil {
-		return nil, errwrap.Wrapf("error adding code to used cache: {{err}}", err)
+	if valid {
+		// Only valid codes need to be tracked to reject replays; keep them
+		// for as long as they could be accepted
+		state.UsedCodes[code] = time.Now().Add(key.validityWindow())
+	}
+	if err := b.recordValidation(ctx, req.Storage, name, key, state, valid); err != nil {
+		return nil, err
 	}
 
 	return &logical.Response{
@@ -118,10 +135,90 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 	}, nil
 }
 
+// validationState is the state of the validations of a key: the failed
+// validations and the TOTP codes already used. It is kept in storage so that
+// the limits hold across restarts and leadership changes.
+type validationState struct {
+	Failures           int                  `json:"failures"`
+	FailuresExpireTime time.Time            `json:"failures_expire_time"`
+	UsedCodes          map[string]time.Time `json:"used_codes"`
+}
+
+// attemptsExceeded returns whether the key has reached its maximum number of
+// failed validations
+func (v *validationState) attemptsExceeded(key *keyEntry) bool {
+	return v.Failures >= key.maxValidationAttempts()
+}
+
+// validationState returns the validation state of the key without the
+// failures and used codes that have expired. The validation lock of the key
+// must be held.
+func (b *backend) validationState(ctx context.Context, s logical.Storage, name string) (*validationState, error) {
+	state := &validationState{}
+
+	entry, err := s.Get(ctx, "validation/"+name)
+	if err != nil {
+		return nil, errwrap.Wrapf("error reading validation state: {{err}}", err)
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(state); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if now.After(state.FailuresExpireTime) {
+		state.Failures = 0
+	}
+	if state.UsedCodes == nil {
+		state.UsedCodes = map[string]time.Time{}
+	}
+	for code, expireTime := range state.UsedCodes {
+		if now.After(expireTime) {
+			delete(state.UsedCodes, code)
+		}
+	}
+
+	return state, nil
+}
+
+// recordValidation records the outcome of a validation in the validation
+// state of the key. Failures expire once the codes that could have been
+// guessed are no longer valid, and a success clears them. The validation
+// lock of the key must be held.
+func (b *backend) recordValidation(ctx context.Context, s logical.Storage, name string, key *keyEntry, state *validationState, valid bool) error {
+	if valid {
+		state.Failures = 0
+	} else {
+		if state.Failures == 0 {
+			state.FailuresExpireTime = time.Now().Add(key.validityWindow())
+		}
+		state.Failures++
+	}
+
+	if state.Failures == 0 && len(state.UsedCodes) == 0 {
+		if err := s.Delete(ctx, "validation/"+name); err != nil {
+			return errwrap.Wrapf("error deleting validation state: {{err}}", err)
+		}
+		return nil
+	}
+
+	entry, err := logical.StorageEntryJSON("validation/"+name, state)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("error storing validation state: {{err}}", err)
+	}
+
+	return nil
+}
+
 const pathCodeHelpSyn = `
 Request time-based one-time use password or validate a password for a certain key .
 `
 const pathCodeHelpDesc = `
 This path generates and validates time-based one-time use passwords for a certain key. 
-
+For hotp keys, the passwords are counter-based instead: generating a password advances
+the counter, and a valid password moves the counter past it.
 `
@@ -0,0 +1,227 @@
+package totp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
+)
+
+// hotpResyncWindow is the number of counter values searched when
+// resynchronizing a HOTP key
+const hotpResyncWindow = 1000
+
+func pathResync(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "resync/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key.",
+			},
+			"code": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "A HOTP code generated by the token.",
+			},
+			"next_code": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The HOTP code generated by the token directly after code.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathResync,
+		},
+
+		HelpSynopsis:    pathResyncHelpSyn,
+		HelpDescription: pathResyncHelpDesc,
+	}
+}
+
+// storeCounter persists the counter of a HOTP key
+func (b *backend) storeCounter(ctx context.Context, s logical.Storage, name string, key *keyEntry, counter uint64) error {
+	key.Counter = counter
+	entry, err := logical.StorageEntryJSON("key/"+name, key)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// findCounter returns the counter, within window values of start, that the
+// given code was generated for
+func findCounter(key *keyEntry, code string, start uint64, window uint64) (uint64, bool, error) {
+	for counter := start; counter <= start+window; counter++ {
+		valid, err := hotplib.ValidateCustom(code, counter, key.Key, hotplib.ValidateOpts{
+			Digits:    key.Digits,
+			Algorithm: key.Algorithm,
+		})
+		if err != nil && err != otplib.ErrValidateInputInvalidLength {
+			return 0, false, err
+		}
+		if valid {
+			return counter, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// readHOTPCode generates the code for the current counter of a HOTP key and
+// advances the counter
+func (b *backend) readHOTPCode(ctx context.Context, s logical.Storage, name string) (*logical.Response, error) {
+	b.counterLock.Lock()
+	defer b.counterLock.Unlock()
+
+	// Reload the key now that the counter cannot change
+	key, err := b.Key(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
+	}
+
+	hotpToken, err := hotplib.GenerateCodeCustom(key.Key, key.Counter, hotplib.ValidateOpts{
+		Digits:    key.Digits,
+		Algorithm: key.Algorithm,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.storeCounter(ctx, s, name, key, key.Counter+1); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"code": hotpToken,
+		},
+	}, nil
+}
+
+// validateHOTPCode validates a code against the look-ahead window of a HOTP
+// key. A valid code moves the counter past it, so that neither it nor any
+// earlier code can be used again. The validation lock of the key must be
+// held.
+func (b *backend) validateHOTPCode(ctx context.Context, s logical.Storage, name, code string, state *validationState) (*logical.Response, error) {
+	b.counterLock.Lock()
+	defer b.counterLock.Unlock()
+
+	key, err := b.Key(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
+	}
+
+	counter, valid, err := findCounter(key, code, key.Counter, uint64(key.LookAheadWindow))
+	if err != nil {
+		return logical.ErrorResponse("an error occured while validating the code"), err
+	}
+
+	if valid {
+		if err := b.storeCounter(ctx, s, name, key, counter+1); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.recordValidation(ctx, s, name, key, state, valid); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"valid": valid,
+		},
+	}, nil
+}
+
+func (b *backend) pathResync(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	code := data.Get("code").(string)
+	nextCode := data.Get("next_code").(string)
+
+	if code == "" || nextCode == "" {
+		return logical.ErrorResponse("the code and next_code values are required"), nil
+	}
+
+	lock := locksutil.LockForKey(b.validationLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	b.counterLock.Lock()
+	defer b.counterLock.Unlock()
+
+	key, err := b.Key(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
+	}
+	if key.keyType() != keyTypeHOTP {
+		return logical.ErrorResponse("only hotp keys can be resynchronized"), nil
+	}
+
+	state, err := b.validationState(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if state.attemptsExceeded(key) {
+		return logical.ErrorResponse("maximum validation attempts exceeded; wait before trying again"), nil
+	}
+
+	// Search the resync window for the first code, then require the second
+	// code to immediately follow it, as described in RFC 4226 section E.4
+	end := key.Counter + hotpResyncWindow
+	for start := key.Counter; start <= end; {
+		counter, found, err := findCounter(key, code, start, end-start)
+		if err != nil {
+			return logical.ErrorResponse("an error occured while validating the code"), err
+		}
+		if !found {
+			break
+		}
+
+		next, found, err := findCounter(key, nextCode, counter+1, 0)
+		if err != nil {
+			return logical.ErrorResponse("an error occured while validating the code"), err
+		}
+		if found {
+			if err := b.storeCounter(ctx, req.Storage, name, key, next+1); err != nil {
+				return nil, err
+			}
+			if err := b.recordValidation(ctx, req.Storage, name, key, state, true); err != nil {
+				return nil, err
+			}
+
+			return &logical.Response{
+				Data: map[string]interface{}{
+					"counter": key.Counter,
+				},
+			}, nil
+		}
+		start = counter + 1
+	}
+
+	if err := b.recordValidation(ctx, req.Storage, name, key, state, false); err != nil {
+		return nil, err
+	}
+	return logical.ErrorResponse("the codes could not be matched to consecutive counter values"), nil
+}
+
+const pathResyncHelpSyn = `
+Resynchronize the counter of a HOTP key.
+`
+
+const pathResyncHelpDesc = `
+This path resynchronizes the counter of a HOTP key whose token has advanced
+past the look-ahead window, for example when codes were generated but never
+validated. Two consecutive codes generated by the token are required; the
+counter is moved past the second one.
+`
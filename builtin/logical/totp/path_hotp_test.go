@@ -0,0 +1,121 @@
+package totp
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
+)
+
+func TestBackend_hotpKeys(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	// Generated keys are returned as otpauth://hotp urls with their counter
+	resp := doReq(logical.UpdateOperation, "keys/generated", map[string]interface{}{
+		"type":         "hotp",
+		"generate":     true,
+		"issuer":       "Vault",
+		"account_name": "Test",
+		"counter":      5,
+	})
+	if resp == nil || resp.IsError() || resp.Data["barcode"] == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	keyURL, err := url.Parse(resp.Data["url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyURL.Host != "hotp" || keyURL.Query().Get("counter") != "5" {
+		t.Fatalf("bad url: %s", keyURL)
+	}
+
+	// Reading a code advances the counter
+	resp = doReq(logical.ReadOperation, "code/generated", nil)
+	expected, _ := hotplib.GenerateCode(keyURL.Query().Get("secret"), 5)
+	if resp.Data["code"] != expected {
+		t.Fatalf("expected code %s for counter 5, got: %#v", expected, resp.Data)
+	}
+	resp = doReq(logical.ReadOperation, "keys/generated", nil)
+	if resp.Data["type"] != "hotp" || resp.Data["counter"] != uint64(6) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Keys created from a url keep its counter
+	key, _ := createKey()
+	doReq(logical.UpdateOperation, "keys/token", map[string]interface{}{
+		"url":               "otpauth://hotp/Vault:test@email.com?secret=" + key + "&counter=10",
+		"look_ahead_window": 3,
+	})
+	code := func(counter uint64) string {
+		code, err := hotplib.GenerateCodeCustom(key, counter, hotplib.ValidateOpts{
+			Digits:    otplib.DigitsSix,
+			Algorithm: otplib.AlgorithmSHA1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	validate := func(code string) interface{} {
+		t.Helper()
+		resp := doReq(logical.UpdateOperation, "code/token", map[string]interface{}{"code": code})
+		if resp == nil || resp.IsError() {
+			t.Fatalf("bad: %#v", resp)
+		}
+		return resp.Data["valid"]
+	}
+
+	// Codes within the look-ahead window are accepted once, and move the
+	// counter past them
+	if validate(code(12)) != true {
+		t.Fatal("expected code within the look-ahead window to be valid")
+	}
+	if validate(code(12)) != false || validate(code(11)) != false {
+		t.Fatal("expected used and skipped codes to be rejected")
+	}
+	if validate(code(20)) != false {
+		t.Fatal("expected code past the look-ahead window to be rejected")
+	}
+
+	// Resynchronization moves the counter past two consecutive codes
+	resp = doReq(logical.UpdateOperation, "resync/token", map[string]interface{}{
+		"code":      code(50),
+		"next_code": code(52),
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected non-consecutive codes to be rejected, got: %#v", resp)
+	}
+	resp = doReq(logical.UpdateOperation, "resync/token", map[string]interface{}{
+		"code":      code(50),
+		"next_code": code(51),
+	})
+	if resp == nil || resp.IsError() || resp.Data["counter"] != uint64(52) {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if validate(code(53)) != true {
+		t.Fatal("expected code after resynchronization to be valid")
+	}
+}
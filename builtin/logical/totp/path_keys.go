@@ -10,14 +10,34 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
+const (
+	keyTypeTOTP = "totp"
+	keyTypeHOTP = "hotp"
+
+	// defaultMaxValidationAttempts is the number of failed validations
+	// allowed for a key unless configured otherwise
+	defaultMaxValidationAttempts = 5
+
+	// maxLookAheadWindow bounds the number of counter values checked when
+	// validating a HOTP code
+	maxLookAheadWindow = 100
+
+	// hotpFailureWindow is how long failed validations of a HOTP key, which
+	// has no period, are counted for
+	hotpFailureWindow = 5 * time.Minute
+)
+
 func pathListKeys(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "keys/?$",
@@ -40,6 +60,24 @@ func pathKeys(b *backend) *framework.Path {
 				Description: "Name of the key.",
 			},
 
+			"type": {
+				Type:        framework.TypeString,
+				Default:     keyTypeTOTP,
+				Description: `The type of one-time password: "totp" for time-based or "hotp" for counter-based (RFC 4226) keys.`,
+			},
+
+			"counter": {
+				Type:        framework.TypeInt,
+				Default:     0,
+				Description: `The initial counter value of a HOTP key. Only used if type is hotp.`,
+			},
+
+			"look_ahead_window": {
+				Type:        framework.TypeInt,
+				Default:     10,
+				Description: `The number of counter values past the current one that are accepted when validating a HOTP code. Only used if type is hotp.`,
+			},
+
 			"generate": {
 				Type:        framework.TypeBool,
 				Default:     false,
@@ -105,7 +143,13 @@ func pathKeys(b *backend) *framework.Path {
 
 			"url": {
 				Type:        framework.TypeString,
-				Description: `A TOTP url string containing all of the parameters for key setup. Only used if generate is false.`,
+				Description: `An otpauth url string, of type totp or hotp, containing all of the parameters for key setup. Only used if generate is false.`,
+			},
+
+			"max_validation_attempts": {
+				Type:        framework.TypeInt,
+				Default:     defaultMaxValidationAttempts,
+				Description: `The number of failed validations, of codes and recovery codes, allowed for the key before further validations are rejected until the failures expire.`,
 			},
 		},
 
@@ -138,7 +182,23 @@ func (b *backend) Key(ctx context.Context, s logical.Storage, n string) (*keyEnt
 }
 
 func (b *backend) pathKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "key/"+data.Get("name").(string))
+	name := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.validationLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	err := req.Storage.Delete(ctx, "key/"+name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recovery codes are only valid for the key they were generated for
+	err = req.Storage.Delete(ctx, "recovery_codes/"+name)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Delete(ctx, "validation/"+name)
 	if err != nil {
 		return nil, err
 	}
@@ -159,15 +219,24 @@ func (b *backend) pathKeyRead(ctx context.Context, req *logical.Request, data *f
 	algorithm := key.Algorithm.String()
 
 	// Return values of key
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"issuer":       key.Issuer,
-			"account_name": key.AccountName,
-			"period":       key.Period,
-			"algorithm":    algorithm,
-			"digits":       key.Digits,
+			"type":                    key.keyType(),
+			"issuer":                  key.Issuer,
+			"account_name":            key.AccountName,
+			"algorithm":               algorithm,
+			"digits":                  key.Digits,
+			"max_validation_attempts": key.maxValidationAttempts(),
 		},
-	}, nil
+	}
+	if key.keyType() == keyTypeHOTP {
+		resp.Data["counter"] = key.Counter
+		resp.Data["look_ahead_window"] = key.LookAheadWindow
+	} else {
+		resp.Data["period"] = key.Period
+	}
+
+	return resp, nil
 }
 
 func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -181,6 +250,9 @@ func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, d *fram
 
 func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
+	keyType := data.Get("type").(string)
+	counter := data.Get("counter").(int)
+	lookAheadWindow := data.Get("look_ahead_window").(int)
 	generate := data.Get("generate").(bool)
 	exported := data.Get("exported").(bool)
 	keyString := data.Get("key").(string)
@@ -193,6 +265,7 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 	qrSize := data.Get("qr_size").(int)
 	keySize := data.Get("key_size").(int)
 	inputURL := data.Get("url").(string)
+	maxValidationAttempts := data.Get("max_validation_attempts").(int)
 
 	if generate {
 		if keyString != "" {
@@ -211,6 +284,14 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 			return logical.ErrorResponse("an error occured while parsing url string"), err
 		}
 
+		//Read type
+		switch urlObject.Host {
+		case keyTypeTOTP, keyTypeHOTP:
+			keyType = urlObject.Host
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("unsupported url type %q", urlObject.Host)), nil
+		}
+
 		//Set up query object
 		urlQuery := urlObject.Query()
 		path := strings.TrimPrefix(urlObject.Path, "/")
@@ -261,6 +342,22 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		if algorithmQuery != "" {
 			algorithm = algorithmQuery
 		}
+
+		//Read counter
+		counterQuery := urlQuery.Get("counter")
+		if counterQuery != "" {
+			counterInt, err := strconv.Atoi(counterQuery)
+			if err != nil {
+				return logical.ErrorResponse("an error occured while parsing counter value in url"), err
+			}
+			counter = counterInt
+		}
+	}
+
+	switch keyType {
+	case keyTypeTOTP, keyTypeHOTP:
+	default:
+		return logical.ErrorResponse(`the type value must be "totp" or "hotp"`), nil
 	}
 
 	// Translate digits and algorithm to a format the totp library understands
@@ -307,6 +404,18 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		return logical.ErrorResponse("the key_size value must be greater than zero"), nil
 	}
 
+	if maxValidationAttempts <= 0 {
+		return logical.ErrorResponse("the max_validation_attempts value must be greater than zero"), nil
+	}
+
+	if counter < 0 {
+		return logical.ErrorResponse("the counter value must be greater than or equal to zero"), nil
+	}
+
+	if lookAheadWindow < 0 || lookAheadWindow > maxLookAheadWindow {
+		return logical.ErrorResponse(fmt.Sprintf("the look_ahead_window value must be between 0 and %d", maxLookAheadWindow)), nil
+	}
+
 	// Period, Skew and Key Size need to be unsigned ints
 	uintPeriod := uint(period)
 	uintSkew := uint(skew)
@@ -326,14 +435,27 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		}
 
 		// Generate a new key
-		keyObject, err := totplib.Generate(totplib.GenerateOpts{
-			Issuer:      issuer,
-			AccountName: accountName,
-			Period:      uintPeriod,
-			Digits:      keyDigits,
-			Algorithm:   keyAlgorithm,
-			SecretSize:  uintKeySize,
-		})
+		var keyObject *otplib.Key
+		var err error
+		switch keyType {
+		case keyTypeHOTP:
+			keyObject, err = generateHOTPKey(hotplib.GenerateOpts{
+				Issuer:      issuer,
+				AccountName: accountName,
+				Digits:      keyDigits,
+				Algorithm:   keyAlgorithm,
+				SecretSize:  uintKeySize,
+			}, uint64(counter))
+		default:
+			keyObject, err = totplib.Generate(totplib.GenerateOpts{
+				Issuer:      issuer,
+				AccountName: accountName,
+				Period:      uintPeriod,
+				Digits:      keyDigits,
+				Algorithm:   keyAlgorithm,
+				SecretSize:  uintKeySize,
+			})
+		}
 		if err != nil {
 			return logical.ErrorResponse("an error occured while generating a key"), err
 		}
@@ -391,6 +513,12 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		Algorithm:   keyAlgorithm,
 		Digits:      keyDigits,
 		Skew:        uintSkew,
+
+		Type:            keyType,
+		Counter:         uint64(counter),
+		LookAheadWindow: uint(lookAheadWindow),
+
+		MaxValidationAttempts: maxValidationAttempts,
 	})
 	if err != nil {
 		return nil, err
@@ -410,6 +538,40 @@ type keyEntry struct {
 	Algorithm   otplib.Algorithm `json:"algorithm" mapstructure:"algorithm" structs:"algorithm"`
 	Digits      otplib.Digits    `json:"digits" mapstructure:"digits" structs:"digits"`
 	Skew        uint             `json:"skew" mapstructure:"skew" structs:"skew"`
+
+	Type            string `json:"type" mapstructure:"type" structs:"type"`
+	Counter         uint64 `json:"counter" mapstructure:"counter" structs:"counter"`
+	LookAheadWindow uint   `json:"look_ahead_window" mapstructure:"look_ahead_window" structs:"look_ahead_window"`
+
+	MaxValidationAttempts int `json:"max_validation_attempts" mapstructure:"max_validation_attempts" structs:"max_validation_attempts"`
+}
+
+// keyType returns the type of the key; keys created before HOTP was
+// supported are TOTP keys
+func (k *keyEntry) keyType() string {
+	if k.Type == "" {
+		return keyTypeTOTP
+	}
+	return k.Type
+}
+
+// maxValidationAttempts returns the number of failed validations allowed for
+// the key, which is not set for keys created before it was configurable
+func (k *keyEntry) maxValidationAttempts() int {
+	if k.MaxValidationAttempts <= 0 {
+		return defaultMaxValidationAttempts
+	}
+	return k.MaxValidationAttempts
+}
+
+// validityWindow returns the length of time during which a code may be
+// accepted: the key skew, plus two for behind and in front, multiplied by
+// the period. HOTP codes do not expire, so a fixed window is used for them.
+func (k *keyEntry) validityWindow() time.Duration {
+	if k.keyType() == keyTypeHOTP {
+		return hotpFailureWindow
+	}
+	return time.Duration(int64(time.Second) * int64(k.Period) * int64(2+k.Skew))
 }
 
 const pathKeyHelpSyn = `
@@ -420,3 +582,22 @@ const pathKeyHelpDesc = `
 This path lets you manage the keys that can be created with this backend.
 
 `
+
+// generateHOTPKey generates a new HOTP key. The counter is added to the key
+// url as required by the otpauth://hotp format.
+func generateHOTPKey(opts hotplib.GenerateOpts, counter uint64) (*otplib.Key, error) {
+	keyObject, err := hotplib.Generate(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keyURL, err := url.Parse(keyObject.String())
+	if err != nil {
+		return nil, err
+	}
+	query := keyURL.Query()
+	query.Set("counter", strconv.FormatUint(counter, 10))
+	keyURL.RawQuery = query.Encode()
+
+	return otplib.NewKeyFromURL(keyURL.String())
+}
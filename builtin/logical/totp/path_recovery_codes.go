@@ -0,0 +1,286 @@
+package totp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	defaultRecoveryCodeCount = 10
+	maxRecoveryCodeCount     = 100
+)
+
+// recoveryCodesEntry holds the hashes of the recovery codes of a key that
+// have not been used yet
+type recoveryCodesEntry struct {
+	Hashes []string `json:"hashes" mapstructure:"hashes" structs:"hashes"`
+}
+
+func pathRecoveryCodes(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "recovery_codes/" + framework.GenericNameRegex("name") + "$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key.",
+			},
+			"count": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     defaultRecoveryCodeCount,
+				Description: "Number of recovery codes to generate.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRecoveryCodesRead,
+			logical.UpdateOperation: b.pathRecoveryCodesGenerate,
+			logical.DeleteOperation: b.pathRecoveryCodesDelete,
+		},
+
+		HelpSynopsis:    pathRecoveryCodesHelpSyn,
+		HelpDescription: pathRecoveryCodesHelpDesc,
+	}
+}
+
+func pathValidateRecoveryCode(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "recovery_codes/" + framework.GenericNameRegex("name") + "/validate$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key.",
+			},
+			"code": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Recovery code to be validated.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRecoveryCodeValidate,
+		},
+
+		HelpSynopsis:    pathValidateRecoveryCodeHelpSyn,
+		HelpDescription: pathValidateRecoveryCodeHelpDesc,
+	}
+}
+
+func (b *backend) recoveryCodes(ctx context.Context, s logical.Storage, name string) (*recoveryCodesEntry, error) {
+	entry, err := s.Get(ctx, "recovery_codes/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result recoveryCodesEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// generateRecoveryCode returns a random recovery code in the form
+// "xxxxx-xxxxx"
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// hashRecoveryCode returns the hash under which a recovery code is stored,
+// ignoring case and separators in the code
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (b *backend) pathRecoveryCodesRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	codes, err := b.recoveryCodes(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if codes == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"remaining": len(codes.Hashes),
+		},
+	}, nil
+}
+
+func (b *backend) pathRecoveryCodesGenerate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	count := data.Get("count").(int)
+
+	if count <= 0 || count > maxRecoveryCodeCount {
+		return logical.ErrorResponse(fmt.Sprintf("the count value must be between 1 and %d", maxRecoveryCodeCount)), nil
+	}
+
+	key, err := b.Key(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
+	}
+
+	// Generating codes replaces any previously generated ones
+	codes := make([]string, 0, count)
+	entry := &recoveryCodesEntry{
+		Hashes: make([]string, 0, count),
+	}
+	for i := 0; i < count; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, errwrap.Wrapf("error generating recovery code: {{err}}", err)
+		}
+		codes = append(codes, code)
+		entry.Hashes = append(entry.Hashes, hashRecoveryCode(code))
+	}
+
+	storageEntry, err := logical.StorageEntryJSON("recovery_codes/"+name, entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, storageEntry); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"codes": codes,
+		},
+	}, nil
+}
+
+func (b *backend) pathRecoveryCodesDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	err := req.Storage.Delete(ctx, "recovery_codes/"+data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRecoveryCodeValidate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	code := data.Get("code").(string)
+
+	if code == "" {
+		return logical.ErrorResponse("the code value is required"), nil
+	}
+
+	key, err := b.Key(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
+	}
+
+	lock := locksutil.LockForKey(b.validationLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Recovery codes share the failure count of the key so that they cannot
+	// be used to get around it
+	state, err := b.validationState(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if state.attemptsExceeded(key) {
+		return logical.ErrorResponse("maximum validation attempts exceeded; wait before trying again"), nil
+	}
+
+	b.recoveryCodesLock.Lock()
+	defer b.recoveryCodesLock.Unlock()
+
+	codes, err := b.recoveryCodes(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashRecoveryCode(code)
+	match := -1
+	if codes != nil {
+		for i, stored := range codes.Hashes {
+			if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+				match = i
+			}
+		}
+	}
+
+	if match == -1 {
+		if err := b.recordValidation(ctx, req.Storage, name, key, state, false); err != nil {
+			return nil, err
+		}
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"valid": false,
+			},
+		}, nil
+	}
+
+	// Each code can only be used once
+	codes.Hashes = append(codes.Hashes[:match], codes.Hashes[match+1:]...)
+	storageEntry, err := logical.StorageEntryJSON("recovery_codes/"+name, codes)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, storageEntry); err != nil {
+		return nil, err
+	}
+	if err := b.recordValidation(ctx, req.Storage, name, key, state, true); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"valid":     true,
+			"remaining": len(codes.Hashes),
+		},
+	}, nil
+}
+
+const pathRecoveryCodesHelpSyn = `
+Manage the recovery codes of a key.
+`
+
+const pathRecoveryCodesHelpDesc = `
+This path generates recovery codes for a key, to be used in place of a
+time-based code if the device holding the key is lost. Generating codes
+replaces any existing ones; the codes are only returned once and only their
+hashes are stored. Reading returns the number of unused codes.
+`
+
+const pathValidateRecoveryCodeHelpSyn = `
+Validate and consume a recovery code of a key.
+`
+
+const pathValidateRecoveryCodeHelpDesc = `
+This path validates a recovery code of a key. A valid code is consumed and
+cannot be used again. Failed validations count towards the maximum number of
+validation attempts of the key.
+`
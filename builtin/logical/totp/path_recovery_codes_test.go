@@ -0,0 +1,116 @@
+package totp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	otplib "github.com/pquerna/otp"
+)
+
+func TestBackend_recoveryCodesAndValidationLimits(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+
+	key, _ := createKey()
+	doReq(logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"key":                     key,
+		"issuer":                  "Vault",
+		"account_name":            "Test",
+		"max_validation_attempts": 3,
+	})
+
+	// Codes cannot be replayed
+	code, err := generateCode(key, 30, otplib.DigitsSix, otplib.AlgorithmSHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := doReq(logical.UpdateOperation, "code/test", map[string]interface{}{"code": code})
+	if resp == nil || resp.IsError() || resp.Data["valid"] != true {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = doReq(logical.UpdateOperation, "code/test", map[string]interface{}{"code": code})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected replayed code to be rejected, got: %#v", resp)
+	}
+
+	// Used codes are kept in storage, so a restart doesn't allow replays
+	b, err = Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = doReq(logical.UpdateOperation, "code/test", map[string]interface{}{"code": code})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected replayed code to be rejected, got: %#v", resp)
+	}
+
+	// Recovery codes are returned once and consumed on use
+	resp = doReq(logical.UpdateOperation, "recovery_codes/test", map[string]interface{}{"count": 2})
+	codes := resp.Data["codes"].([]string)
+	if len(codes) != 2 {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = doReq(logical.UpdateOperation, "recovery_codes/test/validate", map[string]interface{}{
+		"code": strings.ToUpper(codes[0]),
+	})
+	if resp == nil || resp.Data["valid"] != true || resp.Data["remaining"] != 1 {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = doReq(logical.UpdateOperation, "recovery_codes/test/validate", map[string]interface{}{"code": codes[0]})
+	if resp == nil || resp.Data["valid"] != false {
+		t.Fatalf("expected used recovery code to be rejected, got: %#v", resp)
+	}
+	resp = doReq(logical.ReadOperation, "recovery_codes/test", nil)
+	if resp == nil || resp.Data["remaining"] != 1 {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Failed validations of both kinds count towards the limit, after which
+	// even valid codes are rejected
+	for i := 0; i < 2; i++ {
+		resp = doReq(logical.UpdateOperation, "code/test", map[string]interface{}{"code": "000000"})
+		if resp == nil || resp.IsError() || resp.Data["valid"] != false {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+	resp = doReq(logical.UpdateOperation, "recovery_codes/test/validate", map[string]interface{}{"code": codes[1]})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected validation to be rate limited, got: %#v", resp)
+	}
+
+	// Neither does it reset the failed validations
+	b, err = Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = doReq(logical.UpdateOperation, "recovery_codes/test/validate", map[string]interface{}{"code": codes[1]})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected validation to be rate limited, got: %#v", resp)
+	}
+
+	// Deleting the key removes its recovery codes
+	doReq(logical.DeleteOperation, "keys/test", nil)
+	if resp = doReq(logical.ReadOperation, "recovery_codes/test", nil); resp != nil {
+		t.Fatalf("expected recovery codes to be deleted, got: %#v", resp)
+	}
+}
@@ -161,6 +161,9 @@ func TestAuthEnableCommand_Run(t *testing.T) {
 			}
 		}
 
+		// The JWT backend is also available as "oidc"
+		backends = append(backends, "oidc")
+
 		plugins, err := ioutil.ReadDir("../vendor/github.com/hashicorp")
 		if err != nil {
 			t.Fatal(err)
@@ -614,6 +614,10 @@ type StringMapVar struct {
 	Hidden     bool
 	Target     *map[string]string
 	Completion complete.Predictor
+
+	// AllowEmpty allows an empty value, which sets the flag without adding a
+	// pair
+	AllowEmpty bool
 }
 
 func (f *FlagSet) StringMapVar(i *StringMapVar) {
@@ -627,25 +631,34 @@ func (f *FlagSet) StringMapVar(i *StringMapVar) {
 		Aliases:    i.Aliases,
 		Usage:      i.Usage,
 		Default:    def,
-		Value:      newStringMapValue(i.Default, i.Target, i.Hidden),
+		Value:      newStringMapValue(i.Default, i.Target, i.Hidden, i.AllowEmpty),
 		Completion: i.Completion,
 	})
 }
 
 type stringMapValue struct {
-	hidden bool
-	target *map[string]string
+	hidden     bool
+	allowEmpty bool
+	target     *map[string]string
 }
 
-func newStringMapValue(def map[string]string, target *map[string]string, hidden bool) *stringMapValue {
+func newStringMapValue(def map[string]string, target *map[string]string, hidden, allowEmpty bool) *stringMapValue {
 	*target = def
 	return &stringMapValue{
-		hidden: hidden,
-		target: target,
+		hidden:     hidden,
+		allowEmpty: allowEmpty,
+		target:     target,
 	}
 }
 
 func (s *stringMapValue) Set(val string) error {
+	if val == "" && s.allowEmpty {
+		if *s.target == nil {
+			*s.target = make(map[string]string)
+		}
+		return nil
+	}
+
 	idx := strings.Index(val, "=")
 	if idx == -1 {
 		return fmt.Errorf("missing = in KV pair: %q", val)
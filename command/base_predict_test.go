@@ -323,6 +323,7 @@ func TestPredict_Plugins(t *testing.T) {
 			client,
 			[]string{
 				"cassandra-database-plugin",
+				"elasticsearch-database-plugin",
 				"hana-database-plugin",
 				"mongodb-database-plugin",
 				"mssql-database-plugin",
@@ -331,6 +332,7 @@ func TestPredict_Plugins(t *testing.T) {
 				"mysql-legacy-database-plugin",
 				"mysql-rds-database-plugin",
 				"postgresql-database-plugin",
+				"redis-database-plugin",
 			},
 		},
 	}
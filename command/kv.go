@@ -41,6 +41,10 @@ Usage: vault kv <subcommand> [options] [args]
 
       $ vault kv get -version=1 secret/foo
 
+  Make a previous version of the key current again:
+
+      $ vault kv rollback -version=1 secret/foo
+
   Please see the individual subcommand help for detailed usage information.
 `
 
@@ -15,6 +15,7 @@ type KVGetCommand struct {
 	*BaseCommand
 
 	flagVersion int
+	flagSubkeys bool
 }
 
 func (c *KVGetCommand) Synopsis() string {
@@ -36,6 +37,11 @@ Usage: vault kv get [options] KEY
 
       $ vault kv get -version=1 secret/foo
 
+  To view only the structure of the data at the given key name, with its values
+  removed, specify the "-subkeys" flag:
+
+      $ vault kv get -subkeys secret/foo
+
   Additional flags and more advanced use cases are detailed below.
 
 ` + c.Flags().Help()
@@ -55,6 +61,14 @@ func (c *KVGetCommand) Flags() *FlagSets {
 		Usage:   `If passed, the value at the version number will be returned.`,
 	})
 
+	f.BoolVar(&BoolVar{
+		Name:    "subkeys",
+		Target:  &c.flagSubkeys,
+		Default: false,
+		Usage: "If set, only the keys of the data will be returned, with " +
+			"their values removed. This is only supported on KV Version 2.",
+	})
+
 	return set
 }
 
@@ -99,8 +113,19 @@ func (c *KVGetCommand) Run(args []string) int {
 
 	var versionParam map[string]string
 
+	// The data of a v2 secret is returned under the "subkeys" key instead of
+	// the "data" key when reading its subkeys
+	dataKey := "data"
+	if c.flagSubkeys {
+		if !v2 {
+			c.UI.Error("Subkeys not supported on KV Version 1")
+			return 1
+		}
+		dataKey = "subkeys"
+	}
+
 	if v2 {
-		path = addPrefixToVKVPath(path, mountPath, "data")
+		path = addPrefixToVKVPath(path, mountPath, dataKey)
 		if err != nil {
 			c.UI.Error(err.Error())
 			return 2
@@ -129,7 +154,7 @@ func (c *KVGetCommand) Run(args []string) int {
 	if c.flagField != "" {
 		if v2 {
 			// This is a v2, pass in the data field
-			if data, ok := secret.Data["data"]; ok && data != nil {
+			if data, ok := secret.Data[dataKey]; ok && data != nil {
 				return PrintRawField(c.UI, data, c.flagField)
 			} else {
 				c.UI.Error(fmt.Sprintf("No data found at %s", path))
@@ -159,14 +184,18 @@ func (c *KVGetCommand) Run(args []string) int {
 	data := secret.Data
 	if v2 && data != nil {
 		data = nil
-		dataRaw := secret.Data["data"]
+		dataRaw := secret.Data[dataKey]
 		if dataRaw != nil {
 			data = dataRaw.(map[string]interface{})
 		}
 	}
 
 	if data != nil {
-		c.UI.Info(getHeaderForMap("Data", data))
+		header := "Data"
+		if c.flagSubkeys {
+			header = "Subkeys"
+		}
+		c.UI.Info(getHeaderForMap(header, data))
 		OutputData(c.UI, data)
 	}
 
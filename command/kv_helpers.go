@@ -3,6 +3,7 @@ package command
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"path"
 	"strings"
 
@@ -40,6 +41,55 @@ func kvReadRequest(client *api.Client, path string, params map[string]string) (*
 	return api.ParseSecret(resp.Body)
 }
 
+// kvMergePatchRequest sends the data as a JSON merge patch of the given path.
+// The status code of the response is returned along with the secret so that
+// callers can tell if the server doesn't support the PATCH method.
+func kvMergePatchRequest(client *api.Client, path string, data map[string]interface{}) (*api.Secret, int, error) {
+	r := client.NewRequest("PATCH", "/v1/"+path)
+	if err := r.SetJSONBody(data); err != nil {
+		return nil, 0, err
+	}
+
+	// Copy the headers so that the client's headers are left untouched
+	headers := make(http.Header, len(r.Headers)+1)
+	for k, v := range r.Headers {
+		headers[k] = v
+	}
+	headers.Set("Content-Type", "application/merge-patch+json")
+	r.Headers = headers
+
+	resp, err := client.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if resp != nil && resp.StatusCode == 404 {
+		secret, parseErr := api.ParseSecret(resp.Body)
+		switch parseErr {
+		case nil:
+		case io.EOF:
+			return nil, resp.StatusCode, nil
+		default:
+			return nil, resp.StatusCode, err
+		}
+		if secret != nil && (len(secret.Warnings) > 0 || len(secret.Data) > 0) {
+			return secret, resp.StatusCode, err
+		}
+		return nil, resp.StatusCode, nil
+	}
+	if err != nil {
+		if resp != nil {
+			return nil, resp.StatusCode, err
+		}
+		return nil, 0, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, resp.StatusCode, nil
+	}
+
+	secret, err := api.ParseSecret(resp.Body)
+	return secret, resp.StatusCode, err
+}
+
 func kvPreflightVersionRequest(client *api.Client, path string) (string, int, error) {
 	// We don't want to use a wrapping call here so save any custom value and
 	// restore after
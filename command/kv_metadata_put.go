@@ -1,6 +1,7 @@
 package command
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"strings"
@@ -15,9 +16,10 @@ var _ cli.CommandAutocomplete = (*KVMetadataPutCommand)(nil)
 type KVMetadataPutCommand struct {
 	*BaseCommand
 
-	flagMaxVersions int
-	flagCASRequired bool
-	testStdin       io.Reader // for tests
+	flagMaxVersions    int
+	flagCASRequired    bool
+	flagCustomMetadata map[string]string
+	testStdin          io.Reader // for tests
 }
 
 func (c *KVMetadataPutCommand) Synopsis() string {
@@ -43,6 +45,14 @@ Usage: vault metadata kv put [options] KEY [DATA]
 
       $ vault kv metadata put -require-cas secret/foo
 
+  Set custom metadata on the key:
+
+      $ vault kv metadata put -custom-metadata=foo=abc -custom-metadata=bar=123 secret/foo
+
+  Clear the custom metadata of the key:
+
+      $ vault kv metadata put -custom-metadata="" secret/foo
+
   Additional flags and more advanced use cases are detailed below.
 
 ` + c.Flags().Help()
@@ -69,6 +79,17 @@ func (c *KVMetadataPutCommand) Flags() *FlagSets {
 		Usage:   `If true the key will require the cas parameter to be set on all write requests. If false, the backend’s configuration will be used.`,
 	})
 
+	f.StringMapVar(&StringMapVar{
+		Name:       "custom-metadata",
+		Target:     &c.flagCustomMetadata,
+		Completion: complete.PredictAnything,
+		Usage: "Arbitrary key=value metadata to associate with the key. This " +
+			"replaces any custom metadata previously set on the key. This can " +
+			"be specified multiple times to add multiple pieces of metadata. " +
+			"An empty value clears the custom metadata.",
+		AllowEmpty: true,
+	})
+
 	return set
 }
 
@@ -117,10 +138,24 @@ func (c *KVMetadataPutCommand) Run(args []string) int {
 	}
 
 	path = addPrefixToVKVPath(path, mountPath, "metadata")
-	data := map[string]interface{}{
-		"max_versions": c.flagMaxVersions,
-		"cas_required": c.flagCASRequired,
-	}
+	data := map[string]interface{}{}
+
+	// Only send the options that were given, so that the others are left
+	// unchanged. The custom metadata may be given to clear it.
+	f.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "max-versions":
+			data["max_versions"] = c.flagMaxVersions
+		case "cas-required":
+			data["cas_required"] = c.flagCASRequired
+		case "custom-metadata":
+			customMetadata := c.flagCustomMetadata
+			if customMetadata == nil {
+				customMetadata = map[string]string{}
+			}
+			data["custom_metadata"] = customMetadata
+		}
+	})
 
 	secret, err := client.Logical().Write(path, data)
 	if err != nil {
@@ -1,11 +1,14 @@
 package command
 
 import (
+	"flag"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"strings"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/mitchellh/cli"
 	"github.com/posener/complete"
 )
@@ -16,7 +19,9 @@ var _ cli.CommandAutocomplete = (*KVPatchCommand)(nil)
 type KVPatchCommand struct {
 	*BaseCommand
 
-	testStdin io.Reader // for tests
+	flagCAS    int
+	flagMethod string
+	testStdin  io.Reader // for tests
 }
 
 func (c *KVPatchCommand) Synopsis() string {
@@ -43,6 +48,13 @@ Usage: vault kv patch [options] KEY [DATA]
 
       $ echo "abcd1234" | vault kv patch secret/foo bar=-
 
+  The data is merged into the current data by the server using a JSON merge
+  patch. Servers which don't support this are patched with a read followed by
+  a write of the merged data instead. To always use this method, specify the
+  "-method" flag:
+
+      $ vault kv patch -method=rw secret/foo bar=baz
+
   Additional flags and more advanced use cases are detailed below.
 
 ` + c.Flags().Help()
@@ -52,6 +64,32 @@ Usage: vault kv patch [options] KEY [DATA]
 func (c *KVPatchCommand) Flags() *FlagSets {
 	set := c.flagSet(FlagSetHTTP | FlagSetOutputField | FlagSetOutputFormat)
 
+	// Common Options
+	f := set.NewFlagSet("Common Options")
+
+	f.IntVar(&IntVar{
+		Name:    "cas",
+		Target:  &c.flagCAS,
+		Default: -1,
+		Usage: `Specifies to use a Check-And-Set operation. If not set the patch
+		will be allowed. If the index is non-zero the patch will only be allowed
+		if the key’s current version matches the version specified in the cas
+		parameter. This is ignored with the "rw" method, which always uses the
+		version it read.`,
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "method",
+		Target:     &c.flagMethod,
+		Default:    "patch",
+		Completion: complete.PredictSet("patch", "rw"),
+		Usage: `Specifies which method of patching to use. If set to "patch",
+		the data is merged by the server using a JSON merge patch. If set to
+		"rw", the current data is read and the merged data is written back with
+		a Check-And-Set operation. If not set, "patch" is used and the "rw"
+		method is used as a fallback when the server doesn't support it.`,
+	})
+
 	return set
 }
 
@@ -119,49 +157,117 @@ func (c *KVPatchCommand) Run(args []string) int {
 		return 2
 	}
 
+	// Only fall back to a read and write if no method was asked for
+	var methodSet bool
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == "method" {
+			methodSet = true
+		}
+	})
+
+	var secret *api.Secret
+	var code int
+	switch c.flagMethod {
+	case "patch":
+		secret, code = c.mergePatch(client, path, newData, !methodSet)
+	case "rw":
+		secret, code = c.readThenWrite(client, path, newData)
+	default:
+		c.UI.Error(fmt.Sprintf("Unsupported method %q, must be one of \"patch\" or \"rw\"", c.flagMethod))
+		return 1
+	}
+	if code != 0 {
+		return code
+	}
+	if secret == nil {
+		// Don't output anything unless using the "table" format
+		if Format(c.UI) == "table" {
+			c.UI.Info(fmt.Sprintf("Success! Data written to: %s", path))
+		}
+		return 0
+	}
+
+	if c.flagField != "" {
+		return PrintRawField(c.UI, secret, c.flagField)
+	}
+
+	return OutputSecret(c.UI, secret)
+}
+
+// mergePatch sends the new data to the server as a JSON merge patch of the
+// current data. If fallback is set and the server doesn't support the PATCH
+// method, the patch is done with readThenWrite instead.
+func (c *KVPatchCommand) mergePatch(client *api.Client, path string, newData map[string]interface{}, fallback bool) (*api.Secret, int) {
+	data := map[string]interface{}{
+		"data":    newData,
+		"options": map[string]interface{}{},
+	}
+	if c.flagCAS > -1 {
+		data["options"].(map[string]interface{})["cas"] = c.flagCAS
+	}
+
+	secret, status, err := kvMergePatchRequest(client, path, data)
+	if status == http.StatusMethodNotAllowed && fallback {
+		return c.readThenWrite(client, path, newData)
+	}
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error patching data at %s: %s", path, err))
+		return nil, 2
+	}
+	if secret == nil {
+		c.UI.Error(fmt.Sprintf("No value found at %s", path))
+		return nil, 2
+	}
+
+	return secret, 0
+}
+
+// readThenWrite reads the current data, merges the new data into it and
+// writes the result back using a Check-And-Set operation
+func (c *KVPatchCommand) readThenWrite(client *api.Client, path string, newData map[string]interface{}) (*api.Secret, int) {
 	// First, do a read
 	secret, err := kvReadRequest(client, path, nil)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error doing pre-read at %s: %s", path, err))
-		return 2
+		return nil, 2
 	}
 
 	// Make sure a value already exists
 	if secret == nil || secret.Data == nil {
 		c.UI.Error(fmt.Sprintf("No value found at %s", path))
-		return 2
+		return nil, 2
 	}
 
 	// Verify metadata found
 	rawMeta, ok := secret.Data["metadata"]
 	if !ok || rawMeta == nil {
 		c.UI.Error(fmt.Sprintf("No metadata found at %s; patch only works on existing data", path))
-		return 2
+		return nil, 2
 	}
 	meta, ok := rawMeta.(map[string]interface{})
 	if !ok {
 		c.UI.Error(fmt.Sprintf("Metadata found at %s is not the expected type (JSON object)", path))
-		return 2
+		return nil, 2
 	}
 	if meta == nil {
 		c.UI.Error(fmt.Sprintf("No metadata found at %s; patch only works on existing data", path))
-		return 2
+		return nil, 2
 	}
 
 	// Verify old data found
 	rawData, ok := secret.Data["data"]
 	if !ok || rawData == nil {
 		c.UI.Error(fmt.Sprintf("No data found at %s; patch only works on existing data", path))
-		return 2
+		return nil, 2
 	}
 	data, ok := rawData.(map[string]interface{})
 	if !ok {
 		c.UI.Error(fmt.Sprintf("Data found at %s is not the expected type (JSON object)", path))
-		return 2
+		return nil, 2
 	}
 	if data == nil {
 		c.UI.Error(fmt.Sprintf("No data found at %s; patch only works on existing data", path))
-		return 2
+		return nil, 2
 	}
 
 	// Copy new data over
@@ -177,19 +283,8 @@ func (c *KVPatchCommand) Run(args []string) int {
 	})
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error writing data to %s: %s", path, err))
-		return 2
-	}
-	if secret == nil {
-		// Don't output anything unless using the "table" format
-		if Format(c.UI) == "table" {
-			c.UI.Info(fmt.Sprintf("Success! Data written to: %s", path))
-		}
-		return 0
+		return nil, 2
 	}
 
-	if c.flagField != "" {
-		return PrintRawField(c.UI, secret, c.flagField)
-	}
-
-	return OutputSecret(c.UI, secret)
+	return secret, 0
 }
@@ -0,0 +1,163 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*KVRollbackCommand)(nil)
+var _ cli.CommandAutocomplete = (*KVRollbackCommand)(nil)
+
+type KVRollbackCommand struct {
+	*BaseCommand
+
+	flagVersion int
+}
+
+func (c *KVRollbackCommand) Synopsis() string {
+	return "Rolls back to a previous version of data in the KV store"
+}
+
+func (c *KVRollbackCommand) Help() string {
+	helpText := `
+Usage: vault kv rollback [options] KEY
+
+  *NOTE*: This is only supported for KV v2 engine mounts.
+
+  Restores a given previous version to the current version at the given path.
+  The value is written as a new version; for instance, if the current version
+  is 5 and the rollback version is 2, the data from version 2 will become
+  version 6.
+
+      $ vault kv rollback -version=2 secret/foo
+
+  Additional flags and more advanced use cases are detailed below.
+
+` + c.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *KVRollbackCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP | FlagSetOutputField | FlagSetOutputFormat)
+
+	// Common Options
+	f := set.NewFlagSet("Common Options")
+
+	f.IntVar(&IntVar{
+		Name:    "version",
+		Target:  &c.flagVersion,
+		Default: 0,
+		Usage:   `Specifies the version number that should be made current again.`,
+	})
+
+	return set
+}
+
+func (c *KVRollbackCommand) AutocompleteArgs() complete.Predictor {
+	return nil
+}
+
+func (c *KVRollbackCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *KVRollbackCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	switch {
+	case len(args) < 1:
+		c.UI.Error(fmt.Sprintf("Not enough arguments (expected 1, got %d)", len(args)))
+		return 1
+	case len(args) > 1:
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+
+	if c.flagVersion <= 0 {
+		c.UI.Error("Must specify a version to roll back to with the -version flag")
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	path := sanitizePath(args[0])
+	mountPath, v2, err := isKVv2(path, client)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+	if !v2 {
+		c.UI.Error("Rollback not supported on KV Version 1")
+		return 1
+	}
+
+	// Read the metadata to find the current version, which is used as the
+	// Check-And-Set value of the write
+	metadataPath := addPrefixToVKVPath(path, mountPath, "metadata")
+	metadata, err := client.Logical().Read(metadataPath)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading metadata at %s: %s", metadataPath, err))
+		return 2
+	}
+	if metadata == nil || metadata.Data == nil {
+		c.UI.Error(fmt.Sprintf("No value found at %s", path))
+		return 2
+	}
+	currentVersion, ok := metadata.Data["current_version"]
+	if !ok || currentVersion == nil {
+		c.UI.Error(fmt.Sprintf("No current version found at %s", metadataPath))
+		return 2
+	}
+
+	// Read the data of the version to roll back to
+	dataPath := addPrefixToVKVPath(path, mountPath, "data")
+	secret, err := kvReadRequest(client, dataPath, map[string]string{
+		"version": fmt.Sprintf("%d", c.flagVersion),
+	})
+	if err != nil || secret == nil || secret.Data["data"] == nil {
+		c.UI.Error(fmt.Sprintf("Version %d of %s does not exist or has been deleted or destroyed", c.flagVersion, path))
+		return 2
+	}
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		c.UI.Error(fmt.Sprintf("Data found at %s is not the expected type (JSON object)", dataPath))
+		return 2
+	}
+
+	secret, err = client.Logical().Write(dataPath, map[string]interface{}{
+		"data": data,
+		"options": map[string]interface{}{
+			"cas": currentVersion,
+		},
+	})
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing data to %s: %s", dataPath, err))
+		return 2
+	}
+	if secret == nil {
+		// Don't output anything unless using the "table" format
+		if Format(c.UI) == "table" {
+			c.UI.Info(fmt.Sprintf("Success! Data written to: %s", dataPath))
+		}
+		return 0
+	}
+
+	if c.flagField != "" {
+		return PrintRawField(c.UI, secret, c.flagField)
+	}
+
+	return OutputSecret(c.UI, secret)
+}
@@ -1,11 +1,17 @@
 package command
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/api"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
 	"github.com/mitchellh/cli"
 )
 
@@ -382,6 +388,18 @@ func TestKVGetCommand(t *testing.T) {
 			"foo",
 			0,
 		},
+		{
+			"v2_subkeys",
+			[]string{"-subkeys", "kv/read/foo"},
+			"Subkeys",
+			0,
+		},
+		{
+			"v1_subkeys",
+			[]string{"-subkeys", "secret/read/foo"},
+			"Subkeys not supported on KV Version 1",
+			1,
+		},
 	}
 
 	t.Run("validations", func(t *testing.T) {
@@ -527,3 +545,577 @@ func TestKVMetadataGetCommand(t *testing.T) {
 		assertNoTabs(t, cmd)
 	})
 }
+
+func testKVMetadataPutCommand(tb testing.TB) (*cli.MockUi, *KVMetadataPutCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &KVMetadataPutCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func TestKVMetadataPutCommand(t *testing.T) {
+	t.Parallel()
+
+	t.Run("custom_metadata", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+		if err := client.Sys().Mount("kv/", &api.MountInput{
+			Type: "kv-v2",
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		ui, cmd := testKVMetadataPutCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{
+			"-custom-metadata", "foo=abc",
+			"-custom-metadata", "bar=123",
+			"kv/foo",
+		})
+		if exp := 0; code != exp {
+			t.Fatalf("expected %d to be %d: %s", code, exp, ui.ErrorWriter.String())
+		}
+
+		secret, err := client.Logical().Read("kv/metadata/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]interface{}{
+			"foo": "abc",
+			"bar": "123",
+		}
+		if !reflect.DeepEqual(secret.Data["custom_metadata"], expected) {
+			t.Errorf("expected %#v to be %#v", secret.Data["custom_metadata"], expected)
+		}
+
+		// The custom metadata is kept unless the flag is given
+		ui, cmd = testKVMetadataPutCommand(t)
+		cmd.client = client
+		if code := cmd.Run([]string{"-max-versions", "5", "kv/foo"}); code != 0 {
+			t.Fatalf("expected %d to be %d: %s", code, 0, ui.ErrorWriter.String())
+		}
+		secret, err = client.Logical().Read("kv/metadata/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(secret.Data["custom_metadata"], expected) {
+			t.Errorf("expected %#v to be %#v", secret.Data["custom_metadata"], expected)
+		}
+
+		// An empty value clears it
+		ui, cmd = testKVMetadataPutCommand(t)
+		cmd.client = client
+		if code := cmd.Run([]string{"-custom-metadata=", "kv/foo"}); code != 0 {
+			t.Fatalf("expected %d to be %d: %s", code, 0, ui.ErrorWriter.String())
+		}
+		secret, err = client.Logical().Read("kv/metadata/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if customMetadata := secret.Data["custom_metadata"].(map[string]interface{}); len(customMetadata) != 0 {
+			t.Errorf("expected %#v to be empty", customMetadata)
+		}
+
+		// The options that weren't given are left unchanged
+		if maxVersions := fmt.Sprint(secret.Data["max_versions"]); maxVersions != "5" {
+			t.Errorf("expected %s to be 5", maxVersions)
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testKVMetadataPutCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
+
+func testKVPatchCommand(tb testing.TB) (*cli.MockUi, *KVPatchCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &KVPatchCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func TestKVPatchCommand(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		args []string
+		out  string
+		code int
+	}{
+		{
+			"not_enough_args",
+			[]string{},
+			"Not enough arguments",
+			1,
+		},
+		{
+			"empty_kvs",
+			[]string{"kv/patch/foo"},
+			"Must supply data",
+			1,
+		},
+		{
+			"v1",
+			[]string{"secret/patch/foo", "foo=bar"},
+			"must be version 2",
+			2,
+		},
+		{
+			"not_found",
+			[]string{"kv/nope/not/once/never", "foo=bar"},
+			"No value found",
+			2,
+		},
+		{
+			"invalid_method",
+			[]string{"-method", "nope", "kv/patch/foo", "foo=bar"},
+			"Unsupported method",
+			1,
+		},
+		{
+			"patch",
+			[]string{"kv/patch/foo", "zip=zap"},
+			"created_time",
+			0,
+		},
+		{
+			"rw",
+			[]string{"-method", "rw", "kv/patch/foo", "zip=zap"},
+			"created_time",
+			0,
+		},
+		{
+			"cas_mismatch",
+			[]string{"-cas", "5", "kv/patch/foo", "zip=zap"},
+			"check-and-set parameter did not match the current version",
+			2,
+		},
+	}
+
+	t.Run("validations", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tc := range cases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				client, closer := testVaultServer(t)
+				defer closer()
+				if err := client.Sys().Mount("kv/", &api.MountInput{
+					Type: "kv-v2",
+				}); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := client.Logical().Write("kv/data/patch/foo", map[string]interface{}{
+					"data": map[string]interface{}{
+						"foo": "bar",
+					},
+				}); err != nil {
+					t.Fatal(err)
+				}
+
+				ui, cmd := testKVPatchCommand(t)
+				cmd.client = client
+
+				code := cmd.Run(tc.args)
+				if code != tc.code {
+					t.Errorf("expected %d to be %d", code, tc.code)
+				}
+
+				combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+				if !strings.Contains(combined, tc.out) {
+					t.Errorf("expected %q to contain %q", combined, tc.out)
+				}
+			})
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+		if err := client.Sys().Mount("kv/", &api.MountInput{
+			Type: "kv-v2",
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Logical().Write("kv/data/patch/foo", map[string]interface{}{
+			"data": map[string]interface{}{
+				"foo": "bar",
+				"zip": "zap",
+				"nested": map[string]interface{}{
+					"a": "b",
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Logical().JSONMergePatch("kv/data/patch/foo", map[string]interface{}{
+			"data": map[string]interface{}{
+				"foo": "baz",
+				"zip": nil,
+				"nested": map[string]interface{}{
+					"c": "d",
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		secret, err := client.Logical().Read("kv/data/patch/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]interface{}{
+			"foo": "baz",
+			"nested": map[string]interface{}{
+				"a": "b",
+				"c": "d",
+			},
+		}
+		if !reflect.DeepEqual(secret.Data["data"], expected) {
+			t.Errorf("expected %#v to be %#v", secret.Data["data"], expected)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		t.Parallel()
+
+		// Act like a server which doesn't support the PATCH method
+		cluster := vault.NewTestCluster(t, &vault.CoreConfig{
+			DisableMlock:    true,
+			DisableCache:    true,
+			Logger:          defaultVaultLogger,
+			LogicalBackends: defaultVaultLogicalBackends,
+		}, &vault.TestClusterOptions{
+			HandlerFunc: func(core *vault.Core) http.Handler {
+				handler := vaulthttp.Handler(core)
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Method == "PATCH" {
+						w.WriteHeader(http.StatusMethodNotAllowed)
+						return
+					}
+					handler.ServeHTTP(w, r)
+				})
+			},
+			NumCores: 1,
+		})
+		cluster.Start()
+		defer cluster.Cleanup()
+		vault.TestWaitActive(t, cluster.Cores[0].Core)
+
+		client := cluster.Cores[0].Client
+		client.SetToken(cluster.RootToken)
+		if err := client.Sys().Mount("kv/", &api.MountInput{
+			Type: "kv-v2",
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Logical().Write("kv/data/patch/foo", map[string]interface{}{
+			"data": map[string]interface{}{
+				"foo": "bar",
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The fallback is not used if the method was asked for
+		ui, cmd := testKVPatchCommand(t)
+		cmd.client = client
+		if code := cmd.Run([]string{"-method", "patch", "kv/patch/foo", "zip=zap"}); code != 2 {
+			t.Fatalf("expected 2 to be %d: %s", code, ui.ErrorWriter.String())
+		}
+
+		ui, cmd = testKVPatchCommand(t)
+		cmd.client = client
+		if code := cmd.Run([]string{"kv/patch/foo", "zip=zap"}); code != 0 {
+			t.Fatalf("expected 0 to be %d: %s", code, ui.ErrorWriter.String())
+		}
+
+		secret, err := client.Logical().Read("kv/data/patch/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]interface{}{
+			"foo": "bar",
+			"zip": "zap",
+		}
+		if !reflect.DeepEqual(secret.Data["data"], expected) {
+			t.Errorf("expected %#v to be %#v", secret.Data["data"], expected)
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testKVPatchCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
+
+func testKVRollbackCommand(tb testing.TB) (*cli.MockUi, *KVRollbackCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &KVRollbackCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func TestKVRollbackCommand(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		args []string
+		out  string
+		code int
+	}{
+		{
+			"not_enough_args",
+			[]string{},
+			"Not enough arguments",
+			1,
+		},
+		{
+			"no_version",
+			[]string{"kv/foo"},
+			"Must specify a version",
+			1,
+		},
+		{
+			"v1",
+			[]string{"-version", "1", "secret/foo"},
+			"Rollback not supported on KV Version 1",
+			1,
+		},
+		{
+			"version_not_found",
+			[]string{"-version", "5", "kv/foo"},
+			"does not exist",
+			2,
+		},
+		{
+			"rollback",
+			[]string{"-version", "1", "kv/foo"},
+			"created_time",
+			0,
+		},
+	}
+
+	t.Run("validations", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tc := range cases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				client, closer := testVaultServer(t)
+				defer closer()
+				if err := client.Sys().Mount("kv/", &api.MountInput{
+					Type: "kv-v2",
+				}); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, v := range []string{"bar", "baz"} {
+					if _, err := client.Logical().Write("kv/data/foo", map[string]interface{}{
+						"data": map[string]interface{}{
+							"foo": v,
+						},
+					}); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				ui, cmd := testKVRollbackCommand(t)
+				cmd.client = client
+
+				code := cmd.Run(tc.args)
+				if code != tc.code {
+					t.Errorf("expected %d to be %d", code, tc.code)
+				}
+
+				combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+				if !strings.Contains(combined, tc.out) {
+					t.Errorf("expected %q to contain %q", combined, tc.out)
+				}
+
+				if tc.code != 0 {
+					return
+				}
+
+				secret, err := client.Logical().Read("kv/data/foo")
+				if err != nil {
+					t.Fatal(err)
+				}
+				data := secret.Data["data"].(map[string]interface{})
+				if data["foo"] != "bar" {
+					t.Errorf("expected %q to be %q", data["foo"], "bar")
+				}
+			})
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testKVRollbackCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
+
+func TestKVSubkeys(t *testing.T) {
+	t.Parallel()
+
+	client, closer := testVaultServer(t)
+	defer closer()
+	if err := client.Sys().Mount("kv/", &api.MountInput{
+		Type: "kv-v2",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{
+			"foo": "abc",
+			"bar": map[string]interface{}{
+				"baz": "def",
+				"qux": map[string]interface{}{
+					"quux": 123,
+				},
+			},
+		},
+		{"foo": "ghi"},
+		{"foo": "jkl"},
+	} {
+		if _, err := client.Logical().Write("kv/data/foo", map[string]interface{}{
+			"data": data,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readSubkeys := func(params map[string]string) (int, *api.Secret) {
+		t.Helper()
+		r := client.NewRequest("GET", "/v1/kv/subkeys/foo")
+		for k, v := range params {
+			r.Params.Set(k, v)
+		}
+		resp, err := client.RawRequest(r)
+		if resp == nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		secret, err := api.ParseSecret(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, secret
+	}
+
+	for _, tc := range []struct {
+		depth    string
+		expected map[string]interface{}
+	}{
+		{
+			"0",
+			map[string]interface{}{
+				"foo": nil,
+				"bar": map[string]interface{}{
+					"baz": nil,
+					"qux": map[string]interface{}{
+						"quux": nil,
+					},
+				},
+			},
+		},
+		{
+			"1",
+			map[string]interface{}{
+				"foo": nil,
+				"bar": nil,
+			},
+		},
+		{
+			"2",
+			map[string]interface{}{
+				"foo": nil,
+				"bar": map[string]interface{}{
+					"baz": nil,
+					"qux": nil,
+				},
+			},
+		},
+	} {
+		status, secret := readSubkeys(map[string]string{"version": "1", "depth": tc.depth})
+		if status != 200 {
+			t.Fatalf("depth %s: bad status %d", tc.depth, status)
+		}
+		if !reflect.DeepEqual(secret.Data["subkeys"], tc.expected) {
+			t.Fatalf("depth %s: expected %#v to be %#v", tc.depth, secret.Data["subkeys"], tc.expected)
+		}
+	}
+
+	if status, _ := readSubkeys(map[string]string{"depth": "-1"}); status != 400 {
+		t.Fatalf("expected a negative depth to be rejected, got status %d", status)
+	}
+
+	// Deleted and destroyed versions return their metadata with a 404
+	if _, err := client.Logical().Delete("kv/data/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("kv/destroy/foo", map[string]interface{}{
+		"versions": []int{2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		version   string
+		destroyed bool
+	}{
+		{"3", false},
+		{"2", true},
+	} {
+		status, secret := readSubkeys(map[string]string{"version": tc.version})
+		if status != 404 {
+			t.Fatalf("version %s: bad status %d", tc.version, status)
+		}
+		metadata := secret.Data["metadata"].(map[string]interface{})
+		if secret.Data["subkeys"] != nil || metadata["version"] != json.Number(tc.version) || metadata["destroyed"] != tc.destroyed {
+			t.Fatalf("version %s: bad data: %#v", tc.version, secret.Data)
+		}
+		if deleted := metadata["deletion_time"] != ""; deleted == tc.destroyed {
+			t.Fatalf("version %s: bad deletion time: %#v", tc.version, metadata)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package identitytpl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+var (
+	ErrUnbalancedTemplatingCharacter = errors.New("unbalanced templating characters")
+	ErrNoEntityAttachedToToken       = errors.New("string contains entity template directives but no entity was provided")
+	ErrTemplateValueNotFound         = errors.New("no value could be found for one of the template directives")
+)
+
+// PopulateString replaces all identity templating directives in the given
+// string, such as "{{identity.entity.name}}", with the corresponding values
+// of the given entity. The supported directives are:
+//
+//	identity.entity.id
+//	identity.entity.name
+//	identity.entity.metadata.<key>
+//	identity.entity.aliases.<mount accessor>.name
+//	identity.entity.aliases.<mount accessor>.metadata.<key>
+//
+// The returned boolean indicates whether the string contained any templating
+// directives. Strings without directives are returned unchanged even if the
+// entity is nil.
+func PopulateString(entity *logical.Entity, tpl string) (bool, string, error) {
+	if !strings.Contains(tpl, "{{") && !strings.Contains(tpl, "}}") {
+		return false, tpl, nil
+	}
+
+	out, err := populate(tpl, func(directive string) (string, error) {
+		if entity == nil {
+			return "", ErrNoEntityAttachedToToken
+		}
+		return resolveDirective(entity, directive)
+	})
+	return true, out, err
+}
+
+// JSONInput is the identity a JSON template is populated with
+type JSONInput struct {
+	Entity *logical.Entity
+
+	// GroupIDs and GroupNames are the groups the entity is a member of
+	GroupIDs   []string
+	GroupNames []string
+}
+
+// PopulateJSON replaces all identity templating directives in the given JSON
+// template with the JSON encoding of the corresponding values, so that
+// directives are used as JSON values:
+//
+//	{"team": {{identity.entity.metadata.team}}, "groups": {{identity.groups.names}}}
+//
+// Besides the directives of PopulateString, the following are supported:
+//
+//	identity.entity.metadata     (the metadata of the entity as an object)
+//	identity.groups.ids          (the IDs of the groups of the entity)
+//	identity.groups.names        (the names of the groups of the entity)
+//
+// Directives without a value, such as missing metadata keys, are replaced
+// with null. The result is not checked to be valid JSON.
+func PopulateJSON(input JSONInput, tpl string) (string, error) {
+	return populate(tpl, func(directive string) (string, error) {
+		var value interface{}
+		switch directive {
+		case "identity.groups.ids":
+			value = nonNil(input.GroupIDs)
+		case "identity.groups.names":
+			value = nonNil(input.GroupNames)
+		default:
+			if input.Entity == nil {
+				return "", ErrNoEntityAttachedToToken
+			}
+			if directive == "identity.entity.metadata" {
+				value = input.Entity.Metadata
+				if value == nil {
+					value = map[string]string{}
+				}
+				break
+			}
+
+			resolved, err := resolveDirective(input.Entity, directive)
+			switch {
+			case err == ErrTemplateValueNotFound:
+				value = nil
+			case err != nil:
+				return "", err
+			default:
+				value = resolved
+			}
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	})
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// populate replaces each directive of the template with the value returned by
+// resolve
+func populate(tpl string, resolve func(directive string) (string, error)) (string, error) {
+	var b strings.Builder
+	rest := tpl
+	for {
+		start := strings.Index(rest, "{{")
+		end := strings.Index(rest, "}}")
+		if start == -1 && end == -1 {
+			b.WriteString(rest)
+			break
+		}
+		if start == -1 || end == -1 || end < start {
+			return "", ErrUnbalancedTemplatingCharacter
+		}
+
+		directive := strings.TrimSpace(rest[start+2 : end])
+		if strings.Contains(directive, "{{") {
+			return "", ErrUnbalancedTemplatingCharacter
+		}
+
+		value, err := resolve(directive)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(rest[:start])
+		b.WriteString(value)
+		rest = rest[end+2:]
+	}
+
+	return b.String(), nil
+}
+
+func resolveDirective(entity *logical.Entity, directive string) (string, error) {
+	const prefix = "identity.entity."
+	if !strings.HasPrefix(directive, prefix) {
+		return "", fmt.Errorf("unsupported template directive %q", directive)
+	}
+	attr := strings.TrimPrefix(directive, prefix)
+
+	switch {
+	case attr == "id":
+		return entity.ID, nil
+
+	case attr == "name":
+		if entity.Name == "" {
+			return "", ErrTemplateValueNotFound
+		}
+		return entity.Name, nil
+
+	case strings.HasPrefix(attr, "metadata."):
+		return lookupMetadata(entity.Metadata, strings.TrimPrefix(attr, "metadata."))
+
+	case strings.HasPrefix(attr, "aliases."):
+		// The mount accessor never contains dots, so split it off first
+		split := strings.SplitN(strings.TrimPrefix(attr, "aliases."), ".", 2)
+		if len(split) != 2 {
+			return "", fmt.Errorf("unsupported template directive %q", directive)
+		}
+
+		var alias *logical.Alias
+		for _, a := range entity.Aliases {
+			if a.MountAccessor == split[0] {
+				alias = a
+				break
+			}
+		}
+		if alias == nil {
+			return "", ErrTemplateValueNotFound
+		}
+
+		switch {
+		case split[1] == "name":
+			return alias.Name, nil
+		case strings.HasPrefix(split[1], "metadata."):
+			return lookupMetadata(alias.Metadata, strings.TrimPrefix(split[1], "metadata."))
+		}
+	}
+
+	return "", fmt.Errorf("unsupported template directive %q", directive)
+}
+
+func lookupMetadata(metadata map[string]string, key string) (string, error) {
+	value, ok := metadata[key]
+	if !ok {
+		return "", ErrTemplateValueNotFound
+	}
+	return value, nil
+}
@@ -0,0 +1,91 @@
+package identitytpl
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPopulateString(t *testing.T) {
+	entity := &logical.Entity{
+		ID:   "entityID",
+		Name: "entityName",
+		Metadata: map[string]string{
+			"team": "ops",
+		},
+		Aliases: []*logical.Alias{
+			{
+				MountAccessor: "auth_userpass_1234",
+				Name:          "jdoe",
+				Metadata: map[string]string{
+					"region": "eu",
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		tpl         string
+		entity      *logical.Entity
+		hasTemplate bool
+		expected    string
+		err         error
+	}{
+		{tpl: "plain", hasTemplate: false, expected: "plain"},
+		{tpl: "{{identity.entity.id}}", entity: entity, hasTemplate: true, expected: "entityID"},
+		{tpl: "user-{{ identity.entity.name }}", entity: entity, hasTemplate: true, expected: "user-entityName"},
+		{tpl: "{{identity.entity.metadata.team}}-admin", entity: entity, hasTemplate: true, expected: "ops-admin"},
+		{tpl: "{{identity.entity.aliases.auth_userpass_1234.name}}", entity: entity, hasTemplate: true, expected: "jdoe"},
+		{tpl: "{{identity.entity.aliases.auth_userpass_1234.name}}.{{identity.entity.aliases.auth_userpass_1234.metadata.region}}", entity: entity, hasTemplate: true, expected: "jdoe.eu"},
+		{tpl: "{{identity.entity.metadata.missing}}", entity: entity, hasTemplate: true, err: ErrTemplateValueNotFound},
+		{tpl: "{{identity.entity.aliases.auth_other.name}}", entity: entity, hasTemplate: true, err: ErrTemplateValueNotFound},
+		{tpl: "{{identity.entity.name}}", hasTemplate: true, err: ErrNoEntityAttachedToToken},
+		{tpl: "{{identity.entity.name", entity: entity, hasTemplate: true, err: ErrUnbalancedTemplatingCharacter},
+		{tpl: "identity.entity.name}}", entity: entity, hasTemplate: true, err: ErrUnbalancedTemplatingCharacter},
+	} {
+		hasTemplate, out, err := PopulateString(tc.entity, tc.tpl)
+		if hasTemplate != tc.hasTemplate {
+			t.Fatalf("%q: expected hasTemplate %t", tc.tpl, tc.hasTemplate)
+		}
+		if err != tc.err {
+			t.Fatalf("%q: expected error %v, got %v", tc.tpl, tc.err, err)
+		}
+		if out != tc.expected {
+			t.Fatalf("%q: expected %q, got %q", tc.tpl, tc.expected, out)
+		}
+	}
+
+	// Unknown directives are rejected
+	if _, _, err := PopulateString(entity, "{{identity.groups.names}}"); err == nil {
+		t.Fatal("expected error for unsupported directive")
+	}
+}
+
+func TestPopulateJSON(t *testing.T) {
+	input := JSONInput{
+		Entity: &logical.Entity{
+			ID:   "entityID",
+			Name: "entityName",
+			Metadata: map[string]string{
+				"team": "ops",
+			},
+		},
+		GroupNames: []string{"admins", "devs"},
+	}
+
+	out, err := PopulateJSON(input, `{"team": {{identity.entity.metadata.team}}, "missing": {{identity.entity.metadata.missing}}, "groups": {{identity.groups.names}}, "ids": {{identity.groups.ids}}, "metadata": {{identity.entity.metadata}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"team": "ops", "missing": null, "groups": ["admins","devs"], "ids": [], "metadata": {"team":"ops"}}`
+	if out != expected {
+		t.Fatalf("expected %s, got %s", expected, out)
+	}
+
+	if _, err := PopulateJSON(JSONInput{}, `{"name": {{identity.entity.name}}}`); err != ErrNoEntityAttachedToToken {
+		t.Fatalf("expected ErrNoEntityAttachedToToken, got %v", err)
+	}
+	if _, err := PopulateJSON(input, `{"name": {{identity.entity.unknown}}}`); err == nil {
+		t.Fatal("expected error for unsupported directive")
+	}
+}
@@ -0,0 +1,144 @@
+// Package queue provides a priority queue of keyed items, ordered by ascending
+// priority. It is safe for concurrent use.
+package queue
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrEmpty is returned when popping from an empty queue
+	ErrEmpty = errors.New("queue is empty")
+
+	// ErrDuplicateItem is returned when pushing an item whose key is already
+	// in the queue
+	ErrDuplicateItem = errors.New("duplicate item")
+
+	// ErrItemNotFound is returned when popping a key that is not in the queue
+	ErrItemNotFound = errors.New("item not found")
+)
+
+// Item is an element of the queue
+type Item struct {
+	// Key is the unique identifier of the item in the queue
+	Key string
+
+	// Value is the data held by the item
+	Value interface{}
+
+	// Priority orders the items in the queue; the item with the lowest
+	// priority is popped first. Using a Unix time makes the queue a
+	// scheduler of the items.
+	Priority int64
+
+	// index is maintained by the heap interface
+	index int
+}
+
+// PriorityQueue is a min-heap of items, indexed by their keys
+type PriorityQueue struct {
+	data    itemHeap
+	dataMap map[string]*Item
+
+	lock sync.RWMutex
+}
+
+// New returns an empty priority queue
+func New() *PriorityQueue {
+	return &PriorityQueue{
+		dataMap: make(map[string]*Item),
+	}
+}
+
+// Len returns the number of items in the queue
+func (pq *PriorityQueue) Len() int {
+	pq.lock.RLock()
+	defer pq.lock.RUnlock()
+
+	return len(pq.data)
+}
+
+// Push adds an item to the queue. Keys must be unique within the queue.
+func (pq *PriorityQueue) Push(i *Item) error {
+	if i == nil || i.Key == "" {
+		return errors.New("error adding item: item key is required")
+	}
+
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	if _, ok := pq.dataMap[i.Key]; ok {
+		return ErrDuplicateItem
+	}
+
+	// Copy the item so that the caller cannot modify the queue state
+	item := &Item{
+		Key:      i.Key,
+		Value:    i.Value,
+		Priority: i.Priority,
+	}
+	heap.Push(&pq.data, item)
+	pq.dataMap[item.Key] = item
+
+	return nil
+}
+
+// Pop removes and returns the item with the lowest priority
+func (pq *PriorityQueue) Pop() (*Item, error) {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	if len(pq.data) == 0 {
+		return nil, ErrEmpty
+	}
+
+	item := heap.Pop(&pq.data).(*Item)
+	delete(pq.dataMap, item.Key)
+	return item, nil
+}
+
+// PopByKey removes and returns the item with the given key
+func (pq *PriorityQueue) PopByKey(key string) (*Item, error) {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	item, ok := pq.dataMap[key]
+	if !ok {
+		return nil, ErrItemNotFound
+	}
+
+	heap.Remove(&pq.data, item.index)
+	delete(pq.dataMap, key)
+	return item, nil
+}
+
+// itemHeap implements heap.Interface; it is not safe for concurrent use
+type itemHeap []*Item
+
+func (h itemHeap) Len() int { return len(h) }
+
+func (h itemHeap) Less(i, j int) bool { return h[i].Priority < h[j].Priority }
+
+func (h itemHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *itemHeap) Push(x interface{}) {
+	item := x.(*Item)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *itemHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	pq := New()
+
+	if _, err := pq.Pop(); err != ErrEmpty {
+		t.Fatalf("expected empty queue error, got: %v", err)
+	}
+
+	for _, priority := range []int64{5, 1, 4, 2, 3} {
+		err := pq.Push(&Item{
+			Key:      fmt.Sprintf("item-%d", priority),
+			Value:    priority,
+			Priority: priority,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pq.Len() != 5 {
+		t.Fatalf("bad length: %d", pq.Len())
+	}
+
+	if err := pq.Push(&Item{Key: "item-3", Priority: 10}); err != ErrDuplicateItem {
+		t.Fatalf("expected duplicate item error, got: %v", err)
+	}
+
+	item, err := pq.PopByKey("item-4")
+	if err != nil || item.Value.(int64) != 4 {
+		t.Fatalf("bad: %#v, err: %v", item, err)
+	}
+	if _, err := pq.PopByKey("item-4"); err != ErrItemNotFound {
+		t.Fatalf("expected item not found error, got: %v", err)
+	}
+
+	for _, expected := range []int64{1, 2, 3, 5} {
+		item, err := pq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Priority != expected {
+			t.Fatalf("expected priority %d, got %d", expected, item.Priority)
+		}
+	}
+	if pq.Len() != 0 {
+		t.Fatalf("bad length: %d", pq.Len())
+	}
+}
@@ -0,0 +1,220 @@
+// Package random generates random strings, such as passwords, that satisfy a
+// policy of character set rules.
+package random
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// MaxLength is the maximum length of generated strings
+const MaxLength = 4096
+
+// CharsetRule requires generated strings to contain at least MinChars
+// characters of Charset
+type CharsetRule struct {
+	Charset  string `hcl:"charset"`
+	MinChars int    `hcl:"min_chars"`
+}
+
+// StringGenerator generates random strings of Length characters from the
+// union of the charsets of its rules
+type StringGenerator struct {
+	Length int            `hcl:"length"`
+	Rules  []*CharsetRule `hcl:"-"`
+
+	charset []rune
+}
+
+// ParsePolicy parses a password policy written in HCL, such as:
+//
+//	length = 20
+//
+//	rule "charset" {
+//	  charset   = "abcdefghijklmnopqrstuvwxyz"
+//	  min_chars = 1
+//	}
+//
+//	rule "charset" {
+//	  charset   = "0123456789"
+//	  min_chars = 1
+//	}
+func ParsePolicy(raw string) (*StringGenerator, error) {
+	root, err := hcl.Parse(raw)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to parse password policy: {{err}}", err)
+	}
+
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, errors.New("failed to parse password policy: does not contain a root object")
+	}
+	if err := checkHCLKeys(list, "length", "rule"); err != nil {
+		return nil, errwrap.Wrapf("failed to parse password policy: {{err}}", err)
+	}
+
+	g := &StringGenerator{}
+	if err := hcl.DecodeObject(g, list); err != nil {
+		return nil, errwrap.Wrapf("failed to parse password policy: {{err}}", err)
+	}
+
+	for _, item := range list.Filter("rule").Items {
+		ruleType := ""
+		if len(item.Keys) > 0 {
+			ruleType = item.Keys[0].Token.Value().(string)
+		}
+		if ruleType != "charset" {
+			return nil, fmt.Errorf("failed to parse password policy: unknown rule type %q", ruleType)
+		}
+
+		if err := checkHCLKeys(item.Val, "charset", "min_chars"); err != nil {
+			return nil, errwrap.Wrapf("failed to parse password policy: {{err}}", err)
+		}
+		var rule CharsetRule
+		if err := hcl.DecodeObject(&rule, item.Val); err != nil {
+			return nil, errwrap.Wrapf("failed to parse password policy: {{err}}", err)
+		}
+		g.Rules = append(g.Rules, &rule)
+	}
+
+	if err := g.validate(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func (g *StringGenerator) validate() error {
+	if g.Length <= 0 || g.Length > MaxLength {
+		return fmt.Errorf("length must be between 1 and %d", MaxLength)
+	}
+	if len(g.Rules) == 0 {
+		return errors.New("at least one charset rule is required")
+	}
+
+	minChars := 0
+	charset := make(map[rune]struct{})
+	for _, rule := range g.Rules {
+		if rule.Charset == "" {
+			return errors.New("charset rules require a charset")
+		}
+		if rule.MinChars < 0 {
+			return errors.New("min_chars must not be negative")
+		}
+		minChars += rule.MinChars
+
+		for _, r := range rule.Charset {
+			charset[r] = struct{}{}
+		}
+	}
+	if minChars > g.Length {
+		return fmt.Errorf("the min_chars of the rules add up to %d, more than the length of %d", minChars, g.Length)
+	}
+
+	g.charset = make([]rune, 0, len(charset))
+	for r := range charset {
+		g.charset = append(g.charset, r)
+	}
+	sort.Slice(g.charset, func(i, j int) bool { return g.charset[i] < g.charset[j] })
+
+	return nil
+}
+
+// Generate returns a random string satisfying the rules of the generator,
+// reading randomness from crypto/rand.
+func (g *StringGenerator) Generate() (string, error) {
+	return g.GenerateFrom(rand.Reader)
+}
+
+// GenerateFrom returns a random string satisfying the rules of the
+// generator, reading randomness from rng.
+func (g *StringGenerator) GenerateFrom(rng io.Reader) (string, error) {
+	if g.charset == nil {
+		if err := g.validate(); err != nil {
+			return "", err
+		}
+	}
+
+	// Pick the minimum characters of each rule first, fill the rest from
+	// all the charsets, then shuffle so the positions aren't predictable
+	result := make([]rune, 0, g.Length)
+	for _, rule := range g.Rules {
+		charset := []rune(rule.Charset)
+		for i := 0; i < rule.MinChars; i++ {
+			r, err := pick(rng, charset)
+			if err != nil {
+				return "", err
+			}
+			result = append(result, r)
+		}
+	}
+	for len(result) < g.Length {
+		r, err := pick(rng, g.charset)
+		if err != nil {
+			return "", err
+		}
+		result = append(result, r)
+	}
+
+	for i := len(result) - 1; i > 0; i-- {
+		j, err := randomInt(rng, i+1)
+		if err != nil {
+			return "", err
+		}
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return string(result), nil
+}
+
+func pick(rng io.Reader, charset []rune) (rune, error) {
+	i, err := randomInt(rng, len(charset))
+	if err != nil {
+		return 0, err
+	}
+	return charset[i], nil
+}
+
+func randomInt(rng io.Reader, max int) (int, error) {
+	n, err := rand.Int(rng, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, errwrap.Wrapf("unable to read random data: {{err}}", err)
+	}
+	return int(n.Int64()), nil
+}
+
+func checkHCLKeys(node ast.Node, valid ...string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf("invalid key %q on line %d", key, item.Assign.Line))
+		}
+	}
+
+	return result
+}
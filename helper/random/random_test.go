@@ -0,0 +1,71 @@
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	g, err := ParsePolicy(`
+length = 12
+
+rule "charset" {
+  charset   = "abc"
+  min_chars = 2
+}
+
+rule "charset" {
+  charset   = "0123456789"
+  min_chars = 3
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Length != 12 || len(g.Rules) != 2 || g.Rules[1].MinChars != 3 {
+		t.Fatalf("bad: %#v", g)
+	}
+
+	for i := 0; i < 100; i++ {
+		value, err := g.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(value) != 12 {
+			t.Fatalf("bad length: %q", value)
+		}
+		letters, digits := 0, 0
+		for _, r := range value {
+			switch {
+			case strings.ContainsRune("abc", r):
+				letters++
+			case strings.ContainsRune("0123456789", r):
+				digits++
+			default:
+				t.Fatalf("unexpected character in %q", value)
+			}
+		}
+		if letters < 2 || digits < 3 {
+			t.Fatalf("rules not satisfied by %q", value)
+		}
+	}
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"syntax":       `length = `,
+		"no rules":     `length = 10`,
+		"zero length":  `length = 0` + "\n" + `rule "charset" { charset = "a" }`,
+		"too long":     `length = 5000` + "\n" + `rule "charset" { charset = "a" }`,
+		"unknown key":  `length = 10` + "\n" + `foo = 1` + "\n" + `rule "charset" { charset = "a" }`,
+		"unknown rule": `length = 10` + "\n" + `rule "regex" { charset = "a" }`,
+		"rule key":     `length = 10` + "\n" + `rule "charset" { charset = "a" max_chars = 1 }`,
+		"empty":        `length = 10` + "\n" + `rule "charset" { min_chars = 1 }`,
+		"min chars":    `length = 2` + "\n" + `rule "charset" { charset = "a" min_chars = 3 }`,
+	}
+	for name, raw := range cases {
+		if _, err := ParsePolicy(raw); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package template renders user-provided Go templates, such as the username
+// templates of database connections, with a fixed set of helper functions.
+package template
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+)
+
+const randomCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+var ErrEmptyTemplate = errors.New("empty template")
+
+// StringTemplate is a parsed template that renders to a string. The
+// following functions are available to templates, in addition to the Go
+// template builtins:
+//
+//	random <length>                 random alphanumeric string
+//	truncate <length> <string>      string truncated to the given length
+//	truncate_sha256 <length> <str>  string truncated to the given length, with
+//	                                its last 8 characters replaced by a hash
+//	                                of the full string if it was truncated
+//	uppercase, lowercase <string>   string converted to upper or lower case
+//	replace <find> <replace> <str>  string with all occurrences replaced
+//	sha256 <string>                 hex-encoded SHA-256 hash of the string
+//	base64 <string>                 base64 encoding of the string
+//	unix_time, unix_time_millis     current time since the epoch
+//	timestamp <layout>              current UTC time in the given Go layout
+//	uuid                            random UUID
+type StringTemplate struct {
+	raw  string
+	tmpl *template.Template
+}
+
+// NewTemplate parses the given template
+func NewTemplate(raw string) (*StringTemplate, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, ErrEmptyTemplate
+	}
+
+	tmpl, err := template.New("template").
+		Funcs(funcMap()).
+		Option("missingkey=error").
+		Parse(raw)
+	if err != nil {
+		return nil, errwrap.Wrapf("unable to parse template: {{err}}", err)
+	}
+
+	return &StringTemplate{
+		raw:  raw,
+		tmpl: tmpl,
+	}, nil
+}
+
+// String returns the unparsed template
+func (t *StringTemplate) String() string {
+	return t.raw
+}
+
+// Generate renders the template with the given data
+func (t *StringTemplate) Generate(data interface{}) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", errwrap.Wrapf("unable to render template: {{err}}", err)
+	}
+
+	return b.String(), nil
+}
+
+func funcMap() template.FuncMap {
+	return template.FuncMap{
+		"random":           random,
+		"truncate":         truncate,
+		"truncate_sha256":  truncateSHA256,
+		"uppercase":        strings.ToUpper,
+		"lowercase":        strings.ToLower,
+		"replace":          replace,
+		"sha256":           hashSHA256,
+		"base64":           encodeBase64,
+		"unix_time":        unixTime,
+		"unix_time_millis": unixTimeMillis,
+		"timestamp":        timestamp,
+		"uuid":             uuid.GenerateUUID,
+	}
+}
+
+func random(length int) (string, error) {
+	if length <= 0 {
+		return "", fmt.Errorf("random length must be positive, got %d", length)
+	}
+
+	max := big.NewInt(int64(len(randomCharset)))
+	result := make([]byte, length)
+	for i := range result {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result[i] = randomCharset[n.Int64()]
+	}
+
+	return string(result), nil
+}
+
+func truncate(maxLen int, s string) (string, error) {
+	if maxLen < 0 {
+		return "", fmt.Errorf("truncate length must not be negative, got %d", maxLen)
+	}
+	if len(s) > maxLen {
+		return s[:maxLen], nil
+	}
+
+	return s, nil
+}
+
+func truncateSHA256(maxLen int, s string) (string, error) {
+	if maxLen <= 8 {
+		return "", fmt.Errorf("truncate_sha256 length must be greater than 8, got %d", maxLen)
+	}
+	if len(s) <= maxLen {
+		return s, nil
+	}
+
+	return s[:maxLen-8] + hashSHA256(s)[:8], nil
+}
+
+func replace(find, replacement, s string) string {
+	return strings.Replace(s, find, replacement, -1)
+}
+
+func hashSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func encodeBase64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func unixTime() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+func unixTimeMillis() string {
+	return strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+}
+
+func timestamp(layout string) string {
+	return time.Now().UTC().Format(layout)
+}
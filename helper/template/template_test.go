@@ -0,0 +1,94 @@
+package template
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStringTemplate_Generate(t *testing.T) {
+	data := struct {
+		DisplayName string
+		RoleName    string
+	}{
+		DisplayName: "token-displayname",
+		RoleName:    "readonly",
+	}
+
+	for _, tc := range []struct {
+		name     string
+		template string
+		expected *regexp.Regexp
+	}{
+		{"literal", "static", regexp.MustCompile(`^static$`)},
+		{"fields", "{{.DisplayName}}_{{.RoleName}}", regexp.MustCompile(`^token-displayname_readonly$`)},
+		{"truncate", "{{.DisplayName | truncate 5}}", regexp.MustCompile(`^token$`)},
+		{"truncate short", "{{.RoleName | truncate 50}}", regexp.MustCompile(`^readonly$`)},
+		{"truncate_sha256", "{{.DisplayName | truncate_sha256 12}}", regexp.MustCompile(`^toke[0-9a-f]{8}$`)},
+		{"random", "{{random 20}}", regexp.MustCompile(`^[A-Za-z0-9]{20}$`)},
+		{"case", "{{.RoleName | uppercase}}-{{\"ABC\" | lowercase}}", regexp.MustCompile(`^READONLY-abc$`)},
+		{"replace", "{{.DisplayName | replace \"-\" \"_\"}}", regexp.MustCompile(`^token_displayname$`)},
+		{"sha256", "{{sha256 \"abc\"}}", regexp.MustCompile(`^ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad$`)},
+		{"base64", "{{base64 \"abc\"}}", regexp.MustCompile(`^YWJj$`)},
+		{"uuid", "{{uuid}}", regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)},
+		{"timestamp", "{{timestamp \"2006\"}}", regexp.MustCompile(`^[0-9]{4}$`)},
+		{
+			"username",
+			`{{printf "v-%s-%s-%s-%s" (.DisplayName | truncate 8) (.RoleName | truncate 8) (random 20) (unix_time) | truncate 63}}`,
+			regexp.MustCompile(`^v-token-di-readonly-[A-Za-z0-9]{20}-[0-9]+$`),
+		},
+	} {
+		tmpl, err := NewTemplate(tc.template)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if tmpl.String() != tc.template {
+			t.Fatalf("%s: bad raw template: %q", tc.name, tmpl.String())
+		}
+		result, err := tmpl.Generate(data)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.expected.MatchString(result) {
+			t.Fatalf("%s: result %q does not match %s", tc.name, result, tc.expected)
+		}
+	}
+}
+
+func TestStringTemplate_UnixTime(t *testing.T) {
+	tmpl, err := NewTemplate("{{unix_time}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tmpl.Generate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := strconv.ParseInt(result, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := time.Now().Unix() - parsed; diff < 0 || diff > 5 {
+		t.Fatalf("bad unix time: %d", parsed)
+	}
+}
+
+func TestStringTemplate_Errors(t *testing.T) {
+	for _, raw := range []string{"", "  ", "{{.DisplayName", "{{unknown_func}}"} {
+		if _, err := NewTemplate(raw); err == nil {
+			t.Fatalf("expected parse error for %q", raw)
+		}
+	}
+
+	data := map[string]string{"RoleName": "readonly"}
+	for _, raw := range []string{"{{.Missing}}", "{{random 0}}", "{{.RoleName | truncate_sha256 4}}"} {
+		tmpl, err := NewTemplate(raw)
+		if err != nil {
+			t.Fatalf("%q: unexpected parse error: %v", raw, err)
+		}
+		if _, err := tmpl.Generate(data); err == nil {
+			t.Fatalf("expected error rendering %q", raw)
+		}
+	}
+}
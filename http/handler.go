@@ -226,6 +226,12 @@ func wrappingVerificationFunc(core *vault.Core, req *logical.Request) error {
 		return fmt.Errorf("invalid request")
 	}
 
+	// Looking up the history of a wrapping token is done by its creator
+	// using their own token, which is validated by the lookup itself
+	if req.Path == "sys/wrapping/lookup" && req.Data != nil && req.Data["accessor"] != nil {
+		return nil
+	}
+
 	valid, err := core.ValidateWrappingToken(req)
 	if err != nil {
 		return errwrap.Wrapf("error validating wrapping token: {{err}}", err)
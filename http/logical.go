@@ -3,9 +3,13 @@ package http
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"net"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +20,18 @@ import (
 	"github.com/hashicorp/vault/vault"
 )
 
+// mergePatchContentType is the content type of the JSON merge patch
+// documents sent with PATCH requests
+const mergePatchContentType = "application/merge-patch+json"
+
+// estEnrollPathRe matches the EST enrollment endpoints of the PKI backend,
+// which are sent a raw PKCS#10 request rather than JSON
+var estEnrollPathRe = regexp.MustCompile(`/est/([^/]+/)?simple(re)?enroll$`)
+
+// oidcTokenPathRe matches the token endpoints of the OIDC providers of the
+// identity store, which are sent URL-encoded forms by OAuth 2.0 clients
+var oidcTokenPathRe = regexp.MustCompile(`^identity/oidc/provider/[^/]+/token$`)
+
 type PrepareRequestFunc func(*vault.Core, *logical.Request) error
 
 func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Request) (*logical.Request, int, error) {
@@ -49,6 +65,8 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 		}
 	case "POST", "PUT":
 		op = logical.UpdateOperation
+	case "PATCH":
+		op = logical.PatchOperation
 	case "LIST":
 		op = logical.ListOperation
 	case "OPTIONS":
@@ -65,7 +83,33 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	// Parse the request if we can
 	var data map[string]interface{}
 	if op == logical.UpdateOperation {
-		err := parseRequest(r, w, &data)
+		var err error
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case contentType == "application/pkcs10" && estEnrollPathRe.MatchString(path):
+			data, err = parsePKCS10Request(r, w)
+		case contentType == "application/x-www-form-urlencoded" && oidcTokenPathRe.MatchString(path):
+			data, err = parseFormRequest(r, w)
+		default:
+			err = parseRequest(r, w, &data)
+		}
+		if err == io.EOF {
+			data = nil
+			err = nil
+		}
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+
+	// Patch operations only accept JSON merge patch (RFC 7386) documents
+	if op == logical.PatchOperation {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != mergePatchContentType {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("PATCH requires the %q content type", mergePatchContentType)
+		}
+
+		err = parseRequest(r, w, &data)
 		if err == io.EOF {
 			data = nil
 			err = nil
@@ -122,6 +166,49 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	return req, 0, nil
 }
 
+// parsePKCS10Request reads a base64-encoded PKCS#10 certificate request body,
+// as sent by EST (RFC 7030) clients, and places it in the "csr" field of the
+// request data.
+func parsePKCS10Request(r *http.Request, w http.ResponseWriter) (map[string]interface{}, error) {
+	limit := http.MaxBytesReader(w, r.Body, MaxRequestSize)
+	body, err := ioutil.ReadAll(limit)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read PKCS#10 input: {{err}}", err)
+	}
+	if len(body) == 0 {
+		return nil, io.EOF
+	}
+
+	return map[string]interface{}{
+		"csr": strings.TrimSpace(string(body)),
+	}, nil
+}
+
+// parseFormRequest reads a URL-encoded form body, as sent by OAuth 2.0
+// clients, into the request data. Fields given more than once are kept as
+// lists.
+func parseFormRequest(r *http.Request, w http.ResponseWriter) (map[string]interface{}, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestSize)
+	if err := r.ParseForm(); err != nil {
+		return nil, errwrap.Wrapf("failed to parse form input: {{err}}", err)
+	}
+	if len(r.PostForm) == 0 {
+		return nil, io.EOF
+	}
+
+	data := make(map[string]interface{}, len(r.PostForm))
+	for k, v := range r.PostForm {
+		switch len(v) {
+		case 0:
+		case 1:
+			data[k] = v[0]
+		default:
+			data[k] = v
+		}
+	}
+	return data, nil
+}
+
 func handleLogical(core *vault.Core, injectDataIntoTopLevel bool, prepareRequestCallback PrepareRequestFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		req, statusCode, err := buildLogicalRequest(core, w, r)
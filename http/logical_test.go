@@ -7,12 +7,14 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/go-cleanhttp"
 	log "github.com/hashicorp/go-hclog"
 
 	"github.com/hashicorp/vault/helper/logging"
@@ -265,6 +267,70 @@ func TestLogical_RequestSizeLimit(t *testing.T) {
 	testResponseStatus(t, resp, 413)
 }
 
+func TestLogical_FormRequest(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Add("list", "a")
+	form.Add("list", "b")
+
+	// Forms are only parsed for the token endpoints of the OIDC providers
+	req, err := http.NewRequest("POST", addr+"/v1/identity/oidc/provider/test/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	lreq, status, err := buildLogicalRequest(core, httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatalf("err: %v status: %d", err, status)
+	}
+	expected := map[string]interface{}{
+		"grant_type": "authorization_code",
+		"list":       []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(lreq.Data, expected) {
+		t.Fatalf("bad: %#v", lreq.Data)
+	}
+
+	req, err = http.NewRequest("POST", addr+"/v1/secret/foo", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(AuthHeaderName, token)
+	resp, err := cleanhttp.DefaultClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testResponseStatus(t, resp, 400)
+
+	// JSON sent with the default content type of curl --data is still
+	// parsed as JSON
+	req, err = http.NewRequest("POST", addr+"/v1/secret/foo", strings.NewReader(`{"data": "bar"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(AuthHeaderName, token)
+	resp, err = cleanhttp.DefaultClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpGet(t, token, addr+"/v1/secret/foo")
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if !reflect.DeepEqual(actual["data"], map[string]interface{}{"data": "bar"}) {
+		t.Fatalf("bad: %#v", actual["data"])
+	}
+}
+
 func TestLogical_ListSuffix(t *testing.T) {
 	core, _, _ := vault.TestCoreUnsealed(t)
 	req, _ := http.NewRequest("GET", "http://127.0.0.1:8200/v1/secret/foo", nil)
@@ -304,6 +370,51 @@ func TestLogical_ListSuffix(t *testing.T) {
 	}
 }
 
+func TestLogical_Patch(t *testing.T) {
+	core, _, _ := vault.TestCoreUnsealed(t)
+
+	// PATCH requests must be JSON merge patch documents
+	req, _ := http.NewRequest("PATCH", "http://127.0.0.1:8200/v1/secret/foo", strings.NewReader(`{"foo": "bar"}`))
+	_, status, err := buildLogicalRequest(core, nil, req)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if status != http.StatusUnsupportedMediaType {
+		t.Fatalf("got status %d", status)
+	}
+
+	req, _ = http.NewRequest("PATCH", "http://127.0.0.1:8200/v1/secret/foo", strings.NewReader(`{"foo": "bar", "baz": null}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	lreq, status, err := buildLogicalRequest(core, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0 {
+		t.Fatalf("got status %d", status)
+	}
+	if lreq.Operation != logical.PatchOperation {
+		t.Fatalf("bad operation: %s", lreq.Operation)
+	}
+	expected := map[string]interface{}{
+		"foo": "bar",
+		"baz": nil,
+	}
+	if !reflect.DeepEqual(lreq.Data, expected) {
+		t.Fatalf("bad: %#v", lreq.Data)
+	}
+
+	// Media type parameters are allowed
+	req, _ = http.NewRequest("PATCH", "http://127.0.0.1:8200/v1/secret/foo", strings.NewReader(`{"foo": "bar"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json; charset=utf-8")
+	lreq, _, err = buildLogicalRequest(core, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lreq.Data, map[string]interface{}{"foo": "bar"}) {
+		t.Fatalf("bad: %#v", lreq.Data)
+	}
+}
+
 func TestLogical_RespondWithStatusCode(t *testing.T) {
 	resp := &logical.Response{
 		Data: map[string]interface{}{
@@ -2,6 +2,8 @@ package http
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
@@ -352,3 +354,162 @@ func TestHTTP_Wrapping(t *testing.T) {
 		t.Fatalf("secret data did not match expected: %#v", secret.Data)
 	}
 }
+
+func TestHTTP_WrappingHistory(t *testing.T) {
+	cluster := vault.NewTestCluster(t, &vault.CoreConfig{}, &vault.TestClusterOptions{
+		HandlerFunc: Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0].Core
+	vault.TestWaitActive(t, core)
+
+	client := cluster.Cores[0].Client
+	client.SetToken(cluster.RootToken)
+
+	// Receive the unwrap events on a callback
+	callbackCh := make(chan map[string]interface{}, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]interface{}
+		if err := jsonutil.DecodeJSONFromReader(r.Body, &event); err != nil {
+			t.Error(err)
+		}
+		callbackCh <- event
+	}))
+	defer callback.Close()
+
+	if _, err := client.Logical().Write("sys/config/wrapping", map[string]interface{}{
+		"history_retention":   "1h",
+		"unwrap_callback_url": callback.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("sys/config/wrapping", map[string]interface{}{
+		"unwrap_callback_url": "ftp://example.com",
+	}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"zip": "zap",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a token that is not the creator of the wrapping token, but may
+	// rewrap it
+	if err := client.Sys().PutPolicy("rewrap", `path "sys/wrapping/rewrap" { capabilities = ["update"] }`); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := client.Auth().Token().Create(&api.TokenCreateRequest{
+		Policies: []string{"default", "rewrap"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherClient, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherClient.SetToken(secret.Auth.ClientToken)
+	otherAccessor := secret.Auth.Accessor
+
+	client.SetWrappingLookupFunc(func(operation, path string) string {
+		if operation == "GET" && path == "secret/foo" {
+			return "5m"
+		}
+		return api.DefaultWrappingLookupFunc(operation, path)
+	})
+	secret, err = client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.WrapInfo == nil {
+		t.Fatal("secret or wrap info is nil")
+	}
+	originalWrapInfo := secret.WrapInfo
+	client.SetWrappingLookupFunc(nil)
+
+	// Rewrap, look up then unwrap the wrapping token as the other token
+	secret, err = otherClient.Logical().Write("sys/wrapping/rewrap", map[string]interface{}{
+		"token": originalWrapInfo.Token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.WrapInfo == nil {
+		t.Fatal("secret or wrap info is nil")
+	}
+	wrapInfo := secret.WrapInfo
+
+	if _, err := otherClient.Logical().Write("sys/wrapping/lookup", map[string]interface{}{
+		"token": wrapInfo.Token,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := otherClient.Logical().Unwrap(wrapInfo.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-callbackCh:
+		if event["type"] != "unwrap" || event["wrapping_accessor"] != wrapInfo.Accessor || event["accessor"] != otherAccessor {
+			t.Fatalf("bad callback event: %#v", event)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the unwrap callback")
+	}
+
+	// Only the creator can read the history
+	if _, err := otherClient.Logical().Write("sys/wrapping/lookup", map[string]interface{}{
+		"accessor": wrapInfo.Accessor,
+	}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	secret, err = client.Logical().Write("sys/wrapping/lookup", map[string]interface{}{
+		"accessor": wrapInfo.Accessor,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.Data == nil {
+		t.Fatal("secret or secret data is nil")
+	}
+	if secret.Data["creation_path"] != "secret/foo" {
+		t.Fatalf("bad creation path: %v", secret.Data["creation_path"])
+	}
+
+	// The history of the rewrapped token is carried over
+	events := secret.Data["events"].([]interface{})
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %#v", events)
+	}
+	for i, expected := range []string{"rewrap", "lookup", "unwrap"} {
+		event := events[i].(map[string]interface{})
+		if event["type"] != expected {
+			t.Fatalf("bad event type: expected %q, got %v", expected, event["type"])
+		}
+		if event["accessor"] != otherAccessor {
+			t.Fatalf("bad event accessor: expected %q, got %v", otherAccessor, event["accessor"])
+		}
+		if event["remote_addr"] == "" {
+			t.Fatal("missing event remote address")
+		}
+	}
+
+	secret, err = client.Logical().Write("sys/wrapping/lookup", map[string]interface{}{
+		"accessor": originalWrapInfo.Accessor,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.Data == nil {
+		t.Fatal("secret or secret data is nil")
+	}
+	events = secret.Data["events"].([]interface{})
+	if len(events) != 1 || events[0].(map[string]interface{})["type"] != "rewrap" {
+		t.Fatalf("bad events of the rewrapped token: %#v", events)
+	}
+}
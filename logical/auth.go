@@ -58,6 +58,10 @@ type Auth struct {
 	// Number of allowed uses of the issued token
 	NumUses int `json:"num_uses" mapstructure:"num_uses" structs:"num_uses"`
 
+	// NoDefaultPolicy, if set, prevents the 'default' policy from being
+	// added to the policies of the issued token
+	NoDefaultPolicy bool `json:"no_default_policy" mapstructure:"no_default_policy" structs:"no_default_policy"`
+
 	// EntityID is the identifier of the entity in identity store to which the
 	// identity of the authenticating client belongs to.
 	EntityID string `json:"entity_id" mapstructure:"entity_id" structs:"entity_id"`
@@ -80,8 +84,33 @@ type Auth struct {
 	// change the perceived path of the lease, even though they don't change
 	// the request path itself.
 	CreationPath string `json:"creation_path"`
+
+	// MFARequirement is set by Vault core instead of a token when the login
+	// must be completed with login MFA. Setting this manually will have no
+	// effect.
+	MFARequirement *MFARequirement `json:"mfa_requirement"`
 }
 
 func (a *Auth) GoString() string {
 	return fmt.Sprintf("*%#v", *a)
 }
+
+// MFARequirement describes the login MFA validations required to complete a
+// login. The login is completed by validating, for each constraint, any of
+// its methods at sys/mfa/validate with the MFA request ID.
+type MFARequirement struct {
+	MFARequestID   string                       `json:"mfa_request_id"`
+	MFAConstraints map[string]*MFAConstraintAny `json:"mfa_constraints"`
+}
+
+// MFAConstraintAny is satisfied by validating any of its methods
+type MFAConstraintAny struct {
+	Any []*MFAMethodID `json:"any"`
+}
+
+// MFAMethodID identifies a login MFA method
+type MFAMethodID struct {
+	Type         string `json:"type"`
+	ID           string `json:"id"`
+	UsesPasscode bool   `json:"uses_passcode"`
+}
@@ -0,0 +1,280 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	sockaddr "github.com/hashicorp/go-sockaddr"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// The token types that can be set with the token_type field. Only
+	// service tokens are issued, "default" leaves the choice to Vault.
+	TokenTypeDefault = "default"
+	TokenTypeService = "service"
+)
+
+// TokenParams are the standard parameters of the tokens issued by the
+// credential backends. It is embedded in the storage entries of the roles,
+// users or configuration from which the backends issue tokens.
+type TokenParams struct {
+	// The set of CIDRs that tokens generated using this role will be bound to
+	TokenBoundCIDRs []*sockaddr.SockAddrMarshaler `json:"token_bound_cidrs" mapstructure:"token_bound_cidrs"`
+
+	// If set, the token entry will have an explicit maximum TTL set, rather
+	// than deferring to role/mount values
+	TokenExplicitMaxTTL time.Duration `json:"token_explicit_max_ttl" mapstructure:"token_explicit_max_ttl"`
+
+	// The max TTL to use for the token
+	TokenMaxTTL time.Duration `json:"token_max_ttl" mapstructure:"token_max_ttl"`
+
+	// If set, core will not automatically add default to the policy list
+	TokenNoDefaultPolicy bool `json:"token_no_default_policy" mapstructure:"token_no_default_policy"`
+
+	// The maximum number of times a token issued from this role may be used.
+	TokenNumUses int `json:"token_num_uses" mapstructure:"token_num_uses"`
+
+	// If non-zero, tokens created using this role will be able to be renewed
+	// forever, but will have a fixed renewal period of this value
+	TokenPeriod time.Duration `json:"token_period" mapstructure:"token_period"`
+
+	// The policies to set
+	TokenPolicies []string `json:"token_policies" mapstructure:"token_policies"`
+
+	// The type of token this role should issue
+	TokenType string `json:"token_type" mapstructure:"token_type"`
+
+	// The TTL to use for the token
+	TokenTTL time.Duration `json:"token_ttl" mapstructure:"token_ttl"`
+}
+
+// AddTokenFields adds the standard token fields to a field schema map
+func AddTokenFields(m map[string]*FieldSchema) {
+	for k, v := range TokenFields() {
+		if _, ok := m[k]; ok {
+			panic(fmt.Sprintf("adding field %q would overwrite existing field", k))
+		}
+		m[k] = v
+	}
+}
+
+// TokenFields returns the standard token fields
+func TokenFields() map[string]*FieldSchema {
+	return map[string]*FieldSchema{
+		"token_bound_cidrs": &FieldSchema{
+			Type:        TypeCommaStringSlice,
+			Description: `Comma separated string or JSON list of CIDR blocks. If set, specifies the blocks of IP addresses which are allowed to use the generated token.`,
+		},
+
+		"token_explicit_max_ttl": &FieldSchema{
+			Type:        TypeDurationSecond,
+			Description: tokenExplicitMaxTTLHelp,
+		},
+
+		"token_max_ttl": &FieldSchema{
+			Type:        TypeDurationSecond,
+			Description: "The maximum lifetime of the generated token",
+		},
+
+		"token_no_default_policy": &FieldSchema{
+			Type:        TypeBool,
+			Description: "If true, the 'default' policy will not automatically be added to generated tokens",
+		},
+
+		"token_period": &FieldSchema{
+			Type:        TypeDurationSecond,
+			Description: tokenPeriodHelp,
+		},
+
+		"token_policies": &FieldSchema{
+			Type:        TypeCommaStringSlice,
+			Description: "Comma-separated list of policies",
+		},
+
+		"token_type": &FieldSchema{
+			Type:        TypeString,
+			Default:     TokenTypeDefault,
+			Description: `The type of token to generate, "service" or "default". Only service tokens can be generated.`,
+		},
+
+		"token_ttl": &FieldSchema{
+			Type:        TypeDurationSecond,
+			Description: "The initial ttl of the token to generate",
+		},
+
+		"token_num_uses": &FieldSchema{
+			Type:        TypeInt,
+			Description: "The maximum number of times a token may be used, a value of zero means unlimited",
+		},
+	}
+}
+
+// ParseTokenFields sets the token parameters from the fields of a request.
+// Fields that are not set in the request are left unchanged.
+func (t *TokenParams) ParseTokenFields(req *logical.Request, d *FieldData) error {
+	if boundCIDRsRaw, ok := d.GetOk("token_bound_cidrs"); ok {
+		boundCIDRs, err := parseutil.ParseAddrs(boundCIDRsRaw.([]string))
+		if err != nil {
+			return err
+		}
+		t.TokenBoundCIDRs = boundCIDRs
+	}
+
+	if explicitMaxTTLRaw, ok := d.GetOk("token_explicit_max_ttl"); ok {
+		t.TokenExplicitMaxTTL = time.Duration(explicitMaxTTLRaw.(int)) * time.Second
+	}
+
+	if maxTTLRaw, ok := d.GetOk("token_max_ttl"); ok {
+		t.TokenMaxTTL = time.Duration(maxTTLRaw.(int)) * time.Second
+	}
+	if t.TokenMaxTTL < 0 {
+		return errors.New("'token_max_ttl' cannot be negative")
+	}
+
+	if noDefaultRaw, ok := d.GetOk("token_no_default_policy"); ok {
+		t.TokenNoDefaultPolicy = noDefaultRaw.(bool)
+	}
+
+	if periodRaw, ok := d.GetOk("token_period"); ok {
+		t.TokenPeriod = time.Duration(periodRaw.(int)) * time.Second
+	}
+	if t.TokenPeriod < 0 {
+		return errors.New("'token_period' cannot be negative")
+	}
+
+	if policiesRaw, ok := d.GetOk("token_policies"); ok {
+		t.TokenPolicies = policyutil.ParsePolicies(policiesRaw)
+	}
+
+	if tokenTypeRaw, ok := d.GetOk("token_type"); ok {
+		t.TokenType = tokenTypeRaw.(string)
+	} else if req.Operation == logical.CreateOperation || t.TokenType == "" {
+		t.TokenType = d.Get("token_type").(string)
+	}
+	switch t.TokenType {
+	case TokenTypeDefault, TokenTypeService:
+	default:
+		return fmt.Errorf("invalid 'token_type' value %q", t.TokenType)
+	}
+
+	if ttlRaw, ok := d.GetOk("token_ttl"); ok {
+		t.TokenTTL = time.Duration(ttlRaw.(int)) * time.Second
+	}
+	if t.TokenTTL < 0 {
+		return errors.New("'token_ttl' cannot be negative")
+	}
+	if t.TokenTTL > 0 && t.TokenMaxTTL > 0 && t.TokenTTL > t.TokenMaxTTL {
+		return errors.New("'token_ttl' cannot be greater than 'token_max_ttl'")
+	}
+
+	if tokenNumUses, ok := d.GetOk("token_num_uses"); ok {
+		t.TokenNumUses = tokenNumUses.(int)
+	}
+	if t.TokenNumUses < 0 {
+		return errors.New("'token_num_uses' cannot be negative")
+	}
+
+	return nil
+}
+
+// ParseTokenFieldAliases sets the token parameters from the deprecated fields
+// of a backend aliasing the token fields. The aliases map the deprecated field
+// names to the token field names, and the deprecated fields must have the
+// same type as the token fields. It must be called before ParseTokenFields,
+// which validates the resulting parameters.
+func (t *TokenParams) ParseTokenFieldAliases(d *FieldData, aliases map[string]string) error {
+	for alias, field := range aliases {
+		raw, ok := d.GetOk(alias)
+		if !ok {
+			continue
+		}
+		if _, ok := d.GetOk(field); ok {
+			return fmt.Errorf("%q and %q cannot both be set", alias, field)
+		}
+
+		switch field {
+		case "token_bound_cidrs":
+			boundCIDRs, err := parseutil.ParseAddrs(raw.([]string))
+			if err != nil {
+				return err
+			}
+			t.TokenBoundCIDRs = boundCIDRs
+		case "token_explicit_max_ttl":
+			t.TokenExplicitMaxTTL = time.Duration(raw.(int)) * time.Second
+		case "token_max_ttl":
+			t.TokenMaxTTL = time.Duration(raw.(int)) * time.Second
+		case "token_num_uses":
+			t.TokenNumUses = raw.(int)
+		case "token_period":
+			t.TokenPeriod = time.Duration(raw.(int)) * time.Second
+		case "token_policies":
+			t.TokenPolicies = policyutil.ParsePolicies(raw)
+		case "token_ttl":
+			t.TokenTTL = time.Duration(raw.(int)) * time.Second
+		default:
+			return fmt.Errorf("%q is not an aliasable token field", field)
+		}
+	}
+	return nil
+}
+
+// PopulateTokenData adds the token parameters to the data of a read response
+func (t *TokenParams) PopulateTokenData(m map[string]interface{}) {
+	boundCIDRs := make([]string, len(t.TokenBoundCIDRs))
+	for i, cidr := range t.TokenBoundCIDRs {
+		boundCIDRs[i] = cidr.String()
+	}
+
+	policies := t.TokenPolicies
+	if policies == nil {
+		policies = []string{}
+	}
+
+	m["token_bound_cidrs"] = boundCIDRs
+	m["token_explicit_max_ttl"] = int64(t.TokenExplicitMaxTTL.Seconds())
+	m["token_max_ttl"] = int64(t.TokenMaxTTL.Seconds())
+	m["token_no_default_policy"] = t.TokenNoDefaultPolicy
+	m["token_period"] = int64(t.TokenPeriod.Seconds())
+	m["token_policies"] = policies
+	m["token_type"] = t.tokenType()
+	m["token_ttl"] = int64(t.TokenTTL.Seconds())
+	m["token_num_uses"] = t.TokenNumUses
+}
+
+// PopulateTokenAuth sets the token parameters on the auth of a login or a
+// renewal response
+func (t *TokenParams) PopulateTokenAuth(auth *logical.Auth) {
+	auth.BoundCIDRs = t.TokenBoundCIDRs
+	auth.ExplicitMaxTTL = t.TokenExplicitMaxTTL
+	auth.MaxTTL = t.TokenMaxTTL
+	auth.NoDefaultPolicy = t.TokenNoDefaultPolicy
+	auth.NumUses = t.TokenNumUses
+	auth.Period = t.TokenPeriod
+	auth.Policies = t.TokenPolicies
+	auth.TTL = t.TokenTTL
+}
+
+func (t *TokenParams) tokenType() string {
+	if t.TokenType == "" {
+		return TokenTypeDefault
+	}
+	return t.TokenType
+}
+
+const (
+	tokenPeriodHelp = `If set, tokens created via this role
+will have no max lifetime; instead, their
+renewal period will be fixed to this value.
+This takes an integer number of seconds,
+or a string duration (e.g. "24h").`
+	tokenExplicitMaxTTLHelp = `If set, tokens created via this role
+carry an explicit maximum TTL. During renewal,
+the current maximum TTL values of the role
+and the mount are not checked for changes,
+and any updates to these values will have
+no effect on the token being renewed.`
+)
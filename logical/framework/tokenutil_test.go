@@ -0,0 +1,183 @@
+package framework
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTokenParams_ParseTokenFields(t *testing.T) {
+	cases := map[string]struct {
+		Raw      map[string]interface{}
+		Expected TokenParams
+		Err      bool
+	}{
+		"defaults": {
+			map[string]interface{}{},
+			TokenParams{
+				TokenType: TokenTypeDefault,
+			},
+			false,
+		},
+
+		"all fields": {
+			map[string]interface{}{
+				"token_bound_cidrs":       "127.0.0.0/8",
+				"token_explicit_max_ttl":  "3h",
+				"token_max_ttl":           "2h",
+				"token_no_default_policy": true,
+				"token_num_uses":          5,
+				"token_period":            "30m",
+				"token_policies":          "foo,bar",
+				"token_type":              "service",
+				"token_ttl":               "1h",
+			},
+			TokenParams{
+				TokenExplicitMaxTTL:  3 * time.Hour,
+				TokenMaxTTL:          2 * time.Hour,
+				TokenNoDefaultPolicy: true,
+				TokenNumUses:         5,
+				TokenPeriod:          30 * time.Minute,
+				TokenPolicies:        []string{"bar", "foo"},
+				TokenType:            TokenTypeService,
+				TokenTTL:             time.Hour,
+			},
+			false,
+		},
+
+		"ttl greater than max ttl": {
+			map[string]interface{}{
+				"token_ttl":     "2h",
+				"token_max_ttl": "1h",
+			},
+			TokenParams{},
+			true,
+		},
+
+		"negative num uses": {
+			map[string]interface{}{
+				"token_num_uses": -1,
+			},
+			TokenParams{},
+			true,
+		},
+
+		"invalid token type": {
+			map[string]interface{}{
+				"token_type": "batch",
+			},
+			TokenParams{},
+			true,
+		},
+
+		"invalid cidr": {
+			map[string]interface{}{
+				"token_bound_cidrs": "not a cidr",
+			},
+			TokenParams{},
+			true,
+		},
+	}
+
+	for name, tc := range cases {
+		data := &FieldData{
+			Raw:    tc.Raw,
+			Schema: TokenFields(),
+		}
+
+		var actual TokenParams
+		err := actual.ParseTokenFields(&logical.Request{Operation: logical.CreateOperation}, data)
+		if (err != nil) != tc.Err {
+			t.Fatalf("bad: %s\n\nerr: %v", name, err)
+		}
+		if tc.Err {
+			continue
+		}
+
+		// CIDRs are compared on their string representation
+		if cidrs, ok := tc.Raw["token_bound_cidrs"]; ok {
+			if len(actual.TokenBoundCIDRs) != 1 || actual.TokenBoundCIDRs[0].String() != cidrs {
+				t.Fatalf("bad: %s\n\nbound cidrs: %#v", name, actual.TokenBoundCIDRs)
+			}
+			actual.TokenBoundCIDRs = nil
+		}
+
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("bad: %s\n\nexpected: %#v\n\nactual: %#v", name, tc.Expected, actual)
+		}
+	}
+}
+
+func TestTokenParams_ParseTokenFieldAliases(t *testing.T) {
+	schema := TokenFields()
+	schema["policies"] = &FieldSchema{Type: TypeCommaStringSlice}
+	schema["ttl"] = &FieldSchema{Type: TypeDurationSecond}
+	aliases := map[string]string{
+		"policies": "token_policies",
+		"ttl":      "token_ttl",
+	}
+
+	var params TokenParams
+	data := &FieldData{
+		Raw: map[string]interface{}{
+			"policies": "foo",
+			"ttl":      "1h",
+		},
+		Schema: schema,
+	}
+	if err := params.ParseTokenFieldAliases(data, aliases); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(params.TokenPolicies, []string{"foo"}) || params.TokenTTL != time.Hour {
+		t.Fatalf("bad: %#v", params)
+	}
+
+	// An alias and its token field cannot both be set
+	data.Raw["token_policies"] = "bar"
+	if err := params.ParseTokenFieldAliases(data, aliases); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestTokenParams_PopulateTokenAuth(t *testing.T) {
+	var params TokenParams
+	data := &FieldData{
+		Raw: map[string]interface{}{
+			"token_bound_cidrs":       "127.0.0.0/8",
+			"token_explicit_max_ttl":  "3h",
+			"token_no_default_policy": true,
+			"token_num_uses":          5,
+			"token_policies":          "foo",
+			"token_ttl":               "1h",
+		},
+		Schema: TokenFields(),
+	}
+	if err := params.ParseTokenFields(&logical.Request{Operation: logical.CreateOperation}, data); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &logical.Auth{}
+	params.PopulateTokenAuth(auth)
+	switch {
+	case len(auth.BoundCIDRs) != 1 || auth.BoundCIDRs[0].String() != "127.0.0.0/8":
+		t.Fatalf("bad bound cidrs: %#v", auth.BoundCIDRs)
+	case auth.ExplicitMaxTTL != 3*time.Hour:
+		t.Fatalf("bad explicit max ttl: %s", auth.ExplicitMaxTTL)
+	case !auth.NoDefaultPolicy:
+		t.Fatal("expected no default policy")
+	case auth.NumUses != 5:
+		t.Fatalf("bad num uses: %d", auth.NumUses)
+	case !reflect.DeepEqual(auth.Policies, []string{"foo"}):
+		t.Fatalf("bad policies: %#v", auth.Policies)
+	case auth.TTL != time.Hour:
+		t.Fatalf("bad ttl: %s", auth.TTL)
+	}
+
+	m := map[string]interface{}{}
+	params.PopulateTokenData(m)
+	if m["token_ttl"] != int64(3600) || m["token_type"] != TokenTypeDefault || !reflect.DeepEqual(m["token_bound_cidrs"], []string{"127.0.0.0/8"}) {
+		t.Fatalf("bad token data: %#v", m)
+	}
+}
@@ -113,6 +113,8 @@ type Paths struct {
 	Root []string
 
 	// Unauthenticated are the paths that can be accessed without any auth.
+	// A "+" segment matches any single path segment, and a trailing "*"
+	// makes the path a prefix match.
 	Unauthenticated []string
 
 	// LocalStorage are paths (prefixes) that are local to this instance; this
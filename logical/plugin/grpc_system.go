@@ -146,6 +146,10 @@ func (s *gRPCSystemViewClient) EntityInfo(entityID string) (*logical.Entity, err
 	return reply.Entity, nil
 }
 
+func (s *gRPCSystemViewClient) GeneratePasswordFromPolicy(ctx context.Context, policyName string) (string, error) {
+	return "", fmt.Errorf("cannot call GeneratePasswordFromPolicy from a plugin backend")
+}
+
 type gRPCSystemViewServer struct {
 	impl logical.SystemView
 }
@@ -146,6 +146,10 @@ func (s *SystemViewClient) EntityInfo(entityID string) (*logical.Entity, error)
 	return reply.Entity, nil
 }
 
+func (s *SystemViewClient) GeneratePasswordFromPolicy(ctx context.Context, policyName string) (string, error) {
+	return "", fmt.Errorf("cannot call GeneratePasswordFromPolicy from a plugin backend")
+}
+
 type SystemViewServer struct {
 	impl logical.SystemView
 }
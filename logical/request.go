@@ -132,6 +132,10 @@ type Request struct {
 	// to make this request
 	EntityID string `json:"entity_id" structs:"entity_id" mapstructure:"entity_id" sentinel:""`
 
+	// ClientTokenMetadata is the metadata of the token used to make this
+	// request. It is only provided to the AppRole backend.
+	ClientTokenMetadata map[string]string `json:"client_token_metadata" structs:"client_token_metadata" mapstructure:"client_token_metadata" sentinel:""`
+
 	// PolicyOverride indicates that the requestor wishes to override
 	// soft-mandatory Sentinel policies
 	PolicyOverride bool `json:"policy_override" structs:"policy_override" mapstructure:"policy_override"`
@@ -247,6 +251,7 @@ const (
 	CreateOperation         Operation = "create"
 	ReadOperation                     = "read"
 	UpdateOperation                   = "update"
+	PatchOperation                    = "patch"
 	DeleteOperation                   = "delete"
 	ListOperation                     = "list"
 	HelpOperation                     = "help"
@@ -3,6 +3,7 @@ package logical
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/hashicorp/vault/helper/consts"
@@ -61,8 +62,33 @@ type SystemView interface {
 	// EntityInfo returns a subset of information related to the identity entity
 	// for the given entity id
 	EntityInfo(entityID string) (*Entity, error)
+
+	// GeneratePasswordFromPolicy generates a password from the password
+	// policy with the given name, configured at sys/policies/password.
+	GeneratePasswordFromPolicy(ctx context.Context, policyName string) (string, error)
+}
+
+// LoginAuthenticator is implemented by the system views of builtin backends.
+// It lets backends that speak other wire protocols delegate client
+// authentication to an existing auth mount, and authorization to the policies
+// of the authenticated client. It is not part of SystemView so that plugins
+// implementing SystemView are left unaffected.
+type LoginAuthenticator interface {
+	// AuthenticateLogin runs the given login request against the auth mount
+	// with the given accessor and returns the resulting auth information. No
+	// token is created.
+	AuthenticateLogin(ctx context.Context, mountAccessor string, req *Request) (*Auth, error)
+
+	// AuthorizeLogin returns whether the policies of the given auth, along
+	// with the policies of its entity, allow the given request. The path of
+	// the request is relative to the root of the router.
+	AuthorizeLogin(ctx context.Context, auth *Auth, req *Request) (bool, error)
 }
 
+// PasswordGenerator generates a password; it stands in for a password policy
+// in StaticSystemView
+type PasswordGenerator func() (string, error)
+
 type StaticSystemView struct {
 	DefaultLeaseTTLVal  time.Duration
 	MaxLeaseTTLVal      time.Duration
@@ -74,6 +100,9 @@ type StaticSystemView struct {
 	LocalMountVal       bool
 	ReplicationStateVal consts.ReplicationState
 	EntityVal           *Entity
+	AuthVal             *Auth
+	AuthorizedPaths     []string
+	PasswordPolicies    map[string]PasswordGenerator
 }
 
 func (d StaticSystemView) DefaultLeaseTTL() time.Duration {
@@ -119,3 +148,27 @@ func (d StaticSystemView) MlockEnabled() bool {
 func (d StaticSystemView) EntityInfo(entityID string) (*Entity, error) {
 	return d.EntityVal, nil
 }
+
+func (d StaticSystemView) AuthenticateLogin(_ context.Context, mountAccessor string, req *Request) (*Auth, error) {
+	if d.AuthVal == nil {
+		return nil, ErrPermissionDenied
+	}
+	return d.AuthVal, nil
+}
+
+func (d StaticSystemView) AuthorizeLogin(_ context.Context, auth *Auth, req *Request) (bool, error) {
+	for _, path := range d.AuthorizedPaths {
+		if path == req.Path {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d StaticSystemView) GeneratePasswordFromPolicy(_ context.Context, policyName string) (string, error) {
+	generator, ok := d.PasswordPolicies[policyName]
+	if !ok {
+		return "", fmt.Errorf("password policy %q not found", policyName)
+	}
+	return generator()
+}
@@ -72,6 +72,22 @@ func (c *Cassandra) Type() (string, error) {
 	return cassandraTypeName, nil
 }
 
+// Init sets the username template from the connection configuration and
+// initializes the connection.
+func (c *Cassandra) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	if err := credsutil.SetUsernameTemplate(c.CredentialsProducer, conf); err != nil {
+		return nil, err
+	}
+
+	return c.cassandraConnectionProducer.Init(ctx, conf, verifyConnection)
+}
+
+// Initialize is the deprecated form of Init.
+func (c *Cassandra) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := c.Init(ctx, conf, verifyConnection)
+	return err
+}
+
 func (c *Cassandra) getConnection(ctx context.Context) (*gocql.Session, error) {
 	session, err := c.Connection(ctx)
 	if err != nil {
@@ -195,6 +211,11 @@ func (c *Cassandra) RevokeUser(ctx context.Context, statements dbplugin.Statemen
 	return result.ErrorOrNil()
 }
 
+// SetCredentials is not currently supported on Cassandra
+func (c *Cassandra) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	return "", "", dbplugin.ErrSetCredentialsUnsupported
+}
+
 func (c *Cassandra) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	// Grab the lock
 	c.Lock()
@@ -0,0 +1,117 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+)
+
+// esError is an error response of the Elasticsearch API
+type esError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *esError) Error() string {
+	return fmt.Sprintf("elasticsearch returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// isNotFound returns whether the error is a "not found" response
+func isNotFound(err error) bool {
+	esErr, ok := err.(*esError)
+	return ok && esErr.StatusCode == http.StatusNotFound
+}
+
+// esClient is a client of the security API of Elasticsearch, authenticated
+// as the configured user
+type esClient struct {
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// do sends a request with the given JSON body, if any, and decodes the JSON
+// response into out, if given
+func (c *esClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.baseURL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(c.username, c.password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &esError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+		}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return errwrap.Wrapf("error decoding elasticsearch response: {{err}}", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *esClient) authenticate(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/_security/_authenticate", nil, nil)
+}
+
+func (c *esClient) createRole(ctx context.Context, name string, definition map[string]interface{}) error {
+	return c.do(ctx, http.MethodPut, "/_security/role/"+url.PathEscape(name), definition, nil)
+}
+
+func (c *esClient) deleteRole(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/_security/role/"+url.PathEscape(name), nil, nil)
+}
+
+func (c *esClient) createUser(ctx context.Context, name, password string, roles []string) error {
+	return c.do(ctx, http.MethodPut, "/_security/user/"+url.PathEscape(name), map[string]interface{}{
+		"password": password,
+		"roles":    roles,
+	}, nil)
+}
+
+func (c *esClient) changePassword(ctx context.Context, name, password string) error {
+	return c.do(ctx, http.MethodPost, "/_security/user/"+url.PathEscape(name)+"/_password", map[string]interface{}{
+		"password": password,
+	}, nil)
+}
+
+func (c *esClient) deleteUser(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/_security/user/"+url.PathEscape(name), nil, nil)
+}
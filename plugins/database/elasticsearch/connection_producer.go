@@ -0,0 +1,143 @@
+package elasticsearch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+	"github.com/mitchellh/mapstructure"
+)
+
+// esConnectionProducer implements ConnectionProducer and provides an
+// interface for databases to make connections.
+type esConnectionProducer struct {
+	URL           string `json:"url" structs:"url" mapstructure:"url"`
+	Username      string `json:"username" structs:"username" mapstructure:"username"`
+	Password      string `json:"password" structs:"password" mapstructure:"password"`
+	CACert        string `json:"ca_cert" structs:"ca_cert" mapstructure:"ca_cert"`
+	ClientCert    string `json:"client_cert" structs:"client_cert" mapstructure:"client_cert"`
+	ClientKey     string `json:"client_key" structs:"client_key" mapstructure:"client_key"`
+	TLSServerName string `json:"tls_server_name" structs:"tls_server_name" mapstructure:"tls_server_name"`
+	Insecure      bool   `json:"insecure" structs:"insecure" mapstructure:"insecure"`
+
+	Initialized bool
+	RawConfig   map[string]interface{}
+	Type        string
+	client      *esClient
+	sync.Mutex
+}
+
+func (c *esConnectionProducer) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := c.Init(ctx, conf, verifyConnection)
+	return err
+}
+
+// Init parses connection configuration.
+func (c *esConnectionProducer) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.RawConfig = conf
+
+	err := mapstructure.WeakDecode(conf, c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(c.URL) == 0:
+		return nil, errors.New("url cannot be empty")
+	case len(c.Username) == 0:
+		return nil, errors.New("username cannot be empty")
+	case len(c.Password) == 0:
+		return nil, errors.New("password cannot be empty")
+	}
+	if _, err := url.Parse(c.URL); err != nil {
+		return nil, errwrap.Wrapf("invalid url: {{err}}", err)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.Insecure,
+	}
+	if len(c.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+			return nil, errors.New("unable to parse ca_cert")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	switch {
+	case len(c.ClientCert) > 0 && len(c.ClientKey) > 0:
+		cert, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+		if err != nil {
+			return nil, errwrap.Wrapf("unable to parse client_cert and client_key: {{err}}", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case len(c.ClientCert) > 0 || len(c.ClientKey) > 0:
+		return nil, errors.New("client_cert and client_key must be set together")
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSClientConfig = tlsConfig
+
+	c.client = &esClient{
+		baseURL:  c.URL,
+		username: c.Username,
+		password: c.Password,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   time.Minute,
+		},
+	}
+
+	// Set initialized to true at this point since all fields are set,
+	// and the connection can be established at a later time.
+	c.Initialized = true
+
+	if verifyConnection {
+		if err := c.client.authenticate(ctx); err != nil {
+			return nil, errwrap.Wrapf("error verifying connection: {{err}}", err)
+		}
+	}
+
+	return conf, nil
+}
+
+// Connection returns the client of the server. The lock must be held.
+func (c *esConnectionProducer) Connection(_ context.Context) (interface{}, error) {
+	if !c.Initialized {
+		return nil, connutil.ErrNotInitialized
+	}
+
+	return c.client, nil
+}
+
+// Close terminates the idle connections of the client.
+func (c *esConnectionProducer) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.client != nil {
+		if transport, ok := c.client.httpClient.Transport.(*http.Transport); ok {
+			transport.CloseIdleConnections()
+		}
+	}
+
+	return nil
+}
+
+func (c *esConnectionProducer) secretValues() map[string]interface{} {
+	return map[string]interface{}{
+		c.Password:  "[password]",
+		c.ClientKey: "[client_key]",
+	}
+}
@@ -0,0 +1,254 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/plugins"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
+)
+
+const elasticsearchTypeName = "elasticsearch"
+
+// Elasticsearch is an implementation of Database interface that manages the
+// users of the native realm of an Elasticsearch cluster
+type Elasticsearch struct {
+	*esConnectionProducer
+	credsutil.CredentialsProducer
+}
+
+var _ dbplugin.Database = &Elasticsearch{}
+
+// elasticsearchStatement is the creation statement of a user. The user is
+// given the existing roles listed in Roles, and a role of the same name as
+// the user is created from RoleDefinition, if set.
+type elasticsearchStatement struct {
+	RoleDefinition map[string]interface{} `json:"elasticsearch_role_definition"`
+	Roles          []string               `json:"elasticsearch_roles"`
+}
+
+// New implements builtinplugins.BuiltinFactory
+func New() (interface{}, error) {
+	db := new()
+	// Wrap the plugin with middleware to sanitize errors
+	dbType := dbplugin.NewDatabaseErrorSanitizerMiddleware(db, db.secretValues)
+
+	return dbType, nil
+}
+
+func new() *Elasticsearch {
+	connProducer := &esConnectionProducer{}
+	connProducer.Type = elasticsearchTypeName
+
+	credsProducer := &credsutil.SQLCredentialsProducer{
+		DisplayNameLen: 15,
+		RoleNameLen:    15,
+		UsernameLen:    100,
+		Separator:      "-",
+	}
+
+	return &Elasticsearch{
+		esConnectionProducer: connProducer,
+		CredentialsProducer:  credsProducer,
+	}
+}
+
+// Run instantiates an Elasticsearch object, and runs the RPC server for the
+// plugin
+func Run(apiTLSConfig *api.TLSConfig) error {
+	dbType, err := New()
+	if err != nil {
+		return err
+	}
+
+	plugins.Serve(dbType.(dbplugin.Database), apiTLSConfig)
+
+	return nil
+}
+
+// Type returns the TypeName for this backend
+func (e *Elasticsearch) Type() (string, error) {
+	return elasticsearchTypeName, nil
+}
+
+// Init sets the username template from the connection configuration and
+// initializes the connection.
+func (e *Elasticsearch) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	if err := credsutil.SetUsernameTemplate(e.CredentialsProducer, conf); err != nil {
+		return nil, err
+	}
+
+	return e.esConnectionProducer.Init(ctx, conf, verifyConnection)
+}
+
+// Initialize is the deprecated form of Init.
+func (e *Elasticsearch) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := e.Init(ctx, conf, verifyConnection)
+	return err
+}
+
+func (e *Elasticsearch) getConnection(ctx context.Context) (*esClient, error) {
+	client, err := e.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*esClient), nil
+}
+
+// CreateUser creates a user of the native realm as instructed by the creation
+// statement, which is a JSON object such as:
+//
+//	{"elasticsearch_roles": ["monitoring_user"]}
+//
+// or, to create a role dedicated to the user:
+//
+//	{"elasticsearch_role_definition": {"indices": [{"names": ["*"], "privileges": ["read"]}]}}
+func (e *Elasticsearch) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
+	e.Lock()
+	defer e.Unlock()
+
+	statements = dbutil.StatementCompatibilityHelper(statements)
+
+	if len(statements.Creation) == 0 {
+		return "", "", dbutil.ErrEmptyCreationStatement
+	}
+
+	var stmt elasticsearchStatement
+	if err := json.Unmarshal([]byte(statements.Creation[0]), &stmt); err != nil {
+		return "", "", errwrap.Wrapf("unable to parse creation statement: {{err}}", err)
+	}
+	if stmt.RoleDefinition == nil && len(stmt.Roles) == 0 {
+		return "", "", errors.New("creation statement must set elasticsearch_role_definition or elasticsearch_roles")
+	}
+
+	client, err := e.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	username, err = e.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = e.GeneratePassword()
+	if err != nil {
+		return "", "", err
+	}
+
+	roles := stmt.Roles
+	if stmt.RoleDefinition != nil {
+		if err := client.createRole(ctx, username, stmt.RoleDefinition); err != nil {
+			return "", "", errwrap.Wrapf("unable to create role: {{err}}", err)
+		}
+		roles = append(roles, username)
+	}
+
+	if err := client.createUser(ctx, username, password, roles); err != nil {
+		if stmt.RoleDefinition != nil {
+			// Roll back the creation of the role
+			if rollbackErr := client.deleteRole(ctx, username); rollbackErr != nil {
+				err = errwrap.Wrapf("unable to delete role after failing to create user: {{err}}", rollbackErr)
+			}
+		}
+		return "", "", errwrap.Wrapf("unable to create user: {{err}}", err)
+	}
+
+	return username, password, nil
+}
+
+// RenewUser is not supported on Elasticsearch, so this is a no-op.
+func (e *Elasticsearch) RenewUser(ctx context.Context, statements dbplugin.Statements, username string, expiration time.Time) error {
+	// NOOP
+	return nil
+}
+
+// RevokeUser deletes the user, and the role dedicated to the user, if any.
+// Revocation statements are not supported.
+func (e *Elasticsearch) RevokeUser(ctx context.Context, statements dbplugin.Statements, username string) error {
+	e.Lock()
+	defer e.Unlock()
+
+	client, err := e.getConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := client.deleteUser(ctx, username); err != nil && !isNotFound(err) {
+		return errwrap.Wrapf("unable to delete user: {{err}}", err)
+	}
+	if err := client.deleteRole(ctx, username); err != nil && !isNotFound(err) {
+		return errwrap.Wrapf("unable to delete role: {{err}}", err)
+	}
+
+	return nil
+}
+
+// SetCredentials sets the password of an existing user. Rotation statements
+// are not supported.
+func (e *Elasticsearch) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	e.Lock()
+	defer e.Unlock()
+
+	if staticUser.Username == "" {
+		return "", "", errors.New("username is required to set credentials")
+	}
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = e.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	client, err := e.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := client.changePassword(ctx, staticUser.Username, password); err != nil {
+		return "", "", errwrap.Wrapf("unable to change password: {{err}}", err)
+	}
+
+	return staticUser.Username, password, nil
+}
+
+// RotateRootCredentials sets a new password for the user of the connection.
+// Rotation statements are not supported.
+func (e *Elasticsearch) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	if len(e.Username) == 0 || len(e.Password) == 0 {
+		return nil, errors.New("username and password are required to rotate")
+	}
+
+	client, err := e.getConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := e.GeneratePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.changePassword(ctx, e.Username, password); err != nil {
+		return nil, errwrap.Wrapf("unable to change password: {{err}}", err)
+	}
+
+	// Use the new password for the next requests
+	e.Password = password
+	client.password = password
+
+	e.RawConfig["password"] = password
+	return e.RawConfig, nil
+}
@@ -0,0 +1,297 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+)
+
+const testElasticsearchRole = `{"elasticsearch_role_definition": {"indices": [{"names": ["*"], "privileges": ["read"]}]}}`
+
+// fakeElasticsearch is a minimal implementation of the security API of
+// Elasticsearch
+type fakeElasticsearch struct {
+	sync.Mutex
+	users map[string]*fakeElasticsearchUser
+	roles map[string]map[string]interface{}
+}
+
+type fakeElasticsearchUser struct {
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	username, password, ok := r.BasicAuth()
+	if user := f.users[username]; !ok || user == nil || user.Password != password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/_security/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "_authenticate" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"username": username})
+
+	case len(path) == 2 && path[0] == "role" && r.Method == http.MethodPut:
+		var definition map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&definition)
+		f.roles[path[1]] = definition
+		w.Write([]byte(`{"role": {"created": true}}`))
+
+	case len(path) == 2 && path[0] == "role" && r.Method == http.MethodDelete:
+		if _, ok := f.roles[path[1]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"found": false}`))
+			return
+		}
+		delete(f.roles, path[1])
+		w.Write([]byte(`{"found": true}`))
+
+	case len(path) == 2 && path[0] == "user" && r.Method == http.MethodPut:
+		var user fakeElasticsearchUser
+		json.NewDecoder(r.Body).Decode(&user)
+		for _, role := range user.Roles {
+			if _, ok := f.roles[role]; !ok && role != "superuser" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		f.users[path[1]] = &user
+		w.Write([]byte(`{"created": true}`))
+
+	case len(path) == 2 && path[0] == "user" && r.Method == http.MethodDelete:
+		if _, ok := f.users[path[1]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"found": false}`))
+			return
+		}
+		delete(f.users, path[1])
+		w.Write([]byte(`{"found": true}`))
+
+	case len(path) == 3 && path[0] == "user" && path[2] == "_password" && r.Method == http.MethodPost:
+		user, ok := f.users[path[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body struct {
+			Password string `json:"password"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		user.Password = body.Password
+		w.Write([]byte(`{}`))
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// prepareElasticsearchTestServer returns the connection details of the
+// cluster to test against: the cluster set in the environment, or a fake
+// server
+func prepareElasticsearchTestServer(t *testing.T) (cleanup func(), connectionDetails map[string]interface{}) {
+	t.Helper()
+	if os.Getenv("ELASTICSEARCH_URL") != "" {
+		return func() {}, map[string]interface{}{
+			"url":      os.Getenv("ELASTICSEARCH_URL"),
+			"username": os.Getenv("ELASTICSEARCH_USERNAME"),
+			"password": os.Getenv("ELASTICSEARCH_PASSWORD"),
+		}
+	}
+
+	server := httptest.NewServer(&fakeElasticsearch{
+		users: map[string]*fakeElasticsearchUser{
+			"elastic": {
+				Password: "changeme",
+				Roles:    []string{"superuser"},
+			},
+		},
+		roles: map[string]map[string]interface{}{},
+	})
+
+	return server.Close, map[string]interface{}{
+		"url":      server.URL,
+		"username": "elastic",
+		"password": "changeme",
+	}
+}
+
+func testCredsExist(t *testing.T, connectionDetails map[string]interface{}, username, password string) error {
+	t.Helper()
+	client := &esClient{
+		baseURL:    connectionDetails["url"].(string),
+		username:   username,
+		password:   password,
+		httpClient: http.DefaultClient,
+	}
+	return client.authenticate(context.Background())
+}
+
+func copyConfig(config map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		result[k] = v
+	}
+	return result
+}
+
+func TestElasticsearch_Initialize(t *testing.T) {
+	cleanup, connectionDetails := prepareElasticsearchTestServer(t)
+	defer cleanup()
+
+	db := new()
+	_, err := db.Init(context.Background(), copyConfig(connectionDetails), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !db.Initialized {
+		t.Fatal("Database should be initialized")
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	badDetails := copyConfig(connectionDetails)
+	badDetails["password"] = "wrong"
+	if _, err := new().Init(context.Background(), badDetails, true); err == nil {
+		t.Fatal("expected error for bad credentials")
+	}
+
+	badDetails = copyConfig(connectionDetails)
+	badDetails["client_cert"] = "cert"
+	if _, err := new().Init(context.Background(), badDetails, false); err == nil {
+		t.Fatal("expected error for client_cert without client_key")
+	}
+}
+
+func TestElasticsearch_CreateUser_RevokeUser(t *testing.T) {
+	cleanup, connectionDetails := prepareElasticsearchTestServer(t)
+	defer cleanup()
+
+	db := new()
+	_, err := db.Init(context.Background(), copyConfig(connectionDetails), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	// Test with no configured Creation Statement
+	_, _, err = db.CreateUser(context.Background(), dbplugin.Statements{}, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error when no creation statement is provided")
+	}
+	_, _, err = db.CreateUser(context.Background(), dbplugin.Statements{
+		Creation: []string{`{}`},
+	}, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error when no roles are given")
+	}
+
+	for _, creation := range []string{testElasticsearchRole, `{"elasticsearch_roles": ["superuser"]}`} {
+		statements := dbplugin.Statements{
+			Creation: []string{creation},
+		}
+		username, password, err := db.CreateUser(context.Background(), statements, usernameConfig, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !strings.HasPrefix(username, "v-test-test-") {
+			t.Fatalf("bad username: %s", username)
+		}
+
+		if err := testCredsExist(t, connectionDetails, username, password); err != nil {
+			t.Fatalf("Could not connect with new credentials: %s", err)
+		}
+
+		if err := db.RevokeUser(context.Background(), statements, username); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := testCredsExist(t, connectionDetails, username, password); err == nil {
+			t.Fatal("Credentials were not revoked")
+		}
+	}
+}
+
+func TestElasticsearch_SetCredentials(t *testing.T) {
+	cleanup, connectionDetails := prepareElasticsearchTestServer(t)
+	defer cleanup()
+
+	db := new()
+	_, err := db.Init(context.Background(), copyConfig(connectionDetails), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	username, password, err := db.CreateUser(context.Background(), dbplugin.Statements{
+		Creation: []string{testElasticsearchRole},
+	}, dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, newPassword, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: username,
+		Password: "new-password",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := testCredsExist(t, connectionDetails, username, newPassword); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err := testCredsExist(t, connectionDetails, username, password); err == nil {
+		t.Fatal("Old password still valid")
+	}
+}
+
+func TestElasticsearch_RotateRootCredentials(t *testing.T) {
+	cleanup, connectionDetails := prepareElasticsearchTestServer(t)
+	defer cleanup()
+
+	db := new()
+	_, err := db.Init(context.Background(), copyConfig(connectionDetails), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	newConf, err := db.RotateRootCredentials(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if newConf["password"] == connectionDetails["password"] {
+		t.Fatal("password was not updated")
+	}
+	if err := testCredsExist(t, connectionDetails, connectionDetails["username"].(string), newConf["password"].(string)); err != nil {
+		t.Fatalf("Could not connect with new root credentials: %s", err)
+	}
+
+	// The plugin keeps working with the new password
+	if err := db.RevokeUser(context.Background(), dbplugin.Statements{}, "missing"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -74,6 +74,22 @@ func (h *HANA) Type() (string, error) {
 	return hanaTypeName, nil
 }
 
+// Init sets the username template from the connection configuration and
+// initializes the connection.
+func (h *HANA) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	if err := credsutil.SetUsernameTemplate(h.CredentialsProducer, conf); err != nil {
+		return nil, err
+	}
+
+	return h.SQLConnectionProducer.Init(ctx, conf, verifyConnection)
+}
+
+// Initialize is the deprecated form of Init.
+func (h *HANA) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := h.Init(ctx, conf, verifyConnection)
+	return err
+}
+
 func (h *HANA) getConnection(ctx context.Context) (*sql.DB, error) {
 	db, err := h.Connection(ctx)
 	if err != nil {
@@ -290,6 +306,11 @@ func (h *HANA) revokeUserDefault(ctx context.Context, username string) error {
 	return nil
 }
 
+// SetCredentials is not currently supported on HANA
+func (h *HANA) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	return "", "", dbplugin.ErrSetCredentialsUnsupported
+}
+
 // RotateRootCredentials is not currently supported on HANA
 func (h *HANA) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	return nil, errors.New("root credentaion rotation is not currently implemented in this database secrets engine")
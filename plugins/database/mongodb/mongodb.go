@@ -71,6 +71,22 @@ func (m *MongoDB) Type() (string, error) {
 	return mongoDBTypeName, nil
 }
 
+// Init sets the username template from the connection configuration and
+// initializes the connection.
+func (m *MongoDB) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	if err := credsutil.SetUsernameTemplate(m.CredentialsProducer, conf); err != nil {
+		return nil, err
+	}
+
+	return m.mongoDBConnectionProducer.Init(ctx, conf, verifyConnection)
+}
+
+// Initialize is the deprecated form of Init.
+func (m *MongoDB) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := m.Init(ctx, conf, verifyConnection)
+	return err
+}
+
 func (m *MongoDB) getConnection(ctx context.Context) (*mgo.Session, error) {
 	session, err := m.Connection(ctx)
 	if err != nil {
@@ -218,6 +234,11 @@ func (m *MongoDB) RevokeUser(ctx context.Context, statements dbplugin.Statements
 	return nil
 }
 
+// SetCredentials is not currently supported on MongoDB
+func (m *MongoDB) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	return "", "", dbplugin.ErrSetCredentialsUnsupported
+}
+
 // RotateRootCredentials is not currently supported on MongoDB
 func (m *MongoDB) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	return nil, errors.New("root credentaion rotation is not currently implemented in this database secrets engine")
@@ -72,6 +72,22 @@ func (m *MSSQL) Type() (string, error) {
 	return msSQLTypeName, nil
 }
 
+// Init sets the username template from the connection configuration and
+// initializes the connection.
+func (m *MSSQL) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	if err := credsutil.SetUsernameTemplate(m.CredentialsProducer, conf); err != nil {
+		return nil, err
+	}
+
+	return m.SQLConnectionProducer.Init(ctx, conf, verifyConnection)
+}
+
+// Initialize is the deprecated form of Init.
+func (m *MSSQL) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := m.Init(ctx, conf, verifyConnection)
+	return err
+}
+
 func (m *MSSQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	db, err := m.Connection(ctx)
 	if err != nil {
@@ -305,6 +321,67 @@ func (m *MSSQL) revokeUserDefault(ctx context.Context, username string) error {
 	return nil
 }
 
+// SetCredentials sets the password of an existing user, generating one if
+// none is given, using the rotation statements or the default statement.
+func (m *MSSQL) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if staticUser.Username == "" {
+		return "", "", errors.New("username is required to set credentials")
+	}
+
+	rotateStatements := statements.Rotation
+	if len(rotateStatements) == 0 {
+		rotateStatements = []string{setCredentialsSQL}
+	}
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = m.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	db, err := m.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	for _, stmt := range rotateStatements {
+		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+			query = strings.TrimSpace(query)
+			if len(query) == 0 {
+				continue
+			}
+
+			m := map[string]string{
+				"name":     staticUser.Username,
+				"username": staticUser.Username,
+				"password": password,
+			}
+			if err := dbtxn.ExecuteTxQuery(ctx, tx, m, query); err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
 func (m *MSSQL) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	m.Lock()
 	defer m.Unlock()
@@ -386,6 +463,10 @@ BEGIN
 END
 `
 
+const setCredentialsSQL = `
+ALTER LOGIN [{{username}}] WITH PASSWORD = '{{password}}'
+`
+
 const rotateRootCredentialsSQL = `
 ALTER LOGIN [%s] WITH PASSWORD = '%s' 
 `
@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	stdmysql "github.com/go-sql-driver/mysql"
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
 	"github.com/hashicorp/vault/helper/dbtxn"
@@ -28,6 +30,11 @@ const (
 		ALTER USER '{{username}}'@'%' IDENTIFIED BY '{{password}}';
 	`
 
+	// defaultMySQLSetCredentialsSQL is run for each host of the user
+	defaultMySQLSetCredentialsSQL = `
+		ALTER USER '{{username}}'@'{{host}}' IDENTIFIED BY '{{password}}';
+	`
+
 	mySQLTypeName = "mysql"
 )
 
@@ -104,6 +111,22 @@ func (m *MySQL) Type() (string, error) {
 	return mySQLTypeName, nil
 }
 
+// Init sets the username template from the connection configuration and
+// initializes the connection.
+func (m *MySQL) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	if err := credsutil.SetUsernameTemplate(m.CredentialsProducer, conf); err != nil {
+		return nil, err
+	}
+
+	return m.SQLConnectionProducer.Init(ctx, conf, verifyConnection)
+}
+
+// Initialize is the deprecated form of Init.
+func (m *MySQL) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := m.Init(ctx, conf, verifyConnection)
+	return err
+}
+
 func (m *MySQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	db, err := m.Connection(ctx)
 	if err != nil {
@@ -256,6 +279,100 @@ func (m *MySQL) RevokeUser(ctx context.Context, statements dbplugin.Statements,
 	return nil
 }
 
+// SetCredentials sets the password of an existing user, generating one if
+// none is given, using the rotation statements or the default statement.
+func (m *MySQL) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if staticUser.Username == "" {
+		return "", "", errors.New("username is required to set credentials")
+	}
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = m.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	db, err := m.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Without rotation statements, the password is set for every host the
+	// user can connect from, as users are not necessarily created for '%'
+	hosts := []string{""}
+	rotateStatements := statements.Rotation
+	if len(rotateStatements) == 0 {
+		rotateStatements = []string{defaultMySQLSetCredentialsSQL}
+		hosts, err = userHosts(ctx, db, staticUser.Username)
+		if err != nil {
+			return "", "", err
+		}
+		if len(hosts) == 0 {
+			return "", "", fmt.Errorf("user %q does not exist", staticUser.Username)
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	for _, host := range hosts {
+		for _, stmt := range rotateStatements {
+			for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+				query = strings.TrimSpace(query)
+				if len(query) == 0 {
+					continue
+				}
+
+				m := map[string]string{
+					"name":     staticUser.Username,
+					"username": staticUser.Username,
+					"password": password,
+					"host":     host,
+				}
+				if err := dbtxn.ExecuteTxQuery(ctx, tx, m, query); err != nil {
+					return "", "", err
+				}
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
+// userHosts returns the hosts of the accounts of a user
+func userHosts(ctx context.Context, db *sql.DB, username string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT host FROM mysql.user WHERE user = ?;", username)
+	if err != nil {
+		return nil, errwrap.Wrapf("unable to look up the hosts of the user: {{err}}", err)
+	}
+	defer rows.Close()
+
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+
+	return hosts, rows.Err()
+}
+
 func (m *MySQL) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	m.Lock()
 	defer m.Unlock()
@@ -11,6 +11,7 @@ import (
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
 	"github.com/hashicorp/vault/helper/dbtxn"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/plugins"
@@ -31,13 +32,16 @@ ALTER ROLE "{{username}}" WITH PASSWORD '{{password}}';
 )
 
 var _ dbplugin.Database = &PostgreSQL{}
+var _ v5.Database = &PostgreSQLv5{}
 
-// New implements builtinplugins.BuiltinFactory
+// New implements builtinplugins.BuiltinFactory. The builtin plugin
+// implements version 5 of the database plugin interface.
 func New() (interface{}, error) {
 	db := new()
-	// Wrap the plugin with middleware to sanitize errors
-	dbType := dbplugin.NewDatabaseErrorSanitizerMiddleware(db, db.SecretValues)
-	return dbType, nil
+	return &PostgreSQLv5{
+		db:        db,
+		sanitizer: dbplugin.NewDatabaseErrorSanitizerMiddleware(db, db.SecretValues),
+	}, nil
 }
 
 func new() *PostgreSQL {
@@ -61,12 +65,11 @@ func new() *PostgreSQL {
 
 // Run instantiates a PostgreSQL object, and runs the RPC server for the plugin
 func Run(apiTLSConfig *api.TLSConfig) error {
-	dbType, err := New()
-	if err != nil {
-		return err
-	}
+	db := new()
+	// Wrap the plugin with middleware to sanitize errors
+	dbType := dbplugin.NewDatabaseErrorSanitizerMiddleware(db, db.SecretValues)
 
-	plugins.Serve(dbType.(dbplugin.Database), apiTLSConfig)
+	plugins.Serve(dbType, apiTLSConfig)
 
 	return nil
 }
@@ -80,6 +83,22 @@ func (p *PostgreSQL) Type() (string, error) {
 	return postgreSQLTypeName, nil
 }
 
+// Init sets the username template from the connection configuration and
+// initializes the connection.
+func (p *PostgreSQL) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	if err := credsutil.SetUsernameTemplate(p.CredentialsProducer, conf); err != nil {
+		return nil, err
+	}
+
+	return p.SQLConnectionProducer.Init(ctx, conf, verifyConnection)
+}
+
+// Initialize is the deprecated form of Init.
+func (p *PostgreSQL) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := p.Init(ctx, conf, verifyConnection)
+	return err
+}
+
 func (p *PostgreSQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	db, err := p.Connection(ctx)
 	if err != nil {
@@ -110,30 +129,38 @@ func (p *PostgreSQL) CreateUser(ctx context.Context, statements dbplugin.Stateme
 		return "", "", err
 	}
 
+	if err := p.createUser(ctx, statements.Creation, username, password, expiration); err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
+// createUser runs the creation statements of a user in a transaction. The
+// lock must be held.
+func (p *PostgreSQL) createUser(ctx context.Context, creationStmts []string, username, password string, expiration time.Time) error {
 	expirationStr, err := p.GenerateExpiration(expiration)
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
 	// Get the connection
 	db, err := p.getConnection(ctx)
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
 	// Start a transaction
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return "", "", err
-
+		return err
 	}
 	defer func() {
 		tx.Rollback()
 	}()
-	// Return the secret
 
 	// Execute each query
-	for _, stmt := range statements.Creation {
+	for _, stmt := range creationStmts {
 		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
 			query = strings.TrimSpace(query)
 			if len(query) == 0 {
@@ -146,17 +173,13 @@ func (p *PostgreSQL) CreateUser(ctx context.Context, statements dbplugin.Stateme
 				"expiration": expirationStr,
 			}
 			if err := dbtxn.ExecuteTxQuery(ctx, tx, m, query); err != nil {
-				return "", "", err
+				return err
 			}
 		}
 	}
 
 	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return "", "", err
-	}
-
-	return username, password, nil
+	return tx.Commit()
 }
 
 func (p *PostgreSQL) RenewUser(ctx context.Context, statements dbplugin.Statements, username string, expiration time.Time) error {
@@ -366,6 +389,67 @@ func (p *PostgreSQL) defaultRevokeUser(ctx context.Context, username string) err
 	return nil
 }
 
+// SetCredentials sets the password of an existing user, generating one if
+// none is given, using the rotation statements or the default statement.
+func (p *PostgreSQL) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if staticUser.Username == "" {
+		return "", "", errors.New("username is required to set credentials")
+	}
+
+	rotateStatements := statements.Rotation
+	if len(rotateStatements) == 0 {
+		rotateStatements = []string{defaultPostgresRotateRootCredentialsSQL}
+	}
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = p.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	db, err := p.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	for _, stmt := range rotateStatements {
+		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+			query = strings.TrimSpace(query)
+			if len(query) == 0 {
+				continue
+			}
+
+			m := map[string]string{
+				"name":     staticUser.Username,
+				"username": staticUser.Username,
+				"password": password,
+			}
+			if err := dbtxn.ExecuteTxQuery(ctx, tx, m, query); err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
 func (p *PostgreSQL) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	p.Lock()
 	defer p.Unlock()
@@ -425,3 +509,141 @@ func (p *PostgreSQL) RotateRootCredentials(ctx context.Context, statements []str
 	p.RawConfig["password"] = password
 	return p.RawConfig, nil
 }
+
+// PostgreSQLv5 implements version 5 of the database plugin interface on top
+// of PostgreSQL. Besides passwords, it supports client certificates, in
+// which case the name of the new role is the common name of the certificate,
+// as required by the "cert" authentication method of PostgreSQL.
+type PostgreSQLv5 struct {
+	db *PostgreSQL
+
+	// sanitizer wraps db to remove secret values from errors
+	sanitizer *dbplugin.DatabaseErrorSanitizerMiddleware
+}
+
+// Initialize sets the username template and initializes the connection.
+func (p *PostgreSQLv5) Initialize(ctx context.Context, req v5.InitializeRequest) (v5.InitializeResponse, error) {
+	config, err := p.sanitizer.Init(ctx, req.Config, req.VerifyConnection)
+	return v5.InitializeResponse{
+		Config: config,
+	}, err
+}
+
+// NewUser creates a role with the credential of the request.
+func (p *PostgreSQLv5) NewUser(ctx context.Context, req v5.NewUserRequest) (v5.NewUserResponse, error) {
+	if len(req.Statements.Commands) == 0 {
+		return v5.NewUserResponse{}, dbutil.ErrEmptyCreationStatement
+	}
+
+	p.db.Lock()
+	defer p.db.Unlock()
+
+	var username, password string
+	var err error
+	switch req.CredentialType {
+	case "", v5.CredentialTypePassword:
+		username, err = p.db.GenerateUsername(dbplugin.UsernameConfig{
+			DisplayName: req.UsernameConfig.DisplayName,
+			RoleName:    req.UsernameConfig.RoleName,
+		})
+		password = req.Password
+	case v5.CredentialTypeClientCertificate:
+		username, err = commonName(req.Subject)
+	default:
+		err = fmt.Errorf("credential type %q is not supported", req.CredentialType)
+	}
+	if err != nil {
+		return v5.NewUserResponse{}, err
+	}
+
+	if err := p.db.createUser(ctx, req.Statements.Commands, username, password, req.Expiration); err != nil {
+		return v5.NewUserResponse{}, p.sanitizer.SanitizeError(err)
+	}
+
+	return v5.NewUserResponse{
+		Username: username,
+	}, nil
+}
+
+// UpdateUser changes the password or the expiration of a role.
+func (p *PostgreSQLv5) UpdateUser(ctx context.Context, req v5.UpdateUserRequest) (v5.UpdateUserResponse, error) {
+	if req.Password != nil {
+		if req.Password.NewPassword == "" {
+			return v5.UpdateUserResponse{}, errors.New("new password is required")
+		}
+
+		_, _, err := p.sanitizer.SetCredentials(ctx, dbplugin.Statements{
+			Rotation: req.Password.Statements.Commands,
+		}, dbplugin.StaticUserConfig{
+			Username: req.Username,
+			Password: req.Password.NewPassword,
+		})
+		if err != nil {
+			return v5.UpdateUserResponse{}, err
+		}
+	}
+
+	if req.Expiration != nil {
+		err := p.sanitizer.RenewUser(ctx, dbplugin.Statements{
+			Renewal: req.Expiration.Statements.Commands,
+		}, req.Username, req.Expiration.NewExpiration)
+		if err != nil {
+			return v5.UpdateUserResponse{}, err
+		}
+	}
+
+	return v5.UpdateUserResponse{}, nil
+}
+
+// DeleteUser drops a role, using the default revocation if no statements
+// are given.
+func (p *PostgreSQLv5) DeleteUser(ctx context.Context, req v5.DeleteUserRequest) (v5.DeleteUserResponse, error) {
+	err := p.sanitizer.RevokeUser(ctx, dbplugin.Statements{
+		Revocation: req.Statements.Commands,
+	}, req.Username)
+	return v5.DeleteUserResponse{}, err
+}
+
+// Type returns the TypeName for this backend
+func (p *PostgreSQLv5) Type() (string, error) {
+	return postgreSQLTypeName, nil
+}
+
+// Close closes the connection to the database
+func (p *PostgreSQLv5) Close() error {
+	return p.sanitizer.Close()
+}
+
+// commonName returns the common name of a distinguished name formatted as
+// an RFC 2253 string
+func commonName(subject string) (string, error) {
+	start := 0
+	for i := 0; i <= len(subject); i++ {
+		if i < len(subject) {
+			if subject[i] == '\\' {
+				i++
+				continue
+			}
+			if subject[i] != ',' && subject[i] != '+' {
+				continue
+			}
+		}
+
+		attr := subject[start:i]
+		start = i + 1
+		if !strings.HasPrefix(attr, "CN=") || len(attr) == len("CN=") {
+			continue
+		}
+
+		var name strings.Builder
+		for j := len("CN="); j < len(attr); j++ {
+			if attr[j] == '\\' && j+1 < len(attr) {
+				j++
+			}
+			name.WriteByte(attr[j])
+		}
+		return name.String(), nil
+	}
+
+	return "", fmt.Errorf("no common name in subject %q", subject)
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
 	dockertest "gopkg.in/ory-am/dockertest.v3"
 )
 
@@ -318,6 +319,86 @@ func TestPostgreSQL_RevokeUser(t *testing.T) {
 	}
 }
 
+func TestPostgreSQLv5_NewUser(t *testing.T) {
+	cleanup, connURL := preparePostgresTestContainer(t)
+	defer cleanup()
+
+	dbRaw, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dbRaw.(*PostgreSQLv5)
+	_, err = db.Initialize(context.Background(), v5.InitializeRequest{
+		Config: map[string]interface{}{
+			"connection_url": connURL,
+		},
+		VerifyConnection: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	req := v5.NewUserRequest{
+		UsernameConfig: v5.UsernameMetadata{
+			DisplayName: "test",
+			RoleName:    "test",
+		},
+		Statements: v5.Statements{
+			Commands: []string{testPostgresRole},
+		},
+		CredentialType: v5.CredentialTypePassword,
+		Password:       "Vault-test-password",
+		Expiration:     time.Now().Add(time.Minute),
+	}
+	resp, err := db.NewUser(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err = testCredsExist(t, connURL, resp.Username, req.Password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	// Client certificate users are named after the common name
+	req.CredentialType = v5.CredentialTypeClientCertificate
+	req.Password = ""
+	req.Subject = "CN=cert-user"
+	req.Statements.Commands = []string{`CREATE ROLE "{{name}}" WITH LOGIN VALID UNTIL '{{expiration}}';`}
+	resp, err = db.NewUser(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.Username != "cert-user" {
+		t.Fatalf("bad username: %s", resp.Username)
+	}
+
+	req.CredentialType = v5.CredentialTypeRSAPrivateKey
+	if _, err := db.NewUser(context.Background(), req); err == nil {
+		t.Fatal("expected error for unsupported credential type")
+	}
+}
+
+func TestCommonName(t *testing.T) {
+	testCases := map[string]string{
+		"CN=user":             "user",
+		"CN=user.example,O=x": "user.example",
+		"O=x+CN=user":         "user",
+		`CN=a\,b,O=x`:         "a,b",
+	}
+	for subject, expected := range testCases {
+		name, err := commonName(subject)
+		if err != nil {
+			t.Fatalf("subject %q: %s", subject, err)
+		}
+		if name != expected {
+			t.Fatalf("subject %q: expected %q, got %q", subject, expected, name)
+		}
+	}
+
+	if _, err := commonName("O=x"); err == nil {
+		t.Fatal("expected error for subject without a common name")
+	}
+}
+
 func testCredsExist(t testing.TB, connURL, username, password string) error {
 	t.Helper()
 	// Log in with the new creds
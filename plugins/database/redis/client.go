@@ -0,0 +1,141 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// redisTimeout bounds every command sent to the server
+const redisTimeout = 10 * time.Second
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisClient is a minimal client of the Redis serialization protocol
+// (RESP). It only supports sending commands and reading their replies, which
+// is all the plugin needs. It is not safe for concurrent use.
+type redisClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialRedis(ctx context.Context, address string, tlsConfig *tls.Config) (*redisClient, error) {
+	dialer := &net.Dialer{
+		Timeout: redisTimeout,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		tlsConn.SetDeadline(time.Now().Add(redisTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	return &redisClient{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+	}, nil
+}
+
+// Do sends a command and returns its reply, which is a string, an int64, a
+// []interface{} of replies, or nil. Error replies are returned as a
+// redisError.
+func (c *redisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(redisTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+func (c *redisClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *redisClient) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("malformed reply from redis")
+	}
+	payload := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return payload, nil
+
+	case '-':
+		return nil, redisError(payload)
+
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, errors.New("malformed bulk string length from redis")
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+
+	case '*':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, errors.New("malformed array length from redis")
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		result := make([]interface{}, length)
+		for i := range result {
+			result[i], err = c.readReply()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported reply type %q from redis", line[0])
+	}
+}
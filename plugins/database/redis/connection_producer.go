@@ -0,0 +1,158 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+	"github.com/mitchellh/mapstructure"
+)
+
+// redisConnectionProducer implements ConnectionProducer and provides an
+// interface for databases to make connections.
+type redisConnectionProducer struct {
+	Host        string `json:"host" structs:"host" mapstructure:"host"`
+	Port        int    `json:"port" structs:"port" mapstructure:"port"`
+	Username    string `json:"username" structs:"username" mapstructure:"username"`
+	Password    string `json:"password" structs:"password" mapstructure:"password"`
+	TLS         bool   `json:"tls" structs:"tls" mapstructure:"tls"`
+	InsecureTLS bool   `json:"insecure_tls" structs:"insecure_tls" mapstructure:"insecure_tls"`
+	CACert      string `json:"ca_cert" structs:"ca_cert" mapstructure:"ca_cert"`
+
+	Initialized bool
+	RawConfig   map[string]interface{}
+	Type        string
+	client      *redisClient
+	tlsConfig   *tls.Config
+	sync.Mutex
+}
+
+func (c *redisConnectionProducer) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := c.Init(ctx, conf, verifyConnection)
+	return err
+}
+
+// Init parses connection configuration.
+func (c *redisConnectionProducer) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.RawConfig = conf
+
+	err := mapstructure.WeakDecode(conf, c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(c.Host) == 0:
+		return nil, errors.New("host cannot be empty")
+	case c.Port == 0:
+		return nil, errors.New("port cannot be empty")
+	case len(c.Username) == 0:
+		return nil, errors.New("username cannot be empty")
+	case len(c.Password) == 0:
+		return nil, errors.New("password cannot be empty")
+	}
+
+	c.tlsConfig = nil
+	if c.TLS {
+		c.tlsConfig = &tls.Config{
+			ServerName:         c.Host,
+			InsecureSkipVerify: c.InsecureTLS,
+		}
+		if len(c.CACert) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+				return nil, errors.New("unable to parse ca_cert")
+			}
+			c.tlsConfig.RootCAs = pool
+		}
+	}
+
+	// Drop any connection made with a previous configuration
+	c.close()
+
+	// Set initialized to true at this point since all fields are set,
+	// and the connection can be established at a later time.
+	c.Initialized = true
+
+	if verifyConnection {
+		if _, err := c.Connection(ctx); err != nil {
+			return nil, errwrap.Wrapf("error verifying connection: {{err}}", err)
+		}
+	}
+
+	return conf, nil
+}
+
+// Connection creates or returns an existing connection to the server,
+// authenticated as the configured user. If the connection fails a ping
+// check, it is closed and then re-created. The lock must be held.
+func (c *redisConnectionProducer) Connection(ctx context.Context) (interface{}, error) {
+	if !c.Initialized {
+		return nil, connutil.ErrNotInitialized
+	}
+
+	if c.client != nil {
+		if _, err := c.client.Do(ctx, "PING"); err == nil {
+			return c.client, nil
+		}
+		c.close()
+	}
+
+	address := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	client, err := dialRedis(ctx, address, c.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := client.Do(ctx, "AUTH", c.Username, c.Password); err != nil {
+		client.Close()
+		return nil, errwrap.Wrapf("error authenticating to redis: {{err}}", err)
+	}
+	if _, err := client.Do(ctx, "PING"); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	c.client = client
+	return c.client, nil
+}
+
+// Close terminates the connection.
+func (c *redisConnectionProducer) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.close()
+	return nil
+}
+
+func (c *redisConnectionProducer) close() {
+	if c.client != nil {
+		c.client.Close()
+	}
+	c.client = nil
+}
+
+func (c *redisConnectionProducer) secretValues() map[string]interface{} {
+	return map[string]interface{}{
+		c.Password: "[password]",
+	}
+}
+
+// checkReply returns an error if the reply of a command is not "OK"
+func checkReply(reply interface{}) error {
+	if reply != "OK" {
+		return fmt.Errorf("unexpected reply from redis: %v", reply)
+	}
+	return nil
+}
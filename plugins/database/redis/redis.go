@@ -0,0 +1,254 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/plugins"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
+)
+
+const redisTypeName = "redis"
+
+// Redis is an implementation of Database interface that manages the ACL
+// users of a Redis 6+ server
+type Redis struct {
+	*redisConnectionProducer
+	credsutil.CredentialsProducer
+}
+
+var _ dbplugin.Database = &Redis{}
+
+// New implements builtinplugins.BuiltinFactory
+func New() (interface{}, error) {
+	db := new()
+	// Wrap the plugin with middleware to sanitize errors
+	dbType := dbplugin.NewDatabaseErrorSanitizerMiddleware(db, db.secretValues)
+
+	return dbType, nil
+}
+
+func new() *Redis {
+	connProducer := &redisConnectionProducer{}
+	connProducer.Type = redisTypeName
+
+	credsProducer := &credsutil.SQLCredentialsProducer{
+		DisplayNameLen: 15,
+		RoleNameLen:    15,
+		UsernameLen:    100,
+		Separator:      "-",
+	}
+
+	return &Redis{
+		redisConnectionProducer: connProducer,
+		CredentialsProducer:     credsProducer,
+	}
+}
+
+// Run instantiates a Redis object, and runs the RPC server for the plugin
+func Run(apiTLSConfig *api.TLSConfig) error {
+	dbType, err := New()
+	if err != nil {
+		return err
+	}
+
+	plugins.Serve(dbType.(dbplugin.Database), apiTLSConfig)
+
+	return nil
+}
+
+// Type returns the TypeName for this backend
+func (r *Redis) Type() (string, error) {
+	return redisTypeName, nil
+}
+
+// Init sets the username template from the connection configuration and
+// initializes the connection.
+func (r *Redis) Init(ctx context.Context, conf map[string]interface{}, verifyConnection bool) (map[string]interface{}, error) {
+	if err := credsutil.SetUsernameTemplate(r.CredentialsProducer, conf); err != nil {
+		return nil, err
+	}
+
+	return r.redisConnectionProducer.Init(ctx, conf, verifyConnection)
+}
+
+// Initialize is the deprecated form of Init.
+func (r *Redis) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
+	_, err := r.Init(ctx, conf, verifyConnection)
+	return err
+}
+
+func (r *Redis) getConnection(ctx context.Context) (*redisClient, error) {
+	client, err := r.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*redisClient), nil
+}
+
+// CreateUser creates an ACL user with the rules of the creation statement,
+// which is a JSON array of ACL rules, such as:
+//
+//	["~*", "+@read"]
+func (r *Redis) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	statements = dbutil.StatementCompatibilityHelper(statements)
+
+	if len(statements.Creation) == 0 {
+		return "", "", dbutil.ErrEmptyCreationStatement
+	}
+
+	var rules []string
+	if err := json.Unmarshal([]byte(statements.Creation[0]), &rules); err != nil {
+		return "", "", errwrap.Wrapf("creation statement must be a JSON array of ACL rules: {{err}}", err)
+	}
+	for _, rule := range rules {
+		// Users are always enabled with the generated password
+		switch strings.ToLower(rule) {
+		case "off", "nopass", "resetpass", "reset":
+			return "", "", fmt.Errorf("ACL rule %q is not allowed in creation statements", rule)
+		}
+		if strings.HasPrefix(rule, ">") || strings.HasPrefix(rule, "#") {
+			return "", "", errors.New("passwords are not allowed in creation statements")
+		}
+	}
+
+	client, err := r.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	username, err = r.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = r.GeneratePassword()
+	if err != nil {
+		return "", "", err
+	}
+
+	args := append([]string{"ACL", "SETUSER", username, "on", ">" + password}, rules...)
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return "", "", err
+	}
+	if err := checkReply(reply); err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
+// RenewUser is not supported on Redis, so this is a no-op.
+func (r *Redis) RenewUser(ctx context.Context, statements dbplugin.Statements, username string, expiration time.Time) error {
+	// NOOP
+	return nil
+}
+
+// RevokeUser deletes the ACL user. Revocation statements are not supported.
+func (r *Redis) RevokeUser(ctx context.Context, statements dbplugin.Statements, username string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	client, err := r.getConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Deleting a user that does not exist is not an error
+	_, err = client.Do(ctx, "ACL", "DELUSER", username)
+	return err
+}
+
+// SetCredentials sets the password of an existing ACL user, replacing its
+// other passwords. Rotation statements are not supported.
+func (r *Redis) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if staticUser.Username == "" {
+		return "", "", errors.New("username is required to set credentials")
+	}
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = r.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	client, err := r.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := setPassword(ctx, client, staticUser.Username, password); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
+// RotateRootCredentials sets a new password for the user of the connection.
+// Rotation statements are not supported.
+func (r *Redis) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if len(r.Username) == 0 || len(r.Password) == 0 {
+		return nil, errors.New("username and password are required to rotate")
+	}
+
+	client, err := r.getConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := r.GeneratePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setPassword(ctx, client, r.Username, password); err != nil {
+		return nil, err
+	}
+
+	// Close the connection to ensure the next one uses the new password
+	r.close()
+
+	r.Password = password
+	r.RawConfig["password"] = password
+	return r.RawConfig, nil
+}
+
+// setPassword replaces the passwords of an existing ACL user
+func setPassword(ctx context.Context, client *redisClient, username, password string) error {
+	// ACL SETUSER creates missing users, so check that the user exists first
+	user, err := client.Do(ctx, "ACL", "GETUSER", username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %q does not exist", username)
+	}
+
+	reply, err := client.Do(ctx, "ACL", "SETUSER", username, "resetpass", ">"+password)
+	if err != nil {
+		return err
+	}
+
+	return checkReply(reply)
+}
@@ -0,0 +1,369 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+)
+
+const testRedisRole = `["~*", "+@read"]`
+
+// fakeRedis is a minimal Redis server supporting the ACL commands used by the
+// plugin
+type fakeRedis struct {
+	sync.Mutex
+	users map[string]*fakeRedisUser
+}
+
+type fakeRedisUser struct {
+	enabled   bool
+	passwords map[string]bool
+	rules     []string
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := &redisClient{r: bufio.NewReader(conn)}
+	var authenticated bool
+	for {
+		request, err := reader.readReply()
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range request.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		var reply string
+		switch {
+		case strings.ToUpper(args[0]) == "AUTH" && len(args) == 3:
+			if f.checkPassword(args[1], args[2]) {
+				authenticated = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case strings.ToUpper(args[0]) == "PING":
+			reply = "+PONG\r\n"
+		case strings.ToUpper(args[0]) == "ACL" && len(args) >= 3:
+			reply = f.acl(strings.ToUpper(args[1]), args[2], args[3:])
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) checkPassword(username, password string) bool {
+	f.Lock()
+	defer f.Unlock()
+
+	user, ok := f.users[username]
+	return ok && user.enabled && user.passwords[password]
+}
+
+func (f *fakeRedis) acl(subcommand, username string, rules []string) string {
+	f.Lock()
+	defer f.Unlock()
+
+	user := f.users[username]
+	switch subcommand {
+	case "SETUSER":
+		if user == nil {
+			user = &fakeRedisUser{passwords: map[string]bool{}}
+			f.users[username] = user
+		}
+		for _, rule := range rules {
+			switch {
+			case rule == "on":
+				user.enabled = true
+			case rule == "resetpass":
+				user.passwords = map[string]bool{}
+			case strings.HasPrefix(rule, ">"):
+				user.passwords[rule[1:]] = true
+			default:
+				user.rules = append(user.rules, rule)
+			}
+		}
+		return "+OK\r\n"
+
+	case "GETUSER":
+		if user == nil {
+			return "$-1\r\n"
+		}
+		return "*2\r\n$5\r\nflags\r\n*0\r\n"
+
+	case "DELUSER":
+		if user == nil {
+			return ":0\r\n"
+		}
+		delete(f.users, username)
+		return ":1\r\n"
+	}
+
+	return "-ERR unknown subcommand\r\n"
+}
+
+// prepareRedisTestServer returns the connection details of the server to
+// test against: the server set in the environment, or a fake server
+func prepareRedisTestServer(t *testing.T) (cleanup func(), connectionDetails map[string]interface{}) {
+	t.Helper()
+	if os.Getenv("REDIS_HOST") != "" {
+		port, err := strconv.Atoi(os.Getenv("REDIS_PORT"))
+		if err != nil {
+			t.Fatalf("invalid REDIS_PORT: %s", err)
+		}
+		return func() {}, map[string]interface{}{
+			"host":     os.Getenv("REDIS_HOST"),
+			"port":     port,
+			"username": os.Getenv("REDIS_USERNAME"),
+			"password": os.Getenv("REDIS_PASSWORD"),
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeRedis{
+		users: map[string]*fakeRedisUser{
+			"default": {
+				enabled:   true,
+				passwords: map[string]bool{"secret": true},
+			},
+		},
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return func() { ln.Close() }, map[string]interface{}{
+		"host":     "127.0.0.1",
+		"port":     ln.Addr().(*net.TCPAddr).Port,
+		"username": "default",
+		"password": "secret",
+	}
+}
+
+func testCredsExist(t *testing.T, connectionDetails map[string]interface{}, username, password string) error {
+	t.Helper()
+	address := net.JoinHostPort(connectionDetails["host"].(string), strconv.Itoa(connectionDetails["port"].(int)))
+	client, err := dialRedis(context.Background(), address, nil)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	_, err = client.Do(context.Background(), "AUTH", username, password)
+	return err
+}
+
+func copyConfig(config map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		result[k] = v
+	}
+	return result
+}
+
+func TestRedis_Initialize(t *testing.T) {
+	cleanup, connectionDetails := prepareRedisTestServer(t)
+	defer cleanup()
+
+	db := new()
+	_, err := db.Init(context.Background(), copyConfig(connectionDetails), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !db.Initialized {
+		t.Fatal("Database should be initialized")
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Bad credentials are detected when verifying the connection
+	badDetails := copyConfig(connectionDetails)
+	badDetails["password"] = "wrong"
+	if _, err := new().Init(context.Background(), badDetails, true); err == nil {
+		t.Fatal("expected error for bad credentials")
+	}
+}
+
+func TestRedis_CreateUser_RevokeUser(t *testing.T) {
+	cleanup, connectionDetails := prepareRedisTestServer(t)
+	defer cleanup()
+
+	db := new()
+	_, err := db.Init(context.Background(), copyConfig(connectionDetails), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	// Test with no configured Creation Statement
+	_, _, err = db.CreateUser(context.Background(), dbplugin.Statements{}, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error when no creation statement is provided")
+	}
+
+	_, _, err = db.CreateUser(context.Background(), dbplugin.Statements{
+		Creation: []string{`["off"]`},
+	}, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error for disallowed ACL rule")
+	}
+
+	statements := dbplugin.Statements{
+		Creation: []string{testRedisRole},
+	}
+	username, password, err := db.CreateUser(context.Background(), statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.HasPrefix(username, "v-test-test-") {
+		t.Fatalf("bad username: %s", username)
+	}
+
+	if err := testCredsExist(t, connectionDetails, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	if err := db.RevokeUser(context.Background(), statements, username); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := testCredsExist(t, connectionDetails, username, password); err == nil {
+		t.Fatal("Credentials were not revoked")
+	}
+}
+
+func TestRedis_UsernameTemplate(t *testing.T) {
+	cleanup, connectionDetails := prepareRedisTestServer(t)
+	defer cleanup()
+
+	config := copyConfig(connectionDetails)
+	config["username_template"] = "{{.RoleName}}_{{random 8}}"
+
+	db := new()
+	_, err := db.Init(context.Background(), config, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	username, _, err := db.CreateUser(context.Background(), dbplugin.Statements{
+		Creation: []string{testRedisRole},
+	}, dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "reader",
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.HasPrefix(username, "reader_") || len(username) != len("reader_")+8 {
+		t.Fatalf("bad username: %s", username)
+	}
+}
+
+func TestRedis_SetCredentials(t *testing.T) {
+	cleanup, connectionDetails := prepareRedisTestServer(t)
+	defer cleanup()
+
+	db := new()
+	_, err := db.Init(context.Background(), copyConfig(connectionDetails), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	username, password, err := db.CreateUser(context.Background(), dbplugin.Statements{
+		Creation: []string{testRedisRole},
+	}, dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, newPassword, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: username,
+		Password: "new-password",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if newPassword != "new-password" {
+		t.Fatalf("bad password: %s", newPassword)
+	}
+	if err := testCredsExist(t, connectionDetails, username, newPassword); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err := testCredsExist(t, connectionDetails, username, password); err == nil {
+		t.Fatal("Old password still valid")
+	}
+
+	_, _, err = db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: "missing",
+		Password: "new-password",
+	})
+	if err == nil {
+		t.Fatal("Expected error for missing user")
+	}
+}
+
+func TestRedis_RotateRootCredentials(t *testing.T) {
+	cleanup, connectionDetails := prepareRedisTestServer(t)
+	defer cleanup()
+
+	db := new()
+	_, err := db.Init(context.Background(), copyConfig(connectionDetails), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	newConf, err := db.RotateRootCredentials(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if newConf["password"] == connectionDetails["password"] {
+		t.Fatal("password was not updated")
+	}
+	if err := testCredsExist(t, connectionDetails, connectionDetails["username"].(string), newConf["password"].(string)); err != nil {
+		t.Fatalf("Could not connect with new root credentials: %s", err)
+	}
+
+	// The plugin reconnects with the new password
+	if err := db.RevokeUser(context.Background(), dbplugin.Statements{}, "missing"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
 	"github.com/hashicorp/vault/helper/keysutil"
 )
 
@@ -19,6 +20,26 @@ type CredentialsProducer interface {
 	GenerateExpiration(ttl time.Time) (string, error)
 }
 
+// UsernameTemplateSetter is implemented by the credentials producers that
+// support the "username_template" connection configuration.
+type UsernameTemplateSetter interface {
+	SetUsernameTemplate(conf map[string]interface{}) error
+}
+
+// SetUsernameTemplate sets the username template of the given credentials
+// producer from the connection configuration. An error is returned if a
+// template is configured but the producer does not support it.
+func SetUsernameTemplate(producer CredentialsProducer, conf map[string]interface{}) error {
+	if setter, ok := producer.(UsernameTemplateSetter); ok {
+		return setter.SetUsernameTemplate(conf)
+	}
+
+	if raw, ok := conf[v5.UsernameTemplateKey]; ok && raw != nil && raw != "" {
+		return fmt.Errorf("%s is not supported by this plugin", v5.UsernameTemplateKey)
+	}
+	return nil
+}
+
 const (
 	reqStr    = `A1a-`
 	minStrLen = 10
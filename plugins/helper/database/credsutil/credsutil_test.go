@@ -3,6 +3,8 @@ package credsutil
 import (
 	"strings"
 	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
 )
 
 func TestRandomAlphaNumeric(t *testing.T) {
@@ -38,3 +40,74 @@ func TestRandomAlphaNumeric(t *testing.T) {
 		t.Fatalf("Expected %s not to contain %s", s, reqStr)
 	}
 }
+
+func TestSQLCredentialsProducer_UsernameTemplate(t *testing.T) {
+	scp := &SQLCredentialsProducer{
+		DisplayNameLen: 8,
+		RoleNameLen:    8,
+		UsernameLen:    20,
+		Separator:      "-",
+	}
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "token",
+		RoleName:    "readonly",
+	}
+
+	username, err := scp.GenerateUsername(usernameConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(username, "v-token-readonly-") {
+		t.Fatalf("bad default username: %s", username)
+	}
+
+	err = scp.SetUsernameTemplate(map[string]interface{}{
+		"username_template": "{{.RoleName | uppercase}}_{{.DisplayName}}_{{random 20}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	username, err = scp.GenerateUsername(usernameConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(username) != 20 || !strings.HasPrefix(username, "READONLY_token_") {
+		t.Fatalf("bad templated username: %s", username)
+	}
+
+	if err := scp.SetUsernameTemplate(map[string]interface{}{"username_template": "{{.RoleName"}); err == nil {
+		t.Fatal("expected error for invalid template")
+	}
+
+	// Removing the template restores the default format
+	if err := scp.SetUsernameTemplate(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	username, err = scp.GenerateUsername(usernameConfig)
+	if err != nil || !strings.HasPrefix(username, "v-token-readonly-") {
+		t.Fatalf("bad default username: %s, err: %v", username, err)
+	}
+}
+
+// legacyCredentialsProducer is a producer without username template support
+type legacyCredentialsProducer struct {
+	CredentialsProducer
+}
+
+func TestSetUsernameTemplate(t *testing.T) {
+	conf := map[string]interface{}{
+		"username_template": "{{.RoleName}}_{{random 20}}",
+	}
+
+	if err := SetUsernameTemplate(&SQLCredentialsProducer{}, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := legacyCredentialsProducer{}
+	if err := SetUsernameTemplate(legacy, conf); err == nil {
+		t.Fatal("expected error for producer without template support")
+	}
+	if err := SetUsernameTemplate(legacy, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,9 +2,13 @@ package credsutil
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	v5 "github.com/hashicorp/vault/builtin/logical/database/dbplugin/v5"
+	"github.com/hashicorp/vault/helper/template"
 )
 
 const (
@@ -17,9 +21,44 @@ type SQLCredentialsProducer struct {
 	RoleNameLen    int
 	UsernameLen    int
 	Separator      string
+
+	usernameTemplate *template.StringTemplate
+	l                sync.RWMutex
+}
+
+// SetUsernameTemplate sets the template of generated usernames from the
+// "username_template" key of the connection configuration. Without a
+// template, usernames keep the default format.
+func (scp *SQLCredentialsProducer) SetUsernameTemplate(conf map[string]interface{}) error {
+	var tmpl *template.StringTemplate
+	if raw, ok := conf[v5.UsernameTemplateKey]; ok && raw != nil {
+		rawStr, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", v5.UsernameTemplateKey)
+		}
+		if rawStr != "" {
+			var err error
+			tmpl, err = template.NewTemplate(rawStr)
+			if err != nil {
+				return errwrap.Wrapf("invalid username_template: {{err}}", err)
+			}
+		}
+	}
+
+	scp.l.Lock()
+	defer scp.l.Unlock()
+	scp.usernameTemplate = tmpl
+	return nil
 }
 
 func (scp *SQLCredentialsProducer) GenerateUsername(config dbplugin.UsernameConfig) (string, error) {
+	scp.l.RLock()
+	tmpl := scp.usernameTemplate
+	scp.l.RUnlock()
+	if tmpl != nil {
+		return scp.generateTemplatedUsername(tmpl, config)
+	}
+
 	username := "v"
 
 	displayName := config.DisplayName
@@ -58,6 +97,26 @@ func (scp *SQLCredentialsProducer) GenerateUsername(config dbplugin.UsernameConf
 	return username, nil
 }
 
+// generateTemplatedUsername renders the username template, truncating the
+// result to the maximum username length of the database
+func (scp *SQLCredentialsProducer) generateTemplatedUsername(tmpl *template.StringTemplate, config dbplugin.UsernameConfig) (string, error) {
+	username, err := tmpl.Generate(v5.UsernameMetadata{
+		DisplayName: config.DisplayName,
+		RoleName:    config.RoleName,
+	})
+	if err != nil {
+		return "", err
+	}
+	if username == "" {
+		return "", fmt.Errorf("username template generated an empty username")
+	}
+	if scp.UsernameLen > 0 && len(username) > scp.UsernameLen {
+		username = username[:scp.UsernameLen]
+	}
+
+	return username, nil
+}
+
 func (scp *SQLCredentialsProducer) GeneratePassword() (string, error) {
 	password, err := RandomAlphaNumeric(20, true)
 	if err != nil {
@@ -310,9 +310,9 @@ CHECK:
 	case logical.CreateOperation:
 		operationAllowed = capabilities&CreateCapabilityInt > 0
 
-	// These three re-use UpdateCapabilityInt since that's the most appropriate
+	// These re-use UpdateCapabilityInt since that's the most appropriate
 	// capability/operation mapping
-	case logical.RevokeOperation, logical.RenewOperation, logical.RollbackOperation:
+	case logical.PatchOperation, logical.RevokeOperation, logical.RenewOperation, logical.RollbackOperation:
 		operationAllowed = capabilities&UpdateCapabilityInt > 0
 
 	default:
@@ -343,7 +343,7 @@ CHECK:
 
 	// Only check parameter permissions for operations that can modify
 	// parameters.
-	if op == logical.ReadOperation || op == logical.UpdateOperation || op == logical.CreateOperation {
+	if op == logical.ReadOperation || op == logical.UpdateOperation || op == logical.CreateOperation || op == logical.PatchOperation {
 		for _, parameter := range permissions.RequiredParameters {
 			if _, ok := req.Data[strings.ToLower(parameter)]; !ok {
 				return
@@ -25,6 +25,7 @@ import (
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/helper/logging"
 	"github.com/hashicorp/vault/helper/mlock"
 	"github.com/hashicorp/vault/helper/reload"
@@ -264,6 +265,10 @@ type Core struct {
 	// identityStore is used to manage client entities
 	identityStore *IdentityStore
 
+	// loginMFARequestCache holds the logins waiting for their MFA
+	// requirement to be validated. It is not persisted nor replicated.
+	loginMFARequestCache *cache.Cache
+
 	// metricsCh is used to stop the metrics streaming
 	metricsCh chan struct{}
 
@@ -292,6 +297,20 @@ type Core struct {
 	// wrapping information
 	wrappingJWTKey *ecdsa.PrivateKey
 
+	// wrappingHistoryLocks protect the histories of the wrapping tokens,
+	// keyed by their salted accessor
+	wrappingHistoryLocks []*locksutil.LockEntry
+
+	// wrappingHistoryConfig is the configuration of the wrapping token
+	// histories, loaded on unseal
+	wrappingHistoryConfig     *wrappingConfig
+	wrappingHistoryConfigLock sync.RWMutex
+
+	// wrappingHistoryTidyLock protects the time the wrapping token histories
+	// were last tidied
+	wrappingHistoryTidyLock sync.Mutex
+	wrappingHistoryLastTidy time.Time
+
 	//
 	// Cluster information
 	//
@@ -490,6 +509,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		clusterListenerShutdownCh:        make(chan struct{}),
 		clusterListenerShutdownSuccessCh: make(chan struct{}),
 		clusterPeerClusterAddrsCache:     cache.New(3*HeartbeatInterval, time.Second),
+		loginMFARequestCache:             cache.New(loginMFARequestTTL, time.Minute),
 		enableMlock:                      !conf.DisableMlock,
 		rawEnabled:                       conf.EnableRaw,
 		replicationState:                 new(uint32),
@@ -500,6 +520,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		localClusterCert:                 new(atomic.Value),
 		localClusterParsedCert:           new(atomic.Value),
 		activeNodeReplicationState:       new(uint32),
+		wrappingHistoryLocks:             locksutil.CreateLocks(),
 	}
 
 	atomic.StoreUint32(c.replicationState, uint32(consts.ReplicationDRDisabled|consts.ReplicationPerformanceDisabled))
@@ -1242,6 +1263,9 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.loadCORSConfig(c.activeContext); err != nil {
 		return err
 	}
+	if err := c.loadWrappingConfig(c.activeContext); err != nil {
+		return err
+	}
 	if err := c.loadCredentials(c.activeContext); err != nil {
 		return err
 	}
@@ -3,10 +3,13 @@ package vault
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	sockaddr "github.com/hashicorp/go-sockaddr"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/pluginutil"
 	"github.com/hashicorp/vault/helper/wrapping"
 	"github.com/hashicorp/vault/logical"
@@ -174,6 +177,7 @@ func (d dynamicSystemView) EntityInfo(entityID string) (*logical.Entity, error)
 		aliases[i] = &logical.Alias{
 			MountAccessor: alias.MountAccessor,
 			Name:          alias.Name,
+			Metadata:      alias.Metadata,
 		}
 		// MountType is not stored with the entity and must be looked up
 		if mount := d.core.router.validateMountByAccessor(alias.MountAccessor); mount != nil {
@@ -183,8 +187,146 @@ func (d dynamicSystemView) EntityInfo(entityID string) (*logical.Entity, error)
 
 	// Only returning a subset of the data
 	return &logical.Entity{
-		ID:      entity.ID,
-		Name:    entity.Name,
-		Aliases: aliases,
+		ID:       entity.ID,
+		Name:     entity.Name,
+		Aliases:  aliases,
+		Metadata: entity.Metadata,
 	}, nil
 }
+
+// AuthenticateLogin routes the given login request to the auth mount with the
+// given accessor. The request is handled by the credential backend only; no
+// token is created and no lease is registered. Since the caller cannot be
+// asked for a second factor, logins subject to a login MFA enforcement are
+// refused.
+func (d dynamicSystemView) AuthenticateLogin(ctx context.Context, mountAccessor string, req *logical.Request) (*logical.Auth, error) {
+	if d.core == nil {
+		return nil, fmt.Errorf("system view core is nil")
+	}
+
+	entry := d.core.router.MatchingMountByAccessor(mountAccessor)
+	if entry == nil || entry.Table != credentialTableType {
+		return nil, fmt.Errorf("no auth mount found for accessor %q", mountAccessor)
+	}
+
+	loginReq := &logical.Request{
+		ID:         req.ID,
+		Operation:  logical.UpdateOperation,
+		Path:       credentialRoutePrefix + entry.Path + strings.TrimPrefix(req.Path, "/"),
+		Data:       req.Data,
+		Connection: req.Connection,
+	}
+	if !d.core.router.LoginPath(loginReq.Path) {
+		return nil, fmt.Errorf("path %q is not a login path", loginReq.Path)
+	}
+
+	resp, err := d.core.router.Route(ctx, loginReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Auth == nil {
+		if resp != nil && resp.IsError() {
+			return nil, resp.Error()
+		}
+		return nil, logical.ErrPermissionDenied
+	}
+	auth := resp.Auth
+
+	var entity *identity.Entity
+	if auth.Alias != nil && !entry.Local && d.core.identityStore != nil {
+		auth.Alias.MountType = entry.Type
+		auth.Alias.MountAccessor = entry.Accessor
+
+		if auth.Alias.Name == "" {
+			return nil, fmt.Errorf("missing name in alias")
+		}
+
+		entity, err = d.core.identityStore.CreateOrFetchEntity(auth.Alias)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil {
+			return nil, fmt.Errorf("failed to create an entity for the authenticated alias")
+		}
+		if entity.Disabled {
+			return nil, logical.ErrPermissionDenied
+		}
+		auth.EntityID = entity.ID
+	}
+
+	mfaRequirement, err := d.core.loginMFARequirement(ctx, loginReq.Path, resp, entity)
+	if err != nil {
+		return nil, err
+	}
+	if mfaRequirement != nil {
+		return nil, fmt.Errorf("the login is subject to a login MFA enforcement, which cannot be satisfied here")
+	}
+
+	// The bound CIDRs would be checked on the use of the token, which is
+	// never created
+	if len(auth.BoundCIDRs) > 0 {
+		if req.Connection == nil {
+			return nil, logical.ErrPermissionDenied
+		}
+		remoteSockAddr, err := sockaddr.NewSockAddr(req.Connection.RemoteAddr)
+		if err != nil {
+			return nil, logical.ErrPermissionDenied
+		}
+		var valid bool
+		for _, cidr := range auth.BoundCIDRs {
+			if cidr.Contains(remoteSockAddr) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, logical.ErrPermissionDenied
+		}
+	}
+
+	return auth, nil
+}
+
+// AuthorizeLogin checks the request against the ACL built from the policies
+// of the given auth and of its entity, as the token created for the login
+// would be.
+func (d dynamicSystemView) AuthorizeLogin(ctx context.Context, auth *logical.Auth, req *logical.Request) (bool, error) {
+	if d.core == nil {
+		return false, fmt.Errorf("system view core is nil")
+	}
+	if auth == nil {
+		return false, nil
+	}
+
+	policies := append([]string{}, auth.Policies...)
+	_, identityPolicies, err := d.core.fetchEntityAndDerivedPolicies(auth.EntityID)
+	if err != nil {
+		return false, err
+	}
+	policies = append(policies, identityPolicies...)
+
+	acl, err := d.core.policyStore.ACL(ctx, policies...)
+	if err != nil {
+		return false, err
+	}
+
+	return acl.AllowOperation(req).Allowed, nil
+}
+
+// GeneratePasswordFromPolicy generates a password from the named password
+// policy of sys/policies/password.
+func (d dynamicSystemView) GeneratePasswordFromPolicy(ctx context.Context, policyName string) (string, error) {
+	if d.core == nil {
+		return "", fmt.Errorf("system view core is nil")
+	}
+
+	generator, err := d.core.passwordPolicy(ctx, policyName)
+	if err != nil {
+		return "", err
+	}
+	if generator == nil {
+		return "", fmt.Errorf("password policy %q not found", policyName)
+	}
+
+	return generator.Generate()
+}
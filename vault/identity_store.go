@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/hashicorp/errwrap"
@@ -14,6 +15,7 @@ import (
 	"github.com/hashicorp/vault/helper/storagepacker"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
 )
 
 const (
@@ -35,11 +37,12 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 	}
 
 	iStore := &IdentityStore{
-		view:        config.StorageView,
-		db:          db,
-		entityLocks: locksutil.CreateLocks(),
-		logger:      logger,
-		core:        core,
+		view:                 config.StorageView,
+		db:                   db,
+		entityLocks:          locksutil.CreateLocks(),
+		logger:               logger,
+		core:                 core,
+		mfaUsedPasscodeCache: cache.New(cache.NoExpiration, time.Minute),
 	}
 
 	iStore.entityPacker, err = storagepacker.NewStoragePacker(iStore.view, iStore.logger, "")
@@ -61,8 +64,20 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 			groupPaths(iStore),
 			lookupPaths(iStore),
 			upgradePaths(iStore),
+			oidcPaths(iStore),
+			oidcProviderPaths(iStore),
+			mfaPaths(iStore),
 		),
-		Invalidate: iStore.Invalidate,
+		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"oidc/.well-known/*",
+				"oidc/provider/+/.well-known/*",
+				"oidc/provider/+/token",
+				"oidc/provider/+/userinfo",
+			},
+		},
+		PeriodicFunc: iStore.oidcPeriodicFunc,
+		Invalidate:   iStore.Invalidate,
 	}
 
 	err = iStore.Setup(ctx, config)
@@ -0,0 +1,1002 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"strings"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/identitytpl"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// Storage paths of login MFA
+	mfaPrefix            = "mfa/"
+	mfaMethodPath        = mfaPrefix + "method/"
+	mfaTOTPSecretPath    = mfaPrefix + "totp_secret/"
+	mfaLoginEnforcePath  = mfaPrefix + "login_enforcement/"
+	mfaMethodTypeTOTP    = "totp"
+	mfaMethodTypeDuo     = "duo"
+	mfaMethodTypeOkta    = "okta"
+	mfaMethodTypePingID  = "pingid"
+	mfaDefaultTOTPPeriod = 30
+)
+
+// mfaMethodTypes are the supported types of login MFA methods
+var mfaMethodTypes = []string{
+	mfaMethodTypeTOTP,
+	mfaMethodTypeDuo,
+	mfaMethodTypeOkta,
+	mfaMethodTypePingID,
+}
+
+// mfaMethod is a configured login MFA method. Only the configuration of its
+// type is set.
+type mfaMethod struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	UsernameFormat string `json:"username_format"`
+
+	TOTP   *totpConfig   `json:"totp,omitempty"`
+	Duo    *duoConfig    `json:"duo,omitempty"`
+	Okta   *oktaConfig   `json:"okta,omitempty"`
+	PingID *pingIDConfig `json:"pingid,omitempty"`
+}
+
+type totpConfig struct {
+	Issuer    string `json:"issuer"`
+	Period    uint   `json:"period"`
+	KeySize   uint   `json:"key_size"`
+	QRSize    int    `json:"qr_size"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Skew      uint   `json:"skew"`
+}
+
+type duoConfig struct {
+	IntegrationKey string `json:"integration_key"`
+	SecretKey      string `json:"secret_key"`
+	APIHostname    string `json:"api_hostname"`
+	PushInfo       string `json:"push_info"`
+	UsePasscode    bool   `json:"use_passcode"`
+}
+
+type oktaConfig struct {
+	OrgName      string `json:"org_name"`
+	APIToken     string `json:"api_token"`
+	BaseURL      string `json:"base_url"`
+	PrimaryEmail bool   `json:"primary_email"`
+}
+
+type pingIDConfig struct {
+	UseBase64Key     string `json:"use_base64_key"`
+	UseSignature     bool   `json:"use_signature"`
+	Token            string `json:"token"`
+	IDPURL           string `json:"idp_url"`
+	OrgAlias         string `json:"org_alias"`
+	AdminURL         string `json:"admin_url"`
+	AuthenticatorURL string `json:"authenticator_url"`
+}
+
+// usesPasscode returns whether the method is validated with a passcode
+func (m *mfaMethod) usesPasscode() bool {
+	switch m.Type {
+	case mfaMethodTypeTOTP:
+		return true
+	case mfaMethodTypeDuo:
+		return m.Duo.UsePasscode
+	}
+	return false
+}
+
+// loginEnforcement requires logins matching any of its targets to be
+// validated with one of its MFA methods
+type loginEnforcement struct {
+	Name                string   `json:"name"`
+	MFAMethodIDs        []string `json:"mfa_method_ids"`
+	AuthMethodAccessors []string `json:"auth_method_accessors"`
+	AuthMethodTypes     []string `json:"auth_method_types"`
+	IdentityGroupIDs    []string `json:"identity_group_ids"`
+	IdentityEntityIDs   []string `json:"identity_entity_ids"`
+}
+
+// totpSecret is the TOTP key of an entity for a TOTP method
+type totpSecret struct {
+	URL string `json:"url"`
+}
+
+func mfaPaths(i *IdentityStore) []*framework.Path {
+	usernameFormatField := &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Template of the username sent to the MFA provider, for example "{{identity.entity.aliases.<mount accessor>.name}}@example.com". Defaults to the name of the entity.`,
+	}
+	methodIDField := &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "ID of the MFA method.",
+	}
+	entityIDField := &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "ID of the entity.",
+	}
+
+	methodFields := map[string]map[string]*framework.FieldSchema{
+		mfaMethodTypeTOTP: {
+			"issuer": {
+				Type:        framework.TypeString,
+				Description: "Name of the issuer of the TOTP keys. Required.",
+			},
+			"period": {
+				Type:        framework.TypeDurationSecond,
+				Default:     mfaDefaultTOTPPeriod,
+				Description: "Validity period of the passcodes.",
+			},
+			"key_size": {
+				Type:        framework.TypeInt,
+				Default:     20,
+				Description: "Size in bytes of the generated keys.",
+			},
+			"qr_size": {
+				Type:        framework.TypeInt,
+				Default:     200,
+				Description: "Pixel size of the generated square QR code. Zero disables the QR code.",
+			},
+			"algorithm": {
+				Type:        framework.TypeString,
+				Default:     "SHA1",
+				Description: `Hashing algorithm of the passcodes: "SHA1", "SHA256" or "SHA512".`,
+			},
+			"digits": {
+				Type:        framework.TypeInt,
+				Default:     6,
+				Description: "Number of digits of the passcodes: 6 or 8.",
+			},
+			"skew": {
+				Type:        framework.TypeInt,
+				Default:     1,
+				Description: "Number of periods before and after the current one in which passcodes are accepted: 0 or 1.",
+			},
+		},
+		mfaMethodTypeDuo: {
+			"username_format": usernameFormatField,
+			"integration_key": {
+				Type:        framework.TypeString,
+				Description: "Integration key of the Duo application. Required.",
+			},
+			"secret_key": {
+				Type:        framework.TypeString,
+				Description: "Secret key of the Duo application. Required.",
+			},
+			"api_hostname": {
+				Type:        framework.TypeString,
+				Description: "API hostname of the Duo application. Required.",
+			},
+			"push_info": {
+				Type:        framework.TypeString,
+				Description: "URL-encoded key/value pairs shown in Duo push notifications.",
+			},
+			"use_passcode": {
+				Type:        framework.TypeBool,
+				Description: "Whether users must give a Duo passcode instead of approving a push notification.",
+			},
+		},
+		mfaMethodTypeOkta: {
+			"username_format": usernameFormatField,
+			"org_name": {
+				Type:        framework.TypeString,
+				Description: "Name of the Okta organization. Required.",
+			},
+			"api_token": {
+				Type:        framework.TypeString,
+				Description: "Okta API token. Required.",
+			},
+			"base_url": {
+				Type:        framework.TypeString,
+				Default:     "okta.com",
+				Description: "Base domain of the Okta API.",
+			},
+			"primary_email": {
+				Type:        framework.TypeBool,
+				Description: "Whether the username is matched against the primary email of the Okta users instead of their login.",
+			},
+		},
+		mfaMethodTypePingID: {
+			"username_format": usernameFormatField,
+			"settings_file_base64": {
+				Type:        framework.TypeString,
+				Description: "Base64-encoded content of the PingID settings file. Required.",
+			},
+		},
+	}
+
+	var paths []*framework.Path
+	paths = append(paths,
+		&framework.Path{
+			Pattern: "mfa/method/totp/generate$",
+			Fields: map[string]*framework.FieldSchema{
+				"method_id": methodIDField,
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathMFATOTPGenerate(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["totp-generate"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["totp-generate"][1]),
+		},
+		&framework.Path{
+			Pattern: "mfa/method/totp/admin-generate$",
+			Fields: map[string]*framework.FieldSchema{
+				"method_id": methodIDField,
+				"entity_id": entityIDField,
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathMFATOTPAdminGenerate(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["totp-admin-generate"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["totp-admin-generate"][1]),
+		},
+		&framework.Path{
+			Pattern: "mfa/method/totp/admin-destroy$",
+			Fields: map[string]*framework.FieldSchema{
+				"method_id": methodIDField,
+				"entity_id": entityIDField,
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathMFATOTPAdminDestroy(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["totp-admin-destroy"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["totp-admin-destroy"][1]),
+		},
+	)
+
+	for _, methodType := range mfaMethodTypes {
+		fields := methodFields[methodType]
+		paths = append(paths,
+			&framework.Path{
+				Pattern: "mfa/method/" + methodType + "/?$",
+				Fields:  fields,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: i.pathMFAMethodWrite(methodType),
+					logical.ListOperation:   i.pathMFAMethodList(methodType),
+				},
+
+				HelpSynopsis:    strings.TrimSpace(mfaHelp["method"][0]),
+				HelpDescription: strings.TrimSpace(mfaHelp["method"][1]),
+			},
+			&framework.Path{
+				Pattern: "mfa/method/" + methodType + "/" + framework.GenericNameRegex("method_id"),
+				Fields:  withMethodIDField(fields, methodIDField),
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   i.pathMFAMethodRead(methodType),
+					logical.UpdateOperation: i.pathMFAMethodWrite(methodType),
+					logical.DeleteOperation: i.pathMFAMethodDelete(methodType),
+				},
+
+				HelpSynopsis:    strings.TrimSpace(mfaHelp["method"][0]),
+				HelpDescription: strings.TrimSpace(mfaHelp["method"][1]),
+			},
+		)
+	}
+
+	return append(paths,
+		&framework.Path{
+			Pattern: "mfa/method/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathMFAMethodList(""),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["method-list"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["method-list"][1]),
+		},
+		&framework.Path{
+			Pattern: "mfa/login-enforcement/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the login enforcement.",
+				},
+				"mfa_method_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the IDs of the MFA methods, any of which satisfies the enforcement. Required.",
+				},
+				"auth_method_accessors": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the accessors of the auth mounts whose logins are enforced.",
+				},
+				"auth_method_types": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the types of the auth methods whose logins are enforced.",
+				},
+				"identity_group_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the IDs of the groups whose members' logins are enforced.",
+				},
+				"identity_entity_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the IDs of the entities whose logins are enforced.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   i.pathMFALoginEnforcementRead(),
+				logical.UpdateOperation: i.pathMFALoginEnforcementWrite(),
+				logical.DeleteOperation: i.pathMFALoginEnforcementDelete(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["login-enforcement"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["login-enforcement"][1]),
+		},
+		&framework.Path{
+			Pattern: "mfa/login-enforcement/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCList(mfaLoginEnforcePath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["login-enforcement-list"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["login-enforcement-list"][1]),
+		},
+	)
+}
+
+// withMethodIDField returns a copy of fields with the method_id field added
+func withMethodIDField(fields map[string]*framework.FieldSchema, methodIDField *framework.FieldSchema) map[string]*framework.FieldSchema {
+	ret := make(map[string]*framework.FieldSchema, len(fields)+1)
+	for k, v := range fields {
+		ret[k] = v
+	}
+	ret["method_id"] = methodIDField
+	return ret
+}
+
+func (i *IdentityStore) getMFAMethod(ctx context.Context, methodID string) (*mfaMethod, error) {
+	var method mfaMethod
+	ok, err := i.getOIDCEntry(ctx, mfaMethodPath+methodID, &method)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &method, nil
+}
+
+func (i *IdentityStore) getLoginEnforcement(ctx context.Context, name string) (*loginEnforcement, error) {
+	var enforcement loginEnforcement
+	ok, err := i.getOIDCEntry(ctx, mfaLoginEnforcePath+name, &enforcement)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &enforcement, nil
+}
+
+// listLoginEnforcements returns all the login enforcements
+func (i *IdentityStore) listLoginEnforcements(ctx context.Context) ([]*loginEnforcement, error) {
+	names, err := i.view.List(ctx, mfaLoginEnforcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	enforcements := make([]*loginEnforcement, 0, len(names))
+	for _, name := range names {
+		enforcement, err := i.getLoginEnforcement(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if enforcement != nil {
+			enforcements = append(enforcements, enforcement)
+		}
+	}
+	return enforcements, nil
+}
+
+func (i *IdentityStore) pathMFAMethodList(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.RLock()
+		defer i.mfaLock.RUnlock()
+
+		ids, err := i.view.List(ctx, mfaMethodPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var keys []string
+		keyInfo := make(map[string]interface{})
+		for _, id := range ids {
+			method, err := i.getMFAMethod(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if method == nil || (methodType != "" && method.Type != methodType) {
+				continue
+			}
+			keys = append(keys, id)
+			keyInfo[id] = map[string]interface{}{
+				"type": method.Type,
+			}
+		}
+
+		return logical.ListResponseWithInfo(keys, keyInfo), nil
+	}
+}
+
+func (i *IdentityStore) pathMFAMethodRead(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.RLock()
+		defer i.mfaLock.RUnlock()
+
+		method, err := i.getMFAMethod(ctx, d.Get("method_id").(string))
+		if err != nil {
+			return nil, err
+		}
+		if method == nil || method.Type != methodType {
+			return nil, nil
+		}
+
+		data := map[string]interface{}{
+			"id":   method.ID,
+			"type": method.Type,
+		}
+		switch method.Type {
+		case mfaMethodTypeTOTP:
+			data["issuer"] = method.TOTP.Issuer
+			data["period"] = method.TOTP.Period
+			data["key_size"] = method.TOTP.KeySize
+			data["qr_size"] = method.TOTP.QRSize
+			data["algorithm"] = method.TOTP.Algorithm
+			data["digits"] = method.TOTP.Digits
+			data["skew"] = method.TOTP.Skew
+		case mfaMethodTypeDuo:
+			data["username_format"] = method.UsernameFormat
+			data["integration_key"] = method.Duo.IntegrationKey
+			data["api_hostname"] = method.Duo.APIHostname
+			data["push_info"] = method.Duo.PushInfo
+			data["use_passcode"] = method.Duo.UsePasscode
+		case mfaMethodTypeOkta:
+			data["username_format"] = method.UsernameFormat
+			data["org_name"] = method.Okta.OrgName
+			data["base_url"] = method.Okta.BaseURL
+			data["primary_email"] = method.Okta.PrimaryEmail
+		case mfaMethodTypePingID:
+			data["username_format"] = method.UsernameFormat
+			data["use_signature"] = method.PingID.UseSignature
+			data["idp_url"] = method.PingID.IDPURL
+			data["org_alias"] = method.PingID.OrgAlias
+			data["admin_url"] = method.PingID.AdminURL
+			data["authenticator_url"] = method.PingID.AuthenticatorURL
+		}
+
+		return &logical.Response{
+			Data: data,
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathMFAMethodWrite(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.Lock()
+		defer i.mfaLock.Unlock()
+
+		var method *mfaMethod
+		if raw, ok := d.GetOk("method_id"); ok {
+			var err error
+			method, err = i.getMFAMethod(ctx, raw.(string))
+			if err != nil {
+				return nil, err
+			}
+			if method == nil || method.Type != methodType {
+				return logical.ErrorResponse(fmt.Sprintf("no %s MFA method with ID %q", methodType, raw.(string))), logical.ErrInvalidRequest
+			}
+		} else {
+			methodID, err := uuid.GenerateUUID()
+			if err != nil {
+				return nil, err
+			}
+			method = &mfaMethod{
+				ID:   methodID,
+				Type: methodType,
+			}
+		}
+
+		if raw, ok := d.GetOk("username_format"); ok {
+			method.UsernameFormat = raw.(string)
+		}
+		if method.UsernameFormat != "" {
+			if _, _, err := identitytpl.PopulateString(&logical.Entity{}, method.UsernameFormat); err != nil && err != identitytpl.ErrTemplateValueNotFound {
+				return logical.ErrorResponse(fmt.Sprintf("invalid username_format: %s", err)), nil
+			}
+		}
+
+		var err error
+		switch methodType {
+		case mfaMethodTypeTOTP:
+			err = parseTOTPConfig(method, d)
+		case mfaMethodTypeDuo:
+			err = parseDuoConfig(method, d)
+		case mfaMethodTypeOkta:
+			err = parseOktaConfig(method, d)
+		case mfaMethodTypePingID:
+			err = parsePingIDConfig(method, d)
+		}
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if err := i.putOIDCEntry(ctx, mfaMethodPath+method.ID, method); err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"method_id": method.ID,
+			},
+		}, nil
+	}
+}
+
+// fieldValue returns the value of the field if it's set or if existing is
+// the zero value, and existing otherwise
+func fieldValue(d *framework.FieldData, name string, existing interface{}) interface{} {
+	if raw, ok := d.GetOk(name); ok {
+		return raw
+	}
+	switch existing.(type) {
+	case string:
+		if existing != "" {
+			return existing
+		}
+	case int, uint:
+		if fmt.Sprint(existing) != "0" {
+			return existing
+		}
+	default:
+		return existing
+	}
+	return d.Get(name)
+}
+
+func parseTOTPConfig(method *mfaMethod, d *framework.FieldData) error {
+	config := method.TOTP
+	if config == nil {
+		// The TOTP settings can't be changed once keys have been generated
+		config = &totpConfig{
+			Period:    uint(d.Get("period").(int)),
+			KeySize:   uint(d.Get("key_size").(int)),
+			Algorithm: d.Get("algorithm").(string),
+			Digits:    d.Get("digits").(int),
+			Skew:      uint(d.Get("skew").(int)),
+		}
+	}
+	config.Issuer = fieldValue(d, "issuer", config.Issuer).(string)
+	if raw, ok := d.GetOk("qr_size"); ok || method.TOTP == nil {
+		if !ok {
+			raw = d.Get("qr_size")
+		}
+		config.QRSize = raw.(int)
+	}
+
+	switch {
+	case config.Issuer == "":
+		return fmt.Errorf("issuer is required")
+	case config.Period == 0:
+		return fmt.Errorf("period must be positive")
+	case config.KeySize == 0:
+		return fmt.Errorf("key_size must be positive")
+	case config.QRSize < 0:
+		return fmt.Errorf("qr_size must not be negative")
+	case config.Digits != 6 && config.Digits != 8:
+		return fmt.Errorf("digits must be 6 or 8")
+	case config.Skew > 1:
+		return fmt.Errorf("skew must be 0 or 1")
+	}
+	if _, err := totpAlgorithm(config.Algorithm); err != nil {
+		return err
+	}
+
+	method.TOTP = config
+	return nil
+}
+
+func totpAlgorithm(name string) (otp.Algorithm, error) {
+	switch name {
+	case "SHA1":
+		return otp.AlgorithmSHA1, nil
+	case "SHA256":
+		return otp.AlgorithmSHA256, nil
+	case "SHA512":
+		return otp.AlgorithmSHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported algorithm %q", name)
+}
+
+func parseDuoConfig(method *mfaMethod, d *framework.FieldData) error {
+	config := method.Duo
+	if config == nil {
+		config = &duoConfig{}
+	}
+	config.IntegrationKey = fieldValue(d, "integration_key", config.IntegrationKey).(string)
+	config.SecretKey = fieldValue(d, "secret_key", config.SecretKey).(string)
+	config.APIHostname = fieldValue(d, "api_hostname", config.APIHostname).(string)
+	config.PushInfo = fieldValue(d, "push_info", config.PushInfo).(string)
+	config.UsePasscode = fieldValue(d, "use_passcode", config.UsePasscode).(bool)
+
+	if config.IntegrationKey == "" || config.SecretKey == "" || config.APIHostname == "" {
+		return fmt.Errorf("integration_key, secret_key and api_hostname are required")
+	}
+
+	method.Duo = config
+	return nil
+}
+
+func parseOktaConfig(method *mfaMethod, d *framework.FieldData) error {
+	config := method.Okta
+	if config == nil {
+		config = &oktaConfig{}
+	}
+	config.OrgName = fieldValue(d, "org_name", config.OrgName).(string)
+	config.APIToken = fieldValue(d, "api_token", config.APIToken).(string)
+	config.BaseURL = fieldValue(d, "base_url", config.BaseURL).(string)
+	config.PrimaryEmail = fieldValue(d, "primary_email", config.PrimaryEmail).(bool)
+
+	if config.OrgName == "" || config.APIToken == "" {
+		return fmt.Errorf("org_name and api_token are required")
+	}
+
+	method.Okta = config
+	return nil
+}
+
+func parsePingIDConfig(method *mfaMethod, d *framework.FieldData) error {
+	raw, ok := d.GetOk("settings_file_base64")
+	if !ok {
+		if method.PingID == nil {
+			return fmt.Errorf("settings_file_base64 is required")
+		}
+		return nil
+	}
+
+	settings, err := base64.StdEncoding.DecodeString(raw.(string))
+	if err != nil {
+		return fmt.Errorf("error decoding settings_file_base64: %s", err)
+	}
+
+	config := &pingIDConfig{}
+	for _, line := range strings.Split(string(settings), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid line in the settings file: %q", line)
+		}
+		value := strings.TrimSpace(split[1])
+		switch strings.TrimSpace(split[0]) {
+		case "use_base64_key":
+			config.UseBase64Key = value
+		case "use_signature":
+			config.UseSignature = value == "true"
+		case "token":
+			config.Token = value
+		case "idp_url":
+			config.IDPURL = value
+		case "org_alias":
+			config.OrgAlias = value
+		case "admin_url":
+			config.AdminURL = value
+		case "authenticator_url":
+			config.AuthenticatorURL = value
+		}
+	}
+
+	switch {
+	case config.UseBase64Key == "" || config.Token == "" || config.IDPURL == "" || config.OrgAlias == "":
+		return fmt.Errorf("the settings file must set use_base64_key, token, idp_url and org_alias")
+	case !config.UseSignature:
+		return fmt.Errorf("only PingID settings using signatures are supported")
+	}
+
+	method.PingID = config
+	return nil
+}
+
+func (i *IdentityStore) pathMFAMethodDelete(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.Lock()
+		defer i.mfaLock.Unlock()
+
+		methodID := d.Get("method_id").(string)
+		method, err := i.getMFAMethod(ctx, methodID)
+		if err != nil {
+			return nil, err
+		}
+		if method == nil || method.Type != methodType {
+			return nil, nil
+		}
+
+		enforcements, err := i.listLoginEnforcements(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, enforcement := range enforcements {
+			if strutil.StrListContains(enforcement.MFAMethodIDs, methodID) {
+				return logical.ErrorResponse(fmt.Sprintf("unable to delete MFA method %q because it is used by the login enforcement %q", methodID, enforcement.Name)), logical.ErrInvalidRequest
+			}
+		}
+
+		if method.Type == mfaMethodTypeTOTP {
+			entityIDs, err := i.view.List(ctx, mfaTOTPSecretPath+methodID+"/")
+			if err != nil {
+				return nil, err
+			}
+			for _, entityID := range entityIDs {
+				if err := i.view.Delete(ctx, mfaTOTPSecretPath+methodID+"/"+entityID); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return nil, i.view.Delete(ctx, mfaMethodPath+methodID)
+	}
+}
+
+func (i *IdentityStore) pathMFATOTPGenerate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if req.EntityID == "" {
+			return logical.ErrorResponse("no entity associated with the request's token"), logical.ErrInvalidRequest
+		}
+		return i.generateTOTPSecret(ctx, d.Get("method_id").(string), req.EntityID, false)
+	}
+}
+
+func (i *IdentityStore) pathMFATOTPAdminGenerate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		return i.generateTOTPSecret(ctx, d.Get("method_id").(string), d.Get("entity_id").(string), true)
+	}
+}
+
+// generateTOTPSecret generates the TOTP key of the entity for the method.
+// Existing keys are only replaced by administrators.
+func (i *IdentityStore) generateTOTPSecret(ctx context.Context, methodID, entityID string, replace bool) (*logical.Response, error) {
+	i.mfaLock.Lock()
+	defer i.mfaLock.Unlock()
+
+	method, err := i.getMFAMethod(ctx, methodID)
+	if err != nil {
+		return nil, err
+	}
+	if method == nil || method.Type != mfaMethodTypeTOTP {
+		return logical.ErrorResponse(fmt.Sprintf("no TOTP MFA method with ID %q", methodID)), logical.ErrInvalidRequest
+	}
+
+	entity, err := i.MemDBEntityByID(entityID, false)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no entity with ID %q", entityID)), logical.ErrInvalidRequest
+	}
+
+	secretPath := mfaTOTPSecretPath + methodID + "/" + entityID
+	if !replace {
+		existing, err := i.view.Get(ctx, secretPath)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return &logical.Response{
+				Warnings: []string{"the entity already has a key for this MFA method"},
+			}, nil
+		}
+	}
+
+	algorithm, err := totpAlgorithm(method.TOTP.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	accountName := entity.Name
+	if accountName == "" {
+		accountName = entity.ID
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      method.TOTP.Issuer,
+		AccountName: accountName,
+		Period:      method.TOTP.Period,
+		SecretSize:  method.TOTP.KeySize,
+		Digits:      otp.Digits(method.TOTP.Digits),
+		Algorithm:   algorithm,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.putOIDCEntry(ctx, secretPath, &totpSecret{URL: key.String()}); err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"url": key.String(),
+	}
+	if method.TOTP.QRSize > 0 {
+		image, err := key.Image(method.TOTP.QRSize, method.TOTP.QRSize)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image); err != nil {
+			return nil, err
+		}
+		data["barcode"] = base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+func (i *IdentityStore) pathMFATOTPAdminDestroy() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.Lock()
+		defer i.mfaLock.Unlock()
+
+		return nil, i.view.Delete(ctx, mfaTOTPSecretPath+d.Get("method_id").(string)+"/"+d.Get("entity_id").(string))
+	}
+}
+
+// getTOTPSecret returns the TOTP key of the entity for the method
+func (i *IdentityStore) getTOTPSecret(ctx context.Context, methodID, entityID string) (*otp.Key, error) {
+	var secret totpSecret
+	ok, err := i.getOIDCEntry(ctx, mfaTOTPSecretPath+methodID+"/"+entityID, &secret)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return otp.NewKeyFromURL(secret.URL)
+}
+
+func (i *IdentityStore) pathMFALoginEnforcementRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.RLock()
+		defer i.mfaLock.RUnlock()
+
+		enforcement, err := i.getLoginEnforcement(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if enforcement == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"name":                  enforcement.Name,
+				"mfa_method_ids":        enforcement.MFAMethodIDs,
+				"auth_method_accessors": enforcement.AuthMethodAccessors,
+				"auth_method_types":     enforcement.AuthMethodTypes,
+				"identity_group_ids":    enforcement.IdentityGroupIDs,
+				"identity_entity_ids":   enforcement.IdentityEntityIDs,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathMFALoginEnforcementWrite() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.Lock()
+		defer i.mfaLock.Unlock()
+
+		name := d.Get("name").(string)
+		enforcement, err := i.getLoginEnforcement(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if enforcement == nil {
+			enforcement = &loginEnforcement{
+				Name: name,
+			}
+		}
+
+		if raw, ok := d.GetOk("mfa_method_ids"); ok {
+			enforcement.MFAMethodIDs = raw.([]string)
+		}
+		if raw, ok := d.GetOk("auth_method_accessors"); ok {
+			enforcement.AuthMethodAccessors = raw.([]string)
+		}
+		if raw, ok := d.GetOk("auth_method_types"); ok {
+			enforcement.AuthMethodTypes = raw.([]string)
+		}
+		if raw, ok := d.GetOk("identity_group_ids"); ok {
+			enforcement.IdentityGroupIDs = raw.([]string)
+		}
+		if raw, ok := d.GetOk("identity_entity_ids"); ok {
+			enforcement.IdentityEntityIDs = raw.([]string)
+		}
+
+		if len(enforcement.MFAMethodIDs) == 0 {
+			return logical.ErrorResponse("mfa_method_ids is required"), nil
+		}
+		for _, methodID := range enforcement.MFAMethodIDs {
+			method, err := i.getMFAMethod(ctx, methodID)
+			if err != nil {
+				return nil, err
+			}
+			if method == nil {
+				return logical.ErrorResponse(fmt.Sprintf("no MFA method with ID %q", methodID)), nil
+			}
+		}
+		if len(enforcement.AuthMethodAccessors) == 0 && len(enforcement.AuthMethodTypes) == 0 &&
+			len(enforcement.IdentityGroupIDs) == 0 && len(enforcement.IdentityEntityIDs) == 0 {
+			return logical.ErrorResponse("at least one of auth_method_accessors, auth_method_types, identity_group_ids or identity_entity_ids is required"), nil
+		}
+		for _, accessor := range enforcement.AuthMethodAccessors {
+			if i.core.router.MatchingMountByAccessor(accessor) == nil {
+				return logical.ErrorResponse(fmt.Sprintf("no auth mount with accessor %q", accessor)), nil
+			}
+		}
+
+		return nil, i.putOIDCEntry(ctx, mfaLoginEnforcePath+name, enforcement)
+	}
+}
+
+func (i *IdentityStore) pathMFALoginEnforcementDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.Lock()
+		defer i.mfaLock.Unlock()
+
+		return nil, i.view.Delete(ctx, mfaLoginEnforcePath+d.Get("name").(string))
+	}
+}
+
+var mfaHelp = map[string][2]string{
+	"method": {
+		"Create, read, update or delete a login MFA method.",
+		`Login MFA methods validate a second factor of the logins matching the login
+enforcements using them. Writing to the path of a method type creates a new
+method and returns its ID.
+
+  * "totp" methods validate time-based one-time passcodes generated from keys
+    issued to entities with the generate or admin-generate endpoints.
+
+  * "duo" methods validate Duo push notifications or passcodes.
+
+  * "okta" methods validate Okta Verify push notifications.
+
+  * "pingid" methods validate PingID push notifications.
+
+Duo, Okta and PingID users are identified by the username_format template,
+populated with the entity of the login.`,
+	},
+	"method-list": {
+		"List login MFA methods",
+		"List the IDs of all the login MFA methods.",
+	},
+	"totp-generate": {
+		"Generate a TOTP key for the entity of the request's token",
+		`Generate a TOTP key of the given method for the entity of the request's
+token, returning its URL and QR code. Entities with an existing key must ask an
+administrator to replace it.`,
+	},
+	"totp-admin-generate": {
+		"Generate a TOTP key for an entity",
+		`Generate a TOTP key of the given method for the given entity, replacing
+its existing key, and return its URL and QR code.`,
+	},
+	"totp-admin-destroy": {
+		"Delete the TOTP key of an entity",
+		"Delete the TOTP key of the given method for the given entity.",
+	},
+	"login-enforcement": {
+		"Create, read, update or delete a login MFA enforcement.",
+		`Login enforcements require the logins to the given auth mounts or auth
+method types, and the logins of the given entities or members of the given
+groups, to be validated with one of their MFA methods. Such logins return an
+MFA requirement instead of a token, which is completed at sys/mfa/validate.`,
+	},
+	"login-enforcement-list": {
+		"List login MFA enforcements",
+		"List the names of all the login MFA enforcements.",
+	},
+}
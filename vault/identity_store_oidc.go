@@ -0,0 +1,1102 @@
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/identitytpl"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// Storage paths of the OIDC identity tokens
+	oidcTokensPrefix     = "oidc_tokens/"
+	oidcConfigPath       = oidcTokensPrefix + "config"
+	namedKeyConfigPath   = oidcTokensPrefix + "named_keys/"
+	publicKeysConfigPath = oidcTokensPrefix + "public_keys/"
+	roleConfigPath       = oidcTokensPrefix + "roles/"
+
+	// issuerPath is the path of the OIDC issuer below the API address
+	issuerPath = "/v1/identity/oidc"
+)
+
+// oidcSigningMethods are the signature algorithms of named keys
+var oidcSigningMethods = map[string]crypto.SigningMethod{
+	"RS256": crypto.SigningMethodRS256,
+	"RS384": crypto.SigningMethodRS384,
+	"RS512": crypto.SigningMethodRS512,
+}
+
+// reservedClaims are set on tokens by Vault and can't be set by templates
+var reservedClaims = []string{
+	"iat", "aud", "exp", "iss", "sub", "namespace",
+	"nonce", "auth_time", "at_hash", "c_hash", "azp",
+}
+
+// oidcConfig is the configuration of the OIDC issuer
+type oidcConfig struct {
+	Issuer string `json:"issuer"`
+}
+
+// expireableKey is a key of the key ring of a named key. The current signing
+// key doesn't expire; previous keys expire once tokens signed with them can
+// no longer be valid.
+type expireableKey struct {
+	KeyID    string    `json:"key_id"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+// namedKey is a rotating signing key used by roles to sign tokens
+type namedKey struct {
+	Name             string           `json:"name"`
+	Algorithm        string           `json:"signing_algorithm"`
+	VerificationTTL  time.Duration    `json:"verification_ttl"`
+	RotationPeriod   time.Duration    `json:"rotation_period"`
+	AllowedClientIDs []string         `json:"allowed_client_ids"`
+	KeyRing          []*expireableKey `json:"key_ring"`
+	SigningKeyID     string           `json:"signing_key_id"`
+	SigningKey       []byte           `json:"signing_key"`
+	NextRotation     time.Time        `json:"next_rotation"`
+}
+
+// oidcPublicKey is the public part of a signing key, published in the JWKS
+// until it expires
+type oidcPublicKey struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey []byte `json:"public_key"`
+}
+
+// oidcRole describes the tokens minted for a client
+type oidcRole struct {
+	Name     string        `json:"name"`
+	Key      string        `json:"key"`
+	Template string        `json:"template"`
+	TTL      time.Duration `json:"ttl"`
+	ClientID string        `json:"client_id"`
+}
+
+func oidcPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/config/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"issuer": {
+					Type:        framework.TypeString,
+					Description: "Issuer URL of the tokens, including the scheme and host. Defaults to the API address of Vault.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   i.pathOIDCReadConfig(),
+				logical.UpdateOperation: i.pathOIDCUpdateConfig(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-config"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-config"][1]),
+		},
+		{
+			Pattern: "oidc/key/" + framework.GenericNameRegex("name") + "/rotate/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the key.",
+				},
+				"verification_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Time the previous signing key remains valid for verification. Defaults to the verification_ttl of the key.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCRotateKey(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-key-rotate"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-key-rotate"][1]),
+		},
+		{
+			Pattern: "oidc/key/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the key.",
+				},
+				"rotation_period": {
+					Type:        framework.TypeDurationSecond,
+					Default:     "24h",
+					Description: "How often the signing key is rotated.",
+				},
+				"verification_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Default:     "24h",
+					Description: "Time a signing key remains valid for verification after it is rotated.",
+				},
+				"algorithm": {
+					Type:        framework.TypeString,
+					Default:     "RS256",
+					Description: "Signature algorithm: RS256, RS384 or RS512.",
+				},
+				"allowed_client_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: `Client IDs of the roles allowed to use the key. If "*", all roles are allowed.`,
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCCreateUpdateKey(),
+				logical.ReadOperation:   i.pathOIDCReadKey(),
+				logical.DeleteOperation: i.pathOIDCDeleteKey(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-key"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-key"][1]),
+		},
+		{
+			Pattern: "oidc/key/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCList(namedKeyConfigPath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-key-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-key-list"][1]),
+		},
+		{
+			Pattern: "oidc/role/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the role.",
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "Name of the key used to sign the tokens.",
+				},
+				"template": {
+					Type:        framework.TypeString,
+					Description: "JSON template of additional claims, populated with the identity of the entity.",
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Default:     "24h",
+					Description: "TTL of the tokens.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCCreateUpdateRole(),
+				logical.ReadOperation:   i.pathOIDCReadRole(),
+				logical.DeleteOperation: i.pathOIDCDeleteRole(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-role"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-role"][1]),
+		},
+		{
+			Pattern: "oidc/role/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCList(roleConfigPath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-role-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-role-list"][1]),
+		},
+		{
+			Pattern: "oidc/token/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the role.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCGenerateToken(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-token"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-token"][1]),
+		},
+		{
+			Pattern: "oidc/introspect/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"token": {
+					Type:        framework.TypeString,
+					Description: "Token to verify.",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "Optional client ID the token must have been issued for.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCIntrospect(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-introspect"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-introspect"][1]),
+		},
+		{
+			Pattern: "oidc/.well-known/openid-configuration/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCDiscovery(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-discovery"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-discovery"][1]),
+		},
+		{
+			Pattern: "oidc/.well-known/keys/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCReadPublicKeys(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-keys"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-keys"][1]),
+		},
+	}
+}
+
+func (i *IdentityStore) pathOIDCList(prefix string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		keys, err := i.view.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		return logical.ListResponse(keys), nil
+	}
+}
+
+// getOIDCConfig returns the configuration of the issuer, defaulting the
+// issuer to the API address
+func (i *IdentityStore) getOIDCConfig(ctx context.Context, s logical.Storage) (*oidcConfig, error) {
+	config := &oidcConfig{}
+	entry, err := s.Get(ctx, oidcConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(config); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Issuer == "" {
+		config.Issuer = i.core.redirectAddr
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/") + issuerPath
+
+	return config, nil
+}
+
+func (i *IdentityStore) pathOIDCReadConfig() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		entry, err := i.view.Get(ctx, oidcConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		var config oidcConfig
+		if entry != nil {
+			if err := entry.DecodeJSON(&config); err != nil {
+				return nil, err
+			}
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"issuer": config.Issuer,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCUpdateConfig() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		issuer := d.Get("issuer").(string)
+		if issuer != "" && !strings.HasPrefix(issuer, "https://") && !strings.HasPrefix(issuer, "http://") {
+			return logical.ErrorResponse("issuer must be an http or https URL"), nil
+		}
+
+		entry, err := logical.StorageEntryJSON(oidcConfigPath, &oidcConfig{
+			Issuer: issuer,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, i.view.Put(ctx, entry)
+	}
+}
+
+func (i *IdentityStore) getNamedKey(ctx context.Context, s logical.Storage, name string) (*namedKey, error) {
+	entry, err := s.Get(ctx, namedKeyConfigPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key namedKey
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (i *IdentityStore) storeNamedKey(ctx context.Context, s logical.Storage, key *namedKey) error {
+	entry, err := logical.StorageEntryJSON(namedKeyConfigPath+key.Name, key)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateKey() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		key, err := i.getNamedKey(ctx, i.view, name)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			key = &namedKey{
+				Name: name,
+			}
+		}
+
+		if raw, ok := d.GetOk("rotation_period"); ok || key.RotationPeriod == 0 {
+			if !ok {
+				raw = d.Get("rotation_period")
+			}
+			key.RotationPeriod = time.Duration(raw.(int)) * time.Second
+		}
+		if raw, ok := d.GetOk("verification_ttl"); ok || key.VerificationTTL == 0 {
+			if !ok {
+				raw = d.Get("verification_ttl")
+			}
+			key.VerificationTTL = time.Duration(raw.(int)) * time.Second
+		}
+		if raw, ok := d.GetOk("allowed_client_ids"); ok {
+			key.AllowedClientIDs = raw.([]string)
+		}
+		if raw, ok := d.GetOk("algorithm"); ok || key.Algorithm == "" {
+			if !ok {
+				raw = d.Get("algorithm")
+			}
+			algorithm := raw.(string)
+			if _, ok := oidcSigningMethods[algorithm]; !ok {
+				return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %q", algorithm)), nil
+			}
+			if key.Algorithm != "" && key.Algorithm != algorithm {
+				// The signing key must be replaced to use the new algorithm
+				key.NextRotation = time.Time{}
+			}
+			key.Algorithm = algorithm
+		}
+
+		if key.RotationPeriod < time.Minute {
+			return logical.ErrorResponse("rotation_period must be at least one minute"), nil
+		}
+		if key.VerificationTTL <= 0 {
+			return logical.ErrorResponse("verification_ttl must be positive"), nil
+		}
+
+		// New keys and keys changing algorithm get a new signing key right away
+		if key.SigningKeyID == "" || key.NextRotation.IsZero() {
+			if err := i.rotateNamedKey(ctx, i.view, key, key.VerificationTTL); err != nil {
+				return nil, err
+			}
+			return nil, nil
+		}
+
+		return nil, i.storeNamedKey(ctx, i.view, key)
+	}
+}
+
+func (i *IdentityStore) pathOIDCReadKey() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		key, err := i.getNamedKey(ctx, i.view, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"rotation_period":    int64(key.RotationPeriod.Seconds()),
+				"verification_ttl":   int64(key.VerificationTTL.Seconds()),
+				"algorithm":          key.Algorithm,
+				"allowed_client_ids": key.AllowedClientIDs,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCDeleteKey() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		roles, err := i.rolesByKey(ctx, i.view, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(roles) > 0 {
+			return logical.ErrorResponse(fmt.Sprintf("unable to delete key %q because it is used by the roles: %s", name, strings.Join(roles, ", "))), logical.ErrInvalidRequest
+		}
+		clients, err := i.listClients(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range clients {
+			if c.Key == name {
+				return logical.ErrorResponse(fmt.Sprintf("unable to delete key %q because it is used by the client %q", name, c.Name)), logical.ErrInvalidRequest
+			}
+		}
+
+		key, err := i.getNamedKey(ctx, i.view, name)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, nil
+		}
+
+		for _, k := range key.KeyRing {
+			if err := i.view.Delete(ctx, publicKeysConfigPath+k.KeyID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, i.view.Delete(ctx, namedKeyConfigPath+name)
+	}
+}
+
+func (i *IdentityStore) pathOIDCRotateKey() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		key, err := i.getNamedKey(ctx, i.view, name)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no key named %q", name)), logical.ErrInvalidRequest
+		}
+
+		verificationTTL := key.VerificationTTL
+		if raw, ok := d.GetOk("verification_ttl"); ok {
+			verificationTTL = time.Duration(raw.(int)) * time.Second
+		}
+
+		return nil, i.rotateNamedKey(ctx, i.view, key, verificationTTL)
+	}
+}
+
+// rotateNamedKey replaces the signing key of the named key. The previous
+// signing key remains published for verificationTTL.
+func (i *IdentityStore) rotateNamedKey(ctx context.Context, s logical.Storage, key *namedKey, verificationTTL time.Duration) error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return errwrap.Wrapf("failed to generate signing key: {{err}}", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return err
+	}
+	keyID, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
+	}
+
+	entry, err := logical.StorageEntryJSON(publicKeysConfigPath+keyID, &oidcPublicKey{
+		KeyID:     keyID,
+		Algorithm: key.Algorithm,
+		PublicKey: publicDER,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, k := range key.KeyRing {
+		if k.KeyID == key.SigningKeyID {
+			k.ExpireAt = now.Add(verificationTTL)
+		}
+	}
+	key.KeyRing = append(key.KeyRing, &expireableKey{
+		KeyID: keyID,
+	})
+	key.SigningKeyID = keyID
+	key.SigningKey = x509.MarshalPKCS1PrivateKey(privateKey)
+	key.NextRotation = now.Add(key.RotationPeriod)
+
+	return i.storeNamedKey(ctx, s, key)
+}
+
+// rolesByKey returns the names of the roles using the named key
+func (i *IdentityStore) rolesByKey(ctx context.Context, s logical.Storage, keyName string) ([]string, error) {
+	names, err := s.List(ctx, roleConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	for _, name := range names {
+		role, err := i.getOIDCRole(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && role.Key == keyName {
+			roles = append(roles, name)
+		}
+	}
+	return roles, nil
+}
+
+func (i *IdentityStore) getOIDCRole(ctx context.Context, s logical.Storage, name string) (*oidcRole, error) {
+	entry, err := s.Get(ctx, roleConfigPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var role oidcRole
+	if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateRole() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		role, err := i.getOIDCRole(ctx, i.view, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			clientID, err := uuid.GenerateUUID()
+			if err != nil {
+				return nil, err
+			}
+			role = &oidcRole{
+				Name:     name,
+				ClientID: clientID,
+			}
+		}
+
+		if raw, ok := d.GetOk("key"); ok {
+			role.Key = raw.(string)
+		}
+		if role.Key == "" {
+			return logical.ErrorResponse("the key parameter is required"), nil
+		}
+		if raw, ok := d.GetOk("template"); ok {
+			role.Template = raw.(string)
+		}
+		if raw, ok := d.GetOk("ttl"); ok || role.TTL == 0 {
+			if !ok {
+				raw = d.Get("ttl")
+			}
+			role.TTL = time.Duration(raw.(int)) * time.Second
+		}
+
+		key, err := i.getNamedKey(ctx, i.view, role.Key)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no key named %q", role.Key)), nil
+		}
+		if role.TTL > key.VerificationTTL {
+			return logical.ErrorResponse("the ttl of the role must not be greater than the verification_ttl of its key"), nil
+		}
+
+		// Check the template with an empty identity
+		if role.Template != "" {
+			if _, err := populateClaimsTemplate(role.Template, identitytpl.JSONInput{Entity: &logical.Entity{}}); err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+		}
+
+		entry, err := logical.StorageEntryJSON(roleConfigPath+name, role)
+		if err != nil {
+			return nil, err
+		}
+		return nil, i.view.Put(ctx, entry)
+	}
+}
+
+func (i *IdentityStore) pathOIDCReadRole() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		role, err := i.getOIDCRole(ctx, i.view, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"key":       role.Key,
+				"template":  role.Template,
+				"ttl":       int64(role.TTL.Seconds()),
+				"client_id": role.ClientID,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCDeleteRole() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		return nil, i.view.Delete(ctx, roleConfigPath+d.Get("name").(string))
+	}
+}
+
+// populateClaimsTemplate populates the template of a role, returning the
+// resulting claims. Claims with a null value are dropped.
+func populateClaimsTemplate(template string, input identitytpl.JSONInput) (map[string]interface{}, error) {
+	populated, err := identitytpl.PopulateJSON(input, template)
+	if err != nil {
+		return nil, errwrap.Wrapf("error populating template: {{err}}", err)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal([]byte(populated), &claims); err != nil {
+		return nil, errors.New("the template must be a JSON object")
+	}
+
+	for claim, value := range claims {
+		if strutil.StrListContains(reservedClaims, claim) {
+			return nil, fmt.Errorf("the template must not set the reserved claim %q", claim)
+		}
+		if value == nil {
+			delete(claims, claim)
+		}
+	}
+
+	return claims, nil
+}
+
+func (i *IdentityStore) pathOIDCGenerateToken() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if req.EntityID == "" {
+			return logical.ErrorResponse("no entity associated with the request's token"), logical.ErrInvalidRequest
+		}
+
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		name := d.Get("name").(string)
+		role, err := i.getOIDCRole(ctx, i.view, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no role named %q", name)), logical.ErrInvalidRequest
+		}
+
+		key, err := i.getNamedKey(ctx, i.view, role.Key)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no key named %q", role.Key)), logical.ErrInvalidRequest
+		}
+		if !strutil.StrListContains(key.AllowedClientIDs, "*") && !strutil.StrListContains(key.AllowedClientIDs, role.ClientID) {
+			return logical.ErrorResponse("the key of the role does not allow its client ID"), logical.ErrInvalidRequest
+		}
+
+		entity, err := i.MemDBEntityByID(req.EntityID, false)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil || entity.Disabled {
+			return logical.ErrorResponse("the entity of the request's token is missing or disabled"), logical.ErrInvalidRequest
+		}
+
+		config, err := i.getOIDCConfig(ctx, i.view)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		claims := jws.Claims{}
+		if role.Template != "" {
+			input, err := i.claimsTemplateInput(req.EntityID)
+			if err != nil {
+				return nil, err
+			}
+			templateClaims, err := populateClaimsTemplate(role.Template, input)
+			if err != nil {
+				return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+			}
+			for k, v := range templateClaims {
+				claims[k] = v
+			}
+		}
+		claims.SetIssuer(config.Issuer)
+		claims.SetSubject(req.EntityID)
+		claims.SetAudience(role.ClientID)
+		claims.SetIssuedAt(now)
+		claims.SetExpiration(now.Add(role.TTL))
+
+		signedToken, err := signOIDCToken(key, claims)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"token":     signedToken,
+				"client_id": role.ClientID,
+				"ttl":       int64(role.TTL.Seconds()),
+			},
+		}, nil
+	}
+}
+
+// claimsTemplateInput returns the identity of the given entity used to
+// populate claims templates
+func (i *IdentityStore) claimsTemplateInput(entityID string) (identitytpl.JSONInput, error) {
+	entity, err := i.System().EntityInfo(entityID)
+	if err != nil {
+		return identitytpl.JSONInput{}, err
+	}
+
+	directGroups, inheritedGroups, err := i.groupsByEntityID(entityID)
+	if err != nil {
+		return identitytpl.JSONInput{}, err
+	}
+
+	input := identitytpl.JSONInput{
+		Entity: entity,
+	}
+	for _, group := range append(directGroups, inheritedGroups...) {
+		input.GroupIDs = append(input.GroupIDs, group.ID)
+		input.GroupNames = append(input.GroupNames, group.Name)
+	}
+	return input, nil
+}
+
+func signOIDCToken(key *namedKey, claims jws.Claims) (string, error) {
+	privateKey, err := x509.ParsePKCS1PrivateKey(key.SigningKey)
+	if err != nil {
+		return "", errwrap.Wrapf("failed to parse signing key: {{err}}", err)
+	}
+
+	token := jws.NewJWT(claims, oidcSigningMethods[key.Algorithm])
+	token.(jws.JWS).Protected().Set("kid", key.SigningKeyID)
+	serialized, err := token.Serialize(privateKey)
+	if err != nil {
+		return "", errwrap.Wrapf("failed to sign token: {{err}}", err)
+	}
+	return string(serialized), nil
+}
+
+func (i *IdentityStore) pathOIDCIntrospect() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		rawToken := d.Get("token").(string)
+		if rawToken == "" {
+			return logical.ErrorResponse("missing token"), nil
+		}
+
+		inactive := func(reason string) (*logical.Response, error) {
+			return &logical.Response{
+				Data: map[string]interface{}{
+					"active": false,
+					"error":  reason,
+				},
+			}, nil
+		}
+
+		token, err := jws.ParseJWT([]byte(rawToken))
+		if err != nil {
+			return inactive(fmt.Sprintf("error parsing token: %s", err))
+		}
+
+		keyID, _ := token.(jws.JWS).Protected().Get("kid").(string)
+		publicKey, err := i.getOIDCPublicKey(ctx, i.view, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if publicKey == nil {
+			return inactive("unknown signing key")
+		}
+		parsedKey, err := x509.ParsePKIXPublicKey(publicKey.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := token.Validate(parsedKey, oidcSigningMethods[publicKey.Algorithm]); err != nil {
+			return inactive(fmt.Sprintf("error validating token: %s", err))
+		}
+
+		claims := token.Claims()
+		config, err := i.getOIDCConfig(ctx, i.view)
+		if err != nil {
+			return nil, err
+		}
+		if issuer, _ := claims.Issuer(); issuer != config.Issuer {
+			return inactive("invalid issuer")
+		}
+		if clientID := d.Get("client_id").(string); clientID != "" {
+			audience, _ := claims.Audience()
+			if !strutil.StrListContains(audience, clientID) {
+				return inactive("token was not issued for the given client_id")
+			}
+		}
+
+		subject, _ := claims.Subject()
+		entity, err := i.MemDBEntityByID(subject, false)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil || entity.Disabled {
+			return inactive("the entity of the token is missing or disabled")
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"active": true,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) getOIDCPublicKey(ctx context.Context, s logical.Storage, keyID string) (*oidcPublicKey, error) {
+	if keyID == "" {
+		return nil, nil
+	}
+	entry, err := s.Get(ctx, publicKeysConfigPath+keyID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var publicKey oidcPublicKey
+	if err := entry.DecodeJSON(&publicKey); err != nil {
+		return nil, err
+	}
+	return &publicKey, nil
+}
+
+func (i *IdentityStore) pathOIDCDiscovery() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		config, err := i.getOIDCConfig(ctx, i.view)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := json.Marshal(map[string]interface{}{
+			"issuer":                                config.Issuer,
+			"jwks_uri":                              config.Issuer + "/.well-known/keys",
+			"response_types_supported":              []string{"id_token"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256", "RS384", "RS512"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return rawJSONResponse(body), nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCReadPublicKeys() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		keyIDs, err := i.view.List(ctx, publicKeysConfigPath)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make([]map[string]interface{}, 0, len(keyIDs))
+		for _, keyID := range keyIDs {
+			publicKey, err := i.getOIDCPublicKey(ctx, i.view, keyID)
+			if err != nil {
+				return nil, err
+			}
+			if publicKey == nil {
+				continue
+			}
+			jwk, err := publicKeyJWK(publicKey)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, jwk)
+		}
+
+		body, err := json.Marshal(map[string]interface{}{
+			"keys": keys,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return rawJSONResponse(body), nil
+	}
+}
+
+// publicKeyJWK returns the JSON Web Key of a public key
+func publicKeyJWK(publicKey *oidcPublicKey) (map[string]interface{}, error) {
+	parsed, err := x509.ParsePKIXPublicKey(publicKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T", parsed)
+	}
+
+	return map[string]interface{}{
+		"kty": "RSA",
+		"use": "sig",
+		"kid": publicKey.KeyID,
+		"alg": publicKey.Algorithm,
+		"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}, nil
+}
+
+func rawJSONResponse(body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  200,
+		},
+	}
+}
+
+// oidcPeriodicFunc rotates the named keys that are due and removes the
+// public keys that have expired
+func (i *IdentityStore) oidcPeriodicFunc(ctx context.Context, req *logical.Request) error {
+	s := i.view
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	names, err := s.List(ctx, namedKeyConfigPath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, name := range names {
+		key, err := i.getNamedKey(ctx, s, name)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			continue
+		}
+
+		if now.After(key.NextRotation) {
+			if err := i.rotateNamedKey(ctx, s, key, key.VerificationTTL); err != nil {
+				return err
+			}
+		}
+
+		keyRing := make([]*expireableKey, 0, len(key.KeyRing))
+		for _, k := range key.KeyRing {
+			if k.ExpireAt.IsZero() || now.Before(k.ExpireAt) {
+				keyRing = append(keyRing, k)
+				continue
+			}
+			if err := s.Delete(ctx, publicKeysConfigPath+k.KeyID); err != nil {
+				return err
+			}
+		}
+		if len(keyRing) != len(key.KeyRing) {
+			key.KeyRing = keyRing
+			if err := i.storeNamedKey(ctx, s, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return i.tidyOIDCProviderEntries(ctx)
+}
+
+var oidcHelp = map[string][2]string{
+	"oidc-config": {
+		"OIDC configuration",
+		`Update the issuer of the tokens. The issuer is the given URL followed by
+"/v1/identity/oidc", and defaults to the API address of Vault.`,
+	},
+	"oidc-key": {
+		"CRUD operations for OIDC keys.",
+		`Keys sign the tokens of the roles using them. Signing keys are rotated
+every rotation_period, and previous keys remain published for
+verification_ttl so that tokens signed with them can still be verified.`,
+	},
+	"oidc-key-list": {
+		"List OIDC keys",
+		"List all named OIDC keys",
+	},
+	"oidc-key-rotate": {
+		"Rotate a named OIDC key.",
+		`Replace the signing key of the named key. The previous signing key remains
+published for verification_ttl.`,
+	},
+	"oidc-role": {
+		"CRUD operations on OIDC roles",
+		`Roles describe the tokens minted for a client: the key signing them, their
+TTL and a JSON template of additional claims. The template is populated with
+the identity of the entity requesting the token, for example:
+
+  {"team": {{identity.entity.metadata.team}}, "groups": {{identity.groups.names}}}`,
+	},
+	"oidc-role-list": {
+		"List configured OIDC roles",
+		"List all configured OIDC roles in the identity backend.",
+	},
+	"oidc-token": {
+		"Generate an OIDC token",
+		"Generate an OIDC token for the entity of the request's token.",
+	},
+	"oidc-introspect": {
+		"Verify the authenticity of an OIDC token",
+		`Verify the signature, issuer, audience and expiration of a token, and that
+its entity still exists and is enabled.`,
+	},
+	"oidc-discovery": {
+		"Query OIDC configurations",
+		"Query this path to retrieve the configured OIDC Issuer and Keys endpoints.",
+	},
+	"oidc-keys": {
+		"Retrieve public keys",
+		"Query this path to retrieve the public portion of keys used to sign OIDC tokens.",
+	},
+}
@@ -0,0 +1,1607 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SermoDigital/jose/jws"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/identitytpl"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// Storage paths of the OIDC providers
+	oidcProviderPrefix       = "oidc_provider/"
+	assignmentPath           = oidcProviderPrefix + "assignment/"
+	scopePath                = oidcProviderPrefix + "scope/"
+	clientPath               = oidcProviderPrefix + "client/"
+	providerPath             = oidcProviderPrefix + "provider/"
+	authCodePath             = oidcProviderPrefix + "code/"
+	accessTokenPath          = oidcProviderPrefix + "access_token/"
+	providerIssuerPathPrefix = issuerPath + "/provider/"
+
+	// oidcAuthCodeTTL is the time an authorization code can be exchanged for
+	// tokens
+	oidcAuthCodeTTL = 5 * time.Minute
+
+	// openIDScope is the scope required by all OIDC authentication requests
+	openIDScope = "openid"
+
+	clientTypeConfidential = "confidential"
+	clientTypePublic       = "public"
+
+	codeChallengeMethodPlain = "plain"
+	codeChallengeMethodS256  = "S256"
+)
+
+// oidcProviderClientAuthPathRe matches the endpoints of the OIDC providers
+// that authenticate their clients with the Authorization header, which is
+// only passed through to the identity store for them
+var oidcProviderClientAuthPathRe = regexp.MustCompile(`^oidc/provider/[^/]+/(token|userinfo)/?$`)
+
+// assignment lists the entities and groups allowed to use a client
+type assignment struct {
+	Name      string   `json:"name"`
+	EntityIDs []string `json:"entity_ids"`
+	GroupIDs  []string `json:"group_ids"`
+}
+
+// scope is a set of claims, populated from a template, that clients can
+// request
+type scope struct {
+	Name        string `json:"name"`
+	Template    string `json:"template"`
+	Description string `json:"description"`
+}
+
+// client is an application relying on a provider to authenticate its users
+type client struct {
+	Name           string        `json:"name"`
+	Key            string        `json:"key"`
+	RedirectURIs   []string      `json:"redirect_uris"`
+	Assignments    []string      `json:"assignments"`
+	ClientType     string        `json:"client_type"`
+	IDTokenTTL     time.Duration `json:"id_token_ttl"`
+	AccessTokenTTL time.Duration `json:"access_token_ttl"`
+	ClientID       string        `json:"client_id"`
+	ClientSecret   string        `json:"client_secret"`
+}
+
+// provider is an OIDC provider authenticating the entities of the identity
+// store on behalf of its clients
+type provider struct {
+	Name             string   `json:"name"`
+	Issuer           string   `json:"issuer"`
+	AllowedClientIDs []string `json:"allowed_client_ids"`
+	ScopesSupported  []string `json:"scopes_supported"`
+}
+
+// authCodeEntry is the authentication request an authorization code was
+// issued for
+type authCodeEntry struct {
+	Provider            string    `json:"provider"`
+	ClientID            string    `json:"client_id"`
+	EntityID            string    `json:"entity_id"`
+	RedirectURI         string    `json:"redirect_uri"`
+	Nonce               string    `json:"nonce"`
+	Scopes              []string  `json:"scopes"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	AuthTime            time.Time `json:"auth_time"`
+	ExpireAt            time.Time `json:"expire_at"`
+}
+
+// accessTokenEntry is the authorization granted by an access token
+type accessTokenEntry struct {
+	Provider string    `json:"provider"`
+	ClientID string    `json:"client_id"`
+	EntityID string    `json:"entity_id"`
+	Scopes   []string  `json:"scopes"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+func oidcProviderPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/assignment/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the assignment.",
+				},
+				"entity_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the IDs of the entities allowed by the assignment.",
+				},
+				"group_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the IDs of the groups whose members are allowed by the assignment.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCCreateUpdateAssignment(),
+				logical.ReadOperation:   i.pathOIDCReadAssignment(),
+				logical.DeleteOperation: i.pathOIDCDeleteAssignment(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-assignment"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-assignment"][1]),
+		},
+		{
+			Pattern: "oidc/assignment/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCList(assignmentPath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-assignment-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-assignment-list"][1]),
+		},
+		{
+			Pattern: "oidc/scope/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the scope.",
+				},
+				"template": {
+					Type:        framework.TypeString,
+					Description: "JSON template of the claims of the scope.",
+				},
+				"description": {
+					Type:        framework.TypeString,
+					Description: "Description of the scope.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCCreateUpdateScope(),
+				logical.ReadOperation:   i.pathOIDCReadScope(),
+				logical.DeleteOperation: i.pathOIDCDeleteScope(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-scope"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-scope"][1]),
+		},
+		{
+			Pattern: "oidc/scope/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCList(scopePath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-scope-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-scope-list"][1]),
+		},
+		{
+			Pattern: "oidc/client/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the client.",
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "Name of the key signing the ID tokens of the client. Required.",
+				},
+				"redirect_uris": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the redirection URIs of the client.",
+				},
+				"assignments": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the names of the assignments allowed to use the client.",
+				},
+				"client_type": {
+					Type:        framework.TypeString,
+					Default:     clientTypeConfidential,
+					Description: `Type of the client: "confidential" clients authenticate with their client secret, "public" clients must use PKCE. Can't be changed after creation.`,
+				},
+				"id_token_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Default:     "24h",
+					Description: "TTL of the ID tokens issued to the client.",
+				},
+				"access_token_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Default:     "24h",
+					Description: "TTL of the access tokens issued to the client.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCCreateUpdateClient(),
+				logical.ReadOperation:   i.pathOIDCReadClient(),
+				logical.DeleteOperation: i.pathOIDCDeleteClient(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-client"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-client"][1]),
+		},
+		{
+			Pattern: "oidc/client/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCList(clientPath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-client-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-client-list"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/.well-known/openid-configuration/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCProviderDiscovery(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-discovery"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-discovery"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/.well-known/keys/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCProviderReadPublicKeys(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-keys"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-keys"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/authorize/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider.",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "ID of the client requesting authentication.",
+				},
+				"scope": {
+					Type:        framework.TypeString,
+					Description: `Space separated list of the requested scopes. Must include "openid".`,
+				},
+				"redirect_uri": {
+					Type:        framework.TypeString,
+					Description: "Redirection URI of the client the response is sent to.",
+				},
+				"response_type": {
+					Type:        framework.TypeString,
+					Description: `Response type of the authorization flow. Must be "code".`,
+				},
+				"state": {
+					Type:        framework.TypeString,
+					Description: "Opaque value returned to the client with the authorization code.",
+				},
+				"nonce": {
+					Type:        framework.TypeString,
+					Description: "Value set in the nonce claim of the ID token.",
+				},
+				"code_challenge": {
+					Type:        framework.TypeString,
+					Description: "PKCE code challenge derived from the code verifier of the client.",
+				},
+				"code_challenge_method": {
+					Type:        framework.TypeString,
+					Description: `Method used to derive the code challenge: "plain" or "S256". Defaults to "plain".`,
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   i.pathOIDCAuthorize(),
+				logical.UpdateOperation: i.pathOIDCAuthorize(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-authorize"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-authorize"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/token/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider.",
+				},
+				"grant_type": {
+					Type:        framework.TypeString,
+					Description: `Grant type of the request. Must be "authorization_code".`,
+				},
+				"code": {
+					Type:        framework.TypeString,
+					Description: "Authorization code returned by the authorize endpoint.",
+				},
+				"redirect_uri": {
+					Type:        framework.TypeString,
+					Description: "Redirection URI given to the authorize endpoint.",
+				},
+				"code_verifier": {
+					Type:        framework.TypeString,
+					Description: "PKCE code verifier of the code challenge given to the authorize endpoint.",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "ID of the client, if not given with HTTP basic authentication.",
+				},
+				"client_secret": {
+					Type:        framework.TypeString,
+					Description: "Secret of the client, if not given with HTTP basic authentication.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCToken(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-token"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-token"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/userinfo/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   i.pathOIDCUserInfo(),
+				logical.UpdateOperation: i.pathOIDCUserInfo(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-userinfo"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-userinfo"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider.",
+				},
+				"issuer": {
+					Type:        framework.TypeString,
+					Description: "Scheme and host of the issuer of the provider. Defaults to the API address of Vault.",
+				},
+				"allowed_client_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: `Comma separated string or array of the IDs of the clients allowed to use the provider. "*" allows all clients.`,
+				},
+				"scopes_supported": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the names of the scopes available to the clients of the provider.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCCreateUpdateProvider(),
+				logical.ReadOperation:   i.pathOIDCReadProvider(),
+				logical.DeleteOperation: i.pathOIDCDeleteProvider(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider"][1]),
+		},
+		{
+			Pattern: "oidc/provider/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCList(providerPath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-list"][1]),
+		},
+	}
+}
+
+// getOIDCEntry decodes the storage entry at the given path into out,
+// returning false if it doesn't exist
+func (i *IdentityStore) getOIDCEntry(ctx context.Context, path string, out interface{}) (bool, error) {
+	entry, err := i.view.Get(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+	if err := entry.DecodeJSON(out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (i *IdentityStore) putOIDCEntry(ctx context.Context, path string, value interface{}) error {
+	entry, err := logical.StorageEntryJSON(path, value)
+	if err != nil {
+		return err
+	}
+	return i.view.Put(ctx, entry)
+}
+
+func (i *IdentityStore) getAssignment(ctx context.Context, name string) (*assignment, error) {
+	var a assignment
+	ok, err := i.getOIDCEntry(ctx, assignmentPath+name, &a)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (i *IdentityStore) getScope(ctx context.Context, name string) (*scope, error) {
+	var s scope
+	ok, err := i.getOIDCEntry(ctx, scopePath+name, &s)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (i *IdentityStore) getClient(ctx context.Context, name string) (*client, error) {
+	var c client
+	ok, err := i.getOIDCEntry(ctx, clientPath+name, &c)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (i *IdentityStore) getProvider(ctx context.Context, name string) (*provider, error) {
+	var p provider
+	ok, err := i.getOIDCEntry(ctx, providerPath+name, &p)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// listClients returns all the clients
+func (i *IdentityStore) listClients(ctx context.Context) ([]*client, error) {
+	names, err := i.view.List(ctx, clientPath)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make([]*client, 0, len(names))
+	for _, name := range names {
+		c, err := i.getClient(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			clients = append(clients, c)
+		}
+	}
+	return clients, nil
+}
+
+// clientByID returns the client with the given client ID
+func (i *IdentityStore) clientByID(ctx context.Context, clientID string) (*client, error) {
+	if clientID == "" {
+		return nil, nil
+	}
+
+	clients, err := i.listClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range clients {
+		if subtle.ConstantTimeCompare([]byte(c.ClientID), []byte(clientID)) == 1 {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateAssignment() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		a, err := i.getAssignment(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			a = &assignment{
+				Name: name,
+			}
+		}
+
+		if raw, ok := d.GetOk("entity_ids"); ok {
+			a.EntityIDs = raw.([]string)
+		}
+		if raw, ok := d.GetOk("group_ids"); ok {
+			a.GroupIDs = raw.([]string)
+		}
+
+		return nil, i.putOIDCEntry(ctx, assignmentPath+name, a)
+	}
+}
+
+func (i *IdentityStore) pathOIDCReadAssignment() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		a, err := i.getAssignment(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"entity_ids": a.EntityIDs,
+				"group_ids":  a.GroupIDs,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCDeleteAssignment() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		clients, err := i.listClients(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var users []string
+		for _, c := range clients {
+			if strutil.StrListContains(c.Assignments, name) {
+				users = append(users, c.Name)
+			}
+		}
+		if len(users) > 0 {
+			return logical.ErrorResponse(fmt.Sprintf("unable to delete assignment %q because it is used by the clients: %s", name, strings.Join(users, ", "))), logical.ErrInvalidRequest
+		}
+
+		return nil, i.view.Delete(ctx, assignmentPath+name)
+	}
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateScope() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+		if name == openIDScope {
+			return logical.ErrorResponse(fmt.Sprintf("the %q scope is reserved", openIDScope)), nil
+		}
+
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		s, err := i.getScope(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			s = &scope{
+				Name: name,
+			}
+		}
+
+		if raw, ok := d.GetOk("template"); ok {
+			s.Template = raw.(string)
+		}
+		if raw, ok := d.GetOk("description"); ok {
+			s.Description = raw.(string)
+		}
+
+		// Check the template with an empty identity
+		if s.Template != "" {
+			if _, err := populateClaimsTemplate(s.Template, identitytpl.JSONInput{Entity: &logical.Entity{}}); err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+		}
+
+		return nil, i.putOIDCEntry(ctx, scopePath+name, s)
+	}
+}
+
+func (i *IdentityStore) pathOIDCReadScope() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		s, err := i.getScope(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"template":    s.Template,
+				"description": s.Description,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCDeleteScope() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		names, err := i.view.List(ctx, providerPath)
+		if err != nil {
+			return nil, err
+		}
+		var users []string
+		for _, providerName := range names {
+			p, err := i.getProvider(ctx, providerName)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil && strutil.StrListContains(p.ScopesSupported, name) {
+				users = append(users, providerName)
+			}
+		}
+		if len(users) > 0 {
+			return logical.ErrorResponse(fmt.Sprintf("unable to delete scope %q because it is used by the providers: %s", name, strings.Join(users, ", "))), logical.ErrInvalidRequest
+		}
+
+		return nil, i.view.Delete(ctx, scopePath+name)
+	}
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateClient() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		c, err := i.getClient(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			clientType := d.Get("client_type").(string)
+			if clientType != clientTypeConfidential && clientType != clientTypePublic {
+				return logical.ErrorResponse(fmt.Sprintf("unsupported client_type %q", clientType)), nil
+			}
+
+			clientID, err := base64RandomString(24)
+			if err != nil {
+				return nil, err
+			}
+			c = &client{
+				Name:       name,
+				ClientType: clientType,
+				ClientID:   clientID,
+			}
+			if clientType == clientTypeConfidential {
+				c.ClientSecret, err = base64RandomString(48)
+				if err != nil {
+					return nil, err
+				}
+			}
+		} else if raw, ok := d.GetOk("client_type"); ok && raw.(string) != c.ClientType {
+			return logical.ErrorResponse("the client_type of a client can't be changed"), nil
+		}
+
+		if raw, ok := d.GetOk("key"); ok {
+			c.Key = raw.(string)
+		}
+		if raw, ok := d.GetOk("redirect_uris"); ok {
+			c.RedirectURIs = raw.([]string)
+		}
+		if raw, ok := d.GetOk("assignments"); ok {
+			c.Assignments = raw.([]string)
+		}
+		if raw, ok := d.GetOk("id_token_ttl"); ok || c.IDTokenTTL == 0 {
+			if !ok {
+				raw = d.Get("id_token_ttl")
+			}
+			c.IDTokenTTL = time.Duration(raw.(int)) * time.Second
+		}
+		if raw, ok := d.GetOk("access_token_ttl"); ok || c.AccessTokenTTL == 0 {
+			if !ok {
+				raw = d.Get("access_token_ttl")
+			}
+			c.AccessTokenTTL = time.Duration(raw.(int)) * time.Second
+		}
+
+		if c.Key == "" {
+			return logical.ErrorResponse("the key parameter is required"), nil
+		}
+		key, err := i.getNamedKey(ctx, i.view, c.Key)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no key named %q", c.Key)), nil
+		}
+		if c.IDTokenTTL > key.VerificationTTL {
+			return logical.ErrorResponse("the id_token_ttl of the client must not be greater than the verification_ttl of its key"), nil
+		}
+		if c.AccessTokenTTL <= 0 {
+			return logical.ErrorResponse("access_token_ttl must be positive"), nil
+		}
+		for _, name := range c.Assignments {
+			a, err := i.getAssignment(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if a == nil {
+				return logical.ErrorResponse(fmt.Sprintf("no assignment named %q", name)), nil
+			}
+		}
+
+		return nil, i.putOIDCEntry(ctx, clientPath+name, c)
+	}
+}
+
+func (i *IdentityStore) pathOIDCReadClient() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		c, err := i.getClient(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"key":              c.Key,
+				"redirect_uris":    c.RedirectURIs,
+				"assignments":      c.Assignments,
+				"client_type":      c.ClientType,
+				"id_token_ttl":     int64(c.IDTokenTTL.Seconds()),
+				"access_token_ttl": int64(c.AccessTokenTTL.Seconds()),
+				"client_id":        c.ClientID,
+				"client_secret":    c.ClientSecret,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCDeleteClient() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		c, err := i.getClient(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return nil, nil
+		}
+
+		// Revoke the access tokens issued to the client
+		err = i.deleteAccessTokens(ctx, func(accessToken *accessTokenEntry) bool {
+			return accessToken.ClientID == c.ClientID
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, i.view.Delete(ctx, clientPath+name)
+	}
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateProvider() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		name := d.Get("name").(string)
+		p, err := i.getProvider(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			p = &provider{
+				Name: name,
+			}
+		}
+
+		if raw, ok := d.GetOk("issuer"); ok {
+			p.Issuer = raw.(string)
+		}
+		if raw, ok := d.GetOk("allowed_client_ids"); ok {
+			p.AllowedClientIDs = raw.([]string)
+		}
+		if raw, ok := d.GetOk("scopes_supported"); ok {
+			p.ScopesSupported = raw.([]string)
+		}
+
+		if p.Issuer != "" && !strings.HasPrefix(p.Issuer, "https://") && !strings.HasPrefix(p.Issuer, "http://") {
+			return logical.ErrorResponse("issuer must be an http or https URL"), nil
+		}
+		for _, name := range p.ScopesSupported {
+			s, err := i.getScope(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if s == nil {
+				return logical.ErrorResponse(fmt.Sprintf("no scope named %q", name)), nil
+			}
+		}
+
+		return nil, i.putOIDCEntry(ctx, providerPath+name, p)
+	}
+}
+
+func (i *IdentityStore) pathOIDCReadProvider() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		p, err := i.getProvider(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"issuer":             i.providerIssuer(p),
+				"allowed_client_ids": p.AllowedClientIDs,
+				"scopes_supported":   p.ScopesSupported,
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCDeleteProvider() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.Lock()
+		defer i.oidcLock.Unlock()
+
+		return nil, i.view.Delete(ctx, providerPath+d.Get("name").(string))
+	}
+}
+
+// providerIssuer returns the issuer URL of the provider
+func (i *IdentityStore) providerIssuer(p *provider) string {
+	issuer := p.Issuer
+	if issuer == "" {
+		issuer = i.core.redirectAddr
+	}
+	return strings.TrimSuffix(issuer, "/") + providerIssuerPathPrefix + p.Name
+}
+
+// allowsClient returns whether the provider can be used by the client
+func (p *provider) allowsClient(clientID string) bool {
+	return strutil.StrListContains(p.AllowedClientIDs, "*") || strutil.StrListContains(p.AllowedClientIDs, clientID)
+}
+
+func (i *IdentityStore) pathOIDCProviderDiscovery() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		p, err := i.getProvider(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, nil
+		}
+
+		issuer := i.providerIssuer(p)
+		body, err := json.Marshal(map[string]interface{}{
+			"issuer":                                issuer,
+			"jwks_uri":                              issuer + "/.well-known/keys",
+			"authorization_endpoint":                issuer + "/authorize",
+			"token_endpoint":                        issuer + "/token",
+			"userinfo_endpoint":                     issuer + "/userinfo",
+			"response_types_supported":              []string{"code"},
+			"grant_types_supported":                 []string{"authorization_code"},
+			"scopes_supported":                      append([]string{openIDScope}, p.ScopesSupported...),
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256", "RS384", "RS512"},
+			"token_endpoint_auth_methods_supported": []string{"none", "client_secret_basic", "client_secret_post"},
+			"code_challenge_methods_supported":      []string{codeChallengeMethodPlain, codeChallengeMethodS256},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return rawJSONResponse(body), nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCProviderReadPublicKeys() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		p, err := i.getProvider(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, nil
+		}
+
+		// Publish the keys of the clients allowed by the provider
+		clients, err := i.listClients(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var keyNames []string
+		for _, c := range clients {
+			if p.allowsClient(c.ClientID) && !strutil.StrListContains(keyNames, c.Key) {
+				keyNames = append(keyNames, c.Key)
+			}
+		}
+
+		keys := make([]map[string]interface{}, 0)
+		for _, keyName := range keyNames {
+			key, err := i.getNamedKey(ctx, i.view, keyName)
+			if err != nil {
+				return nil, err
+			}
+			if key == nil {
+				continue
+			}
+			for _, k := range key.KeyRing {
+				publicKey, err := i.getOIDCPublicKey(ctx, i.view, k.KeyID)
+				if err != nil {
+					return nil, err
+				}
+				if publicKey == nil {
+					continue
+				}
+				jwk, err := publicKeyJWK(publicKey)
+				if err != nil {
+					return nil, err
+				}
+				keys = append(keys, jwk)
+			}
+		}
+
+		body, err := json.Marshal(map[string]interface{}{
+			"keys": keys,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return rawJSONResponse(body), nil
+	}
+}
+
+// entityAssigned returns whether the entity is allowed to use the client by
+// one of its assignments
+func (i *IdentityStore) entityAssigned(ctx context.Context, c *client, entityID string) (bool, error) {
+	directGroups, inheritedGroups, err := i.groupsByEntityID(entityID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range c.Assignments {
+		a, err := i.getAssignment(ctx, name)
+		if err != nil {
+			return false, err
+		}
+		if a == nil {
+			continue
+		}
+		if strutil.StrListContains(a.EntityIDs, entityID) {
+			return true, nil
+		}
+		for _, group := range append(directGroups, inheritedGroups...) {
+			if strutil.StrListContains(a.GroupIDs, group.ID) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// activeEntity returns whether the entity exists and is enabled
+func (i *IdentityStore) activeEntity(entityID string) (bool, error) {
+	if entityID == "" {
+		return false, nil
+	}
+	entity, err := i.MemDBEntityByID(entityID, false)
+	if err != nil {
+		return false, err
+	}
+	return entity != nil && !entity.Disabled, nil
+}
+
+func (i *IdentityStore) pathOIDCAuthorize() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		name := d.Get("name").(string)
+		p, err := i.getProvider(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no provider named %q", name)), logical.ErrInvalidRequest
+		}
+
+		clientID := d.Get("client_id").(string)
+		c, err := i.clientByID(ctx, clientID)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil || !p.allowsClient(c.ClientID) {
+			return logical.ErrorResponse("invalid client_id"), logical.ErrInvalidRequest
+		}
+		redirectURI := d.Get("redirect_uri").(string)
+		if !strutil.StrListContains(c.RedirectURIs, redirectURI) {
+			return logical.ErrorResponse("redirect_uri is not allowed for the client"), logical.ErrInvalidRequest
+		}
+
+		if responseType := d.Get("response_type").(string); responseType != "code" {
+			return logical.ErrorResponse(fmt.Sprintf("unsupported response_type %q", responseType)), logical.ErrInvalidRequest
+		}
+		scopes := strutil.RemoveDuplicates(strings.Fields(d.Get("scope").(string)), false)
+		if !strutil.StrListContains(scopes, openIDScope) {
+			return logical.ErrorResponse(fmt.Sprintf("the scope parameter must contain %q", openIDScope)), logical.ErrInvalidRequest
+		}
+
+		codeChallenge := d.Get("code_challenge").(string)
+		codeChallengeMethod := d.Get("code_challenge_method").(string)
+		switch {
+		case codeChallenge == "" && c.ClientType == clientTypePublic:
+			return logical.ErrorResponse("public clients must use PKCE"), logical.ErrInvalidRequest
+		case codeChallenge == "" && codeChallengeMethod != "":
+			return logical.ErrorResponse("code_challenge_method requires a code_challenge"), logical.ErrInvalidRequest
+		case codeChallenge != "" && codeChallengeMethod == "":
+			codeChallengeMethod = codeChallengeMethodPlain
+		}
+		if codeChallengeMethod != "" && codeChallengeMethod != codeChallengeMethodPlain && codeChallengeMethod != codeChallengeMethodS256 {
+			return logical.ErrorResponse(fmt.Sprintf("unsupported code_challenge_method %q", codeChallengeMethod)), logical.ErrInvalidRequest
+		}
+
+		active, err := i.activeEntity(req.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return logical.ErrorResponse("the entity of the request's token is missing or disabled"), logical.ErrPermissionDenied
+		}
+		assigned, err := i.entityAssigned(ctx, c, req.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		if !assigned {
+			return logical.ErrorResponse("the entity of the request's token is not assigned to the client"), logical.ErrPermissionDenied
+		}
+
+		code, err := base64RandomString(32)
+		if err != nil {
+			return nil, err
+		}
+		err = i.storeOIDCProviderEntry(ctx, authCodePath, code, &authCodeEntry{
+			Provider:            p.Name,
+			ClientID:            c.ClientID,
+			EntityID:            req.EntityID,
+			RedirectURI:         redirectURI,
+			Nonce:               d.Get("nonce").(string),
+			Scopes:              scopes,
+			CodeChallenge:       codeChallenge,
+			CodeChallengeMethod: codeChallengeMethod,
+			AuthTime:            time.Now(),
+			ExpireAt:            time.Now().Add(oidcAuthCodeTTL),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"code":  code,
+				"state": d.Get("state").(string),
+			},
+		}, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCToken() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		p, err := i.getProvider(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, nil
+		}
+
+		// Authenticate the client
+		clientID, clientSecret, basicAuth := basicAuthCredentials(req)
+		if !basicAuth {
+			clientID = d.Get("client_id").(string)
+			clientSecret = d.Get("client_secret").(string)
+		}
+		c, err := i.clientByID(ctx, clientID)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil || !p.allowsClient(c.ClientID) {
+			return oauthErrorResponse(http.StatusUnauthorized, "invalid_client", "client failed to authenticate"), nil
+		}
+		if c.ClientType == clientTypeConfidential && subtle.ConstantTimeCompare([]byte(c.ClientSecret), []byte(clientSecret)) != 1 {
+			return oauthErrorResponse(http.StatusUnauthorized, "invalid_client", "client failed to authenticate"), nil
+		}
+
+		if grantType := d.Get("grant_type").(string); grantType != "authorization_code" {
+			return oauthErrorResponse(http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("unsupported grant_type %q", grantType)), nil
+		}
+
+		// Authorization codes can only be used once
+		authCode, err := i.takeAuthCode(ctx, d.Get("code").(string))
+		if err != nil {
+			return nil, err
+		}
+		if authCode == nil {
+			return oauthErrorResponse(http.StatusBadRequest, "invalid_grant", "invalid or expired authorization code"), nil
+		}
+
+		if authCode.Provider != p.Name || authCode.ClientID != c.ClientID {
+			return oauthErrorResponse(http.StatusBadRequest, "invalid_grant", "authorization code was not issued to the client"), nil
+		}
+		if authCode.RedirectURI != d.Get("redirect_uri").(string) {
+			return oauthErrorResponse(http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request"), nil
+		}
+		if err := verifyCodeChallenge(authCode, d.Get("code_verifier").(string)); err != nil {
+			return oauthErrorResponse(http.StatusBadRequest, "invalid_grant", err.Error()), nil
+		}
+
+		active, err := i.activeEntity(authCode.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return oauthErrorResponse(http.StatusBadRequest, "invalid_grant", "the entity is missing or disabled"), nil
+		}
+		assigned, err := i.entityAssigned(ctx, c, authCode.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		if !assigned {
+			return oauthErrorResponse(http.StatusBadRequest, "invalid_grant", "the entity is not assigned to the client"), nil
+		}
+
+		key, err := i.getNamedKey(ctx, i.view, c.Key)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return oauthErrorResponse(http.StatusBadRequest, "invalid_client", fmt.Sprintf("no key named %q", c.Key)), nil
+		}
+		if !strutil.StrListContains(key.AllowedClientIDs, "*") && !strutil.StrListContains(key.AllowedClientIDs, c.ClientID) {
+			return oauthErrorResponse(http.StatusBadRequest, "invalid_client", "the key of the client does not allow its client ID"), nil
+		}
+
+		claims, err := i.scopesClaims(ctx, p, authCode.EntityID, authCode.Scopes)
+		if err != nil {
+			return oauthErrorResponse(http.StatusInternalServerError, "server_error", err.Error()), nil
+		}
+
+		now := time.Now()
+		idTokenClaims := jws.Claims{}
+		for k, v := range claims {
+			idTokenClaims[k] = v
+		}
+		idTokenClaims.SetIssuer(i.providerIssuer(p))
+		idTokenClaims.SetSubject(authCode.EntityID)
+		idTokenClaims.SetAudience(c.ClientID)
+		idTokenClaims.SetIssuedAt(now)
+		idTokenClaims.SetExpiration(now.Add(c.IDTokenTTL))
+		idTokenClaims.Set("auth_time", authCode.AuthTime.Unix())
+		if authCode.Nonce != "" {
+			idTokenClaims.Set("nonce", authCode.Nonce)
+		}
+
+		idToken, err := signOIDCToken(key, idTokenClaims)
+		if err != nil {
+			return nil, err
+		}
+
+		accessToken, err := base64RandomString(32)
+		if err != nil {
+			return nil, err
+		}
+		err = i.storeOIDCProviderEntry(ctx, accessTokenPath, accessToken, &accessTokenEntry{
+			Provider: p.Name,
+			ClientID: c.ClientID,
+			EntityID: authCode.EntityID,
+			Scopes:   authCode.Scopes,
+			ExpireAt: now.Add(c.AccessTokenTTL),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := json.Marshal(map[string]interface{}{
+			"access_token": accessToken,
+			"token_type":   "Bearer",
+			"expires_in":   int64(c.AccessTokenTTL.Seconds()),
+			"id_token":     idToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return rawJSONResponse(body), nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCUserInfo() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.oidcLock.RLock()
+		defer i.oidcLock.RUnlock()
+
+		p, err := i.getProvider(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, nil
+		}
+
+		authorization := requestHeader(req, "Authorization")
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			return oauthErrorResponse(http.StatusUnauthorized, "invalid_token", "missing bearer access token"), nil
+		}
+		var accessToken accessTokenEntry
+		ok, err := i.getOIDCProviderEntry(ctx, accessTokenPath, strings.TrimPrefix(authorization, "Bearer "), &accessToken)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || time.Now().After(accessToken.ExpireAt) {
+			return oauthErrorResponse(http.StatusUnauthorized, "invalid_token", "invalid or expired access token"), nil
+		}
+		if accessToken.Provider != p.Name {
+			return oauthErrorResponse(http.StatusUnauthorized, "invalid_token", "access token was not issued by the provider"), nil
+		}
+
+		active, err := i.activeEntity(accessToken.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return oauthErrorResponse(http.StatusUnauthorized, "invalid_token", "the entity is missing or disabled"), nil
+		}
+
+		claims, err := i.scopesClaims(ctx, p, accessToken.EntityID, accessToken.Scopes)
+		if err != nil {
+			return oauthErrorResponse(http.StatusInternalServerError, "server_error", err.Error()), nil
+		}
+		claims["sub"] = accessToken.EntityID
+
+		body, err := json.Marshal(claims)
+		if err != nil {
+			return nil, err
+		}
+
+		return rawJSONResponse(body), nil
+	}
+}
+
+// scopesClaims returns the claims of the requested scopes supported by the
+// provider for the entity. Unknown scopes are ignored.
+func (i *IdentityStore) scopesClaims(ctx context.Context, p *provider, entityID string, scopes []string) (map[string]interface{}, error) {
+	claims := make(map[string]interface{})
+
+	var input *identitytpl.JSONInput
+	for _, name := range scopes {
+		if name == openIDScope || !strutil.StrListContains(p.ScopesSupported, name) {
+			continue
+		}
+		s, err := i.getScope(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil || s.Template == "" {
+			continue
+		}
+
+		if input == nil {
+			in, err := i.claimsTemplateInput(entityID)
+			if err != nil {
+				return nil, err
+			}
+			input = &in
+		}
+		scopeClaims, err := populateClaimsTemplate(s.Template, *input)
+		if err != nil {
+			return nil, fmt.Errorf("error populating the template of scope %q: %s", name, err)
+		}
+		for k, v := range scopeClaims {
+			claims[k] = v
+		}
+	}
+
+	return claims, nil
+}
+
+// oidcProviderEntryKey returns the storage key of an authorization code or an
+// access token, which are only stored hashed
+func oidcProviderEntryKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (i *IdentityStore) storeOIDCProviderEntry(ctx context.Context, prefix, secret string, v interface{}) error {
+	entry, err := logical.StorageEntryJSON(prefix+oidcProviderEntryKey(secret), v)
+	if err != nil {
+		return err
+	}
+	return i.view.Put(ctx, entry)
+}
+
+func (i *IdentityStore) getOIDCProviderEntry(ctx context.Context, prefix, secret string, v interface{}) (bool, error) {
+	if secret == "" {
+		return false, nil
+	}
+	entry, err := i.view.Get(ctx, prefix+oidcProviderEntryKey(secret))
+	if err != nil || entry == nil {
+		return false, err
+	}
+	return true, entry.DecodeJSON(v)
+}
+
+// takeAuthCode returns the unexpired authorization code and deletes it, so
+// that it can only be exchanged once
+func (i *IdentityStore) takeAuthCode(ctx context.Context, code string) (*authCodeEntry, error) {
+	i.oidcAuthCodeLock.Lock()
+	defer i.oidcAuthCodeLock.Unlock()
+
+	var authCode authCodeEntry
+	ok, err := i.getOIDCProviderEntry(ctx, authCodePath, code, &authCode)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if err := i.view.Delete(ctx, authCodePath+oidcProviderEntryKey(code)); err != nil {
+		return nil, err
+	}
+	if time.Now().After(authCode.ExpireAt) {
+		return nil, nil
+	}
+	return &authCode, nil
+}
+
+// deleteAccessTokens deletes the access tokens matching the given filter
+func (i *IdentityStore) deleteAccessTokens(ctx context.Context, filter func(*accessTokenEntry) bool) error {
+	keys, err := i.view.List(ctx, accessTokenPath)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		entry, err := i.view.Get(ctx, accessTokenPath+key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+		var accessToken accessTokenEntry
+		if err := entry.DecodeJSON(&accessToken); err != nil {
+			return err
+		}
+		if filter(&accessToken) {
+			if err := i.view.Delete(ctx, accessTokenPath+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tidyOIDCProviderEntries deletes the expired authorization codes and
+// access tokens
+func (i *IdentityStore) tidyOIDCProviderEntries(ctx context.Context) error {
+	now := time.Now()
+
+	i.oidcAuthCodeLock.Lock()
+	defer i.oidcAuthCodeLock.Unlock()
+
+	keys, err := i.view.List(ctx, authCodePath)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		entry, err := i.view.Get(ctx, authCodePath+key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+		var authCode authCodeEntry
+		if err := entry.DecodeJSON(&authCode); err != nil {
+			return err
+		}
+		if now.After(authCode.ExpireAt) {
+			if err := i.view.Delete(ctx, authCodePath+key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return i.deleteAccessTokens(ctx, func(accessToken *accessTokenEntry) bool {
+		return now.After(accessToken.ExpireAt)
+	})
+}
+
+// verifyCodeChallenge checks the PKCE code verifier against the code
+// challenge of the authorization request
+func verifyCodeChallenge(authCode *authCodeEntry, codeVerifier string) error {
+	if authCode.CodeChallenge == "" {
+		if codeVerifier != "" {
+			return fmt.Errorf("code_verifier given without a code_challenge in the authorization request")
+		}
+		return nil
+	}
+	if codeVerifier == "" {
+		return fmt.Errorf("missing code_verifier")
+	}
+
+	challenge := codeVerifier
+	if authCode.CodeChallengeMethod == codeChallengeMethodS256 {
+		sum := sha256.Sum256([]byte(codeVerifier))
+		challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(authCode.CodeChallenge)) != 1 {
+		return fmt.Errorf("invalid code_verifier")
+	}
+	return nil
+}
+
+// requestHeader returns the first value of the given header of the request,
+// ignoring the case of its name
+func requestHeader(req *logical.Request, name string) string {
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, name) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// basicAuthCredentials returns the credentials of the HTTP basic
+// authentication of the request, if any
+func basicAuthCredentials(req *logical.Request) (string, string, bool) {
+	authorization := requestHeader(req, "Authorization")
+	if !strings.HasPrefix(authorization, "Basic ") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, "Basic "))
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// oauthErrorResponse returns an error response in the format of RFC 6749
+func oauthErrorResponse(status int, code, description string) *logical.Response {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error":             code,
+		"error_description": description,
+	})
+	resp := rawJSONResponse(body)
+	resp.Data[logical.HTTPStatusCode] = status
+	return resp
+}
+
+// base64RandomString returns a URL-safe string encoding n random bytes
+func base64RandomString(n int) (string, error) {
+	b, err := uuid.GenerateRandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+var oidcProviderHelp = map[string][2]string{
+	"oidc-assignment": {
+		"CRUD operations for OIDC assignments.",
+		`Assignments list the entities and groups allowed to use the clients
+they are attached to.`,
+	},
+	"oidc-assignment-list": {
+		"List OIDC assignments",
+		"List all configured OIDC assignments in the identity backend.",
+	},
+	"oidc-scope": {
+		"CRUD operations for OIDC scopes.",
+		`Scopes are sets of claims that clients can request. The claims are
+populated from the JSON template of the scope with the identity of the
+authenticated entity, for example:
+
+  {"groups": {{identity.groups.names}}}
+
+The "openid" scope is reserved and always supported.`,
+	},
+	"oidc-scope-list": {
+		"List OIDC scopes",
+		"List all configured OIDC scopes in the identity backend.",
+	},
+	"oidc-client": {
+		"CRUD operations for OIDC clients.",
+		`Clients are the applications relying on the OIDC providers to
+authenticate their users. The ID tokens issued to a client are signed with its
+key, and only the entities allowed by its assignments can use it. The client ID
+and, for confidential clients, the client secret are generated when the client
+is created.`,
+	},
+	"oidc-client-list": {
+		"List OIDC clients",
+		"List all configured OIDC clients in the identity backend.",
+	},
+	"oidc-provider": {
+		"CRUD operations for OIDC providers.",
+		`OIDC providers authenticate the entities of the identity store on behalf
+of the allowed clients, using the authorization code flow. The issuer of a
+provider is the given URL followed by "/v1/identity/oidc/provider/<name>", and
+defaults to the API address of Vault.`,
+	},
+	"oidc-provider-list": {
+		"List OIDC providers",
+		"List all configured OIDC providers in the identity backend.",
+	},
+	"oidc-provider-discovery": {
+		"Query the OIDC configuration of a provider",
+		"Query this path to retrieve the OpenID configuration of the provider.",
+	},
+	"oidc-provider-keys": {
+		"Retrieve the public keys of a provider",
+		"Query this path to retrieve the public keys verifying the ID tokens of the clients of the provider.",
+	},
+	"oidc-provider-authorize": {
+		"Authorize a client to authenticate the entity",
+		`Issue an authorization code to the client for the entity of the request's
+token. The authorization code can be exchanged for tokens at the token endpoint
+of the provider within five minutes.`,
+	},
+	"oidc-provider-token": {
+		"Exchange an authorization code for tokens",
+		`Exchange an authorization code for an ID token and an access token.
+Confidential clients authenticate with their client secret, using HTTP basic
+authentication or the client_id and client_secret parameters. Public clients
+must give the PKCE code verifier.`,
+	},
+	"oidc-provider-userinfo": {
+		"Retrieve the claims of the authenticated entity",
+		"Retrieve the claims of the scopes granted to the bearer access token.",
+	},
+}
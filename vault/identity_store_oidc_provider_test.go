@@ -0,0 +1,351 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SermoDigital/jose/jws"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestIdentityStore_OIDCProvider(t *testing.T) {
+	is, _, _ := testIdentityStoreWithGithubAuth(t)
+	ctx := context.Background()
+
+	doReq := func(req *logical.Request) *logical.Response {
+		t.Helper()
+		resp, err := is.HandleRequest(ctx, req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s resp: %#v err: %v", req.Path, resp, err)
+		}
+		return resp
+	}
+	write := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		return doReq(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+	}
+	expectError := func(req *logical.Request) {
+		t.Helper()
+		resp, err := is.HandleRequest(ctx, req)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error for path %s, got: %#v", req.Path, resp)
+		}
+	}
+	rawJSON := func(resp *logical.Response, status int) map[string]interface{} {
+		t.Helper()
+		if resp.Data[logical.HTTPStatusCode] != status {
+			t.Fatalf("expected status %d, got: %s", status, resp.Data[logical.HTTPRawBody])
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	entityID := write("entity", map[string]interface{}{
+		"name":     "jdoe",
+		"metadata": "email=jdoe@example.com",
+	}).Data["id"].(string)
+	otherEntityID := write("entity", map[string]interface{}{
+		"name": "other",
+	}).Data["id"].(string)
+	groupID := write("group", map[string]interface{}{
+		"name":              "engineering",
+		"member_entity_ids": entityID,
+	}).Data["id"].(string)
+
+	write("oidc/key/signing", map[string]interface{}{
+		"allowed_client_ids": "*",
+	})
+	write("oidc/assignment/engineers", map[string]interface{}{
+		"group_ids": groupID,
+	})
+
+	expectError(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "oidc/scope/openid",
+	})
+	write("oidc/scope/profile", map[string]interface{}{
+		"template": `{"email": {{identity.entity.metadata.email}}, "groups": {{identity.groups.names}}}`,
+	})
+
+	// Clients must reference existing keys and assignments
+	expectError(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "oidc/client/app",
+		Data: map[string]interface{}{
+			"key":         "signing",
+			"assignments": "missing",
+		},
+	})
+	write("oidc/client/app", map[string]interface{}{
+		"key":           "signing",
+		"redirect_uris": "https://app.example.com/callback",
+		"assignments":   "engineers",
+	})
+	resp := doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/client/app",
+	})
+	clientID := resp.Data["client_id"].(string)
+	clientSecret := resp.Data["client_secret"].(string)
+	if clientID == "" || clientSecret == "" || resp.Data["client_type"] != "confidential" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	write("oidc/client/cli", map[string]interface{}{
+		"key":           "signing",
+		"client_type":   "public",
+		"redirect_uris": "http://127.0.0.1:8250/callback",
+		"assignments":   "engineers",
+	})
+	resp = doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/client/cli",
+	})
+	publicClientID := resp.Data["client_id"].(string)
+	if resp.Data["client_secret"] != "" {
+		t.Fatalf("public clients have no secret: %#v", resp.Data)
+	}
+
+	write("oidc/provider/sso", map[string]interface{}{
+		"issuer":             "https://vault.example.com",
+		"allowed_client_ids": "*",
+		"scopes_supported":   "profile",
+	})
+	issuer := "https://vault.example.com/v1/identity/oidc/provider/sso"
+
+	// Scopes used by providers can't be deleted
+	expectError(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "oidc/scope/profile",
+	})
+
+	discovery := rawJSON(doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/provider/sso/.well-known/openid-configuration",
+	}), http.StatusOK)
+	if discovery["issuer"] != issuer || discovery["token_endpoint"] != issuer+"/token" {
+		t.Fatalf("bad: %#v", discovery)
+	}
+	keys := rawJSON(doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/provider/sso/.well-known/keys",
+	}), http.StatusOK)
+	if len(keys["keys"].([]interface{})) != 1 {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	authorize := func(entityID string, data map[string]interface{}) (*logical.Response, error) {
+		params := map[string]interface{}{
+			"client_id":     clientID,
+			"scope":         "openid profile",
+			"redirect_uri":  "https://app.example.com/callback",
+			"response_type": "code",
+			"state":         "st",
+			"nonce":         "n0nce",
+		}
+		for k, v := range data {
+			params[k] = v
+		}
+		return is.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "oidc/provider/sso/authorize",
+			Data:      params,
+			EntityID:  entityID,
+		})
+	}
+
+	// Invalid authentication requests
+	for _, data := range []map[string]interface{}{
+		{"redirect_uri": "https://evil.example.com/callback"},
+		{"scope": "profile"},
+		{"response_type": "token"},
+		{"client_id": "unknown"},
+		{"client_id": publicClientID, "redirect_uri": "http://127.0.0.1:8250/callback"},
+		{"code_challenge": "abc", "code_challenge_method": "S512"},
+	} {
+		if resp, err := authorize(entityID, data); err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error for %#v, got: %#v", data, resp)
+		}
+	}
+
+	// Entities must be assigned to the client
+	if _, err := authorize(otherEntityID, nil); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+
+	codeVerifier := "a-code-verifier-that-is-long-enough-for-pkce"
+	sum := sha256.Sum256([]byte(codeVerifier))
+	codeChallenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	getCode := func() string {
+		t.Helper()
+		resp, err := authorize(entityID, map[string]interface{}{
+			"code_challenge":        codeChallenge,
+			"code_challenge_method": "S256",
+		})
+		if err != nil || resp.IsError() {
+			t.Fatalf("bad: %#v %v", resp, err)
+		}
+		if resp.Data["state"] != "st" {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+		return resp.Data["code"].(string)
+	}
+	exchange := func(code, secret, verifier string) *logical.Response {
+		t.Helper()
+		basicAuth := base64.StdEncoding.EncodeToString([]byte(clientID + ":" + secret))
+		return doReq(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "oidc/provider/sso/token",
+			Data: map[string]interface{}{
+				"grant_type":    "authorization_code",
+				"code":          code,
+				"redirect_uri":  "https://app.example.com/callback",
+				"code_verifier": verifier,
+			},
+			Headers: map[string][]string{
+				"Authorization": {"Basic " + basicAuth},
+			},
+		})
+	}
+
+	// Clients must authenticate, and failing to do so doesn't use the code
+	code := getCode()
+	if body := rawJSON(exchange(code, "wrong", codeVerifier), http.StatusUnauthorized); body["error"] != "invalid_client" {
+		t.Fatalf("bad: %#v", body)
+	}
+	if body := rawJSON(exchange(code, clientSecret, "wrong"), http.StatusBadRequest); body["error"] != "invalid_grant" {
+		t.Fatalf("bad: %#v", body)
+	}
+	// The code was used by the previous request
+	if body := rawJSON(exchange(code, clientSecret, codeVerifier), http.StatusBadRequest); body["error"] != "invalid_grant" {
+		t.Fatalf("bad: %#v", body)
+	}
+
+	tokens := rawJSON(exchange(getCode(), clientSecret, codeVerifier), http.StatusOK)
+	if tokens["token_type"] != "Bearer" || tokens["access_token"] == "" {
+		t.Fatalf("bad: %#v", tokens)
+	}
+
+	idToken, err := jws.ParseJWT([]byte(tokens["id_token"].(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := idToken.Claims()
+	if iss, _ := claims.Issuer(); iss != issuer {
+		t.Fatalf("bad issuer: %s", iss)
+	}
+	if sub, _ := claims.Subject(); sub != entityID {
+		t.Fatalf("bad subject: %s", sub)
+	}
+	if aud, _ := claims.Audience(); len(aud) != 1 || aud[0] != clientID {
+		t.Fatalf("bad audience: %v", aud)
+	}
+	if claims.Get("nonce") != "n0nce" || claims.Get("email") != "jdoe@example.com" {
+		t.Fatalf("bad claims: %#v", claims)
+	}
+
+	userInfo := rawJSON(doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/provider/sso/userinfo",
+		Headers: map[string][]string{
+			"Authorization": {"Bearer " + tokens["access_token"].(string)},
+		},
+	}), http.StatusOK)
+	if userInfo["sub"] != entityID || userInfo["email"] != "jdoe@example.com" {
+		t.Fatalf("bad: %#v", userInfo)
+	}
+	if groups := userInfo["groups"].([]interface{}); len(groups) != 1 || groups[0] != "engineering" {
+		t.Fatalf("bad: %#v", userInfo)
+	}
+
+	userInfo = rawJSON(doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/provider/sso/userinfo",
+		Headers: map[string][]string{
+			"Authorization": {"Bearer invalid"},
+		},
+	}), http.StatusUnauthorized)
+	if userInfo["error"] != "invalid_token" {
+		t.Fatalf("bad: %#v", userInfo)
+	}
+
+	// Keys and assignments used by clients can't be deleted
+	expectError(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "oidc/key/signing",
+	})
+	expectError(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "oidc/assignment/engineers",
+	})
+}
+
+func TestIdentityStore_OIDCProviderEntries(t *testing.T) {
+	is, _, _ := testIdentityStoreWithGithubAuth(t)
+	ctx := context.Background()
+
+	// Authorization codes are stored hashed and can be taken only once
+	err := is.storeOIDCProviderEntry(ctx, authCodePath, "code", &authCodeEntry{
+		ClientID: "client",
+		ExpireAt: time.Now().Add(oidcAuthCodeTTL),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := is.view.List(ctx, authCodePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] == "code" {
+		t.Fatalf("bad: %v", keys)
+	}
+	authCode, err := is.takeAuthCode(ctx, "code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authCode == nil || authCode.ClientID != "client" {
+		t.Fatalf("bad: %#v", authCode)
+	}
+	if authCode, err = is.takeAuthCode(ctx, "code"); err != nil || authCode != nil {
+		t.Fatalf("expected the code to be used only once, got: %#v, %v", authCode, err)
+	}
+
+	// Expired codes and access tokens are tidied
+	for _, prefix := range []string{authCodePath, accessTokenPath} {
+		for secret, expireAt := range map[string]time.Time{
+			"expired": time.Now().Add(-time.Minute),
+			"valid":   time.Now().Add(time.Minute),
+		} {
+			if err := is.storeOIDCProviderEntry(ctx, prefix, secret, &accessTokenEntry{ExpireAt: expireAt}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if authCode, err = is.takeAuthCode(ctx, "expired"); err != nil || authCode != nil {
+		t.Fatalf("expected the expired code to be rejected, got: %#v, %v", authCode, err)
+	}
+	if err := is.tidyOIDCProviderEntries(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{authCodePath, accessTokenPath} {
+		var entry accessTokenEntry
+		if ok, err := is.getOIDCProviderEntry(ctx, prefix, "expired", &entry); err != nil || ok {
+			t.Fatalf("expected %sexpired to be deleted: %v", prefix, err)
+		}
+		if ok, err := is.getOIDCProviderEntry(ctx, prefix, "valid", &entry); err != nil || !ok {
+			t.Fatalf("expected %svalid to be kept: %v", prefix, err)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package vault
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestIdentityStore_OIDCTokens(t *testing.T) {
+	is, _, _ := testIdentityStoreWithGithubAuth(t)
+	ctx := context.Background()
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}, entityID string) *logical.Response {
+		t.Helper()
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+			EntityID:  entityID,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s resp: %#v err: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	resp := doReq(logical.UpdateOperation, "entity", map[string]interface{}{
+		"name":     "jdoe",
+		"metadata": "team=ops",
+	}, "")
+	entityID := resp.Data["id"].(string)
+	doReq(logical.UpdateOperation, "group", map[string]interface{}{
+		"name":              "admins",
+		"member_entity_ids": entityID,
+	}, "")
+
+	doReq(logical.UpdateOperation, "oidc/config", map[string]interface{}{
+		"issuer": "https://vault.example.com",
+	}, "")
+	doReq(logical.UpdateOperation, "oidc/key/signing", map[string]interface{}{
+		"rotation_period":    "1h",
+		"verification_ttl":   "2h",
+		"allowed_client_ids": "*",
+	}, "")
+
+	// Templates must be JSON objects and can't set reserved claims
+	for _, template := range []string{`["not", "an", "object"]`, `{"sub": "override"}`, `{"team": {{identity.unknown}}}`} {
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "oidc/role/invalid",
+			Data: map[string]interface{}{
+				"key":      "signing",
+				"template": template,
+			},
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for template %s, got: %#v %v", template, resp, err)
+		}
+	}
+
+	doReq(logical.UpdateOperation, "oidc/role/app", map[string]interface{}{
+		"key":      "signing",
+		"ttl":      "30m",
+		"template": `{"team": {{identity.entity.metadata.team}}, "groups": {{identity.groups.names}}, "missing": {{identity.entity.metadata.missing}}}`,
+	}, "")
+	resp = doReq(logical.ReadOperation, "oidc/role/app", nil, "")
+	clientID := resp.Data["client_id"].(string)
+
+	// Tokens require an entity
+	if resp, err := is.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/token/app",
+	}); err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error without an entity, got: %#v %v", resp, err)
+	}
+
+	resp = doReq(logical.ReadOperation, "oidc/token/app", nil, entityID)
+	signedToken := resp.Data["token"].(string)
+	if resp.Data["client_id"] != clientID || resp.Data["ttl"] != int64(1800) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The token verifies against the published keys
+	resp = doReq(logical.ReadOperation, "oidc/.well-known/keys", nil, "")
+	var jwks struct {
+		Keys []struct {
+			KeyID string `json:"kid"`
+			N     string `json:"n"`
+			E     string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &jwks); err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 1 {
+		t.Fatalf("expected one key, got: %#v", jwks)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+	e, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+	publicKey := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+
+	token, err := jws.ParseJWT([]byte(signedToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := token.(jws.JWS).Protected().Get("kid"); kid != jwks.Keys[0].KeyID {
+		t.Fatalf("bad kid: %v", kid)
+	}
+	if err := token.Validate(publicKey, crypto.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	claims := token.Claims()
+	if iss, _ := claims.Issuer(); iss != "https://vault.example.com/v1/identity/oidc" {
+		t.Fatalf("bad issuer: %s", iss)
+	}
+	if sub, _ := claims.Subject(); sub != entityID {
+		t.Fatalf("bad subject: %s", sub)
+	}
+	if aud, _ := claims.Audience(); len(aud) != 1 || aud[0] != clientID {
+		t.Fatalf("bad audience: %v", aud)
+	}
+	if claims.Get("team") != "ops" {
+		t.Fatalf("bad team claim: %#v", claims)
+	}
+	if groups, ok := claims.Get("groups").([]interface{}); !ok || len(groups) != 1 || groups[0] != "admins" {
+		t.Fatalf("bad groups claim: %#v", claims)
+	}
+	if claims.Has("missing") {
+		t.Fatal("null claims should be dropped")
+	}
+
+	// Introspection
+	resp = doReq(logical.UpdateOperation, "oidc/introspect", map[string]interface{}{
+		"token":     signedToken,
+		"client_id": clientID,
+	}, "")
+	if resp.Data["active"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = doReq(logical.UpdateOperation, "oidc/introspect", map[string]interface{}{
+		"token":     signedToken,
+		"client_id": "other",
+	}, "")
+	if resp.Data["active"] != false {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Discovery
+	resp = doReq(logical.ReadOperation, "oidc/.well-known/openid-configuration", nil, "")
+	var discovery map[string]interface{}
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &discovery); err != nil {
+		t.Fatal(err)
+	}
+	if discovery["jwks_uri"] != "https://vault.example.com/v1/identity/oidc/.well-known/keys" {
+		t.Fatalf("bad: %#v", discovery)
+	}
+
+	// Rotated keys remain published until their verification TTL passes
+	doReq(logical.UpdateOperation, "oidc/key/signing/rotate", map[string]interface{}{
+		"verification_ttl": "1s",
+	}, "")
+	resp = doReq(logical.UpdateOperation, "oidc/introspect", map[string]interface{}{
+		"token": signedToken,
+	}, "")
+	if resp.Data["active"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if err := is.oidcPeriodicFunc(ctx, &logical.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	resp = doReq(logical.UpdateOperation, "oidc/introspect", map[string]interface{}{
+		"token": signedToken,
+	}, "")
+	if resp.Data["active"] != false {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if keys := doReq(logical.ListOperation, "oidc/key/", nil, "").Data["keys"].([]string); len(keys) != 1 {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	// Keys in use by roles can't be deleted
+	if resp, err := is.HandleRequest(ctx, &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "oidc/key/signing",
+	}); err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error deleting a key in use, got: %#v %v", resp, err)
+	}
+	doReq(logical.DeleteOperation, "oidc/role/app", nil, "")
+	doReq(logical.DeleteOperation, "oidc/key/signing", nil, "")
+}
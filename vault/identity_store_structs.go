@@ -11,6 +11,7 @@ import (
 	"github.com/hashicorp/vault/helper/storagepacker"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
 )
 
 const (
@@ -72,6 +73,21 @@ type IdentityStore struct {
 
 	// core is the pointer to Vault's core
 	core *Core
+
+	// oidcLock protects the keys and roles of the OIDC identity tokens
+	oidcLock sync.RWMutex
+
+	// oidcAuthCodeLock ensures the authorization codes issued by the OIDC
+	// providers are exchanged for tokens only once
+	oidcAuthCodeLock sync.Mutex
+
+	// mfaLock protects the login MFA methods, TOTP keys and login
+	// enforcements
+	mfaLock sync.RWMutex
+
+	// mfaUsedPasscodeCache holds the TOTP passcodes used to validate logins
+	// until they expire, so that they can't be replayed
+	mfaUsedPasscodeCache *cache.Cache
 }
 
 type groupDiff struct {
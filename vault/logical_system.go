@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"hash"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -25,6 +26,7 @@ import (
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/random"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/helper/wrapping"
 	"github.com/hashicorp/vault/logical"
@@ -80,6 +82,7 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				"replication/reindex",
 				"rotate",
 				"config/cors",
+				"config/wrapping",
 				"config/auditing/*",
 				"config/ui/headers/*",
 				"plugins/catalog/*",
@@ -93,6 +96,7 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 			Unauthenticated: []string{
 				"wrapping/lookup",
 				"wrapping/pubkey",
+				"mfa/validate",
 				"replication/status",
 				"internal/ui/mounts",
 				"internal/ui/mounts/*",
@@ -154,6 +158,29 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				HelpSynopsis:    strings.TrimSpace(sysHelp["config/cors"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "config/wrapping$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"history_retention": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Description: "How long the history of a wrapping token is kept after it is unwrapped or expires. Defaults to 24 hours.",
+					},
+					"unwrap_callback_url": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "URL sent a POST request with the event of each unwrap. Set to an empty string to disable.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleWrappingConfigRead,
+					logical.UpdateOperation: b.handleWrappingConfigUpdate,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["config/wrapping"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["config/wrapping"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "config/ui/headers/" + framework.GenericNameRegex("header"),
 
@@ -728,6 +755,59 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				HelpDescription: strings.TrimSpace(sysHelp["policy"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "policies/password/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handlePasswordPoliciesList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "policies/password/" + framework.GenericNameRegex("name") + "/generate$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["password-policy-name"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handlePasswordPolicyGenerate,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy-generate"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy-generate"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "policies/password/" + framework.GenericNameRegex("name") + "$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["password-policy-name"][0]),
+					},
+					"policy": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["password-policy-rules"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handlePasswordPolicyRead,
+					logical.UpdateOperation: b.handlePasswordPolicySet,
+					logical.DeleteOperation: b.handlePasswordPolicyDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy"][1]),
+			},
+
 			&framework.Path{
 				Pattern:         "seal-status$",
 				HelpSynopsis:    strings.TrimSpace(sysHelp["seal-status"][0]),
@@ -865,6 +945,28 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				HelpDescription: strings.TrimSpace(sysHelp["unwrap"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "mfa/validate$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"mfa_request_id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "ID of the MFA requirement returned by the login.",
+					},
+					"mfa_payload": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: "Map of the IDs of the MFA methods to their passcodes. Methods using push notifications take an empty list.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleMFAValidate,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-validate"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["mfa-validate"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "wrapping/lookup$",
 
@@ -872,6 +974,10 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 					"token": &framework.FieldSchema{
 						Type: framework.TypeString,
 					},
+					"accessor": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Accessor of a wrapping token created by the caller. If set, the history of the lookups and unwraps of the wrapping token is returned.",
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -1131,6 +1237,7 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 	}
 
 	b.Backend.Invalidate = b.invalidate
+	b.Backend.PeriodicFunc = b.periodicFunc
 
 	return b
 }
@@ -1190,6 +1297,51 @@ func (b *SystemBackend) handleTidyLeases(ctx context.Context, req *logical.Reque
 	return nil, err
 }
 
+// periodicFunc removes the expired wrapping token histories
+func (b *SystemBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	return b.Core.tidyWrappingHistory(ctx)
+}
+
+// handleWrappingConfigRead returns the configuration of the wrapping token
+// histories
+func (b *SystemBackend) handleWrappingConfigRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := b.Core.wrappingConfig()
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"history_retention":   int64(config.HistoryRetention.Seconds()),
+			"unwrap_callback_url": config.UnwrapCallbackURL,
+		},
+	}, nil
+}
+
+// handleWrappingConfigUpdate updates the configuration of the wrapping token
+// histories
+func (b *SystemBackend) handleWrappingConfigUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := b.Core.wrappingConfig()
+
+	if retentionRaw, ok := d.GetOk("history_retention"); ok {
+		retention := retentionRaw.(int)
+		if retention < 0 {
+			return logical.ErrorResponse("history_retention cannot be negative"), logical.ErrInvalidRequest
+		}
+		config.HistoryRetention = time.Duration(retention) * time.Second
+	}
+
+	if callbackRaw, ok := d.GetOk("unwrap_callback_url"); ok {
+		callbackURL := callbackRaw.(string)
+		if callbackURL != "" {
+			u, err := url.Parse(callbackURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return logical.ErrorResponse("unwrap_callback_url must be an http or https URL"), logical.ErrInvalidRequest
+			}
+		}
+		config.UnwrapCallbackURL = callbackURL
+	}
+
+	return nil, b.Core.saveWrappingConfig(ctx, config)
+}
+
 func (b *SystemBackend) invalidate(ctx context.Context, key string) {
 	/*
 		if b.Core.logger.IsTrace() {
@@ -2656,6 +2808,97 @@ func (b *SystemBackend) handlePoliciesDelete(policyType PolicyType) framework.Op
 	}
 }
 
+// handlePasswordPoliciesList handles the "/sys/policies/password/" endpoint
+// to list the password policies
+func (b *SystemBackend) handlePasswordPoliciesList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keys, err := b.Core.passwordPolicyView().List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(keys), nil
+}
+
+// handlePasswordPolicyRead handles the "/sys/policies/password/<name>"
+// endpoint to read a password policy
+func (b *SystemBackend) handlePasswordPolicyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	policy, err := b.Core.passwordPolicyEntry(ctx, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"policy": policy.Policy,
+		},
+	}, nil
+}
+
+// handlePasswordPolicySet handles the "/sys/policies/password/<name>"
+// endpoint to create or update a password policy
+func (b *SystemBackend) handlePasswordPolicySet(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	rules := data.Get("policy").(string)
+	if rules == "" {
+		return logical.ErrorResponse("missing policy"), nil
+	}
+
+	// Allow the policy to be given in base64 like ACL policies
+	if decoded, err := base64.StdEncoding.DecodeString(rules); err == nil {
+		rules = string(decoded)
+	}
+
+	generator, err := random.ParsePolicy(rules)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if _, err := generator.Generate(); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to generate a password from the policy: %s", err)), nil
+	}
+
+	if err := b.Core.setPasswordPolicy(ctx, data.Get("name").(string), &passwordPolicyEntry{
+		Policy: rules,
+	}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handlePasswordPolicyDelete handles the "/sys/policies/password/<name>"
+// endpoint to delete a password policy
+func (b *SystemBackend) handlePasswordPolicyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.Core.passwordPolicyView().Delete(ctx, data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handlePasswordPolicyGenerate handles the
+// "/sys/policies/password/<name>/generate" endpoint to generate a password
+// from a password policy
+func (b *SystemBackend) handlePasswordPolicyGenerate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	generator, err := b.Core.passwordPolicy(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if generator == nil {
+		return logical.ErrorResponse(fmt.Sprintf("password policy %q not found", name)), logical.ErrInvalidRequest
+	}
+
+	password, err := generator.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"password": password,
+		},
+	}, nil
+}
+
 // handleAuditTable handles the "audit" endpoint to provide the audit table
 func (b *SystemBackend) handleAuditTable(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	b.Core.auditLock.RLock()
@@ -3054,6 +3297,10 @@ func (b *SystemBackend) handleWrappingUnwrap(ctx context.Context, req *logical.R
 		return respErr, err
 	}
 
+	if err := b.Core.recordWrappingEvent(ctx, req, wrappingEventUnwrap, te.Accessor); err != nil {
+		b.Core.Logger().Error("failed to record wrapping token unwrap", "error", err)
+	}
+
 	resp := &logical.Response{
 		Data: map[string]interface{}{},
 	}
@@ -3158,7 +3405,34 @@ func (b *SystemBackend) responseWrappingUnwrap(ctx context.Context, token string
 	return response, nil
 }
 
+// handleMFAValidate validates the MFA requirement of a login, returning its
+// token
+func (b *SystemBackend) handleMFAValidate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	requestID := data.Get("mfa_request_id").(string)
+	if requestID == "" {
+		return logical.ErrorResponse("missing mfa_request_id"), logical.ErrInvalidRequest
+	}
+
+	payload := make(map[string][]string)
+	for methodID, raw := range data.Get("mfa_payload").(map[string]interface{}) {
+		var passcodes []string
+		if err := mapstructure.WeakDecode(raw, &passcodes); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid passcodes for MFA method %q", methodID)), logical.ErrInvalidRequest
+		}
+		payload[methodID] = passcodes
+	}
+	if len(payload) == 0 {
+		return logical.ErrorResponse("missing mfa_payload"), logical.ErrInvalidRequest
+	}
+
+	return b.Core.validateLoginMFA(ctx, requestID, payload)
+}
+
 func (b *SystemBackend) handleWrappingLookup(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if accessor := data.Get("accessor").(string); accessor != "" {
+		return b.handleWrappingHistoryLookup(ctx, req, accessor)
+	}
+
 	// This ordering of lookups has been validated already in the wrapping
 	// validation func, we're just doing this for a safety check
 	token := data.Get("token").(string)
@@ -3210,9 +3484,57 @@ func (b *SystemBackend) handleWrappingLookup(ctx context.Context, req *logical.R
 		resp.Data["creation_path"] = cubbyResp.Data["creation_path"]
 	}
 
+	te, err := b.Core.tokenStore.Lookup(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if te != nil {
+		if err := b.Core.recordWrappingEvent(ctx, req, wrappingEventLookup, te.Accessor); err != nil {
+			b.Core.Logger().Error("failed to record wrapping token lookup", "error", err)
+		}
+	}
+
 	return resp, nil
 }
 
+// handleWrappingHistoryLookup returns the history of the lookups and unwraps
+// of a wrapping token to its creator
+func (b *SystemBackend) handleWrappingHistoryLookup(ctx context.Context, req *logical.Request, accessor string) (*logical.Response, error) {
+	// The lookup path is unauthenticated, so the token of the caller is
+	// validated here
+	if req.ClientToken == "" {
+		return nil, logical.ErrPermissionDenied
+	}
+	te, err := b.Core.tokenStore.Lookup(ctx, req.ClientToken)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	history, err := b.Core.wrappingHistory(ctx, accessor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the creator of the wrapping token, either the same token or the
+	// same entity, can read its history. Unknown accessors are denied alike
+	// so that their existence is not disclosed.
+	if history == nil {
+		return nil, logical.ErrPermissionDenied
+	}
+	sameToken := history.CreatorAccessor != "" && te.Accessor == history.CreatorAccessor
+	sameEntity := history.CreatorEntityID != "" && te.EntityID == history.CreatorEntityID
+	if !sameToken && !sameEntity {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	return &logical.Response{
+		Data: history.toResponseData(),
+	}, nil
+}
+
 func (b *SystemBackend) handleWrappingRewrap(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	// If a third party is rewrapping (rather than the calling token being the
 	// wrapping token) we detect this so that we can revoke the original
@@ -3228,6 +3550,14 @@ func (b *SystemBackend) handleWrappingRewrap(ctx context.Context, req *logical.R
 		token = req.ClientToken
 	}
 
+	// Get the accessor of the wrapping token so that its history can be
+	// carried over. We use lookupTainted since the token might have already
+	// been used by handleRequest().
+	te, err := b.Core.tokenStore.lookupTainted(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
 	if thirdParty {
 		// Use the token to decrement the use count to avoid a second operation on the token.
 		_, err := b.Core.tokenStore.UseTokenByID(ctx, token)
@@ -3299,11 +3629,20 @@ func (b *SystemBackend) handleWrappingRewrap(ctx context.Context, req *logical.R
 		return nil, fmt.Errorf("no response found inside the cubbyhole")
 	}
 
+	var rewrappedAccessor string
+	if te != nil {
+		rewrappedAccessor = te.Accessor
+		if err := b.Core.recordWrappingEvent(ctx, req, wrappingEventRewrap, te.Accessor); err != nil {
+			b.Core.Logger().Error("failed to record wrapping token rewrap", "error", err)
+		}
+	}
+
 	// Return response in "response"; wrapping code will detect the rewrap and
 	// slot in instead of nesting
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"response": response,
+			"response":                response,
+			wrappingRewrapAccessorKey: rewrappedAccessor,
 		},
 		WrapInfo: &wrapping.ResponseWrapInfo{
 			TTL:          time.Duration(creationTTL),
@@ -4062,6 +4401,49 @@ or delete a policy.
 		"",
 	},
 
+	"password-policy-list": {
+		`List the password policies.`,
+		"",
+	},
+
+	"password-policy": {
+		`Read, Modify, or Delete a password policy.`,
+		`
+Password policies describe how the passwords generated by secrets engines are
+built. A policy is written in HCL with the length of the passwords and charset
+rules requiring a minimum number of characters from each charset:
+
+    length = 20
+
+    rule "charset" {
+      charset   = "abcdefghijklmnopqrstuvwxyz"
+      min_chars = 1
+    }
+
+    rule "charset" {
+      charset   = "0123456789"
+      min_chars = 1
+    }
+
+Passwords are made of characters from the union of the charsets of the rules.
+		`,
+	},
+
+	"password-policy-generate": {
+		`Generate a password from a password policy.`,
+		"",
+	},
+
+	"password-policy-name": {
+		`The name of the password policy.`,
+		"",
+	},
+
+	"password-policy-rules": {
+		`The rules of the password policy, in HCL.`,
+		"",
+	},
+
 	"audit-hash": {
 		"The hash of the given string via the given audit backend",
 		"",
@@ -4175,9 +4557,33 @@ it.`,
 		string, the returned response is the exact same as the contained wrapped response.`,
 	},
 
+	"mfa-validate": {
+		"Validates the MFA requirement of a login.",
+		`Validates the MFA requirement returned by a login matching a login
+		enforcement, returning the token of the login. The payload maps the IDs of
+		the MFA methods to their passcodes.
+
+		Logins waiting for their MFA requirement are held in memory by the node
+		that handled them for 5 minutes. They do not survive a restart or a
+		failover, in which case the login must be made again.`,
+	},
+
 	"wraplookup": {
 		"Looks up the properties of a response-wrapped token.",
-		`Returns the creation TTL and creation time of a response-wrapped token.`,
+		`Returns the creation TTL and creation time of a response-wrapped token.
+
+If "accessor" is set to the accessor of a response-wrapped token created by
+the caller, returns instead the lookups and unwraps of the token, including
+the accessor and entity of the tokens used, their remote address and time.
+The history is kept for the configured retention after the token is unwrapped
+or expires.`,
+	},
+
+	"config/wrapping": {
+		"Configures the history of the response-wrapped tokens.",
+		`Configures how long the history of the lookups and unwraps of a
+response-wrapped token is kept after it is unwrapped or expires, and an
+optional URL notified of each unwrap.`,
 	},
 
 	"rewrap": {
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -35,6 +36,7 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"replication/reindex",
 		"rotate",
 		"config/cors",
+		"config/wrapping",
 		"config/auditing/*",
 		"config/ui/headers/*",
 		"plugins/catalog/*",
@@ -1632,6 +1634,84 @@ func TestSystemBackend_policyCRUD(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_passwordPolicies(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+
+	// Invalid policies are rejected
+	req := logical.TestRequest(t, logical.UpdateOperation, "policies/password/digits")
+	req.Data["policy"] = `length = 0`
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	policy := `
+length = 8
+
+rule "charset" {
+  charset   = "0123456789"
+  min_chars = 1
+}
+`
+	req.Data["policy"] = policy
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "policies/password/digits")
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["policy"] != policy {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	req = logical.TestRequest(t, logical.ListOperation, "policies/password/")
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"digits"}) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Passwords are generated from the endpoint and the system view
+	req = logical.TestRequest(t, logical.ReadOperation, "policies/password/digits/generate")
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := strconv.Atoi(resp.Data["password"].(string)); err != nil || len(resp.Data["password"].(string)) != 8 {
+		t.Fatalf("bad password: %#v", resp.Data)
+	}
+
+	sysView := dynamicSystemView{core: c}
+	password, err := sysView.GeneratePasswordFromPolicy(context.Background(), "digits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strconv.Atoi(password); err != nil || len(password) != 8 {
+		t.Fatalf("bad password: %q", password)
+	}
+
+	req = logical.TestRequest(t, logical.DeleteOperation, "policies/password/digits")
+	if _, err := b.HandleRequest(context.Background(), req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := sysView.GeneratePasswordFromPolicy(context.Background(), "digits"); err == nil {
+		t.Fatal("expected error for a deleted policy")
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "policies/password/digits/generate")
+	if _, err := b.HandleRequest(context.Background(), req); err == nil {
+		t.Fatal("expected error for a deleted policy")
+	}
+}
+
 func TestSystemBackend_enableAudit(t *testing.T) {
 	c, b, _ := testCoreSystemBackend(t)
 	c.auditBackends["noop"] = func(ctx context.Context, config *audit.BackendConfig) (audit.Backend, error) {
@@ -0,0 +1,520 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/chrismalek/oktasdk-go/okta"
+	duoapi "github.com/duosecurity/duo_api_golang"
+	"github.com/duosecurity/duo_api_golang/authapi"
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/identitytpl"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// loginMFARequestTTL is how long logins wait for their MFA requirement
+	// to be validated
+	loginMFARequestTTL = 5 * time.Minute
+
+	// oktaPushPollInterval is the interval at which Okta push verifications
+	// are polled
+	oktaPushPollInterval = time.Second
+)
+
+// pendingLogin is a login waiting for its MFA requirement to be validated
+type pendingLogin struct {
+	path        string
+	resp        *logical.Response
+	entityID    string
+	constraints map[string]*logical.MFAConstraintAny
+}
+
+// loginMFARequirement returns the MFA requirement of the login response made
+// to the given path, if any login enforcement applies to it. The login is
+// kept until the requirement is validated. Pending logins are only held in
+// the memory of the node that handled the login: they are lost on restart or
+// failover, and the requirement must be validated on the same node.
+func (c *Core) loginMFARequirement(ctx context.Context, path string, resp *logical.Response, entity *identity.Entity) (*logical.MFARequirement, error) {
+	if c.identityStore == nil {
+		return nil, nil
+	}
+	i := c.identityStore
+
+	i.mfaLock.RLock()
+	defer i.mfaLock.RUnlock()
+
+	enforcements, err := i.listLoginEnforcements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(enforcements) == 0 {
+		return nil, nil
+	}
+
+	var mountAccessor, mountType string
+	if mEntry := c.router.MatchingMountEntry(path); mEntry != nil {
+		mountAccessor = mEntry.Accessor
+		mountType = mEntry.Type
+	}
+
+	var groupIDs []string
+	if entity != nil {
+		directGroups, inheritedGroups, err := i.groupsByEntityID(entity.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range append(directGroups, inheritedGroups...) {
+			groupIDs = append(groupIDs, group.ID)
+		}
+	}
+
+	constraints := make(map[string]*logical.MFAConstraintAny)
+	for _, enforcement := range enforcements {
+		applies := strutil.StrListContains(enforcement.AuthMethodAccessors, mountAccessor) ||
+			strutil.StrListContains(enforcement.AuthMethodTypes, mountType)
+		if entity != nil {
+			applies = applies ||
+				strutil.StrListContains(enforcement.IdentityEntityIDs, entity.ID) ||
+				strListsIntersect(enforcement.IdentityGroupIDs, groupIDs)
+		}
+		if !applies {
+			continue
+		}
+
+		// The MFA methods need the entity to look up the second factor
+		if entity == nil {
+			return nil, fmt.Errorf("login enforcement %q applies to the login but it has no entity", enforcement.Name)
+		}
+
+		constraint := &logical.MFAConstraintAny{}
+		for _, methodID := range enforcement.MFAMethodIDs {
+			method, err := i.getMFAMethod(ctx, methodID)
+			if err != nil {
+				return nil, err
+			}
+			if method == nil {
+				continue
+			}
+			constraint.Any = append(constraint.Any, &logical.MFAMethodID{
+				Type:         method.Type,
+				ID:           method.ID,
+				UsesPasscode: method.usesPasscode(),
+			})
+		}
+		if len(constraint.Any) == 0 {
+			return nil, fmt.Errorf("login enforcement %q has no MFA method", enforcement.Name)
+		}
+		constraints[enforcement.Name] = constraint
+	}
+	if len(constraints) == 0 {
+		return nil, nil
+	}
+
+	requestID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	c.loginMFARequestCache.Set(requestID, &pendingLogin{
+		path:        path,
+		resp:        resp,
+		entityID:    entity.ID,
+		constraints: constraints,
+	}, loginMFARequestTTL)
+
+	return &logical.MFARequirement{
+		MFARequestID:   requestID,
+		MFAConstraints: constraints,
+	}, nil
+}
+
+// strListsIntersect returns whether the lists have a common item
+func strListsIntersect(a, b []string) bool {
+	for _, item := range a {
+		if strutil.StrListContains(b, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLoginMFA validates the MFA requirement of the pending login with
+// the given request ID, creating its token. The payload holds the passcodes
+// of the MFA methods, with empty passcodes for the methods using push
+// notifications. The pending login is discarded whatever the outcome.
+func (c *Core) validateLoginMFA(ctx context.Context, requestID string, payload map[string][]string) (*logical.Response, error) {
+	raw, ok := c.loginMFARequestCache.Get(requestID)
+	if !ok {
+		return logical.ErrorResponse("invalid or expired MFA request ID"), logical.ErrInvalidRequest
+	}
+	c.loginMFARequestCache.Delete(requestID)
+	pending := raw.(*pendingLogin)
+
+	validated := make(map[string]bool)
+	for name, constraint := range pending.constraints {
+		satisfied := false
+		for _, methodID := range constraint.Any {
+			passcodes, ok := payload[methodID.ID]
+			if !ok {
+				continue
+			}
+			if !validated[methodID.ID] {
+				var passcode string
+				if len(passcodes) > 0 {
+					passcode = passcodes[0]
+				}
+				if err := c.validateMFAMethod(ctx, methodID.ID, pending.entityID, passcode); err != nil {
+					return logical.ErrorResponse(fmt.Sprintf("failed to validate MFA method %q: %s", methodID.ID, err)), logical.ErrPermissionDenied
+				}
+				validated[methodID.ID] = true
+			}
+			satisfied = true
+			break
+		}
+		if !satisfied {
+			return logical.ErrorResponse(fmt.Sprintf("no MFA method of the login enforcement %q was validated", name)), logical.ErrPermissionDenied
+		}
+	}
+
+	if errResp, err := c.createLoginToken(ctx, pending.path, pending.resp); err != nil {
+		return errResp, err
+	}
+	return pending.resp, nil
+}
+
+// validateMFAMethod validates the passcode or push notification of the MFA
+// method for the entity
+func (c *Core) validateMFAMethod(ctx context.Context, methodID, entityID, passcode string) error {
+	i := c.identityStore
+
+	i.mfaLock.RLock()
+	method, err := i.getMFAMethod(ctx, methodID)
+	i.mfaLock.RUnlock()
+	if err != nil {
+		return err
+	}
+	if method == nil {
+		return fmt.Errorf("MFA method not found")
+	}
+	if method.usesPasscode() && passcode == "" {
+		return fmt.Errorf("a passcode is required")
+	}
+
+	if method.Type == mfaMethodTypeTOTP {
+		return c.validateTOTP(ctx, method, entityID, passcode)
+	}
+
+	username, err := c.mfaUsername(method, entityID)
+	if err != nil {
+		return err
+	}
+	switch method.Type {
+	case mfaMethodTypeDuo:
+		return validateDuo(method.Duo, username, passcode)
+	case mfaMethodTypeOkta:
+		return validateOkta(ctx, method.Okta, username)
+	case mfaMethodTypePingID:
+		return validatePingID(method.PingID, username)
+	}
+	return fmt.Errorf("unsupported MFA method type %q", method.Type)
+}
+
+// mfaUsername returns the username of the entity with the MFA provider
+func (c *Core) mfaUsername(method *mfaMethod, entityID string) (string, error) {
+	entity, err := dynamicSystemView{core: c}.EntityInfo(entityID)
+	if err != nil {
+		return "", err
+	}
+	if entity == nil {
+		return "", fmt.Errorf("entity not found")
+	}
+
+	if method.UsernameFormat == "" {
+		return entity.Name, nil
+	}
+	_, username, err := identitytpl.PopulateString(entity, method.UsernameFormat)
+	if err != nil {
+		return "", fmt.Errorf("error populating username_format: %s", err)
+	}
+	return username, nil
+}
+
+func (c *Core) validateTOTP(ctx context.Context, method *mfaMethod, entityID, passcode string) error {
+	i := c.identityStore
+
+	i.mfaLock.RLock()
+	key, err := i.getTOTPSecret(ctx, method.ID, entityID)
+	i.mfaLock.RUnlock()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("the entity has no TOTP key for this method")
+	}
+
+	algorithm, err := totpAlgorithm(method.TOTP.Algorithm)
+	if err != nil {
+		return err
+	}
+	valid, err := totp.ValidateCustom(passcode, key.Secret(), time.Now(), totp.ValidateOpts{
+		Period:    method.TOTP.Period,
+		Skew:      method.TOTP.Skew,
+		Digits:    otp.Digits(method.TOTP.Digits),
+		Algorithm: algorithm,
+	})
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("invalid passcode")
+	}
+
+	// Passcodes can't be reused while they're valid. Add fails if the
+	// passcode is already in the cache, so concurrent validations of the
+	// same passcode can't both succeed.
+	usedKey := method.ID + "/" + entityID + "/" + passcode
+	validity := time.Duration(method.TOTP.Period*(2*method.TOTP.Skew+1)) * time.Second
+	if err := i.mfaUsedPasscodeCache.Add(usedKey, struct{}{}, validity); err != nil {
+		return fmt.Errorf("the passcode was already used")
+	}
+	return nil
+}
+
+func validateDuo(config *duoConfig, username, passcode string) error {
+	client := authapi.NewAuthApi(*duoapi.NewDuoApi(
+		config.IntegrationKey,
+		config.SecretKey,
+		config.APIHostname,
+		"vault",
+	))
+
+	preauth, err := client.Preauth(authapi.PreauthUsername(username))
+	if err != nil || preauth == nil {
+		return fmt.Errorf("could not call Duo preauth")
+	}
+	if preauth.StatResult.Stat != "OK" {
+		return duoStatError("could not look up Duo user information", preauth.StatResult)
+	}
+
+	switch preauth.Response.Result {
+	case "allow":
+		return nil
+	case "deny":
+		return errors.New(preauth.Response.Status_Msg)
+	case "enroll":
+		return fmt.Errorf("%s (%s)", preauth.Response.Status_Msg, preauth.Response.Enroll_Portal_Url)
+	case "auth":
+	default:
+		return fmt.Errorf("invalid Duo preauth response: %s", preauth.Response.Result)
+	}
+
+	options := []func(*url.Values){authapi.AuthUsername(username)}
+	factor := "push"
+	if passcode != "" {
+		factor = "passcode"
+		options = append(options, authapi.AuthPasscode(passcode))
+	} else {
+		options = append(options, authapi.AuthDevice("auto"))
+		if config.PushInfo != "" {
+			options = append(options, authapi.AuthPushinfo(config.PushInfo))
+		}
+	}
+
+	result, err := client.Auth(factor, options...)
+	if err != nil || result == nil {
+		return fmt.Errorf("could not call Duo auth")
+	}
+	if result.StatResult.Stat != "OK" {
+		return duoStatError("could not authenticate Duo user", result.StatResult)
+	}
+	if result.Response.Result != "allow" {
+		return errors.New(result.Response.Status_Msg)
+	}
+	return nil
+}
+
+func duoStatError(msg string, stat authapi.StatResult) error {
+	if stat.Message != nil {
+		msg = msg + ": " + *stat.Message
+	}
+	if stat.Message_Detail != nil {
+		msg = msg + " (" + *stat.Message_Detail + ")"
+	}
+	return errors.New(msg)
+}
+
+func validateOkta(ctx context.Context, config *oktaConfig, username string) error {
+	client, err := okta.NewClientWithDomain(cleanhttp.DefaultClient(), config.OrgName, config.BaseURL, config.APIToken)
+	if err != nil {
+		return err
+	}
+
+	filter := &okta.UserListFilterOptions{
+		Limit: 1,
+	}
+	if config.PrimaryEmail {
+		filter.EmailEqualTo = username
+	} else {
+		filter.LoginEqualTo = username
+	}
+	users, _, err := client.Users.ListWithFilter(filter)
+	if err != nil {
+		return fmt.Errorf("error looking up the Okta user: %s", err)
+	}
+	if len(users) == 0 {
+		return fmt.Errorf("no Okta user found")
+	}
+	user := &users[0]
+
+	if _, err := client.Users.PopulateEnrolledFactors(user); err != nil {
+		return fmt.Errorf("error looking up the Okta factors: %s", err)
+	}
+	var factorID string
+	for _, factor := range user.MFAFactors {
+		if factor.FactorType == "push" && factor.Provider == "OKTA" && factor.Status == "ACTIVE" {
+			factorID = factor.ID
+			break
+		}
+	}
+	if factorID == "" {
+		return fmt.Errorf("the Okta user has no Okta Verify push factor")
+	}
+
+	type verifyResult struct {
+		FactorResult string `json:"factorResult"`
+		Links        struct {
+			Poll struct {
+				Href string `json:"href"`
+			} `json:"poll"`
+		} `json:"_links"`
+	}
+
+	req, err := client.NewRequest("POST", fmt.Sprintf("users/%s/factors/%s/verify", user.ID, factorID), nil)
+	if err != nil {
+		return err
+	}
+	var result verifyResult
+	if _, err := client.Do(req, &result); err != nil {
+		return fmt.Errorf("error sending the Okta push notification: %s", err)
+	}
+
+	for {
+		switch result.FactorResult {
+		case "SUCCESS":
+			return nil
+		case "WAITING":
+		case "REJECTED":
+			return fmt.Errorf("the Okta push notification was rejected")
+		case "TIMEOUT":
+			return fmt.Errorf("the Okta push notification timed out")
+		default:
+			return fmt.Errorf("unexpected Okta verification result %q", result.FactorResult)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(oktaPushPollInterval):
+		}
+
+		req, err := client.NewRequest("GET", result.Links.Poll.Href, nil)
+		if err != nil {
+			return err
+		}
+		result = verifyResult{}
+		if _, err := client.Do(req, &result); err != nil {
+			return fmt.Errorf("error polling the Okta push notification: %s", err)
+		}
+	}
+}
+
+// validatePingID sends a PingID push notification to the user and waits for
+// its approval
+func validatePingID(config *pingIDConfig, username string) error {
+	key, err := base64.StdEncoding.DecodeString(config.UseBase64Key)
+	if err != nil {
+		return fmt.Errorf("error decoding the PingID key: %s", err)
+	}
+
+	payload := map[string]interface{}{
+		"reqHeader": map[string]interface{}{
+			"locale":    "en",
+			"orgAlias":  config.OrgAlias,
+			"secretKey": config.Token,
+			"timestamp": time.Now().UTC().Format("2006-01-02 15:04:05.000"),
+			"version":   "4.9",
+		},
+		"reqBody": map[string]interface{}{
+			"spAlias":  "web",
+			"userName": username,
+			"authType": "CONFIRM",
+			"clientData": []map[string]interface{}{
+				{
+					"msg": "Vault login",
+				},
+			},
+		},
+	}
+	request := jws.New(payload, crypto.SigningMethodHS256)
+	request.Protected().Set("org_alias", config.OrgAlias)
+	request.Protected().Set("token", config.Token)
+	signed, err := request.Compact(key)
+	if err != nil {
+		return err
+	}
+
+	idpURL := strings.TrimSuffix(config.IDPURL, "/")
+	httpResp, err := cleanhttp.DefaultClient().Post(idpURL+"/rest/4/authonline/do", "application/json", strings.NewReader(string(signed)))
+	if err != nil {
+		return fmt.Errorf("error calling PingID: %s", err)
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PingID returned status %d", httpResp.StatusCode)
+	}
+
+	response, err := jws.ParseCompact(body)
+	if err != nil {
+		return fmt.Errorf("error parsing the PingID response: %s", err)
+	}
+	if err := response.Verify(key, crypto.SigningMethodHS256); err != nil {
+		return fmt.Errorf("error verifying the PingID response: %s", err)
+	}
+
+	var result struct {
+		ResponseBody struct {
+			ErrorID  json.Number `json:"errorId"`
+			ErrorMsg string      `json:"errorMsg"`
+		} `json:"responseBody"`
+	}
+	rawPayload, err := json.Marshal(response.Payload())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(rawPayload, &result); err != nil {
+		return err
+	}
+	if result.ResponseBody.ErrorID.String() != "200" {
+		return fmt.Errorf("PingID authentication failed: %s", result.ResponseBody.ErrorMsg)
+	}
+	return nil
+}
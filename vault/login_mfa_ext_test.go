@@ -0,0 +1,342 @@
+package vault_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/builtin/logical/pki"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+func TestLoginMFA_TOTP(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0].Core
+	vault.TestWaitActive(t, core)
+	client := cluster.Cores[0].Client
+
+	err := client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{
+		Type: "userpass",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts, err := client.Sys().ListAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userpassAccessor := mounts["userpass/"].Accessor
+
+	_, err = client.Logical().Write("auth/userpass/users/jdoe", map[string]interface{}{
+		"password": "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func() *api.Secret {
+		t.Helper()
+		secret, err := client.Logical().Write("auth/userpass/login/jdoe", map[string]interface{}{
+			"password": "secret",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	// Logins aren't enforced yet
+	secret := login()
+	if secret.Auth.ClientToken == "" || secret.Auth.MFARequirement != nil {
+		t.Fatalf("bad: %#v", secret.Auth)
+	}
+	secret, err = client.Auth().Token().Lookup(secret.Auth.ClientToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entityID := secret.Data["entity_id"].(string)
+
+	secret, err = client.Logical().Write("identity/mfa/method/totp", map[string]interface{}{
+		"issuer": "Vault",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	methodID := secret.Data["method_id"].(string)
+
+	secret, err = client.Logical().Write("identity/mfa/method/totp/admin-generate", map[string]interface{}{
+		"method_id": methodID,
+		"entity_id": entityID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["barcode"] == "" {
+		t.Fatalf("bad: %#v", secret.Data)
+	}
+	key, err := otp.NewKeyFromURL(secret.Data["url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enforcements must reference existing methods
+	_, err = client.Logical().Write("identity/mfa/login-enforcement/invalid", map[string]interface{}{
+		"mfa_method_ids":        "unknown",
+		"auth_method_accessors": userpassAccessor,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	_, err = client.Logical().Write("identity/mfa/login-enforcement/userpass", map[string]interface{}{
+		"mfa_method_ids":        methodID,
+		"auth_method_accessors": userpassAccessor,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enforced methods can't be deleted
+	if _, err := client.Logical().Delete("identity/mfa/method/totp/" + methodID); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	requirement := func() *api.MFARequirement {
+		t.Helper()
+		secret := login()
+		if secret.Auth.ClientToken != "" || secret.Auth.MFARequirement == nil {
+			t.Fatalf("bad: %#v", secret.Auth)
+		}
+		constraint := secret.Auth.MFARequirement.MFAConstraints["userpass"]
+		if constraint == nil || len(constraint.Any) != 1 || constraint.Any[0].ID != methodID || !constraint.Any[0].UsesPasscode {
+			t.Fatalf("bad: %#v", secret.Auth.MFARequirement)
+		}
+		return secret.Auth.MFARequirement
+	}
+	validate := func(requestID, passcode string) (*api.Secret, error) {
+		return client.Sys().MFAValidate(requestID, map[string][]string{
+			methodID: {passcode},
+		})
+	}
+
+	code, err := totp.GenerateCodeCustom(key.Secret(), time.Now(), totp.ValidateOpts{
+		Period:    30,
+		Skew:      1,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongCode := "000000"
+	if code == wrongCode {
+		wrongCode = "111111"
+	}
+
+	// Failed validations discard the login
+	requestID := requirement().MFARequestID
+	if _, err := validate(requestID, wrongCode); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := validate(requestID, code); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// Concurrent validations can't both use the passcode
+	requestIDs := []string{requirement().MFARequestID, requirement().MFARequestID}
+	secrets := make([]*api.Secret, len(requestIDs))
+	errs := make([]error, len(requestIDs))
+	var wg sync.WaitGroup
+	for idx, requestID := range requestIDs {
+		wg.Add(1)
+		go func(idx int, requestID string) {
+			defer wg.Done()
+			secrets[idx], errs[idx] = validate(requestID, code)
+		}(idx, requestID)
+	}
+	wg.Wait()
+	if (errs[0] == nil) == (errs[1] == nil) {
+		t.Fatalf("expected exactly one validation to succeed, got: %v, %v", errs[0], errs[1])
+	}
+	secret = secrets[0]
+	if errs[0] != nil {
+		secret = secrets[1]
+	}
+	if secret.Auth.ClientToken == "" {
+		t.Fatalf("bad: %#v", secret.Auth)
+	}
+	secret, err = client.Auth().Token().Lookup(secret.Auth.ClientToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["entity_id"] != entityID || secret.Data["display_name"] != "userpass-jdoe" {
+		t.Fatalf("bad: %#v", secret.Data)
+	}
+
+	// Passcodes can't be replayed
+	if _, err := validate(requirement().MFARequestID, code); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestLoginMFA_EST(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+		LogicalBackends: map[string]logical.Factory{
+			"pki": pki.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0].Core
+	vault.TestWaitActive(t, core)
+	client := cluster.Cores[0].Client
+
+	err := client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{
+		Type: "userpass",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts, err := client.Sys().ListAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userpassAccessor := mounts["userpass/"].Accessor
+
+	_, err = client.Logical().Write("auth/userpass/users/device", map[string]interface{}{
+		"password": "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Sys().Mount("pki", &api.MountInput{
+		Type: "pki",
+		Config: api.MountConfigInput{
+			PassthroughRequestHeaders: []string{"Authorization"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range map[string]map[string]interface{}{
+		"pki/root/generate/internal": {
+			"common_name": "myvault.com",
+			"ttl":         "40h",
+		},
+		"pki/roles/devices": {
+			"allowed_domains":  "myvault.com",
+			"allow_subdomains": true,
+			"key_type":         "ec",
+			"key_bits":         256,
+			"ttl":              "1h",
+		},
+	} {
+		if _, err := client.Logical().Write(path, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = client.Logical().Write("pki/config/est", map[string]interface{}{
+		"enabled":        true,
+		"default_role":   "devices",
+		"authenticators": map[string]interface{}{"userpass": userpassAccessor},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "sensor1.myvault.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enroll := func() int {
+		t.Helper()
+		r := client.NewRequest("POST", "/v1/pki/est/simpleenroll")
+		r.ClientToken = ""
+		r.Headers = http.Header{
+			"Content-Type":  []string{"application/pkcs10"},
+			"Authorization": []string{"Basic " + base64.StdEncoding.EncodeToString([]byte("device:secret"))},
+		}
+		r.BodyBytes = []byte(base64.StdEncoding.EncodeToString(csr))
+		resp, err := client.RawRequest(r)
+		if resp == nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The user must be allowed to sign with the role
+	if status := enroll(); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", status)
+	}
+
+	if err := client.Sys().PutPolicy("est-devices", `path "pki/sign/devices" { capabilities = ["update"] }`); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Logical().Write("auth/userpass/users/device", map[string]interface{}{
+		"policies": "est-devices",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := enroll(); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	secret, err := client.Logical().Write("identity/mfa/method/totp", map[string]interface{}{
+		"issuer": "Vault",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Logical().Write("identity/mfa/login-enforcement/userpass", map[string]interface{}{
+		"mfa_method_ids":        secret.Data["method_id"],
+		"auth_method_accessors": userpassAccessor,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// EST clients can't provide a second factor, so enforced logins are
+	// refused
+	if status := enroll(); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", status)
+	}
+}
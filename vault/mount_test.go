@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"reflect"
@@ -38,6 +39,22 @@ func TestMount_ReadOnlyViewDuringMount(t *testing.T) {
 	}
 }
 
+// mountTablesEqual compares the mount tables as they are persisted. The
+// config caches of the entries are left out since their internal state
+// depends on how the entries were used.
+func mountTablesEqual(t *testing.T, a, b *MountTable) bool {
+	t.Helper()
+	aJSON, err := json.Marshal(a.sortEntriesByPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bJSON, err := json.Marshal(b.sortEntriesByPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Equal(aJSON, bJSON)
+}
+
 func TestCore_DefaultMountTable(t *testing.T) {
 	c, keys, _ := TestCoreUnsealed(t)
 	verifyDefaultTable(t, c.mounts)
@@ -62,7 +79,7 @@ func TestCore_DefaultMountTable(t *testing.T) {
 	}
 
 	// Verify matching mount tables
-	if !reflect.DeepEqual(c.mounts.sortEntriesByPath(), c2.mounts.sortEntriesByPath()) {
+	if !mountTablesEqual(t, c.mounts, c2.mounts) {
 		t.Fatalf("mismatch: %v %v", c.mounts, c2.mounts)
 	}
 }
@@ -103,7 +120,7 @@ func TestCore_Mount(t *testing.T) {
 	}
 
 	// Verify matching mount tables
-	if !reflect.DeepEqual(c.mounts.sortEntriesByPath(), c2.mounts.sortEntriesByPath()) {
+	if !mountTablesEqual(t, c.mounts, c2.mounts) {
 		t.Fatalf("mismatch: %v %v", c.mounts, c2.mounts)
 	}
 }
@@ -237,7 +254,7 @@ func TestCore_Unmount(t *testing.T) {
 	}
 
 	// Verify matching mount tables
-	if !reflect.DeepEqual(c.mounts.sortEntriesByPath(), c2.mounts.sortEntriesByPath()) {
+	if !mountTablesEqual(t, c.mounts, c2.mounts) {
 		t.Fatalf("mismatch: %v %v", c.mounts, c2.mounts)
 	}
 }
@@ -357,7 +374,7 @@ func TestCore_Remount(t *testing.T) {
 	}
 
 	// Verify matching mount tables
-	if !reflect.DeepEqual(c.mounts, c2.mounts) {
+	if !mountTablesEqual(t, c.mounts, c2.mounts) {
 		t.Fatalf("mismatch: %v %v", c.mounts, c2.mounts)
 	}
 }
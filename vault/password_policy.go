@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/random"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// passwordPolicySubPath is the sub-path of the system barrier view
+	// holding the password policies
+	passwordPolicySubPath = "password_policy/"
+)
+
+// passwordPolicyEntry is the stored form of a password policy
+type passwordPolicyEntry struct {
+	Policy string `json:"policy"`
+}
+
+func (c *Core) passwordPolicyView() *BarrierView {
+	return c.systemBarrierView.SubView(passwordPolicySubPath)
+}
+
+// passwordPolicyEntry returns the stored password policy with the given
+// name, or nil if it doesn't exist
+func (c *Core) passwordPolicyEntry(ctx context.Context, name string) (*passwordPolicyEntry, error) {
+	entry, err := c.passwordPolicyView().Get(ctx, name)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read password policy: {{err}}", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var policy passwordPolicyEntry
+	if err := json.Unmarshal(entry.Value, &policy); err != nil {
+		return nil, errwrap.Wrapf("failed to decode password policy: {{err}}", err)
+	}
+	return &policy, nil
+}
+
+// passwordPolicy returns the generator of the password policy with the given
+// name, or nil if it doesn't exist
+func (c *Core) passwordPolicy(ctx context.Context, name string) (*random.StringGenerator, error) {
+	policy, err := c.passwordPolicyEntry(ctx, name)
+	if err != nil || policy == nil {
+		return nil, err
+	}
+
+	return random.ParsePolicy(policy.Policy)
+}
+
+func (c *Core) setPasswordPolicy(ctx context.Context, name string, policy *passwordPolicyEntry) error {
+	entry, err := logical.StorageEntryJSON(name, policy)
+	if err != nil {
+		return err
+	}
+	if err := c.passwordPolicyView().Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("failed to persist password policy: {{err}}", err)
+	}
+	return nil
+}
@@ -136,7 +136,7 @@ func (c *Core) reloadBackendCommon(ctx context.Context, entry *MountEntry, isAut
 	paths := backend.SpecialPaths()
 	if paths != nil {
 		re.rootPaths.Store(pathsToRadix(paths.Root))
-		re.loginPaths.Store(pathsToRadix(paths.Unauthenticated))
+		re.loginPaths.Store(parseLoginPaths(paths.Unauthenticated))
 	}
 
 	return nil
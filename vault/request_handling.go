@@ -221,8 +221,9 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 		auth.Metadata = te.Meta
 		auth.DisplayName = te.DisplayName
 		auth.EntityID = te.EntityID
-		// Store the entity ID in the request object
+		// Store the entity ID and the token metadata in the request object
 		req.EntityID = te.EntityID
+		req.ClientTokenMetadata = te.Meta
 	}
 
 	// Check the standard non-root ACLs. Return the token entry if it's not
@@ -264,7 +265,8 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 	// backends. Basically, it's all just terrible, so don't allow it.
 	if strings.HasSuffix(req.Path, "/") &&
 		(req.Operation == logical.UpdateOperation ||
-			req.Operation == logical.CreateOperation) {
+			req.Operation == logical.CreateOperation ||
+			req.Operation == logical.PatchOperation) {
 		return logical.ErrorResponse("cannot write to a path ending in '/'"), nil
 	}
 
@@ -709,6 +711,14 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 		return nil, nil, ErrInternalError
 	}
 
+	// Logins completed by validating their MFA requirement already have a
+	// token
+	if resp != nil && resp.Auth != nil && req.Path == "sys/mfa/validate" {
+		auth = resp.Auth
+		req.DisplayName = auth.DisplayName
+		return resp, auth, routeErr
+	}
+
 	// If the response generated an authentication, then generate the token
 	if resp != nil && resp.Auth != nil {
 
@@ -756,75 +766,106 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 			}
 		}
 
-		if strutil.StrListSubset(auth.Policies, []string{"root"}) {
-			return logical.ErrorResponse("auth methods cannot create root tokens"), nil, logical.ErrInvalidRequest
-		}
-
-		// Determine the source of the login
-		source := c.router.MatchingMount(req.Path)
-		source = strings.TrimPrefix(source, credentialRoutePrefix)
-		source = strings.Replace(source, "/", "-", -1)
-
-		// Prepend the source to the display name
-		auth.DisplayName = strings.TrimSuffix(source+auth.DisplayName, "-")
-
-		sysView := c.router.MatchingSystemView(req.Path)
-		if sysView == nil {
-			c.logger.Error("unable to look up sys view for login path", "request_path", req.Path)
-			return nil, nil, ErrInternalError
-		}
-
-		tokenTTL, warnings, err := framework.CalculateTTL(sysView, 0, auth.TTL, auth.Period, auth.MaxTTL, auth.ExplicitMaxTTL, time.Time{})
+		// Logins matching a login enforcement return an MFA requirement
+		// instead of a token
+		mfaRequirement, err := c.loginMFARequirement(ctx, req.Path, resp, entity)
 		if err != nil {
 			return nil, nil, err
 		}
-		for _, warning := range warnings {
-			resp.AddWarning(warning)
+		if mfaRequirement != nil {
+			return &logical.Response{
+				Auth: &logical.Auth{
+					MFARequirement: mfaRequirement,
+				},
+				Warnings: resp.Warnings,
+			}, nil, routeErr
 		}
 
-		// Generate a token
-		te := TokenEntry{
-			Path:         req.Path,
-			Policies:     auth.Policies,
-			Meta:         auth.Metadata,
-			DisplayName:  auth.DisplayName,
-			CreationTime: time.Now().Unix(),
-			TTL:          tokenTTL,
-			NumUses:      auth.NumUses,
-			EntityID:     auth.EntityID,
-			BoundCIDRs:   auth.BoundCIDRs,
+		if errResp, err := c.createLoginToken(ctx, req.Path, resp); err != nil {
+			if errResp != nil {
+				return errResp, nil, err
+			}
+			return nil, auth, err
 		}
 
-		te.Policies = policyutil.SanitizePolicies(te.Policies, true)
+		// Attach the display name, might be used by audit backends
+		req.DisplayName = auth.DisplayName
+	}
 
-		// Prevent internal policies from being assigned to tokens
-		for _, policy := range te.Policies {
-			if strutil.StrListContains(nonAssignablePolicies, policy) {
-				return logical.ErrorResponse(fmt.Sprintf("cannot assign policy %q", policy)), nil, logical.ErrInvalidRequest
-			}
-		}
+	return resp, auth, routeErr
+}
 
-		if err := c.tokenStore.create(ctx, &te); err != nil {
-			c.logger.Error("failed to create token", "error", err)
-			return nil, auth, ErrInternalError
-		}
+// createLoginToken creates the token of the auth of a login response made to
+// the given path, populating the auth with the token
+func (c *Core) createLoginToken(ctx context.Context, path string, resp *logical.Response) (*logical.Response, error) {
+	auth := resp.Auth
 
-		// Populate the client token, accessor, and TTL
-		auth.ClientToken = te.ID
-		auth.Accessor = te.Accessor
-		auth.Policies = te.Policies
-		auth.TTL = te.TTL
+	if strutil.StrListSubset(auth.Policies, []string{"root"}) {
+		return logical.ErrorResponse("auth methods cannot create root tokens"), logical.ErrInvalidRequest
+	}
 
-		// Register with the expiration manager
-		if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
-			c.tokenStore.revokeOrphan(ctx, te.ID)
-			c.logger.Error("failed to register token lease", "request_path", req.Path, "error", err)
-			return nil, auth, ErrInternalError
+	// Determine the source of the login
+	source := c.router.MatchingMount(path)
+	source = strings.TrimPrefix(source, credentialRoutePrefix)
+	source = strings.Replace(source, "/", "-", -1)
+
+	// Prepend the source to the display name
+	auth.DisplayName = strings.TrimSuffix(source+auth.DisplayName, "-")
+
+	sysView := c.router.MatchingSystemView(path)
+	if sysView == nil {
+		c.logger.Error("unable to look up sys view for login path", "request_path", path)
+		return nil, ErrInternalError
+	}
+
+	tokenTTL, warnings, err := framework.CalculateTTL(sysView, 0, auth.TTL, auth.Period, auth.MaxTTL, auth.ExplicitMaxTTL, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	// Generate a token
+	te := TokenEntry{
+		Path:           path,
+		Policies:       auth.Policies,
+		Meta:           auth.Metadata,
+		DisplayName:    auth.DisplayName,
+		CreationTime:   time.Now().Unix(),
+		TTL:            tokenTTL,
+		NumUses:        auth.NumUses,
+		EntityID:       auth.EntityID,
+		BoundCIDRs:     auth.BoundCIDRs,
+		ExplicitMaxTTL: auth.ExplicitMaxTTL,
+	}
+
+	te.Policies = policyutil.SanitizePolicies(te.Policies, !auth.NoDefaultPolicy)
+
+	// Prevent internal policies from being assigned to tokens
+	for _, policy := range te.Policies {
+		if strutil.StrListContains(nonAssignablePolicies, policy) {
+			return logical.ErrorResponse(fmt.Sprintf("cannot assign policy %q", policy)), logical.ErrInvalidRequest
 		}
+	}
 
-		// Attach the display name, might be used by audit backends
-		req.DisplayName = auth.DisplayName
+	if err := c.tokenStore.create(ctx, &te); err != nil {
+		c.logger.Error("failed to create token", "error", err)
+		return nil, ErrInternalError
 	}
 
-	return resp, auth, routeErr
+	// Populate the client token, accessor, and TTL
+	auth.ClientToken = te.ID
+	auth.Accessor = te.Accessor
+	auth.Policies = te.Policies
+	auth.TTL = te.TTL
+
+	// Register with the expiration manager
+	if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
+		c.tokenStore.revokeOrphan(ctx, te.ID)
+		c.logger.Error("failed to register token lease", "request_path", path, "error", err)
+		return nil, ErrInternalError
+	}
+
+	return nil, nil
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/armon/go-radix"
 	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -116,7 +117,7 @@ func (r *Router) Mount(backend logical.Backend, prefix string, mountEntry *Mount
 		storageView:   storageView,
 	}
 	re.rootPaths.Store(pathsToRadix(paths.Root))
-	re.loginPaths.Store(pathsToRadix(paths.Unauthenticated))
+	re.loginPaths.Store(parseLoginPaths(paths.Unauthenticated))
 
 	switch {
 	case prefix == "":
@@ -465,6 +466,13 @@ func (r *Router) routeCommon(ctx context.Context, req *logical.Request, existenc
 	originalClientTokenRemainingUses := req.ClientTokenRemainingUses
 	req.ClientTokenRemainingUses = 0
 
+	// The token metadata is only given to the AppRole backend, which salts the
+	// client token like every other backend
+	clientTokenMetadata := req.ClientTokenMetadata
+	if re.mountEntry.Type != "approle" {
+		req.ClientTokenMetadata = nil
+	}
+
 	// Cache the headers
 	headers := req.Headers
 
@@ -473,6 +481,9 @@ func (r *Router) routeCommon(ctx context.Context, req *logical.Request, existenc
 	if rawVal, ok := re.mountEntry.synthesizedConfigCache.Load("passthrough_request_headers"); ok {
 		passthroughRequestHeaders = rawVal.([]string)
 	}
+	if re.mountEntry.Type == "identity" && oidcProviderClientAuthPathRe.MatchString(req.Path) {
+		passthroughRequestHeaders = append([]string{"Authorization"}, passthroughRequestHeaders...)
+	}
 	req.Headers = filteredPassthroughHeaders(headers, passthroughRequestHeaders)
 
 	// Cache the wrap info of the request
@@ -495,6 +506,7 @@ func (r *Router) routeCommon(ctx context.Context, req *logical.Request, existenc
 		req.Storage = nil
 		req.ClientToken = clientToken
 		req.ClientTokenRemainingUses = originalClientTokenRemainingUses
+		req.ClientTokenMetadata = clientTokenMetadata
 		req.WrapInfo = wrapInfo
 		req.Headers = headers
 		// This is only set in one place, after routing, so should never be set
@@ -583,20 +595,95 @@ func (r *Router) LoginPath(path string) bool {
 	remain := strings.TrimPrefix(path, mount)
 
 	// Check the loginPaths of this backend
-	loginPaths := re.loginPaths.Load().(*radix.Tree)
-	match, raw, ok := loginPaths.LongestPrefix(remain)
-	if !ok {
+	loginPaths := re.loginPaths.Load().(*loginPathsEntry)
+	match, raw, ok := loginPaths.paths.LongestPrefix(remain)
+	if ok {
+		prefixMatch := raw.(bool)
+
+		// Handle the prefix match case
+		if prefixMatch && strings.HasPrefix(remain, match) {
+			return true
+		}
+
+		// Handle the exact match case
+		if match == remain {
+			return true
+		}
+	}
+
+	// Check the paths with wildcard segments
+	segments := strings.Split(remain, "/")
+	for _, w := range loginPaths.wildcardPaths {
+		if w.matches(segments) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loginPathsEntry holds the unauthenticated paths of a backend. Paths
+// containing "+" segments can't be stored in the radix tree and are matched
+// segment by segment.
+type loginPathsEntry struct {
+	paths         *radix.Tree
+	wildcardPaths []wildcardPath
+}
+
+// wildcardPath is a path in which "+" segments match any single segment. A
+// trailing "*" makes the last segment a prefix match.
+type wildcardPath struct {
+	segments    []string
+	prefixMatch bool
+}
+
+func (w wildcardPath) matches(segments []string) bool {
+	if len(segments) < len(w.segments) || (!w.prefixMatch && len(segments) != len(w.segments)) {
 		return false
 	}
-	prefixMatch := raw.(bool)
 
-	// Handle the prefix match case
-	if prefixMatch {
-		return strings.HasPrefix(remain, match)
+	last := len(w.segments) - 1
+	for i, segment := range w.segments {
+		switch {
+		case segment == "+":
+			if segments[i] == "" {
+				return false
+			}
+		case i == last && w.prefixMatch:
+			// The last segment of a prefix match also matches the segments
+			// that follow it
+			return strings.HasPrefix(strings.Join(segments[i:], "/"), segment)
+		case segment != segments[i]:
+			return false
+		}
 	}
+	return true
+}
 
-	// Handle the exact match case
-	return match == remain
+// parseLoginPaths converts the unauthenticated paths of a backend to a
+// loginPathsEntry
+func parseLoginPaths(paths []string) *loginPathsEntry {
+	var plainPaths []string
+	var wildcardPaths []wildcardPath
+	for _, path := range paths {
+		if !strutil.StrListContains(strings.Split(path, "/"), "+") {
+			plainPaths = append(plainPaths, path)
+			continue
+		}
+
+		w := wildcardPath{}
+		if strings.HasSuffix(path, "*") {
+			w.prefixMatch = true
+			path = strings.TrimSuffix(path, "*")
+		}
+		w.segments = strings.Split(path, "/")
+		wildcardPaths = append(wildcardPaths, w)
+	}
+
+	return &loginPathsEntry{
+		paths:         pathsToRadix(plainPaths),
+		wildcardPaths: wildcardPaths,
+	}
 }
 
 // pathsToRadix converts a the mapping of special paths to a mapping
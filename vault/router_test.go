@@ -428,6 +428,9 @@ func TestRouter_LoginPath(t *testing.T) {
 		Login: []string{
 			"login",
 			"oauth/*",
+			"glob1/+",
+			"glob2/+/login",
+			"glob3/+/.well-known/*",
 		},
 	}
 	err = r.Mount(n, "auth/foo/", &MountEntry{UUID: meUUID, Accessor: "authfooaccessor"}, view)
@@ -445,6 +448,16 @@ func TestRouter_LoginPath(t *testing.T) {
 		{"auth/foo/login", true},
 		{"auth/foo/oauth", false},
 		{"auth/foo/oauth/redirect", true},
+		{"auth/foo/glob1", false},
+		{"auth/foo/glob1/", false},
+		{"auth/foo/glob1/bar", true},
+		{"auth/foo/glob1/bar/baz", false},
+		{"auth/foo/glob2/bar/login", true},
+		{"auth/foo/glob2/bar/logout", false},
+		{"auth/foo/glob2//login", false},
+		{"auth/foo/glob3/bar/.well-known/keys", true},
+		{"auth/foo/glob3/bar/.well-known", false},
+		{"auth/foo/glob3/bar/config", false},
 	}
 
 	for _, tc := range tcases {
@@ -533,6 +546,115 @@ func TestRouter_Untaint(t *testing.T) {
 	}
 }
 
+func TestRouter_IdentityAuthorizationHeader(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	meUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mountEntry := &MountEntry{
+		Path:     "identity/",
+		Type:     "identity",
+		UUID:     meUUID,
+		Accessor: "identityaccessor",
+		Config: MountConfig{
+			PassthroughRequestHeaders: []string{"X-Custom"},
+		},
+	}
+	mountEntry.SyncCache()
+
+	n := &NoopBackend{}
+	if err := r.Mount(n, "identity/", mountEntry, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	route := func(path string) map[string][]string {
+		t.Helper()
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+			Headers: map[string][]string{
+				"Authorization": {"Bearer token"},
+				"X-Custom":      {"value"},
+			},
+		}
+		if _, err := r.Route(context.Background(), req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return n.Requests[len(n.Requests)-1].Headers
+	}
+
+	// The header is only passed to the endpoints of the OIDC providers that
+	// authenticate their clients, along with the configured headers
+	for _, path := range []string{"identity/oidc/provider/sso/token", "identity/oidc/provider/sso/userinfo"} {
+		headers := route(path)
+		if len(headers["Authorization"]) != 1 || len(headers["X-Custom"]) != 1 {
+			t.Fatalf("bad headers for %s: %#v", path, headers)
+		}
+	}
+	for _, path := range []string{"identity/entity/id", "identity/oidc/provider/sso/authorize"} {
+		headers := route(path)
+		if _, ok := headers["Authorization"]; ok || len(headers["X-Custom"]) != 1 {
+			t.Fatalf("bad headers for %s: %#v", path, headers)
+		}
+	}
+}
+
+func TestRouter_ClientTokenMetadata(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+
+	backends := make(map[string]*NoopBackend)
+	for _, mountType := range []string{"approle", "userpass"} {
+		meUUID, err := uuid.GenerateUUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mountEntry := &MountEntry{
+			Path:     mountType + "/",
+			Type:     mountType,
+			UUID:     meUUID,
+			Accessor: mountType + "accessor",
+		}
+		view := NewBarrierView(barrier, credentialBarrierPrefix+meUUID+"/")
+
+		n := &NoopBackend{}
+		if err := r.Mount(n, "auth/"+mountType+"/", mountEntry, view); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		backends[mountType] = n
+	}
+
+	meta := map[string]string{"foo": "bar"}
+	for mountType, n := range backends {
+		req := &logical.Request{
+			Operation:           logical.UpdateOperation,
+			Path:                "auth/" + mountType + "/role/test",
+			ClientTokenMetadata: meta,
+		}
+		if _, err := r.Route(context.Background(), req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// The metadata is restored once the request has been routed
+		if !reflect.DeepEqual(req.ClientTokenMetadata, meta) {
+			t.Fatalf("bad: %#v", req.ClientTokenMetadata)
+		}
+
+		// Only the AppRole backend gets the metadata
+		got := n.Requests[0].ClientTokenMetadata
+		if mountType == "approle" && !reflect.DeepEqual(got, meta) {
+			t.Fatalf("bad metadata for %s: %#v", mountType, got)
+		}
+		if mountType != "approle" && got != nil {
+			t.Fatalf("bad metadata for %s: %#v", mountType, got)
+		}
+	}
+}
+
 func TestPathsToRadix(t *testing.T) {
 	// Provide real paths
 	paths := []string{
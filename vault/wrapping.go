@@ -266,6 +266,17 @@ DONELISTHANDLING:
 		return nil, ErrInternalError
 	}
 
+	// Start the history of the wrapping token so that its creator can learn
+	// who looked it up and unwrapped it. The wrapped response is usable
+	// without it, so failures are only logged.
+	var rewrappedAccessor string
+	if req.Path == "sys/wrapping/rewrap" {
+		rewrappedAccessor, _ = resp.Data[wrappingRewrapAccessorKey].(string)
+	}
+	if err := c.recordWrappingCreation(ctx, req, te.Accessor, resp.WrapInfo.CreationPath, te.TTL, rewrappedAccessor); err != nil {
+		c.logger.Error("failed to record wrapping token history", "error", err)
+	}
+
 	return nil, nil
 }
 
@@ -0,0 +1,397 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// wrappingConfigPath is the location of the wrapping configuration in
+	// the config view of the system barrier
+	wrappingConfigPath = "wrapping"
+
+	// wrappingHistorySubPath is the prefix of the wrapping token histories in
+	// the system barrier view. Histories are keyed by the salted accessor of
+	// their wrapping token.
+	wrappingHistorySubPath = "wrapping/history/"
+
+	// defaultWrappingHistoryRetention is how long the history of a wrapping
+	// token is kept after it is unwrapped or expires
+	defaultWrappingHistoryRetention = 24 * time.Hour
+
+	// wrappingHistoryTidyInterval is the minimum interval between two tidies
+	// of the expired wrapping token histories
+	wrappingHistoryTidyInterval = time.Hour
+
+	// unwrapCallbackTimeout is the timeout of the requests to the unwrap
+	// callback
+	unwrapCallbackTimeout = 10 * time.Second
+
+	// wrappingRewrapAccessorKey is the key of the response data of a rewrap
+	// holding the accessor of the rewrapped token, so that its history can
+	// be carried over to the new wrapping token. It is never stored or
+	// returned since the response is wrapped.
+	wrappingRewrapAccessorKey = "rewrapped_accessor"
+
+	wrappingEventLookup = "lookup"
+	wrappingEventUnwrap = "unwrap"
+	wrappingEventRewrap = "rewrap"
+)
+
+// wrappingConfig is the configuration of the response wrapping history
+type wrappingConfig struct {
+	// HistoryRetention is how long the history of a wrapping token is kept
+	// after it is unwrapped or expires
+	HistoryRetention time.Duration `json:"history_retention"`
+
+	// UnwrapCallbackURL, if set, is sent a POST request with the event of
+	// each unwrap
+	UnwrapCallbackURL string `json:"unwrap_callback_url"`
+}
+
+// wrappingHistory records the creator of a wrapping token along with the
+// lookups and unwraps performed with it
+type wrappingHistory struct {
+	Accessor        string           `json:"accessor"`
+	CreationPath    string           `json:"creation_path"`
+	CreatorAccessor string           `json:"creator_accessor"`
+	CreatorEntityID string           `json:"creator_entity_id"`
+	ExpireTime      time.Time        `json:"expire_time"`
+	Events          []*wrappingEvent `json:"events"`
+}
+
+// wrappingEvent is a lookup, an unwrap or a rewrap of a wrapping token
+type wrappingEvent struct {
+	Type       string    `json:"type"`
+	Accessor   string    `json:"accessor,omitempty"`
+	EntityID   string    `json:"entity_id,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+func (e *wrappingEvent) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        e.Type,
+		"accessor":    e.Accessor,
+		"entity_id":   e.EntityID,
+		"remote_addr": e.RemoteAddr,
+		"time":        e.Time.Format(time.RFC3339Nano),
+	}
+}
+
+func (h *wrappingHistory) toResponseData() map[string]interface{} {
+	events := make([]map[string]interface{}, 0, len(h.Events))
+	for _, e := range h.Events {
+		events = append(events, e.toResponseData())
+	}
+
+	return map[string]interface{}{
+		"accessor":      h.Accessor,
+		"creation_path": h.CreationPath,
+		"expire_time":   h.ExpireTime.Format(time.RFC3339Nano),
+		"events":        events,
+	}
+}
+
+// wrappingConfig returns a copy of the wrapping configuration
+func (c *Core) wrappingConfig() *wrappingConfig {
+	c.wrappingHistoryConfigLock.RLock()
+	defer c.wrappingHistoryConfigLock.RUnlock()
+
+	if c.wrappingHistoryConfig == nil {
+		return &wrappingConfig{
+			HistoryRetention: defaultWrappingHistoryRetention,
+		}
+	}
+
+	config := *c.wrappingHistoryConfig
+	return &config
+}
+
+// loadWrappingConfig loads the wrapping configuration, or the default one if
+// none has been stored
+func (c *Core) loadWrappingConfig(ctx context.Context) error {
+	view := c.systemBarrierView.SubView("config/")
+
+	entry, err := view.Get(ctx, wrappingConfigPath)
+	if err != nil {
+		return errwrap.Wrapf("failed to read wrapping config: {{err}}", err)
+	}
+
+	config := &wrappingConfig{
+		HistoryRetention: defaultWrappingHistoryRetention,
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(config); err != nil {
+			return err
+		}
+	}
+
+	c.wrappingHistoryConfigLock.Lock()
+	c.wrappingHistoryConfig = config
+	c.wrappingHistoryConfigLock.Unlock()
+
+	return nil
+}
+
+func (c *Core) saveWrappingConfig(ctx context.Context, config *wrappingConfig) error {
+	view := c.systemBarrierView.SubView("config/")
+
+	entry, err := logical.StorageEntryJSON(wrappingConfigPath, config)
+	if err != nil {
+		return errwrap.Wrapf("failed to create wrapping config entry: {{err}}", err)
+	}
+
+	c.wrappingHistoryConfigLock.Lock()
+	defer c.wrappingHistoryConfigLock.Unlock()
+
+	if err := view.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("failed to save wrapping config: {{err}}", err)
+	}
+	c.wrappingHistoryConfig = config
+
+	return nil
+}
+
+// wrappingHistory returns the history of the wrapping token with the given
+// accessor, or nil if there is none or it has expired
+func (c *Core) wrappingHistory(ctx context.Context, accessor string) (*wrappingHistory, error) {
+	saltedAccessor, err := c.tokenStore.SaltID(ctx, accessor)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.wrappingHistoryBySaltedAccessor(ctx, saltedAccessor)
+}
+
+func (c *Core) wrappingHistoryBySaltedAccessor(ctx context.Context, saltedAccessor string) (*wrappingHistory, error) {
+	entry, err := c.systemBarrierView.Get(ctx, wrappingHistorySubPath+saltedAccessor)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read wrapping history: {{err}}", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var history wrappingHistory
+	if err := entry.DecodeJSON(&history); err != nil {
+		return nil, err
+	}
+	if time.Now().After(history.ExpireTime) {
+		return nil, nil
+	}
+
+	return &history, nil
+}
+
+func (c *Core) storeWrappingHistory(ctx context.Context, saltedAccessor string, history *wrappingHistory) error {
+	entry, err := logical.StorageEntryJSON(wrappingHistorySubPath+saltedAccessor, history)
+	if err != nil {
+		return errwrap.Wrapf("failed to create wrapping history entry: {{err}}", err)
+	}
+
+	if err := c.systemBarrierView.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("failed to save wrapping history: {{err}}", err)
+	}
+
+	return nil
+}
+
+// recordWrappingCreation starts the history of a newly created wrapping
+// token. If the token is the result of a rewrap, the history of the
+// rewrapped token is carried over instead, keeping its creator and events.
+func (c *Core) recordWrappingCreation(ctx context.Context, req *logical.Request, accessor, creationPath string, ttl time.Duration, rewrappedAccessor string) error {
+	history := &wrappingHistory{
+		Accessor:     accessor,
+		CreationPath: creationPath,
+		ExpireTime:   time.Now().Add(ttl + c.wrappingConfig().HistoryRetention),
+		Events:       []*wrappingEvent{},
+	}
+
+	if rewrappedAccessor != "" {
+		rewrapped, err := c.wrappingHistory(ctx, rewrappedAccessor)
+		if err != nil {
+			return err
+		}
+		if rewrapped == nil {
+			// The rewrapped token was created before histories were
+			// recorded, so its creator is unknown
+			return nil
+		}
+		history.CreatorAccessor = rewrapped.CreatorAccessor
+		history.CreatorEntityID = rewrapped.CreatorEntityID
+		history.Events = rewrapped.Events
+	} else {
+		history.CreatorAccessor, history.CreatorEntityID = c.wrappingEventCaller(ctx, req)
+	}
+
+	saltedAccessor, err := c.tokenStore.SaltID(ctx, accessor)
+	if err != nil {
+		return err
+	}
+
+	// No other request can know the accessor yet, so no lock is needed
+	return c.storeWrappingHistory(ctx, saltedAccessor, history)
+}
+
+// recordWrappingEvent adds a lookup, an unwrap or a rewrap performed by the
+// request to the history of the wrapping token with the given accessor.
+// Unwraps and rewraps consume the wrapping token, so they start the retention
+// period of its history. Unwraps are also sent to the unwrap callback, if
+// any.
+func (c *Core) recordWrappingEvent(ctx context.Context, req *logical.Request, eventType, accessor string) error {
+	config := c.wrappingConfig()
+
+	event := &wrappingEvent{
+		Type: eventType,
+		Time: time.Now(),
+	}
+	event.Accessor, event.EntityID = c.wrappingEventCaller(ctx, req)
+	if req.Connection != nil {
+		event.RemoteAddr = req.Connection.RemoteAddr
+	}
+
+	saltedAccessor, err := c.tokenStore.SaltID(ctx, accessor)
+	if err != nil {
+		return err
+	}
+
+	lock := locksutil.LockForKey(c.wrappingHistoryLocks, saltedAccessor)
+	lock.Lock()
+	defer lock.Unlock()
+
+	history, err := c.wrappingHistoryBySaltedAccessor(ctx, saltedAccessor)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		// The wrapping token was created before histories were recorded
+		return nil
+	}
+
+	history.Events = append(history.Events, event)
+	if eventType != wrappingEventLookup {
+		history.ExpireTime = event.Time.Add(config.HistoryRetention)
+	}
+	if err := c.storeWrappingHistory(ctx, saltedAccessor, history); err != nil {
+		return err
+	}
+
+	if eventType == wrappingEventUnwrap && config.UnwrapCallbackURL != "" {
+		go c.notifyUnwrapCallback(config.UnwrapCallbackURL, history, event)
+	}
+
+	return nil
+}
+
+// wrappingEventCaller returns the accessor and the entity of the token used
+// by the request
+func (c *Core) wrappingEventCaller(ctx context.Context, req *logical.Request) (string, string) {
+	accessor, entityID := req.ClientTokenAccessor, req.EntityID
+	if req.ClientToken == "" || (accessor != "" && entityID != "") {
+		return accessor, entityID
+	}
+
+	// The token may have already been used by the request, such as a
+	// wrapping token being unwrapped
+	te, err := c.tokenStore.lookupTainted(ctx, req.ClientToken)
+	if err != nil || te == nil {
+		return accessor, entityID
+	}
+
+	return te.Accessor, te.EntityID
+}
+
+// notifyUnwrapCallback sends the unwrap event to the callback URL. Failures
+// are only logged since the unwrap has already happened.
+func (c *Core) notifyUnwrapCallback(callbackURL string, history *wrappingHistory, event *wrappingEvent) {
+	payload := event.toResponseData()
+	payload["wrapping_accessor"] = history.Accessor
+	payload["creation_path"] = history.CreationPath
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		c.logger.Error("failed to encode unwrap callback payload", "error", err)
+		return
+	}
+
+	client := cleanhttp.DefaultClient()
+	client.Timeout = unwrapCallbackTimeout
+
+	resp, err := client.Post(callbackURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		c.logger.Error("failed to send unwrap callback", "error", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		c.logger.Error("unwrap callback returned an error", "status_code", resp.StatusCode)
+	}
+}
+
+// tidyWrappingHistory removes the expired wrapping token histories. It runs
+// at most once per tidy interval. Only the history being removed is locked,
+// so tidying doesn't hold up the recording of events.
+func (c *Core) tidyWrappingHistory(ctx context.Context) error {
+	now := time.Now()
+
+	c.wrappingHistoryTidyLock.Lock()
+	if now.Sub(c.wrappingHistoryLastTidy) < wrappingHistoryTidyInterval {
+		c.wrappingHistoryTidyLock.Unlock()
+		return nil
+	}
+	c.wrappingHistoryLastTidy = now
+	c.wrappingHistoryTidyLock.Unlock()
+
+	view := c.systemBarrierView.SubView(wrappingHistorySubPath)
+	keys, err := view.List(ctx, "")
+	if err != nil {
+		return errwrap.Wrapf("failed to list wrapping histories: {{err}}", err)
+	}
+
+	for _, key := range keys {
+		if err := c.tidyWrappingHistoryEntry(ctx, view, key, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tidyWrappingHistoryEntry removes the wrapping token history stored under
+// the given salted accessor if it expired before the given time
+func (c *Core) tidyWrappingHistoryEntry(ctx context.Context, view *BarrierView, saltedAccessor string, now time.Time) error {
+	lock := locksutil.LockForKey(c.wrappingHistoryLocks, saltedAccessor)
+	lock.Lock()
+	defer lock.Unlock()
+
+	entry, err := view.Get(ctx, saltedAccessor)
+	if err != nil {
+		return errwrap.Wrapf("failed to read wrapping history: {{err}}", err)
+	}
+	if entry == nil {
+		return nil
+	}
+
+	var history wrappingHistory
+	if err := entry.DecodeJSON(&history); err != nil {
+		return err
+	}
+	if now.Before(history.ExpireTime) {
+		return nil
+	}
+
+	if err := view.Delete(ctx, saltedAccessor); err != nil {
+		return errwrap.Wrapf("failed to delete wrapping history: {{err}}", err)
+	}
+
+	return nil
+}